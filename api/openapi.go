@@ -0,0 +1,28 @@
+// Package api holds the OpenAPI description of the HTTP API
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIYAML is openapi.yaml, embedded so the server can serve its own docs
+//
+//go:embed openapi.yaml
+var OpenAPIYAML []byte
+
+// OpenAPIJSON returns the OpenAPI document converted to JSON
+func OpenAPIJSON() ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(OpenAPIYAML, &doc); err != nil {
+		return nil, fmt.Errorf("could not parse OpenAPI spec: %w", err)
+	}
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert OpenAPI spec to JSON: %w", err)
+	}
+	return spec, nil
+}
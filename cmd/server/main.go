@@ -12,13 +12,23 @@ import (
 
 	"github.com/getsentry/sentry-go"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"go.uber.org/zap"
 
 	"secure-task-api/internal/auth"
+	"secure-task-api/internal/clock"
 	"secure-task-api/internal/config"
 	"secure-task-api/internal/handlers"
+	"secure-task-api/internal/health"
+	"secure-task-api/internal/httpclient"
 	"secure-task-api/internal/logger"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/reminder"
 	"secure-task-api/internal/repository"
+	"secure-task-api/internal/stats"
+	"secure-task-api/internal/webhook"
+	"secure-task-api/pkg/utils"
 )
 
 func main() {
@@ -63,22 +73,67 @@ func main() {
 	}
 	defer db.Close()
 	log.Info("Database connection established")
+	if cfg.Metrics.Enabled {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+	}
 
 	// Setup dependencies
-	repo := repository.NewRepository(db)
-	jwtManager := auth.NewJWTManager(
-		cfg.JWT.Secret,
-		cfg.JWT.AccessTokenDuration,
-		cfg.JWT.RefreshTokenDuration,
-	)
+	repoOpts := repository.Options{}
+	if cfg.Privacy.HashEmails {
+		repoOpts.EmailHashKey = []byte(cfg.Privacy.EmailHashKey)
+	}
+	repo := repository.NewRepository(db, repoOpts)
+	jwtManager, err := initJWTManager(cfg.JWT)
+	if err != nil {
+		log.Fatal("Failed to initialize JWT manager", zap.Error(err))
+	}
+	blocklist, err := auth.LoadPasswordBlocklist(cfg.Password.BlocklistFile)
+	if err != nil {
+		log.Fatal("Failed to load password blocklist", zap.Error(err))
+	}
+
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	statsCache := stats.NewCache(repo.Task, cfg.Stats.CacheEnabled, cfg.Stats.RefreshInterval, log)
+	go statsCache.Run(jobsCtx)
+
+	reminders := reminder.NewScheduler(repo.Task, cfg.Reminder, httpclient.New(cfg.Outbound), log)
+	go reminders.Run(jobsCtx)
+
+	// Flips readiness, and with DB_REJECT_WHEN_DOWN the API, during outages
+	var dbMonitor *health.Monitor
+	if cfg.Database.HealthInterval > 0 {
+		dbMonitor = health.NewMonitor(health.Check{Name: "database", Fn: repo.Task.HealthCheck},
+			cfg.Database.HealthInterval, log)
+		go dbMonitor.Run(jobsCtx)
+	}
+
+	webhooks := webhook.NewDispatcher(cfg.Webhook, httpclient.New(cfg.Outbound), repo.WebhookDelivery, log)
+	go webhooks.Run(jobsCtx)
+
+	utils.SetJSONOptions(utils.JSONOptions{
+		MaxBodyBytes:          cfg.App.MaxBodyBytes,
+		DisallowUnknownFields: cfg.App.DisallowUnknownFields,
+		RequireContentType:    cfg.App.RequireJSONContentType,
+	})
+	utils.SetValidationErrorFormat(cfg.App.ValidationErrorFormat)
+	utils.SetPaginationOptions(utils.PaginationOptions{
+		MaxLimit: cfg.App.MaxPageSize,
+		Strict:   cfg.App.StrictPageSize,
+		MaxPage:  cfg.App.MaxPage,
+	})
 
 	// Setup router - NO external middleware wrapping
-	router := handlers.NewRouter(cfg, repo, jwtManager, log).SetupRoutes()
+	router := handlers.NewRouter(cfg, repo, jwtManager, statsCache, webhooks, dbMonitor, blocklist, log).SetupRoutes()
+	// Only counts requests, so shutdown can report what it drained
+	requests := middleware.NewRequestTracker()
 
 	// Create server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.App.Port),
-		Handler:      router, // Direct use, no StripTrailingSlash wrapper
+		Handler:      requests.Middleware(router), // Trailing slashes are handled by the router, per APP_TRAILING_SLASH
 		ReadTimeout:  cfg.App.ReadTimeout,
 		WriteTimeout: cfg.App.WriteTimeout,
 		IdleTimeout:  cfg.App.IdleTimeout,
@@ -97,15 +152,57 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Info("Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	stopJobs()
+	drainServer(server, requests, cfg.App.ShutdownTimeout, log)
+
+	log.Info("Server exited cleanly")
+}
+
+// drainServer shuts server down, waiting up to timeout for in-flight
+// requests, and logs how many were drained and any still active at the deadline
+func drainServer(server *http.Server, requests *middleware.RequestTracker, timeout time.Duration, log *logger.Logger) error {
+	servedBefore := requests.Served()
+	log.Info("Shutting down server...",
+		zap.Int64("in_flight", requests.Active()),
+		zap.Duration("timeout", timeout))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	
+
 	if err := server.Shutdown(ctx); err != nil {
-		log.Error("Server shutdown forced", zap.Error(err))
+		log.Error("Server shutdown forced",
+			zap.Error(err),
+			zap.Int64("drained", requests.Served()-servedBefore),
+			zap.Int64("still_active", requests.Active()))
+		return err
 	}
+	log.Info("In-flight requests drained", zap.Int64("drained", requests.Served()-servedBefore))
+	return nil
+}
 
-	log.Info("Server exited cleanly")
+func initJWTManager(cfg config.JWTConfig) (*auth.JWTManager, error) {
+	opts := []auth.Option{
+		auth.WithSessionMaxLifetime(cfg.SessionMaxLifetime),
+		auth.WithRevocationStore(auth.NewMemoryRevocationStore(clock.Real{})),
+		auth.WithIssuer(cfg.Issuer),
+		auth.WithAudience(cfg.Audience),
+		auth.WithLeeway(cfg.Leeway),
+	}
+
+	if cfg.Algorithm == "RS256" {
+		privateKey, err := auth.LoadRSAPrivateKey(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := auth.LoadRSAPublicKey(cfg.PublicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewJWTManagerRS256(privateKey, publicKey,
+			cfg.AccessTokenDuration, cfg.RefreshTokenDuration, opts...), nil
+	}
+
+	return auth.NewJWTManager(cfg.Secret,
+		cfg.AccessTokenDuration, cfg.RefreshTokenDuration, opts...), nil
 }
 
 func initDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/middleware"
+)
+
+// slowServer serves requests that block until release is closed, returning
+// the server, its URL and the tracker counting its requests
+func slowServer(t *testing.T, release chan struct{}) (*http.Server, string, *middleware.RequestTracker) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := middleware.NewRequestTracker()
+	server := &http.Server{Handler: requests.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("done"))
+	}))}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return server, "http://" + listener.Addr().String(), requests
+}
+
+// startRequest sends a GET in the background, waiting until the server is
+// serving it, and returns a channel with its status or error
+func startRequest(t *testing.T, url string, requests *middleware.RequestTracker) chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		done <- err
+	}()
+	for deadline := time.Now().Add(5 * time.Second); requests.Active() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("request never reached the server")
+		}
+	}
+	return done
+}
+
+// shutdownLog returns a logger writing to a file and a reader of its entries by message
+func shutdownLog(t *testing.T) (*logger.Logger, func() map[string]map[string]interface{}) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.json")
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "info", OutputPaths: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log, func() map[string]map[string]interface{} {
+		log.Sync()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := make(map[string]map[string]interface{})
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("decode %s: %v", line, err)
+			}
+			entries[entry["msg"].(string)] = entry
+		}
+		return entries
+	}
+}
+
+func TestDrainServerWaitsForInFlightRequests(t *testing.T) {
+	release := make(chan struct{})
+	server, url, requests := slowServer(t, release)
+	log, entries := shutdownLog(t)
+	done := startRequest(t, url, requests)
+
+	drained := make(chan error, 1)
+	go func() { drained <- drainServer(server, requests, 5*time.Second, log) }()
+	select {
+	case err := <-drained:
+		t.Fatalf("shutdown returned %v with a request still in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight request: %v, want it completed", err)
+	}
+
+	logged := entries()
+	if start := logged["Shutting down server..."]; start == nil || start["in_flight"] != 1.0 {
+		t.Fatalf("shutdown start logged %v, want one request in flight", start)
+	}
+	if end := logged["In-flight requests drained"]; end == nil || end["drained"] != 1.0 {
+		t.Fatalf("drain logged %v, want one request drained", end)
+	}
+}
+
+func TestDrainServerLogsRequestsLeftAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server, url, requests := slowServer(t, release)
+	log, entries := shutdownLog(t)
+	startRequest(t, url, requests)
+
+	if err := drainServer(server, requests, 50*time.Millisecond, log); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("drain: err = %v, want the deadline exceeded", err)
+	}
+	forced := entries()["Server shutdown forced"]
+	if forced == nil || forced["still_active"] != 1.0 || forced["drained"] != 0.0 {
+		t.Fatalf("forced shutdown logged %v, want one request still active", forced)
+	}
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognise
+const APIKeyPrefix = "sta_"
+
+// apiKeyDisplayLen is how much of a key is kept in the clear to identify it
+const apiKeyDisplayLen = len(APIKeyPrefix) + 8
+
+// GenerateAPIKey returns a new random API key and the start of it to show in
+// listings. Only a hash of the key should be stored.
+func GenerateAPIKey() (key, prefix string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("could not generate API key: %w", err)
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	return key, key[:apiKeyDisplayLen], nil
+}
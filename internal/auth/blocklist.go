@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PasswordBlocklist is a set of common or breached passwords, stored
+// lowercased so lookups ignore case. A nil blocklist blocks nothing.
+type PasswordBlocklist map[string]struct{}
+
+// LoadPasswordBlocklist reads one password per line from path, skipping blank
+// lines and lines starting with #. An empty path returns a nil blocklist.
+func LoadPasswordBlocklist(path string) (PasswordBlocklist, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open password blocklist: %w", err)
+	}
+	defer f.Close()
+
+	blocklist := make(PasswordBlocklist)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read password blocklist: %w", err)
+	}
+	return blocklist, nil
+}
+
+// Contains reports whether password is on the blocklist, ignoring case
+func (b PasswordBlocklist) Contains(password string) bool {
+	_, ok := b[strings.ToLower(password)]
+	return ok
+}
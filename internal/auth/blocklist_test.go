@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPasswordBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# common passwords\n\nPassword123\n  qwerty  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	blocklist, err := LoadPasswordBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"password123", "PASSWORD123", "qwerty"} {
+		if !blocklist.Contains(password) {
+			t.Errorf("%q not blocked", password)
+		}
+	}
+	for _, password := range []string{"password1234", "# common passwords", ""} {
+		if blocklist.Contains(password) {
+			t.Errorf("%q blocked", password)
+		}
+	}
+
+	policy := &PasswordPolicy{MinLength: 8, Blocklist: blocklist}
+	if failed, score, _ := policy.Check(context.Background(), "Password123", ""); len(failed) != 1 || failed[0] != RuleNotCommon || score != 0 {
+		t.Fatalf("blocklisted password failed %v with score %d, want only %s and score 0", failed, score, RuleNotCommon)
+	}
+}
+
+func TestLoadPasswordBlocklistUnconfigured(t *testing.T) {
+	blocklist, err := LoadPasswordBlocklist("")
+	if err != nil || blocklist != nil {
+		t.Fatalf("blocklist %v, err %v, want none without a file", blocklist, err)
+	}
+	if blocklist.Contains("password123") {
+		t.Fatal("nil blocklist blocked a password")
+	}
+
+	if _, err := LoadPasswordBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("missing blocklist file loaded, want an error at startup")
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"secure-task-api/internal/httpclient"
+)
+
+// DefaultHIBPURL is the Have I Been Pwned range API
+const DefaultHIBPURL = "https://api.pwnedpasswords.com/range/"
+
+// HIBPChecker looks passwords up in Have I Been Pwned using k-anonymity:
+// only the first five hex characters of the SHA-1 leave the process.
+type HIBPChecker struct {
+	client  *httpclient.Client
+	baseURL string
+}
+
+// NewHIBPChecker creates a BreachChecker querying the range API at baseURL
+func NewHIBPChecker(client *httpclient.Client, baseURL string) *HIBPChecker {
+	if baseURL == "" {
+		baseURL = DefaultHIBPURL
+	}
+	return &HIBPChecker{client: client, baseURL: baseURL}
+}
+
+// IsBreached reports whether password appears in the breach corpus
+func (c *HIBPChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides the real number of matches from observers
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("breach lookup returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && candidate == suffix && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -1,133 +1,420 @@
 package auth
 
 import (
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+
+	"secure-task-api/internal/clock"
 )
 
-// Claims holds JWT claims for the user
+// Claims holds JWT claims for the user. RegisteredClaims.ID carries the jti
+// used for revocation.
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	// Role is the user's role when the token was issued
+	Role string `json:"role,omitempty"`
+	// TokenVersion is the user's token version when the token was issued; the
+	// token is rejected once the user's version moves past it
+	TokenVersion int `json:"token_version,omitempty"`
+	// Purpose is only set on single-purpose tokens, which are never valid for API access
+	Purpose string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// RefreshClaims holds JWT claims for a refresh token
+type RefreshClaims struct {
+	// SessionStart is the time of the login that started the refresh chain.
+	// It is carried forward unchanged on every refresh.
+	SessionStart *jwt.NumericDate `json:"sst,omitempty"`
+	// FamilyID groups every token rotated from the same login, so reuse of
+	// any one of them can revoke the whole chain.
+	FamilyID string `json:"fid,omitempty"`
+	// Purpose is only set on single-purpose tokens, which are never valid for refresh
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenPair is an access and refresh token issued together
+type TokenPair struct {
+	AccessToken   string
+	RefreshToken  string
+	RefreshClaims *RefreshClaims
+}
+
+// ErrSessionExpired is returned when a refresh token belongs to a session
+// older than the configured absolute session lifetime
+var ErrSessionExpired = errors.New("session expired")
+
+// ErrTokenRevoked is returned for tokens whose jti is in the revocation store
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// ErrMalformedToken is returned for input that isn't structurally a JWT
+var ErrMalformedToken = errors.New("malformed token")
+
+// DefaultIssuer is the iss claim of tokens when WithIssuer isn't given
+const DefaultIssuer = "secure-task-api"
+
+// maxTokenLength bounds the input parsed as a token; real tokens are well under 1KB
+const maxTokenLength = 8 << 10
+
 // JWTManager manages creating and validating JWTs
 type JWTManager struct {
-	secret               string
+	signingMethod        jwt.SigningMethod
+	signKey              interface{} // nil for verify-only managers
+	verifyKey            interface{}
 	accessTokenDuration  time.Duration
 	refreshTokenDuration time.Duration
+	sessionMaxLifetime   time.Duration
+	revocations          RevocationStore
+	issuer               string
+	audience             string        // Only access tokens carry and are checked for it
+	leeway               time.Duration // Clock skew tolerated on exp, nbf and iat
+	clock                clock.Clock
+}
+
+// Option configures optional JWTManager behaviour
+type Option func(*JWTManager)
+
+// WithSessionMaxLifetime caps how long a session can be kept alive through
+// refreshes, measured from the initial login. Zero disables the cap.
+func WithSessionMaxLifetime(d time.Duration) Option {
+	return func(j *JWTManager) {
+		j.sessionMaxLifetime = d
+	}
 }
 
-// NewJWTManager initializes a JWTManager
-func NewJWTManager(secret string, accessDuration, refreshDuration time.Duration) *JWTManager {
-	return &JWTManager{
-		secret:               secret,
+// WithRevocationStore makes validation reject tokens revoked in store
+func WithRevocationStore(store RevocationStore) Option {
+	return func(j *JWTManager) {
+		j.revocations = store
+	}
+}
+
+// WithIssuer sets the iss claim of issued tokens, and rejects tokens from any other issuer
+func WithIssuer(iss string) Option {
+	return func(j *JWTManager) {
+		j.issuer = iss
+	}
+}
+
+// WithAudience sets the aud claim of access tokens, and rejects access tokens
+// not meant for aud. Empty disables the check.
+func WithAudience(aud string) Option {
+	return func(j *JWTManager) {
+		j.audience = aud
+	}
+}
+
+// WithLeeway tolerates clocks up to d apart when checking token times
+func WithLeeway(d time.Duration) Option {
+	return func(j *JWTManager) {
+		j.leeway = d
+	}
+}
+
+// WithClock makes the manager stamp and check token times against c instead
+// of the system clock
+func WithClock(c clock.Clock) Option {
+	return func(j *JWTManager) {
+		j.clock = c
+	}
+}
+
+// NewJWTManager initializes a JWTManager signing with HS256 and a shared secret
+func NewJWTManager(secret string, accessDuration, refreshDuration time.Duration, opts ...Option) *JWTManager {
+	return newJWTManager(jwt.SigningMethodHS256, []byte(secret), []byte(secret),
+		accessDuration, refreshDuration, opts...)
+}
+
+// NewJWTManagerRS256 initializes a JWTManager signing with RS256. Services that
+// only verify tokens can pass a nil private key.
+func NewJWTManagerRS256(
+	privateKey *rsa.PrivateKey,
+	publicKey *rsa.PublicKey,
+	accessDuration, refreshDuration time.Duration,
+	opts ...Option,
+) *JWTManager {
+	var signKey interface{}
+	if privateKey != nil {
+		signKey = privateKey
+	}
+	return newJWTManager(jwt.SigningMethodRS256, signKey, publicKey,
+		accessDuration, refreshDuration, opts...)
+}
+
+func newJWTManager(
+	method jwt.SigningMethod,
+	signKey, verifyKey interface{},
+	accessDuration, refreshDuration time.Duration,
+	opts ...Option,
+) *JWTManager {
+	j := &JWTManager{
+		signingMethod:        method,
+		signKey:              signKey,
+		verifyKey:            verifyKey,
 		accessTokenDuration:  accessDuration,
 		refreshTokenDuration: refreshDuration,
+		issuer:               DefaultIssuer,
+		clock:                clock.Real{},
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// LoadRSAPrivateKey reads a PEM-encoded RSA private key from path
+func LoadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read private key: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadRSAPublicKey reads a PEM-encoded RSA public key from path
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read public key: %w", err)
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key: %w", err)
+	}
+	return key, nil
+}
+
+// sign serializes and signs claims with the configured algorithm
+func (j *JWTManager) sign(claims jwt.Claims) (string, error) {
+	if j.signKey == nil {
+		return "", fmt.Errorf("no signing key configured")
 	}
+	return jwt.NewWithClaims(j.signingMethod, claims).SignedString(j.signKey)
 }
 
-// GenerateAccessToken creates a JWT access token for a user
-func (j *JWTManager) GenerateAccessToken(userID uuid.UUID, email string) (string, error) {
+// keyFunc returns the verification key, accepting only the configured
+// algorithm so a token can't pick a weaker one (e.g. HS256 signed with the
+// RSA public key, or "none")
+func (j *JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != j.signingMethod.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return j.verifyKey, nil
+}
+
+// parse verifies tokenString into claims. Input that can't be a JWT is
+// rejected before reaching the library, and a panic while parsing is
+// reported as ErrMalformedToken so junk input can never crash a request.
+func (j *JWTManager) parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (token *jwt.Token, err error) {
+	if tokenString == "" || len(tokenString) > maxTokenLength || strings.Count(tokenString, ".") != 2 {
+		return nil, ErrMalformedToken
+	}
+
+	defer func() {
+		if recover() != nil {
+			token, err = nil, ErrMalformedToken
+		}
+	}()
+	return jwt.ParseWithClaims(tokenString, claims, j.keyFunc, append(j.parserOptions(), opts...)...)
+}
+
+// parserOptions restricts parsing to the configured algorithm and issuer, and
+// requires an expiry; exp, nbf and iat are checked allowing for clock skew
+func (j *JWTManager) parserOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{
+		jwt.WithValidMethods([]string{j.signingMethod.Alg()}),
+		jwt.WithIssuer(j.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(j.leeway),
+		jwt.WithTimeFunc(j.clock.Now),
+	}
+}
+
+// GenerateAccessToken creates a JWT access token for a user with the given
+// role and token version
+func (j *JWTManager) GenerateAccessToken(userID uuid.UUID, email, role string, tokenVersion int) (string, error) {
+	now := j.clock.Now()
 	claims := Claims{
-		UserID: userID.String(),
-		Email:  email,
+		UserID:       userID.String(),
+		Email:        email,
+		Role:         role,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "secure-task-api",
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.accessTokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    j.issuer,
 		},
 	}
+	if j.audience != "" {
+		claims.Audience = jwt.ClaimStrings{j.audience}
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secret))
+	return j.sign(claims)
 }
 
-// GenerateRefreshToken creates a refresh token for a user
+// GenerateRefreshToken creates a refresh token for a user, starting a new session
 func (j *JWTManager) GenerateRefreshToken(userID uuid.UUID) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Subject:   userID.String(),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.refreshTokenDuration)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		Issuer:    "secure-task-api",
+	token, _, err := j.IssueRefreshToken(userID, uuid.New(), j.clock.Now())
+	return token, err
+}
+
+// IssueRefreshToken creates a refresh token in the given token family for a
+// session that started at sessionStart, returning its claims for persistence
+func (j *JWTManager) IssueRefreshToken(userID, familyID uuid.UUID, sessionStart time.Time) (string, *RefreshClaims, error) {
+	now := j.clock.Now()
+	claims := &RefreshClaims{
+		SessionStart: jwt.NewNumericDate(sessionStart),
+		FamilyID:     familyID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.refreshTokenDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    j.issuer,
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secret))
+	token, err := j.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
 }
 
 // ValidateToken parses and validates a JWT, returning the claims if valid
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(j.secret), nil
-	})
+	var opts []jwt.ParserOption
+	if j.audience != "" {
+		opts = append(opts, jwt.WithAudience(j.audience))
+	}
+	token, err := j.parse(tokenString, &Claims{}, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("token is not an access token")
+	}
+
+	if j.isRevoked(claims.ID) {
+		return nil, ErrTokenRevoked
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
 }
 
-// GenerateTokenPair creates both access and refresh tokens for a user
-func (j *JWTManager) GenerateTokenPair(userID uuid.UUID, email string) (accessToken, refreshToken string, err error) {
-	accessToken, err = j.GenerateAccessToken(userID, email)
+// Now returns the current time on the manager's clock, for callers that
+// stamp times checked against its tokens, such as a session's start
+func (j *JWTManager) Now() time.Time {
+	return j.clock.Now()
+}
+
+// AccessTokenTTL returns how long access tokens are valid for
+func (j *JWTManager) AccessTokenTTL() time.Duration {
+	return j.accessTokenDuration
+}
+
+// Revoke denies the token with the given jti until it expires.
+// It is a no-op without a revocation store.
+func (j *JWTManager) Revoke(jti string, expiresAt time.Time) {
+	if j.revocations == nil || jti == "" {
+		return
+	}
+	j.revocations.Revoke(jti, expiresAt)
+}
+
+// isRevoked reports whether the jti is in the revocation store
+func (j *JWTManager) isRevoked(jti string) bool {
+	return j.revocations != nil && jti != "" && j.revocations.IsRevoked(jti)
+}
+
+// GenerateTokenPair creates both access and refresh tokens for a user, starting a new session
+func (j *JWTManager) GenerateTokenPair(userID uuid.UUID, email, role string, tokenVersion int) (accessToken, refreshToken string, err error) {
+	pair, err := j.IssueTokenPair(userID, email, role, tokenVersion, uuid.New(), j.clock.Now())
 	if err != nil {
 		return "", "", err
 	}
+	return pair.AccessToken, pair.RefreshToken, nil
+}
 
-	refreshToken, err = j.GenerateRefreshToken(userID)
+// IssueTokenPair creates both tokens, the refresh token belonging to familyID
+// and to a session that started at sessionStart
+func (j *JWTManager) IssueTokenPair(userID uuid.UUID, email, role string, tokenVersion int, familyID uuid.UUID, sessionStart time.Time) (*TokenPair, error) {
+	accessToken, err := j.GenerateAccessToken(userID, email, role, tokenVersion)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return accessToken, refreshToken, nil
+	refreshToken, refreshClaims, err := j.IssueRefreshToken(userID, familyID, sessionStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:   accessToken,
+		RefreshToken:  refreshToken,
+		RefreshClaims: refreshClaims,
+	}, nil
 }
 
-// ValidateRefreshToken parses and validates a refresh token, returning the user ID if valid
-func (j *JWTManager) ValidateRefreshToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(j.secret), nil
-	})
+// ValidateRefreshToken parses and validates a refresh token, returning its claims if valid
+func (j *JWTManager) ValidateRefreshToken(tokenString string) (*RefreshClaims, error) {
+	token, err := j.parse(tokenString, &RefreshClaims{})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to parse refresh token: %w", err)
+		return nil, fmt.Errorf("failed to parse refresh token: %w", err)
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*RefreshClaims)
 	if !ok || !token.Valid {
-		return "", fmt.Errorf("invalid refresh token")
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	// Return subject (user ID)
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token missing subject")
 	}
 
-	// Verify issuer matches
-	if claims.Issuer != "secure-task-api" {
-		return "", fmt.Errorf("invalid token issuer")
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("token is not a refresh token")
 	}
 
-	// Check expiration
-	if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Time) {
-		return "", fmt.Errorf("refresh token has expired")
+	if j.isRevoked(claims.ID) {
+		return nil, ErrTokenRevoked
 	}
 
-	// Return subject (user ID)
-	if claims.Subject == "" {
-		return "", fmt.Errorf("token missing subject")
+	// Tokens issued before session tracking fall back to their own issue time
+	if claims.SessionStart == nil {
+		claims.SessionStart = claims.IssuedAt
+	}
+
+	// Enforce the absolute session lifetime regardless of rotation
+	if j.sessionMaxLifetime > 0 && claims.SessionStart != nil &&
+		j.clock.Now().After(claims.SessionStart.Add(j.sessionMaxLifetime)) {
+		return nil, ErrSessionExpired
 	}
 
-	return claims.Subject, nil
+	return claims, nil
 }
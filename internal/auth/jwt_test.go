@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/clock"
+)
+
+var testEpoch = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestAccessTokenExpiresOnClock(t *testing.T) {
+	clk := clock.NewFake(testEpoch)
+	j := NewJWTManager("test-secret", 15*time.Minute, time.Hour, WithClock(clk))
+
+	token, err := j.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := j.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("fresh token rejected: %v", err)
+	}
+	if want := testEpoch.Add(15 * time.Minute); !claims.ExpiresAt.Time.Equal(want) {
+		t.Fatalf("expires at %v, want %v", claims.ExpiresAt.Time, want)
+	}
+
+	clk.Advance(15*time.Minute - time.Second)
+	if _, err := j.ValidateToken(token); err != nil {
+		t.Fatalf("token rejected a second before expiry: %v", err)
+	}
+
+	clk.Advance(2 * time.Second)
+	if _, err := j.ValidateToken(token); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("expired token: err = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+}
+
+func TestLeewayExtendsExpiry(t *testing.T) {
+	clk := clock.NewFake(testEpoch)
+	j := NewJWTManager("test-secret", time.Minute, time.Hour, WithClock(clk), WithLeeway(30*time.Second))
+
+	token, err := j.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(time.Minute + 20*time.Second)
+	if _, err := j.ValidateToken(token); err != nil {
+		t.Fatalf("token rejected within leeway: %v", err)
+	}
+	clk.Advance(20 * time.Second)
+	if _, err := j.ValidateToken(token); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("token past leeway: err = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+}
+
+func TestRefreshTokenSessionMaxLifetime(t *testing.T) {
+	clk := clock.NewFake(testEpoch)
+	j := NewJWTManager("test-secret", time.Minute, 24*time.Hour, WithClock(clk), WithSessionMaxLifetime(36*time.Hour))
+	userID, familyID := uuid.New(), uuid.New()
+	sessionStart := j.Now()
+
+	// Rotating every 20 hours keeps each token fresh, but not the session
+	token, _, err := j.IssueRefreshToken(userID, familyID, sessionStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(20 * time.Hour)
+	claims, err := j.ValidateRefreshToken(token)
+	if err != nil {
+		t.Fatalf("refresh token rejected: %v", err)
+	}
+
+	token, _, err = j.IssueRefreshToken(userID, familyID, claims.SessionStart.Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(20 * time.Hour)
+	if _, err := j.ValidateRefreshToken(token); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("refresh past session lifetime: err = %v, want %v", err, ErrSessionExpired)
+	}
+}
+
+func TestRevocationExpiresOnClock(t *testing.T) {
+	clk := clock.NewFake(testEpoch)
+	store := NewMemoryRevocationStore(clk)
+	j := NewJWTManager("test-secret", time.Minute, time.Hour, WithClock(clk), WithRevocationStore(store))
+
+	token, err := j.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := j.ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j.Revoke(claims.ID, claims.ExpiresAt.Time)
+	if _, err := j.ValidateToken(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("revoked token: err = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	clk.Advance(time.Minute + time.Second)
+	if store.IsRevoked(claims.ID) {
+		t.Fatal("revocation kept after the token expired")
+	}
+}
+
+// rsaKeyFiles writes a new RSA key pair as PEM files, returning their paths
+func rsaKeyFiles(t *testing.T) (privatePath, publicPath string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privatePath, publicPath = filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")
+	writePEM := func(path, blockType string, der []byte) {
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePEM(privatePath, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	writePEM(publicPath, "PUBLIC KEY", publicDER)
+	return privatePath, publicPath
+}
+
+func TestRS256RoundTrip(t *testing.T) {
+	privatePath, publicPath := rsaKeyFiles(t)
+	privateKey, err := LoadRSAPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := LoadRSAPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := NewJWTManagerRS256(privateKey, publicKey, time.Minute, time.Hour)
+	userID := uuid.New()
+	token, err := signer.GenerateAccessToken(userID, "user@example.com", "admin", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A service holding only the public key verifies but can't sign
+	verifier := NewJWTManagerRS256(nil, publicKey, time.Minute, time.Hour)
+	claims, err := verifier.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("RS256 token rejected: %v", err)
+	}
+	if claims.UserID != userID.String() || claims.Role != "admin" || claims.TokenVersion != 3 {
+		t.Fatalf("claims = %+v, want the signed user, role and version", claims)
+	}
+	if _, err := verifier.GenerateAccessToken(userID, "user@example.com", "user", 0); err == nil {
+		t.Fatal("signed a token without a private key")
+	}
+
+	// Another key pair's tokens don't verify
+	otherPrivate, _ := rsaKeyFiles(t)
+	otherKey, err := LoadRSAPrivateKey(otherPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := NewJWTManagerRS256(otherKey, &otherKey.PublicKey, time.Minute, time.Hour).
+		GenerateAccessToken(userID, "user@example.com", "admin", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.ValidateToken(forged); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Fatalf("token from another key: err = %v, want %v", err, jwt.ErrTokenSignatureInvalid)
+	}
+}
+
+func TestRS256RejectsHS256SignedWithPublicKey(t *testing.T) {
+	privatePath, publicPath := rsaKeyFiles(t)
+	privateKey, err := LoadRSAPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM, err := os.ReadFile(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := NewJWTManagerRS256(privateKey, &privateKey.PublicKey, time.Minute, time.Hour)
+
+	// The classic algorithm confusion: the public key is no secret, so an
+	// HS256 token keyed with it must not pass for RS256
+	now := time.Now()
+	claims := &Claims{
+		UserID: uuid.NewString(),
+		Role:   "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    DefaultIssuer,
+			Subject:   uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+	for name, key := range map[string][]byte{
+		"PEM": publicPEM,
+		"DER": x509.MarshalPKCS1PublicKey(&privateKey.PublicKey),
+	} {
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := j.ValidateToken(forged); err == nil {
+			t.Fatalf("HS256 token keyed with the %s public key accepted", name)
+		}
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.ValidateToken(unsigned); err == nil {
+		t.Fatal(`"none" token accepted`)
+	}
+}
+
+func TestValidateTokenChecksAudience(t *testing.T) {
+	j := NewJWTManager("test-secret", time.Minute, time.Hour, WithAudience("tasks-web"))
+	token, err := j.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := j.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("token for our audience rejected: %v", err)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "tasks-web" {
+		t.Fatalf("aud = %v, want tasks-web", claims.Audience)
+	}
+
+	other := NewJWTManager("test-secret", time.Minute, time.Hour, WithAudience("tasks-mobile"))
+	token, err = other.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.ValidateToken(token); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Fatalf("wrong audience: err = %v, want %v", err, jwt.ErrTokenInvalidAudience)
+	}
+
+	token, err = NewJWTManager("test-secret", time.Minute, time.Hour).GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.ValidateToken(token); !errors.Is(err, jwt.ErrTokenRequiredClaimMissing) {
+		t.Fatalf("no audience: err = %v, want %v", err, jwt.ErrTokenRequiredClaimMissing)
+	}
+}
+
+func TestValidateTokenChecksIssuer(t *testing.T) {
+	j := NewJWTManager("test-secret", time.Minute, time.Hour, WithIssuer("tasks.example.com"))
+	other := NewJWTManager("test-secret", time.Minute, time.Hour, WithIssuer("evil.example.com"))
+
+	access, refresh, err := j.GenerateTokenPair(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.ValidateToken(access); err != nil {
+		t.Fatalf("access token from our issuer rejected: %v", err)
+	}
+	if _, err := j.ValidateRefreshToken(refresh); err != nil {
+		t.Fatalf("refresh token from our issuer rejected: %v", err)
+	}
+
+	access, refresh, err = other.GenerateTokenPair(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.ValidateToken(access); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Fatalf("access token from another issuer: err = %v, want %v", err, jwt.ErrTokenInvalidIssuer)
+	}
+	if _, err := j.ValidateRefreshToken(refresh); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Fatalf("refresh token from another issuer: err = %v, want %v", err, jwt.ErrTokenInvalidIssuer)
+	}
+}
+
+func TestLeewayToleratesIssuerClockAhead(t *testing.T) {
+	j := NewJWTManager("test-secret", time.Minute, time.Hour, WithClock(clock.NewFake(testEpoch)), WithLeeway(30*time.Second))
+
+	// Tokens from a server whose clock runs ahead have nbf and iat in our future
+	issue := func(ahead time.Duration) (string, string) {
+		t.Helper()
+		issuer := NewJWTManager("test-secret", time.Minute, time.Hour, WithClock(clock.NewFake(testEpoch.Add(ahead))))
+		access, refresh, err := issuer.GenerateTokenPair(uuid.New(), "user@example.com", "user", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return access, refresh
+	}
+
+	access, refresh := issue(20 * time.Second)
+	if _, err := j.ValidateToken(access); err != nil {
+		t.Fatalf("access token 20s ahead rejected within leeway: %v", err)
+	}
+	if _, err := j.ValidateRefreshToken(refresh); err != nil {
+		t.Fatalf("refresh token 20s ahead rejected within leeway: %v", err)
+	}
+
+	access, refresh = issue(45 * time.Second)
+	if _, err := j.ValidateToken(access); !errors.Is(err, jwt.ErrTokenNotValidYet) && !errors.Is(err, jwt.ErrTokenUsedBeforeIssued) {
+		t.Fatalf("access token 45s ahead: err = %v, want it not valid yet", err)
+	}
+	if _, err := j.ValidateRefreshToken(refresh); !errors.Is(err, jwt.ErrTokenNotValidYet) && !errors.Is(err, jwt.ErrTokenUsedBeforeIssued) {
+		t.Fatalf("refresh token 45s ahead: err = %v, want it not valid yet", err)
+	}
+}
+
+func TestAccessTokenTTL(t *testing.T) {
+	j := NewJWTManager("test-secret", 20*time.Minute, time.Hour)
+	if got := j.AccessTokenTTL(); got != 20*time.Minute {
+		t.Fatalf("AccessTokenTTL = %s, want 20m", got)
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// junkTokens returns malformed inputs: fixed edge cases, then seeded random
+// strings and mutations of valid, so failures reproduce
+func junkTokens(valid string) []string {
+	tokens := []string{
+		"",
+		".",
+		"..",
+		"...",
+		"a.b",
+		"a.b.c.d",
+		"!!!.@@@.###",
+		"eyJ.eyJ.sig",
+		"Bearer " + valid,
+		valid[:len(valid)/2],
+		valid + ".",
+		strings.Repeat("a", maxTokenLength+1),
+		strings.Repeat("a.", maxTokenLength/2) + "a",
+		"\x00\xff." + valid,
+	}
+
+	const alphabet = "abcXYZ0189-_.=+/ \x00\xff"
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		b := make([]byte, rng.Intn(80))
+		for j := range b {
+			b[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+		tokens = append(tokens, string(b))
+
+		// A segment's last character carries padding bits, so changing it can
+		// decode to the same bytes; mutations leave those alone
+		m := []byte(valid)
+		for n := 1 + rng.Intn(4); n > 0; n-- {
+			pos := rng.Intn(len(m) - 1)
+			if m[pos+1] != '.' {
+				m[pos] = alphabet[rng.Intn(len(alphabet))]
+			}
+		}
+		if string(m) != valid {
+			tokens = append(tokens, string(m))
+		}
+	}
+	return tokens
+}
+
+func TestValidateTokenRejectsMalformedInput(t *testing.T) {
+	j := NewJWTManager("test-secret", time.Minute, time.Hour)
+	access, err := j.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, _, err := j.IssueRefreshToken(uuid.New(), uuid.New(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range junkTokens(access) {
+		if claims, err := j.ValidateToken(token); err == nil {
+			t.Errorf("access token %.60q accepted with claims %+v", token, claims)
+		}
+	}
+	for _, token := range junkTokens(refresh) {
+		if claims, err := j.ValidateRefreshToken(token); err == nil {
+			t.Errorf("refresh token %.60q accepted with claims %+v", token, claims)
+		}
+	}
+}
+
+func TestValidateTokenMalformedShape(t *testing.T) {
+	j := NewJWTManager("test-secret", time.Minute, time.Hour)
+	for _, token := range []string{"", "a.b", "a.b.c.d", strings.Repeat("a", maxTokenLength+1)} {
+		if _, err := j.ValidateToken(token); !errors.Is(err, ErrMalformedToken) {
+			t.Errorf("token %.60q: err = %v, want ErrMalformedToken", token, err)
+		}
+		if _, err := j.ValidateRefreshToken(token); !errors.Is(err, ErrMalformedToken) {
+			t.Errorf("refresh token %.60q: err = %v, want ErrMalformedToken", token, err)
+		}
+	}
+}
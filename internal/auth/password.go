@@ -1,25 +1,132 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword takes a plain password and returns a bcrypt hash
-func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// Password hashing algorithms selectable in config
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrPasswordMismatch is returned when a password doesn't match its hash
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// argon2idPrefix starts every hash in the PHC format Argon2idHasher writes
+const argon2idPrefix = "$argon2id$"
+
+// Hasher hashes new passwords with one algorithm. Check accepts a hash from
+// any supported algorithm, so switching hashers keeps old hashes working.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Check(password, hashed string) error
+}
+
+// BcryptHasher hashes passwords with bcrypt at Cost
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash returns a bcrypt hash of password
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
 	if err != nil {
 		return "", fmt.Errorf("could not hash password: %w", err)
 	}
 	return string(hashed), nil
 }
 
-// CheckPassword compares a plain password with its hashed version
+// Check compares password with a hash from any supported algorithm
+func (BcryptHasher) Check(password, hashed string) error {
+	return CheckPassword(password, hashed)
+}
+
+// Argon2idHasher hashes passwords with Argon2id. Memory is in KiB.
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// argon2id salt and key sizes, as recommended by RFC 9106
+const (
+	argon2idSaltLen = 16
+	argon2idKeyLen  = 32
+)
+
+// Hash returns an Argon2id hash of password in PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("could not hash password: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2idKeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Check compares password with a hash from any supported algorithm
+func (Argon2idHasher) Check(password, hashed string) error {
+	return CheckPassword(password, hashed)
+}
+
+// CheckPassword compares a plain password with its hashed version, telling
+// bcrypt and Argon2id hashes apart by their prefix
 func CheckPassword(password, hashed string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
+	if strings.HasPrefix(hashed, argon2idPrefix) {
+		return checkArgon2id(password, hashed)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)); err != nil {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// checkArgon2id verifies password against a PHC-format Argon2id hash, using
+// the parameters recorded in it
+func checkArgon2id(password, hashed string) error {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return ErrPasswordMismatch
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return ErrPasswordMismatch
+	}
+	var memory, iterations uint32
+	var parallelism uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil ||
+		iterations == 0 || parallelism == 0 {
+		return ErrPasswordMismatch
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return fmt.Errorf("password does not match")
+		return ErrPasswordMismatch
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return ErrPasswordMismatch
+	}
+
+	got := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrPasswordMismatch
 	}
 	return nil
 }
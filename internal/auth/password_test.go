@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id is cheap enough for tests while exercising the real algorithm
+var testArgon2id = Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestHashersRoundTrip(t *testing.T) {
+	for name, hasher := range map[string]Hasher{
+		AlgorithmBcrypt:   BcryptHasher{Cost: bcrypt.MinCost},
+		AlgorithmArgon2id: testArgon2id,
+	} {
+		hashed, err := hasher.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := hasher.Check("correct horse", hashed); err != nil {
+			t.Errorf("%s: password rejected: %v", name, err)
+		}
+		if err := hasher.Check("wrong horse", hashed); !errors.Is(err, ErrPasswordMismatch) {
+			t.Errorf("%s: wrong password: err = %v, want %v", name, err, ErrPasswordMismatch)
+		}
+	}
+}
+
+func TestArgon2idHashRecordsParameters(t *testing.T) {
+	hashed, err := testArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("hash %q does not record its parameters", hashed)
+	}
+
+	// Hashes stay verifiable after the configured parameters change
+	stronger := Argon2idHasher{Memory: 128, Iterations: 2, Parallelism: 1}
+	if err := stronger.Check("correct horse", hashed); err != nil {
+		t.Fatalf("hash rejected after changing parameters: %v", err)
+	}
+}
+
+func TestBcryptHasherUsesCost(t *testing.T) {
+	for _, cost := range []int{bcrypt.MinCost, bcrypt.MinCost + 1} {
+		hashed, err := BcryptHasher{Cost: cost}.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := bcrypt.Cost([]byte(hashed)); err != nil || got != cost {
+			t.Fatalf("cost %d, want %d (%v)", got, cost, err)
+		}
+	}
+}
+
+func TestCheckPasswordAcrossAlgorithms(t *testing.T) {
+	bcryptHash, err := BcryptHasher{Cost: bcrypt.MinCost}.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := testArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Switching the default algorithm must keep existing hashes working
+	if err := testArgon2id.Check("correct horse", bcryptHash); err != nil {
+		t.Errorf("argon2id hasher rejected a bcrypt hash: %v", err)
+	}
+	if err := (BcryptHasher{Cost: bcrypt.MinCost}).Check("correct horse", argonHash); err != nil {
+		t.Errorf("bcrypt hasher rejected an argon2id hash: %v", err)
+	}
+	for _, malformed := range []string{"$argon2id$v=19$m=64,t=1,p=1$", "$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5", "plain"} {
+		if err := CheckPassword("correct horse", malformed); !errors.Is(err, ErrPasswordMismatch) {
+			t.Errorf("hash %q: err = %v, want %v", malformed, err, ErrPasswordMismatch)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password rules reported by PasswordPolicy.Check
+const (
+	RuleMinLength = "min_length"
+	RuleUpper     = "uppercase"
+	RuleLower     = "lowercase"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleBreached  = "not_breached"
+	RuleNotEmail  = "not_email"
+	RuleNotCommon = "not_common"
+)
+
+// BreachChecker reports whether a password appears in known data breaches
+type BreachChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+// PasswordPolicy is the set of rules new passwords must satisfy
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	RejectEmail   bool              // Refuse the local part of the user's email as the password
+	Blocklist     PasswordBlocklist // Common passwords refused outright; nil skips the check
+	Breaches      BreachChecker     // nil skips the breach check
+}
+
+// Check returns the rules password fails for the user with email, which may
+// be empty if unknown, and a 0-4 strength score. A breach lookup error is
+// returned alongside the other results so callers can decide whether to fail open.
+func (p *PasswordPolicy) Check(ctx context.Context, password, email string) (failed []string, score int, err error) {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		failed = append(failed, RuleMinLength)
+	}
+	if p.RequireUpper && !upper {
+		failed = append(failed, RuleUpper)
+	}
+	if p.RequireLower && !lower {
+		failed = append(failed, RuleLower)
+	}
+	if p.RequireDigit && !digit {
+		failed = append(failed, RuleDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		failed = append(failed, RuleSymbol)
+	}
+	emailMatch := p.RejectEmail && isEmailLocalPart(password, email)
+	if emailMatch {
+		failed = append(failed, RuleNotEmail)
+	}
+	blocked := p.Blocklist.Contains(password)
+	if blocked {
+		failed = append(failed, RuleNotCommon)
+	}
+
+	breached := false
+	if p.Breaches != nil {
+		breached, err = p.Breaches.IsBreached(ctx, password)
+		if breached {
+			failed = append(failed, RuleBreached)
+		}
+	}
+
+	// A password that breaks policy is never more than weak, whatever its length
+	score = strengthScore(length, upper, lower, digit, symbol)
+	switch {
+	case breached, emailMatch, blocked:
+		score = 0
+	case len(failed) > 0 && score > 1:
+		score = 1
+	}
+	return failed, score, err
+}
+
+// isEmailLocalPart reports whether password is the part of email before the
+// @, ignoring case
+func isEmailLocalPart(password, email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(password), email[:at])
+}
+
+// strengthScore rates length and character variety from 0 (very weak) to 4 (strong)
+func strengthScore(length int, classes ...bool) int {
+	variety := 0
+	for _, c := range classes {
+		if c {
+			variety++
+		}
+	}
+
+	score := 0
+	switch {
+	case length >= 16:
+		score = 3
+	case length >= 12:
+		score = 2
+	case length >= 8:
+		score = 1
+	}
+	if variety >= 3 {
+		score++
+	}
+	if score > 4 {
+		score = 4
+	}
+	return score
+}
+
+// StrengthLabel names a strength score for display
+func StrengthLabel(score int) string {
+	switch {
+	case score >= 4:
+		return "strong"
+	case score == 3:
+		return "good"
+	case score == 2:
+		return "fair"
+	default:
+		return "weak"
+	}
+}
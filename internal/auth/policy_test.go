@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPasswordPolicyRules(t *testing.T) {
+	policy := &PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		RejectEmail:   true,
+	}
+
+	for _, tc := range []struct {
+		password string
+		want     []string
+	}{
+		{"Sh0rt!pw", []string{RuleMinLength}},
+		{"no-upper-case-1", []string{RuleUpper}},
+		{"NO-LOWER-CASE-1", []string{RuleLower}},
+		{"No-Digits-Here", []string{RuleDigit}},
+		{"NoSymbols12345", []string{RuleSymbol}},
+		{"Jane.Doe-1990", []string{RuleNotEmail}},
+		{"nothing", []string{RuleMinLength, RuleUpper, RuleDigit, RuleSymbol}},
+		{"Correct-Horse-42", nil},
+	} {
+		failed, _, err := policy.Check(context.Background(), tc.password, "jane.doe-1990@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(failed, tc.want) {
+			t.Errorf("%q failed %v, want %v", tc.password, failed, tc.want)
+		}
+	}
+}
+
+func TestPasswordPolicyRelaxed(t *testing.T) {
+	// Dev and tests can turn every rule but length off
+	policy := &PasswordPolicy{MinLength: 4}
+	if failed, _, _ := policy.Check(context.Background(), "jane", "jane@example.com"); len(failed) != 0 {
+		t.Fatalf("relaxed policy failed %v", failed)
+	}
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Purposes for single-use tokens sent to the user out of band
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeCalendarFeed      = "calendar_feed"
+	PurposeTwoFactor         = "two_factor" // Password checked, TOTP code still due
+)
+
+// PurposeClaims holds JWT claims for a single-purpose token such as a
+// password reset link. The purpose claim keeps it from being accepted as an
+// access or refresh token.
+type PurposeClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// GeneratePurposeToken creates a token for userID usable only for purpose, valid for ttl
+func (j *JWTManager) GeneratePurposeToken(userID uuid.UUID, purpose string, ttl time.Duration) (string, *PurposeClaims, error) {
+	now := j.clock.Now()
+	claims := &PurposeClaims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    j.issuer,
+		},
+	}
+
+	token, err := j.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// ValidatePurposeToken parses a single-purpose token, rejecting it unless it
+// was issued for purpose. Single use is enforced by the caller's token store.
+func (j *JWTManager) ValidatePurposeToken(tokenString, purpose string) (*PurposeClaims, error) {
+	token, err := j.parse(tokenString, &PurposeClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*PurposeClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("token purpose mismatch")
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token missing subject")
+	}
+
+	return claims, nil
+}
+
+// HashToken returns the hex SHA-256 of a token, for storing issued tokens
+// without keeping anything that could be replayed
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
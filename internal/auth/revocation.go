@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"secure-task-api/internal/clock"
+)
+
+// RevocationStore records revoked token IDs (jti) until the tokens expire
+type RevocationStore interface {
+	// Revoke denies the token with the given jti until expiresAt
+	Revoke(jti string, expiresAt time.Time)
+	// IsRevoked reports whether the jti has been revoked
+	IsRevoked(jti string) bool
+}
+
+// MemoryRevocationStore is an in-process RevocationStore. Entries are evicted
+// once the token they deny has expired, since it would be rejected anyway.
+//
+// Revocations are neither shared nor persisted: with more than one instance,
+// a token logged out on one is still accepted by the others, and a restart
+// forgets every entry. It suits single-instance deployments only; logout-all
+// bumps the token version in the database and holds across instances.
+type MemoryRevocationStore struct {
+	mu        sync.Mutex
+	revoked   map[string]time.Time
+	lastSweep time.Time
+	clock     clock.Clock
+}
+
+// NewMemoryRevocationStore creates an empty in-memory denylist that expires
+// entries by clk
+func NewMemoryRevocationStore(clk clock.Clock) *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time), clock: clk}
+}
+
+func (s *MemoryRevocationStore) Revoke(jti string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(s.clock.Now())
+	s.revoked[jti] = expiresAt
+}
+
+func (s *MemoryRevocationStore) IsRevoked(jti string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.revoked[jti]
+	if !ok {
+		return false
+	}
+	if s.clock.Now().After(expiresAt) {
+		delete(s.revoked, jti)
+		return false
+	}
+	return true
+}
+
+// sweep evicts expired entries at most once a minute; callers hold the lock
+func (s *MemoryRevocationStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for jti, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+}
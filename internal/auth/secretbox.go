@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrSecretCorrupt is returned for ciphertext that wasn't sealed with this key
+var ErrSecretCorrupt = errors.New("secret could not be decrypted")
+
+// SecretBox encrypts small secrets, such as TOTP secrets, for storage with
+// AES-256-GCM
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a SecretBox keyed by the SHA-256 of key. Changing key
+// makes every sealed secret unreadable.
+func NewSecretBox(key string) *SecretBox {
+	sum := sha256.Sum256([]byte(key))
+	// Neither call can fail for a 32-byte AES key
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &SecretBox{aead: aead}
+}
+
+// Seal encrypts plaintext, returning base64 of the nonce and ciphertext
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("could not encrypt secret: %w", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal
+func (b *SecretBox) Open(sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", ErrSecretCorrupt
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrSecretCorrupt
+	}
+	return string(plaintext), nil
+}
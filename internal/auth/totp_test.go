@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC's eight-digit codes, truncated to our six
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPCounter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTPAllowsOneStepOfDrift(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret := rfcSecret
+
+	for _, offset := range []time.Duration{-30 * time.Second, 0, 30 * time.Second} {
+		code, _ := TOTPCode(secret, TOTPCounter(now.Add(offset)))
+		counter, ok := VerifyTOTP(secret, code, now)
+		if !ok || counter != TOTPCounter(now.Add(offset)) {
+			t.Errorf("code from %v away: ok = %v, counter = %d", offset, ok, counter)
+		}
+	}
+	for _, offset := range []time.Duration{-90 * time.Second, 90 * time.Second} {
+		code, _ := TOTPCode(secret, TOTPCounter(now.Add(offset)))
+		if _, ok := VerifyTOTP(secret, code, now); ok {
+			t.Errorf("code from %v away accepted", offset)
+		}
+	}
+	if _, ok := VerifyTOTP(secret, "12345", now); ok {
+		t.Error("five-digit code accepted")
+	}
+}
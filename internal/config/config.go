@@ -2,19 +2,37 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+	"go.uber.org/zap/zapcore"
 )
 
 type Config struct {
-	App      AppConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Sentry   SentryConfig
-	Logging  LoggingConfig
+	App         AppConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Sentry      SentryConfig
+	Logging     LoggingConfig
+	Task        TaskConfig
+	Stats       StatsConfig
+	Reminder    ReminderConfig
+	Webhook     WebhookConfig
+	Metrics     MetricsConfig
+	Idempotency IdempotencyConfig
+	Privacy     PrivacyConfig
+	TwoFactor   TwoFactorConfig
+	Compression CompressionConfig
+	Outbound    OutboundConfig
+	Account     AccountConfig
+	Mail        MailConfig
+	Password    PasswordConfig
+	RateLimit   RateLimitConfig
+	CORS        CORSConfig
 }
 
 type AppConfig struct {
@@ -25,6 +43,57 @@ type AppConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+
+	// ShutdownTimeout bounds how long shutdown waits for in-flight requests
+	ShutdownTimeout time.Duration
+
+	// RequestTimeout is the deadline on each request's context; requests
+	// still unanswered when it passes get 503. 0 disables it.
+	RequestTimeout time.Duration
+
+	// BasePath mounts every route under a prefix (e.g. /api/tasks) for
+	// path-based reverse proxies. Empty means the root.
+	BasePath string
+
+	// RequireContentLength makes large-payload endpoints (bulk, import)
+	// reject requests without a declared Content-Length
+	RequireContentLength bool
+
+	// MaxBodyBytes caps JSON request bodies; larger ones get 413
+	MaxBodyBytes int64
+
+	// MaxPageSize caps the limit of paginated lists. Larger limits are
+	// capped, or get 400 with StrictPageSize. Pages past MaxPage are capped.
+	MaxPageSize    int
+	StrictPageSize bool
+	MaxPage        int
+
+	// DisallowUnknownFields rejects JSON bodies with fields the endpoint doesn't accept
+	DisallowUnknownFields bool
+
+	// RequireJSONContentType answers JSON bodies not sent as application/json
+	// with 415
+	RequireJSONContentType bool
+
+	// ValidationErrorFormat shapes validation error responses: "map" of
+	// field to first message, or "array" of every {field, message}
+	ValidationErrorFormat string
+
+	// TrailingSlash handles paths like /tasks/: "off" routes them as is,
+	// "strip" serves them as /tasks, "redirect" redirects to /tasks
+	TrailingSlash string
+
+	// Docs serves the OpenAPI spec at /openapi.json and Swagger UI at /docs
+	Docs bool
+}
+
+// normalizeBasePath turns "api/", "/api" and "/api/" into "/api", and "/" into ""
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
 }
 
 type DatabaseConfig struct {
@@ -38,6 +107,13 @@ type DatabaseConfig struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	DSN             string // Full connection string override
+
+	// HealthInterval is how often a background check pings the database to
+	// decide readiness; zero turns the monitor off
+	HealthInterval time.Duration
+	// RejectWhenDown answers API requests with 503 while the monitor finds
+	// the database unreachable, instead of letting each fail on its own
+	RejectWhenDown bool
 }
 
 func (d DatabaseConfig) GetDSN() string {
@@ -57,10 +133,402 @@ func (d DatabaseConfig) GetDSN() string {
 	)
 }
 
+// validSSLModes are the sslmode values accepted by Postgres
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// effectiveSSLMode returns the sslmode in use, reading it from the DSN when one is set
+func (d DatabaseConfig) effectiveSSLMode() string {
+	if d.DSN == "" {
+		return d.SSLMode
+	}
+	if u, err := url.Parse(d.DSN); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		return u.Query().Get("sslmode")
+	}
+	for _, kv := range strings.Fields(d.DSN) {
+		if value, ok := strings.CutPrefix(kv, "sslmode="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// validateSSLMode rejects unknown sslmode values, and disabled TLS in production
+func (d DatabaseConfig) validateSSLMode(environment string) error {
+	mode := d.effectiveSSLMode()
+	if mode == "" {
+		// Left to the driver default
+		return nil
+	}
+	if !validSSLModes[mode] {
+		return fmt.Errorf("invalid database sslmode %q: use disable, allow, prefer, require, verify-ca or verify-full", mode)
+	}
+	if mode == "disable" && environment == "production" {
+		return fmt.Errorf("database sslmode=disable is not allowed in production")
+	}
+	return nil
+}
+
 type JWTConfig struct {
+	Algorithm            string // HS256 (shared secret) or RS256 (key pair)
 	Secret               string
+	PrivateKeyPath       string // RS256 signing key, PEM
+	PublicKeyPath        string // RS256 verification key, PEM
 	AccessTokenDuration  time.Duration
 	RefreshTokenDuration time.Duration
+	SessionMaxLifetime   time.Duration // Absolute cap on a refresh chain, 0 disables
+	Issuer               string        // iss claim set and required on every token
+	Audience             string        // aud claim set and required on access tokens, empty disables
+	Leeway               time.Duration // Clock skew tolerated on token exp, nbf and iat checks
+
+	// Startup bounds for the token durations above
+	AccessTokenMinDuration  time.Duration
+	AccessTokenMaxDuration  time.Duration
+	RefreshTokenMaxDuration time.Duration
+}
+
+// validateDurations rejects token durations outside the configured bounds
+func (j JWTConfig) validateDurations() error {
+	if j.AccessTokenDuration < j.AccessTokenMinDuration || j.AccessTokenDuration > j.AccessTokenMaxDuration {
+		return fmt.Errorf("JWT_ACCESS_DURATION %s must be between %s and %s",
+			j.AccessTokenDuration, j.AccessTokenMinDuration, j.AccessTokenMaxDuration)
+	}
+	if j.RefreshTokenDuration <= j.AccessTokenDuration {
+		return fmt.Errorf("JWT_REFRESH_DURATION %s must be longer than JWT_ACCESS_DURATION %s",
+			j.RefreshTokenDuration, j.AccessTokenDuration)
+	}
+	if j.RefreshTokenDuration > j.RefreshTokenMaxDuration {
+		return fmt.Errorf("JWT_REFRESH_DURATION %s must not exceed %s",
+			j.RefreshTokenDuration, j.RefreshTokenMaxDuration)
+	}
+	return nil
+}
+
+type TaskConfig struct {
+	UniqueTitles bool // Reject titles matching another task case-insensitively
+	MaxPerUser   int  // Quota on live tasks per user, 0 means unlimited
+
+	// GroupByStatus is the default for the list group_by_status param
+	GroupByStatus bool
+
+	// CalendarFeedTTL is how long a calendar subscription URL stays valid
+	CalendarFeedTTL time.Duration
+
+	// ExportBatchSize is how many tasks exports read from the DB and flush
+	// to the client at a time
+	ExportBatchSize int
+
+	// MaxDescriptionLength caps descriptions in runes. The DB enforces a
+	// hard ceiling of 65535 characters regardless.
+	MaxDescriptionLength int
+
+	// MaxTags caps how many tags a task may have
+	MaxTags int
+
+	// AllowReopen lets completed tasks move back to pending or in_progress
+	AllowReopen bool
+}
+
+type AccountConfig struct {
+	PasswordResetTTL time.Duration // Lifetime of a password reset token
+	PasswordResetURL string        // Frontend page the reset token is appended to as ?token=
+
+	RequireEmailVerification bool          // Refuse login until the email address is confirmed
+	EmailVerificationTTL     time.Duration // Lifetime of an email verification token
+	EmailVerificationURL     string        // Frontend page the verification token is appended to as ?token=
+
+	LockoutThreshold int           // Failed logins within LockoutWindow that lock the account, 0 disables
+	LockoutWindow    time.Duration // Failures further apart than this start a new count
+	LockoutDuration  time.Duration // How long a locked account refuses logins
+}
+
+// MAIL_SENDER values
+const (
+	MailSenderSMTP = "smtp"
+	MailSenderLog  = "log"
+)
+
+// MailConfig chooses how password reset and verification emails are sent
+type MailConfig struct {
+	Sender       string // smtp, or log to only log each message's recipient and subject
+	From         string // Envelope sender and From header
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string // Empty skips SMTP authentication
+	SMTPPassword string
+}
+
+// validate rejects an unknown sender, the log sender outside development,
+// where it would silently drop every reset and verification link, and SMTP
+// without a server or sender address
+func (m MailConfig) validate(environment string) error {
+	switch m.Sender {
+	case MailSenderLog:
+		if environment != "development" {
+			return fmt.Errorf("MAIL_SENDER=log delivers no email and is only allowed in development")
+		}
+	case MailSenderSMTP:
+		if m.SMTPHost == "" || m.From == "" {
+			return fmt.Errorf("SMTP_HOST and MAIL_FROM are required when MAIL_SENDER is smtp")
+		}
+		if m.SMTPPort < 1 || m.SMTPPort > 65535 {
+			return fmt.Errorf("SMTP_PORT %d must be between 1 and 65535", m.SMTPPort)
+		}
+	default:
+		return fmt.Errorf("invalid MAIL_SENDER %q: must be smtp or log", m.Sender)
+	}
+	return nil
+}
+
+// PasswordConfig is the policy new passwords must satisfy
+type PasswordConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	RejectEmail   bool   // Reject the local part of the user's email
+	BlocklistFile string // Common passwords to reject, one per line; empty disables
+	CheckBreached bool   // Reject passwords found in Have I Been Pwned
+	BreachAPIURL  string // Range API base, defaults to the public HIBP endpoint
+
+	HashAlgorithm     string // Hashes new passwords: bcrypt or argon2id; both verify
+	BcryptCost        int
+	Argon2Memory      int // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+}
+
+// validateHashing rejects an unknown algorithm or out-of-range parameters for the chosen one
+func (p PasswordConfig) validateHashing() error {
+	switch p.HashAlgorithm {
+	case "bcrypt":
+		if p.BcryptCost < 10 || p.BcryptCost > 16 {
+			return fmt.Errorf("BCRYPT_COST must be between 10 and 16")
+		}
+	case "argon2id":
+		if p.Argon2Memory < 8*1024 || p.Argon2Memory > 1024*1024 {
+			return fmt.Errorf("ARGON2_MEMORY_KIB must be between 8192 and 1048576")
+		}
+		if p.Argon2Iterations < 1 || p.Argon2Iterations > 20 {
+			return fmt.Errorf("ARGON2_ITERATIONS must be between 1 and 20")
+		}
+		if p.Argon2Parallelism < 1 || p.Argon2Parallelism > 64 {
+			return fmt.Errorf("ARGON2_PARALLELISM must be between 1 and 64")
+		}
+	default:
+		return fmt.Errorf("PASSWORD_HASH_ALGORITHM %q is not supported, use bcrypt or argon2id", p.HashAlgorithm)
+	}
+	return nil
+}
+
+type RateLimitConfig struct {
+	Window           time.Duration // Length of each counting window
+	CheckPassword    int           // Requests per window per key to /auth/check-password
+	CheckPasswordKey []string      // What check-password requests are counted against
+	Bulk             int           // Requests per window per key to bulk task endpoints, 0 disables
+	BulkKey          []string      // What bulk requests are counted against
+}
+
+// RateLimitKeys are the parts a rate limit key can combine: the client IP,
+// the authenticated user, and the matched route
+var RateLimitKeys = []string{"ip", "user", "route"}
+
+// validateRateLimitKey returns an error naming the valid parts if key is
+// empty or has a part that isn't one of RateLimitKeys
+func validateRateLimitKey(name string, key []string) error {
+	if len(key) == 0 {
+		return fmt.Errorf("%s must not be empty", name)
+	}
+next:
+	for _, part := range key {
+		for _, k := range RateLimitKeys {
+			if part == k {
+				continue next
+			}
+		}
+		return fmt.Errorf("invalid %s part %q: must be one of %s", name, part, strings.Join(RateLimitKeys, ", "))
+	}
+	return nil
+}
+
+// CORSConfig controls cross-origin access from browser frontends. CORS is
+// off while AllowedOrigins is empty.
+type CORSConfig struct {
+	AllowedOrigins   []string // Exact origins such as https://app.example.com, or "*"
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool          // Let browsers send cookies and Authorization
+	MaxAge           time.Duration // How long browsers may cache a preflight
+}
+
+// Enabled reports whether any origin is allowed
+func (c CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// parseList splits a comma-separated value, dropping blanks
+func parseList(val string) []string {
+	var items []string
+	for _, part := range strings.Split(val, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
+
+type MetricsConfig struct {
+	Enabled bool // Record request metrics and serve them at /metrics
+}
+
+type StatsConfig struct {
+	CacheEnabled    bool          // Serve task stats from a background-refreshed cache
+	RefreshInterval time.Duration // How often cached stats are recomputed
+}
+
+type ReminderConfig struct {
+	Enabled    bool          // Send reminders for tasks coming due
+	Interval   time.Duration // How often to look for tasks coming due
+	Window     time.Duration // How long before its due date a task is reminded
+	WebhookURL string        // Also POST each reminder here as JSON; empty only logs them
+}
+
+// validate checks the scheduler settings when reminders are enabled
+func (r ReminderConfig) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	if r.Interval <= 0 || r.Window <= 0 {
+		return fmt.Errorf("REMINDER_INTERVAL and REMINDER_WINDOW must be positive")
+	}
+	if r.WebhookURL != "" {
+		u, err := url.Parse(r.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("REMINDER_WEBHOOK_URL must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// WebhookConfig controls outbound webhooks for task lifecycle events
+type WebhookConfig struct {
+	URL       string // POST task events here as signed JSON; empty turns webhooks off
+	Secret    string // HMAC-SHA256 key for the X-Webhook-Signature header
+	QueueSize int    // Events buffered for delivery before new ones are dropped
+
+	DeadLetterRetention time.Duration // How long deliveries that failed every retry are kept for replay
+}
+
+// validate checks the webhook settings when a URL is set
+func (w WebhookConfig) validate() error {
+	if w.DeadLetterRetention <= 0 {
+		return fmt.Errorf("TASK_WEBHOOK_DEAD_LETTER_RETENTION must be positive")
+	}
+	if w.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("TASK_WEBHOOK_URL must be an http or https URL")
+	}
+	if w.Secret == "" {
+		return fmt.Errorf("TASK_WEBHOOK_SECRET is required when TASK_WEBHOOK_URL is set")
+	}
+	if w.QueueSize < 1 {
+		return fmt.Errorf("TASK_WEBHOOK_QUEUE_SIZE must be at least 1")
+	}
+	return nil
+}
+
+type IdempotencyConfig struct {
+	TTL time.Duration // Dedup window for repeated Idempotency-Key requests
+}
+
+type TwoFactorConfig struct {
+	EncryptionKey string        // Encrypts stored TOTP secrets; empty disables 2FA setup
+	Issuer        string        // Account issuer shown in authenticator apps
+	ChallengeTTL  time.Duration // Time allowed between the password and the code
+}
+
+func (t TwoFactorConfig) validate() error {
+	if t.EncryptionKey != "" && len(t.EncryptionKey) < 32 {
+		return fmt.Errorf("TOTP_ENCRYPTION_KEY must be at least 32 bytes")
+	}
+	if t.ChallengeTTL < time.Minute || t.ChallengeTTL > 15*time.Minute {
+		return fmt.Errorf("TWO_FACTOR_CHALLENGE_TTL must be between 1m and 15m")
+	}
+	return nil
+}
+
+// Enabled reports whether users can set up two-factor authentication
+func (t TwoFactorConfig) Enabled() bool {
+	return t.EncryptionKey != ""
+}
+
+type PrivacyConfig struct {
+	HashEmails   bool   // Store and look up users by a keyed email hash
+	EmailHashKey string // HMAC key for email hashes; changing it breaks lookups
+}
+
+type CompressionConfig struct {
+	Enabled    bool
+	Algorithms []string // Server preference order, used to break client q-value ties
+	Level      int      // compress/flate level: -1 default, 1 fastest to 9 best
+}
+
+// validate checks the algorithms are ones this build can produce and the level is in range
+func (c CompressionConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	for i, alg := range c.Algorithms {
+		alg = strings.TrimSpace(alg)
+		c.Algorithms[i] = alg
+		switch alg {
+		case "gzip", "deflate":
+		case "br":
+			return fmt.Errorf("COMPRESSION_ALGORITHMS: br is not supported by this build, use gzip or deflate")
+		default:
+			return fmt.Errorf("COMPRESSION_ALGORITHMS: unknown algorithm %q, use gzip or deflate", alg)
+		}
+	}
+	if c.Level < -1 || c.Level > 9 {
+		return fmt.Errorf("COMPRESSION_LEVEL %d must be between -1 and 9", c.Level)
+	}
+	return nil
+}
+
+// OutboundConfig controls retries for outbound HTTP calls to integrations
+type OutboundConfig struct {
+	MaxAttempts       int           // Total tries per request, including the first
+	AttemptTimeout    time.Duration // Deadline for each try
+	BackoffBase       time.Duration // Backoff before the first retry, doubled each retry
+	BackoffMax        time.Duration // Upper bound on a single backoff
+	RetryableStatuses []int         // Response codes worth retrying
+}
+
+// parseStatusCodes parses a comma-separated list of HTTP status codes
+func parseStatusCodes(val string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(val, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
 }
 
 type SentryConfig struct {
@@ -69,11 +537,74 @@ type SentryConfig struct {
 	SampleRate  float64
 }
 
+// LogEncodings are the supported LOG_ENCODING values
+var LogEncodings = []string{"json", "console", "logfmt"}
+
+// ValidateLogEncoding returns an error naming the valid options if
+// encoding isn't one of LogEncodings
+func ValidateLogEncoding(encoding string) error {
+	for _, e := range LogEncodings {
+		if encoding == e {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid LOG_ENCODING %q: must be one of %s", encoding, strings.Join(LogEncodings, ", "))
+}
+
 type LoggingConfig struct {
-	Level            string
+	Level            string // Severity: debug, info, warn, error
+	Development      bool   // Human-friendly zap development preset
 	Encoding         string
 	OutputPaths      []string
 	ErrorOutputPaths []string
+
+	// ValidationFailures logs failing field names per request: off, debug or info
+	ValidationFailures string
+
+	// Rotation applies to output paths that are files; stdout and stderr are untouched
+	Rotation LogRotationConfig
+
+	// Stacktrace attaches stack traces to entries at StacktraceLevel and
+	// above. Sentry captures its own stack traces either way.
+	Stacktrace      bool
+	StacktraceLevel string
+
+	// Sampling caps repeated entries to keep volume down under load
+	Sampling LogSamplingConfig
+
+	// RequestHandler adds the route pattern and the handler function and
+	// source location to request logs
+	RequestHandler bool
+
+	// SlowRequestThreshold logs requests taking at least this long at warn
+	// with slow=true, 0 disables it. SlowRequestsOnly then drops request logs
+	// for fast successful requests.
+	SlowRequestThreshold time.Duration
+	SlowRequestsOnly     bool
+
+	// RequestHeaders adds the request headers to request logs, with those
+	// named in RedactKeys masked
+	RequestHeaders bool
+
+	// RedactKeys are the field and header names whose values are masked
+	// wherever request data is logged, empty for the logger's defaults
+	RedactKeys []string
+}
+
+// LogSamplingConfig bounds how many identical entries are logged. Each
+// second, the first Initial entries with the same level and message are
+// logged, then only every Thereafter-th one.
+type LogSamplingConfig struct {
+	Enabled    bool
+	Initial    int
+	Thereafter int
+}
+
+type LogRotationConfig struct {
+	MaxSizeMB  int  // Rotate once a file reaches this size, 0 disables rotation
+	MaxAgeDays int  // Delete rotated files older than this, 0 keeps them regardless of age
+	MaxBackups int  // Rotated files to keep, 0 keeps all
+	Compress   bool // Gzip rotated files
 }
 
 func LoadConfig() (*Config, error) {
@@ -85,11 +616,32 @@ func LoadConfig() (*Config, error) {
 
 	// Set defaults
 	v.SetDefault("APP_PORT", "8080")
+	v.SetDefault("APP_MAX_BODY_BYTES", "1048576")
+	v.SetDefault("APP_MAX_PAGE_SIZE", "100")
+	v.SetDefault("APP_MAX_PAGE", "10000")
 	v.SetDefault("APP_ENVIRONMENT", "development")
 	v.SetDefault("DB_PORT", "5432")
 	v.SetDefault("DB_SSLMODE", "require") // Render requires SSL
+	v.SetDefault("SMTP_PORT", "587")
 	v.SetDefault("LOG_LEVEL", "info")
 	v.SetDefault("LOG_ENCODING", "json")
+	v.SetDefault("TASK_MAX_DESCRIPTION_LENGTH", "10000")
+	v.SetDefault("TASK_EXPORT_BATCH_SIZE", "500")
+	v.SetDefault("TASK_MAX_TAGS", "10")
+	v.SetDefault("OUTBOUND_MAX_ATTEMPTS", "3")
+	v.SetDefault("TASK_WEBHOOK_QUEUE_SIZE", "100")
+	v.SetDefault("PASSWORD_MIN_LENGTH", "8")
+	v.SetDefault("BCRYPT_COST", "12")
+	v.SetDefault("ARGON2_MEMORY_KIB", "65536")
+	v.SetDefault("ARGON2_ITERATIONS", "3")
+	v.SetDefault("ARGON2_PARALLELISM", "2")
+	v.SetDefault("RATE_LIMIT_CHECK_PASSWORD", "20")
+	v.SetDefault("LOGIN_LOCKOUT_THRESHOLD", "5")
+	v.SetDefault("LOG_SAMPLING_INITIAL", "100")
+	v.SetDefault("LOG_SAMPLING_THEREAFTER", "100")
+	v.SetDefault("LOG_MAX_SIZE_MB", "100")
+	v.SetDefault("LOG_MAX_AGE_DAYS", "30")
+	v.SetDefault("LOG_MAX_BACKUPS", "5")
 
 	// Helper to get env var with fallback
 	getEnv := func(key, fallback string) string {
@@ -111,16 +663,60 @@ func LoadConfig() (*Config, error) {
 		return d
 	}
 
+	// Parse booleans safely
+	parseBool := func(val string, defaultVal bool) bool {
+		if val == "" {
+			return defaultVal
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return defaultVal
+		}
+		return b
+	}
+
+	// Parse integers strictly: viper reads a typo as 0, which for some
+	// settings silently changes behavior, so bad values are reported
+	var intProblems []error
+	parseInt := func(key string, defaultVal int) int {
+		val := strings.TrimSpace(os.Getenv(key))
+		if val == "" {
+			return defaultVal
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			intProblems = append(intProblems, fmt.Errorf("%s %q is not an integer", key, val))
+			return defaultVal
+		}
+		return n
+	}
+
 	// Build config explicitly from environment variables
 	cfg := &Config{
 		App: AppConfig{
-			Name:         getEnv("APP_NAME", "Secure Task Management API"),
-			Version:      getEnv("APP_VERSION", "1.0.0"),
-			Port:         v.GetInt("APP_PORT"),
-			Environment:  getEnv("APP_ENVIRONMENT", "development"),
-			ReadTimeout:  parseDuration(os.Getenv("APP_READ_TIMEOUT"), 15*time.Second),
-			WriteTimeout: parseDuration(os.Getenv("APP_WRITE_TIMEOUT"), 15*time.Second),
-			IdleTimeout:  parseDuration(os.Getenv("APP_IDLE_TIMEOUT"), 60*time.Second),
+			Name:            getEnv("APP_NAME", "Secure Task Management API"),
+			Version:         getEnv("APP_VERSION", "1.0.0"),
+			Port:            v.GetInt("APP_PORT"),
+			Environment:     getEnv("APP_ENVIRONMENT", "development"),
+			ReadTimeout:     parseDuration(os.Getenv("APP_READ_TIMEOUT"), 15*time.Second),
+			WriteTimeout:    parseDuration(os.Getenv("APP_WRITE_TIMEOUT"), 15*time.Second),
+			IdleTimeout:     parseDuration(os.Getenv("APP_IDLE_TIMEOUT"), 60*time.Second),
+			ShutdownTimeout: parseDuration(os.Getenv("APP_SHUTDOWN_TIMEOUT"), 30*time.Second),
+			RequestTimeout:  parseDuration(os.Getenv("REQUEST_TIMEOUT"), 10*time.Second),
+			BasePath:        normalizeBasePath(os.Getenv("API_BASE_PATH")),
+
+			RequireContentLength: parseBool(os.Getenv("APP_REQUIRE_CONTENT_LENGTH"), false),
+
+			RequireJSONContentType: parseBool(os.Getenv("APP_REQUIRE_JSON_CONTENT_TYPE"), true),
+
+			MaxBodyBytes:          v.GetInt64("APP_MAX_BODY_BYTES"),
+			MaxPageSize:           v.GetInt("APP_MAX_PAGE_SIZE"),
+			StrictPageSize:        parseBool(os.Getenv("APP_STRICT_PAGE_SIZE"), false),
+			MaxPage:               v.GetInt("APP_MAX_PAGE"),
+			DisallowUnknownFields: parseBool(os.Getenv("APP_DISALLOW_UNKNOWN_FIELDS"), false),
+			ValidationErrorFormat: getEnv("APP_VALIDATION_ERROR_FORMAT", "map"),
+			TrailingSlash:         strings.ToLower(getEnv("APP_TRAILING_SLASH", "off")),
+			Docs:                  parseBool(os.Getenv("API_DOCS_ENABLED"), true),
 		},
 		Database: DatabaseConfig{
 			// Check for DATABASE_URL first (Render provides this)
@@ -134,11 +730,24 @@ func LoadConfig() (*Config, error) {
 			MaxOpenConns:    v.GetInt("DB_MAX_OPEN_CONNS"),
 			MaxIdleConns:    v.GetInt("DB_MAX_IDLE_CONNS"),
 			ConnMaxLifetime: parseDuration(os.Getenv("DB_CONN_MAX_LIFETIME"), 30*time.Minute),
+			HealthInterval:  parseDuration(os.Getenv("DB_HEALTH_INTERVAL"), 5*time.Second),
+			RejectWhenDown:  parseBool(os.Getenv("DB_REJECT_WHEN_DOWN"), true),
 		},
 		JWT: JWTConfig{
+			Algorithm:            strings.ToUpper(getEnv("JWT_ALGORITHM", "HS256")),
 			Secret:               getEnv("JWT_SECRET", ""),
+			PrivateKeyPath:       getEnv("JWT_PRIVATE_KEY_PATH", ""),
+			PublicKeyPath:        getEnv("JWT_PUBLIC_KEY_PATH", ""),
 			AccessTokenDuration:  parseDuration(os.Getenv("JWT_ACCESS_DURATION"), 15*time.Minute),
 			RefreshTokenDuration: parseDuration(os.Getenv("JWT_REFRESH_DURATION"), 7*24*time.Hour),
+			SessionMaxLifetime:   parseDuration(os.Getenv("JWT_SESSION_MAX_LIFETIME"), 30*24*time.Hour),
+			Issuer:               getEnv("JWT_ISSUER", "secure-task-api"),
+			Audience:             getEnv("JWT_AUDIENCE", ""),
+			Leeway:               parseDuration(os.Getenv("JWT_LEEWAY"), 30*time.Second),
+
+			AccessTokenMinDuration:  parseDuration(os.Getenv("JWT_ACCESS_MIN_DURATION"), time.Minute),
+			AccessTokenMaxDuration:  parseDuration(os.Getenv("JWT_ACCESS_MAX_DURATION"), 24*time.Hour),
+			RefreshTokenMaxDuration: parseDuration(os.Getenv("JWT_REFRESH_MAX_DURATION"), 90*24*time.Hour),
 		},
 		Sentry: SentryConfig{
 			DSN:         getEnv("SENTRY_DSN", ""),
@@ -146,21 +755,383 @@ func LoadConfig() (*Config, error) {
 			SampleRate:  v.GetFloat64("SENTRY_SAMPLE_RATE"),
 		},
 		Logging: LoggingConfig{
-			Level:            getEnv("LOG_LEVEL", "info"),
-			Encoding:         getEnv("LOG_ENCODING", "json"),
+			Level:            strings.ToLower(getEnv("LOG_LEVEL", "info")),
+			Development:      parseBool(os.Getenv("LOG_DEVELOPMENT"), false),
+			Encoding:         strings.ToLower(getEnv("LOG_ENCODING", "json")),
 			OutputPaths:      strings.Split(getEnv("LOG_OUTPUT_PATHS", "stdout"), ","),
 			ErrorOutputPaths: strings.Split(getEnv("LOG_ERROR_OUTPUT_PATHS", "stderr"), ","),
+
+			ValidationFailures: strings.ToLower(getEnv("LOG_VALIDATION_FAILURES", "off")),
+			Rotation: LogRotationConfig{
+				MaxSizeMB:  v.GetInt("LOG_MAX_SIZE_MB"),
+				MaxAgeDays: v.GetInt("LOG_MAX_AGE_DAYS"),
+				MaxBackups: v.GetInt("LOG_MAX_BACKUPS"),
+				Compress:   parseBool(os.Getenv("LOG_COMPRESS"), false),
+			},
+
+			StacktraceLevel: strings.ToLower(getEnv("LOG_STACKTRACE_LEVEL", "error")),
+			RequestHandler:  parseBool(os.Getenv("LOG_REQUEST_HANDLER"), false),
+
+			SlowRequestThreshold: parseDuration(os.Getenv("SLOW_REQUEST_THRESHOLD"), 0),
+			SlowRequestsOnly:     parseBool(os.Getenv("LOG_SLOW_REQUESTS_ONLY"), false),
+
+			RequestHeaders: parseBool(os.Getenv("LOG_REQUEST_HEADERS"), false),
+			RedactKeys:     parseList(os.Getenv("LOG_REDACT_KEYS")),
+			Sampling: LogSamplingConfig{
+				Initial:    v.GetInt("LOG_SAMPLING_INITIAL"),
+				Thereafter: v.GetInt("LOG_SAMPLING_THEREAFTER"),
+			},
+		},
+		Task: TaskConfig{
+			UniqueTitles: parseBool(os.Getenv("TASK_UNIQUE_TITLES"), false),
+			MaxPerUser:   parseInt("QUOTA_MAX_TASKS", 0),
+
+			GroupByStatus: parseBool(os.Getenv("TASK_GROUP_BY_STATUS"), false),
+
+			CalendarFeedTTL: parseDuration(os.Getenv("CALENDAR_FEED_TTL"), 365*24*time.Hour),
+			ExportBatchSize: v.GetInt("TASK_EXPORT_BATCH_SIZE"),
+
+			MaxDescriptionLength: v.GetInt("TASK_MAX_DESCRIPTION_LENGTH"),
+			MaxTags:              v.GetInt("TASK_MAX_TAGS"),
+			AllowReopen:          parseBool(os.Getenv("TASK_ALLOW_REOPEN"), false),
+		},
+		Stats: StatsConfig{
+			CacheEnabled:    parseBool(os.Getenv("STATS_CACHE_ENABLED"), false),
+			RefreshInterval: parseDuration(os.Getenv("STATS_REFRESH_INTERVAL"), 5*time.Minute),
+		},
+		Reminder: ReminderConfig{
+			Enabled:    parseBool(os.Getenv("REMINDERS_ENABLED"), false),
+			Interval:   parseDuration(os.Getenv("REMINDER_INTERVAL"), time.Minute),
+			Window:     parseDuration(os.Getenv("REMINDER_WINDOW"), 24*time.Hour),
+			WebhookURL: getEnv("REMINDER_WEBHOOK_URL", ""),
+		},
+		Webhook: WebhookConfig{
+			URL:       getEnv("TASK_WEBHOOK_URL", ""),
+			Secret:    getEnv("TASK_WEBHOOK_SECRET", ""),
+			QueueSize: v.GetInt("TASK_WEBHOOK_QUEUE_SIZE"),
+
+			DeadLetterRetention: parseDuration(os.Getenv("TASK_WEBHOOK_DEAD_LETTER_RETENTION"), 7*24*time.Hour),
+		},
+		Metrics: MetricsConfig{
+			Enabled: parseBool(os.Getenv("METRICS_ENABLED"), true),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: parseDuration(os.Getenv("IDEMPOTENCY_TTL"), 24*time.Hour),
+		},
+		Compression: CompressionConfig{
+			Enabled:    parseBool(os.Getenv("COMPRESSION_ENABLED"), false),
+			Algorithms: strings.Split(strings.ToLower(getEnv("COMPRESSION_ALGORITHMS", "gzip")), ","),
+			Level:      parseInt("COMPRESSION_LEVEL", 5),
+		},
+		Privacy: PrivacyConfig{
+			HashEmails:   parseBool(os.Getenv("PRIVACY_HASH_EMAILS"), false),
+			EmailHashKey: getEnv("EMAIL_HASH_KEY", ""),
+		},
+		TwoFactor: TwoFactorConfig{
+			EncryptionKey: getEnv("TOTP_ENCRYPTION_KEY", ""),
+			Issuer:        getEnv("TOTP_ISSUER", "Secure Task API"),
+			ChallengeTTL:  parseDuration(os.Getenv("TWO_FACTOR_CHALLENGE_TTL"), 5*time.Minute),
+		},
+		Account: AccountConfig{
+			PasswordResetTTL: parseDuration(os.Getenv("PASSWORD_RESET_TTL"), 30*time.Minute),
+			PasswordResetURL: getEnv("PASSWORD_RESET_URL", ""),
+
+			EmailVerificationTTL: parseDuration(os.Getenv("EMAIL_VERIFICATION_TTL"), 24*time.Hour),
+			EmailVerificationURL: getEnv("EMAIL_VERIFICATION_URL", ""),
+
+			LockoutThreshold: v.GetInt("LOGIN_LOCKOUT_THRESHOLD"),
+			LockoutWindow:    parseDuration(os.Getenv("LOGIN_LOCKOUT_WINDOW"), 15*time.Minute),
+			LockoutDuration:  parseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION"), 15*time.Minute),
+		},
+		Mail: MailConfig{
+			From:         getEnv("MAIL_FROM", ""),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     v.GetInt("SMTP_PORT"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		},
+		Password: PasswordConfig{
+			MinLength:     v.GetInt("PASSWORD_MIN_LENGTH"),
+			RequireUpper:  parseBool(os.Getenv("PASSWORD_REQUIRE_UPPER"), false),
+			RequireLower:  parseBool(os.Getenv("PASSWORD_REQUIRE_LOWER"), false),
+			RequireDigit:  parseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT"), false),
+			RequireSymbol: parseBool(os.Getenv("PASSWORD_REQUIRE_SYMBOL"), false),
+			RejectEmail:   parseBool(os.Getenv("PASSWORD_REJECT_EMAIL"), true),
+			BlocklistFile: getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+			CheckBreached: parseBool(os.Getenv("PASSWORD_CHECK_BREACHED"), false),
+			BreachAPIURL:  getEnv("PASSWORD_BREACH_API_URL", ""),
+
+			HashAlgorithm:     strings.ToLower(getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt")),
+			BcryptCost:        v.GetInt("BCRYPT_COST"),
+			Argon2Memory:      v.GetInt("ARGON2_MEMORY_KIB"),
+			Argon2Iterations:  v.GetInt("ARGON2_ITERATIONS"),
+			Argon2Parallelism: v.GetInt("ARGON2_PARALLELISM"),
+		},
+		RateLimit: RateLimitConfig{
+			Window:           parseDuration(os.Getenv("RATE_LIMIT_WINDOW"), time.Minute),
+			CheckPassword:    v.GetInt("RATE_LIMIT_CHECK_PASSWORD"),
+			CheckPasswordKey: parseList(getEnv("RATE_LIMIT_CHECK_PASSWORD_KEY", "ip")),
+			Bulk:             v.GetInt("RATE_LIMIT_BULK"),
+			BulkKey:          parseList(getEnv("RATE_LIMIT_BULK_KEY", "ip,user")),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   parseList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+			AllowedMethods:   parseList(getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE")),
+			AllowedHeaders:   parseList(getEnv("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,Idempotency-Key")),
+			AllowCredentials: parseBool(os.Getenv("CORS_ALLOW_CREDENTIALS"), false),
+			MaxAge:           parseDuration(os.Getenv("CORS_MAX_AGE"), 10*time.Minute),
+		},
+		Outbound: OutboundConfig{
+			MaxAttempts:    v.GetInt("OUTBOUND_MAX_ATTEMPTS"),
+			AttemptTimeout: parseDuration(os.Getenv("OUTBOUND_ATTEMPT_TIMEOUT"), 5*time.Second),
+			BackoffBase:    parseDuration(os.Getenv("OUTBOUND_BACKOFF_BASE"), 200*time.Millisecond),
+			BackoffMax:     parseDuration(os.Getenv("OUTBOUND_BACKOFF_MAX"), 5*time.Second),
 		},
 	}
 
-	// Validate required fields
-	if cfg.Database.DSN == "" && (cfg.Database.Host == "" || cfg.Database.User == "") {
-		return nil, fmt.Errorf("database configuration missing: set DATABASE_URL or DB_HOST/DB_USER/DB_PASSWORD/DB_NAME")
+	statuses, statusErr := parseStatusCodes(getEnv("OUTBOUND_RETRY_STATUSES", "429,502,503,504"))
+	cfg.Outbound.RetryableStatuses = statuses
+
+	// LOG_LEVEL=development used to be the only way to get development
+	// logging, at info level; keep it working
+	if cfg.Logging.Level == "development" {
+		cfg.Logging.Development = true
+		cfg.Logging.Level = "info"
 	}
 
-	if cfg.JWT.Secret == "" {
-		return nil, fmt.Errorf("JWT_SECRET is required")
+	// Sampling follows the zap preset unless set: on in production mode,
+	// off in development mode. A zero initial or thereafter count turns it
+	// off too, logging everything.
+	s := &cfg.Logging.Sampling
+	s.Enabled = parseBool(os.Getenv("LOG_SAMPLING"), !cfg.Logging.Development) &&
+		s.Initial != 0 && s.Thereafter != 0
+
+	// Stack traces are noisy in production logs, so they default off there
+	cfg.Logging.Stacktrace = parseBool(os.Getenv("LOG_STACKTRACE"), cfg.App.Environment != "production")
+
+	// Mail is only logged by default in development; anywhere else it has
+	// to reach users
+	mailSender := MailSenderSMTP
+	if cfg.App.Environment == "development" {
+		mailSender = MailSenderLog
+	}
+	cfg.Mail.Sender = strings.ToLower(getEnv("MAIL_SENDER", mailSender))
+
+	// Verification links have to reach users, so it is only required by
+	// default when mail is really sent
+	cfg.Account.RequireEmailVerification = parseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"),
+		cfg.Mail.Sender == MailSenderSMTP)
+
+	problems := cfg.validate()
+	if statusErr != nil {
+		problems = append([]error{fmt.Errorf("OUTBOUND_RETRY_STATUSES: %w", statusErr)}, problems...)
+	}
+	problems = append(intProblems, problems...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
 	}
 
 	return cfg, nil
 }
+
+// minJWTSecretLength is the shortest HS256 secret accepted outside development
+const minJWTSecretLength = 32
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid configuration: " + e.Problems[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d problems):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p.Error())
+	}
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks the whole configuration and returns a *ValidationError
+// listing every problem, or nil
+func (c *Config) Validate() error {
+	if problems := c.validate(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validate returns every problem with the configuration, in field order
+func (c *Config) validate() []error {
+	var problems []error
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.App.Port < 1 || c.App.Port > 65535 {
+		fail("APP_PORT %d must be between 1 and 65535", c.App.Port)
+	}
+	if c.App.ReadTimeout < 0 || c.App.WriteTimeout < 0 || c.App.IdleTimeout < 0 {
+		fail("APP_READ_TIMEOUT, APP_WRITE_TIMEOUT and APP_IDLE_TIMEOUT must not be negative")
+	}
+	if c.App.ShutdownTimeout <= 0 {
+		fail("APP_SHUTDOWN_TIMEOUT must be positive")
+	}
+	// Leave time to write the 503 before the server's own write deadline
+	if t := c.App.RequestTimeout; t < 0 || (t > 0 && c.App.WriteTimeout > 0 && t >= c.App.WriteTimeout) {
+		fail("REQUEST_TIMEOUT must not be negative and must be less than APP_WRITE_TIMEOUT")
+	}
+	if c.App.MaxBodyBytes < 1 {
+		fail("APP_MAX_BODY_BYTES must be positive")
+	}
+	if c.App.MaxPageSize < 1 || c.App.MaxPage < 1 {
+		fail("APP_MAX_PAGE_SIZE and APP_MAX_PAGE must be positive")
+	}
+	if f := c.App.ValidationErrorFormat; f != "map" && f != "array" {
+		fail("invalid APP_VALIDATION_ERROR_FORMAT %q: must be map or array", f)
+	}
+	if t := c.App.TrailingSlash; t != "off" && t != "strip" && t != "redirect" {
+		fail("invalid APP_TRAILING_SLASH %q: must be off, strip or redirect", t)
+	}
+
+	if c.Database.DSN == "" {
+		if c.Database.Host == "" || c.Database.User == "" {
+			fail("database configuration missing: set DATABASE_URL or DB_HOST/DB_USER/DB_PASSWORD/DB_NAME")
+		}
+		if c.Database.Port < 1 || c.Database.Port > 65535 {
+			fail("DB_PORT %d must be between 1 and 65535", c.Database.Port)
+		}
+	}
+	check(c.Database.validateSSLMode(c.App.Environment))
+	if c.Database.HealthInterval < 0 {
+		fail("DB_HEALTH_INTERVAL must not be negative")
+	}
+
+	switch c.JWT.Algorithm {
+	case "HS256":
+		if c.JWT.Secret == "" {
+			fail("JWT_SECRET is required")
+		} else if c.App.Environment != "development" && len(c.JWT.Secret) < minJWTSecretLength {
+			fail("JWT_SECRET must be at least %d bytes outside development", minJWTSecretLength)
+		}
+	case "RS256":
+		if c.JWT.PrivateKeyPath == "" || c.JWT.PublicKeyPath == "" {
+			fail("JWT_PRIVATE_KEY_PATH and JWT_PUBLIC_KEY_PATH are required for RS256")
+		}
+	default:
+		fail("JWT_ALGORITHM %q is not supported, use HS256 or RS256", c.JWT.Algorithm)
+	}
+	check(c.JWT.validateDurations())
+	if c.JWT.SessionMaxLifetime < 0 {
+		fail("JWT_SESSION_MAX_LIFETIME must not be negative")
+	}
+	if c.JWT.Issuer == "" {
+		fail("JWT_ISSUER must not be empty")
+	}
+	if c.JWT.Leeway < 0 || c.JWT.Leeway > 5*time.Minute {
+		fail("JWT_LEEWAY must be between 0 and 5m")
+	}
+
+	if c.Task.MaxPerUser < 0 {
+		fail("QUOTA_MAX_TASKS must not be negative, 0 means unlimited")
+	}
+	if c.Task.MaxDescriptionLength < 1 || c.Task.MaxDescriptionLength > 65535 {
+		fail("TASK_MAX_DESCRIPTION_LENGTH must be between 1 and 65535")
+	}
+	if c.Task.MaxTags < 1 || c.Task.MaxTags > 100 {
+		fail("TASK_MAX_TAGS must be between 1 and 100")
+	}
+	if c.Task.ExportBatchSize < 1 || c.Task.ExportBatchSize > 10000 {
+		fail("TASK_EXPORT_BATCH_SIZE must be between 1 and 10000")
+	}
+	if c.Task.CalendarFeedTTL <= 0 {
+		fail("CALENDAR_FEED_TTL must be positive")
+	}
+
+	check(c.Compression.validate())
+	check(c.Reminder.validate())
+	check(c.Webhook.validate())
+	check(c.TwoFactor.validate())
+
+	if c.Privacy.HashEmails && c.Privacy.EmailHashKey == "" {
+		fail("EMAIL_HASH_KEY is required when PRIVACY_HASH_EMAILS is enabled")
+	}
+
+	if c.Password.MinLength < 1 {
+		fail("PASSWORD_MIN_LENGTH must be at least 1")
+	}
+	check(c.Password.validateHashing())
+
+	if c.RateLimit.Window <= 0 || c.RateLimit.CheckPassword < 1 {
+		fail("RATE_LIMIT_WINDOW and RATE_LIMIT_CHECK_PASSWORD must be positive")
+	}
+	if c.RateLimit.Bulk < 0 {
+		fail("RATE_LIMIT_BULK must not be negative")
+	}
+	check(validateRateLimitKey("RATE_LIMIT_CHECK_PASSWORD_KEY", c.RateLimit.CheckPasswordKey))
+	check(validateRateLimitKey("RATE_LIMIT_BULK_KEY", c.RateLimit.BulkKey))
+
+	if c.Account.PasswordResetTTL <= 0 || c.Account.EmailVerificationTTL <= 0 {
+		fail("PASSWORD_RESET_TTL and EMAIL_VERIFICATION_TTL must be positive")
+	}
+	check(c.Mail.validate(c.App.Environment))
+	if c.Account.RequireEmailVerification && c.Mail.Sender != MailSenderSMTP {
+		fail("REQUIRE_EMAIL_VERIFICATION needs MAIL_SENDER=smtp: no verification link would reach anyone")
+	}
+	if a := c.Account; a.LockoutThreshold < 0 || (a.LockoutThreshold > 0 && (a.LockoutWindow <= 0 || a.LockoutDuration <= 0)) {
+		fail("LOGIN_LOCKOUT_THRESHOLD must not be negative, and LOGIN_LOCKOUT_WINDOW and LOGIN_LOCKOUT_DURATION must be positive when it is set")
+	}
+
+	if c.Outbound.MaxAttempts < 1 {
+		fail("OUTBOUND_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+		fail("invalid LOG_LEVEL %q: use debug, info, warn or error", c.Logging.Level)
+	}
+	check(ValidateLogEncoding(c.Logging.Encoding))
+	if c.Logging.Stacktrace {
+		if _, err := zapcore.ParseLevel(c.Logging.StacktraceLevel); err != nil {
+			fail("invalid LOG_STACKTRACE_LEVEL %q: use debug, info, warn or error", c.Logging.StacktraceLevel)
+		}
+	}
+	if s := c.Logging.Sampling; s.Initial < 0 || s.Thereafter < 0 {
+		fail("LOG_SAMPLING_INITIAL and LOG_SAMPLING_THEREAFTER must not be negative")
+	}
+	if c.Logging.SlowRequestThreshold < 0 {
+		fail("SLOW_REQUEST_THRESHOLD must not be negative")
+	}
+	if c.Logging.SlowRequestsOnly && c.Logging.SlowRequestThreshold == 0 {
+		fail("LOG_SLOW_REQUESTS_ONLY requires SLOW_REQUEST_THRESHOLD")
+	}
+	if r := c.Logging.Rotation; r.MaxSizeMB < 0 || r.MaxAgeDays < 0 || r.MaxBackups < 0 {
+		fail("LOG_MAX_SIZE_MB, LOG_MAX_AGE_DAYS and LOG_MAX_BACKUPS must not be negative")
+	}
+
+	// Browsers reject credentialed responses for a wildcard origin
+	if c.CORS.AllowCredentials {
+		for _, origin := range c.CORS.AllowedOrigins {
+			if origin == "*" {
+				fail("CORS_ALLOWED_ORIGINS must list origins explicitly when CORS_ALLOW_CREDENTIALS is true")
+				break
+			}
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		fail("CORS_MAX_AGE must not be negative")
+	}
+
+	return problems
+}
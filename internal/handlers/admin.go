@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+	"secure-task-api/pkg/utils"
+)
+
+// AdminHandler serves administrator views across every user
+type AdminHandler struct {
+	repo *repository.Repository
+	cfg  config.TaskConfig
+	log  *logger.Logger
+}
+
+func NewAdminHandler(repo *repository.Repository, cfg config.TaskConfig, log *logger.Logger) *AdminHandler {
+	return &AdminHandler{repo: repo, cfg: cfg, log: log}
+}
+
+// Registers admin routes under /v1/admin; every one requires the admin role.
+func (h *AdminHandler) RegisterRoutes(r chi.Router) {
+	r.Use(middleware.RequireRole(string(models.UserRoleAdmin)))
+	r.Get("/tasks", h.ListTasks)
+	r.Get("/users", h.ListUsers)
+}
+
+// ListTasks lists every user's live tasks, or one user's with user_id
+func (h *AdminHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	page, limit, ok := paginationParams(w, r, h.log)
+	if !ok {
+		return
+	}
+
+	var ownerID uuid.NullUUID
+	if raw := utils.GetQueryParam(r, "user_id", ""); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			logValidationFailure(h.log, r, "user_id")
+			utils.BadRequest(w, r, "Invalid user ID")
+			return
+		}
+		ownerID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	filter, sort, ok := taskListQuery(w, r, h.log, h.cfg.GroupByStatus)
+	if !ok {
+		return
+	}
+
+	tasks, total, err := h.repo.Task.ListAll(r.Context(), ownerID, filter, sort, page, limit)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch tasks for admin")
+		utils.InternalServerError(w, r, "Failed to get tasks")
+		return
+	}
+
+	p := pagination(page, limit, total)
+	setPaginationHeaders(w, r, p)
+	utils.JSONSuccess(w, http.StatusOK, models.TaskListResponse{
+		Tasks:      tasks,
+		Pagination: p,
+	})
+}
+
+// ListUsers lists every live user, oldest first
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, limit, ok := paginationParams(w, r, h.log)
+	if !ok {
+		return
+	}
+
+	users, total, err := h.repo.User.List(r.Context(), page, limit)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch users for admin")
+		utils.InternalServerError(w, r, "Failed to get users")
+		return
+	}
+
+	p := pagination(page, limit, total)
+	setPaginationHeaders(w, r, p)
+	utils.JSONSuccess(w, http.StatusOK, models.UserListResponse{
+		Users:      users,
+		Pagination: p,
+	})
+}
+
+// pagination describes page of a listing with total items
+func pagination(page, limit, total int) models.Pagination {
+	return models.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + limit - 1) / limit,
+	}
+}
+
+// setPaginationHeaders describes p in X-Total-Count and a Link header with
+// the next and previous pages, for clients that page by headers. The links
+// keep every other query parameter of r.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, p models.Pagination) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(p.Total))
+
+	var links []string
+	if p.Page < p.TotalPages {
+		links = append(links, pageLink(r, p.Page+1, "next"))
+	}
+	if p.Page > 1 {
+		links = append(links, pageLink(r, p.Page-1, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink returns a Link header entry for page of the listing r requested
+func pageLink(r *http.Request, page int, rel string) string {
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	u := url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: query.Encode()}
+	return "<" + requestOrigin(r) + u.String() + `>; rel="` + rel + `"`
+}
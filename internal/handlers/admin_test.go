@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+)
+
+// adminTaskRepo lists every task for admins
+type adminTaskRepo struct {
+	fakeTaskRepo
+	tasks []models.Task
+}
+
+func (f *adminTaskRepo) ListAll(ctx context.Context, ownerID uuid.NullUUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	return f.tasks, len(f.tasks), nil
+}
+
+// adminUserRepo lists every user for admins
+type adminUserRepo struct {
+	fakeUserRepo
+	users []models.User
+}
+
+func (f *adminUserRepo) List(ctx context.Context, page, limit int) ([]models.User, int, error) {
+	return f.users, len(f.users), nil
+}
+
+func TestAdminRoutesRequireAdminRole(t *testing.T) {
+	log := testLogger(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	tasks := &adminTaskRepo{tasks: []models.Task{{ID: uuid.New(), UserID: uuid.New(), Title: "Someone else's"}}}
+	users := &adminUserRepo{users: []models.User{{ID: uuid.New(), Email: "user@example.com"}}}
+	h := NewAdminHandler(&repository.Repository{Task: tasks, User: users}, config.TaskConfig{}, log)
+
+	router := chi.NewRouter()
+	router.Use(middleware.AuthMiddleware(jwtManager, nil, log))
+	router.Route("/v1/admin", h.RegisterRoutes)
+
+	token := func(role models.UserRole) string {
+		t.Helper()
+		token, err := jwtManager.GenerateAccessToken(uuid.New(), "someone@example.com", string(role), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	userToken, adminToken := token(models.UserRoleUser), token(models.UserRoleAdmin)
+
+	for _, path := range []string{"/v1/admin/tasks", "/v1/admin/users"} {
+		if rec := sendJSON(router, http.MethodGet, path, userToken, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("user GET %s: status %d, want 403", path, rec.Code)
+		}
+		if rec := sendJSON(router, http.MethodGet, path, adminToken, ""); rec.Code != http.StatusOK {
+			t.Errorf("admin GET %s: status %d, want 200: %s", path, rec.Code, rec.Body)
+		}
+	}
+
+	rec := sendJSON(router, http.MethodGet, "/v1/admin/tasks", adminToken, "")
+	if got := responseData[models.TaskListResponse](t, rec.Body.Bytes()).Tasks; len(got) != 1 || got[0].Title != "Someone else's" {
+		t.Fatalf("admin tasks = %+v, want every user's tasks", got)
+	}
+}
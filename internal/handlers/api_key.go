@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+	"secure-task-api/pkg/utils"
+)
+
+// apiKeyScopes lists the scopes a key may be created with
+var apiKeyScopes = []string{models.APIKeyScopeRead, models.APIKeyScopeWrite}
+
+// CreateAPIKey issues an API key for the current user. The full key is only
+// ever returned here.
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	if !validRequest(w, r, h.log, &req) {
+		return
+	}
+	scopes, ok := h.validScopes(w, r, req.Scopes)
+	if !ok {
+		return
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to generate API key")
+		utils.InternalServerError(w, r, "Failed to create API key")
+		return
+	}
+
+	record := models.APIKey{
+		UserID:  userID,
+		Name:    req.Name,
+		KeyHash: auth.HashToken(key),
+		Prefix:  prefix,
+		Scopes:  scopes,
+	}
+	if err := h.repo.APIKey.Create(r.Context(), &record); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to store API key")
+		utils.InternalServerError(w, r, "Failed to create API key")
+		return
+	}
+
+	logger.FromContext(r.Context()).WithUserID(userID.String()).Info("API key created")
+	utils.JSONSuccess(w, http.StatusCreated, models.APIKeyCreatedResponse{APIKey: record, Key: key})
+}
+
+// ListAPIKeys lists the current user's unrevoked API keys, without the keys themselves
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.repo.APIKey.ListByUser(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to list API keys")
+		utils.InternalServerError(w, r, "Failed to get API keys")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.APIKeyListResponse{APIKeys: keys})
+}
+
+// RevokeAPIKey stops one of the current user's API keys working
+func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
+		return
+	}
+
+	revoked, err := h.repo.APIKey.Revoke(r.Context(), id, userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to revoke API key")
+		utils.InternalServerError(w, r, "Failed to revoke API key")
+		return
+	}
+	if !revoked {
+		utils.NotFound(w, r, "API key not found")
+		return
+	}
+
+	logger.FromContext(r.Context()).WithUserID(userID.String()).Info("API key revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validScopes checks each requested scope is known, writing a validation
+// error if not. Repeated scopes are dropped.
+func (h *AuthHandler) validScopes(w http.ResponseWriter, r *http.Request, scopes []string) ([]string, bool) {
+	v := utils.NewValidator()
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for i, scope := range scopes {
+		v.OneOf("scopes["+strconv.Itoa(i)+"]", scope, apiKeyScopes)
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	if !v.IsValid() {
+		logValidationFailure(h.log, r, "scopes")
+		utils.ValidationError(w, v.Errors)
+		return nil, false
+	}
+	return unique, true
+}
@@ -1,34 +1,98 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"secure-task-api/internal/auth"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/httpclient"
 	"secure-task-api/internal/logger"
+	"secure-task-api/internal/middleware"
 	"secure-task-api/internal/models"
+	"secure-task-api/internal/notify"
 	"secure-task-api/internal/repository"
 	"secure-task-api/pkg/utils"
 )
 
 type AuthHandler struct {
-	repo       *repository.Repository
-	jwtManager *auth.JWTManager
-	log        *logger.Logger
+	config         *config.Config
+	repo           *repository.Repository
+	jwtManager     *auth.JWTManager
+	authMiddleware func(http.Handler) http.Handler
+	sender         notify.Sender
+	policy         *auth.PasswordPolicy
+	hasher         auth.Hasher
+	totpSecrets    *auth.SecretBox // nil when two-factor authentication isn't configured
+	log            *logger.Logger
 }
 
-// Wires repository, JWT logic, and logger into the auth handler.
+// Wires config, repository, JWT logic, and logger into the auth handler.
+// authMiddleware protects the routes that act on the current user; sender
+// delivers password reset and verification links; policy vets new passwords
+// and hasher stores them.
 func NewAuthHandler(
+	config *config.Config,
 	repo *repository.Repository,
 	jwtManager *auth.JWTManager,
+	authMiddleware func(http.Handler) http.Handler,
+	sender notify.Sender,
+	policy *auth.PasswordPolicy,
+	hasher auth.Hasher,
 	log *logger.Logger,
 ) *AuthHandler {
-	return &AuthHandler{
-		repo:       repo,
-		jwtManager: jwtManager,
-		log:        log,
+	h := &AuthHandler{
+		config:         config,
+		repo:           repo,
+		jwtManager:     jwtManager,
+		authMiddleware: authMiddleware,
+		sender:         sender,
+		policy:         policy,
+		hasher:         hasher,
+		log:            log,
 	}
+	if config.TwoFactor.Enabled() {
+		h.totpSecrets = auth.NewSecretBox(config.TwoFactor.EncryptionKey)
+	}
+	return h
+}
+
+// newPasswordPolicy builds the password policy from config and the loaded
+// blocklist, with a breach check over the outbound client when enabled
+func newPasswordPolicy(cfg config.PasswordConfig, blocklist auth.PasswordBlocklist, outbound config.OutboundConfig) *auth.PasswordPolicy {
+	policy := &auth.PasswordPolicy{
+		MinLength:     cfg.MinLength,
+		RequireUpper:  cfg.RequireUpper,
+		RequireLower:  cfg.RequireLower,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+		RejectEmail:   cfg.RejectEmail,
+		Blocklist:     blocklist,
+	}
+	if cfg.CheckBreached {
+		policy.Breaches = auth.NewHIBPChecker(httpclient.New(outbound), cfg.BreachAPIURL)
+	}
+	return policy
+}
+
+// newPasswordHasher builds the hasher for new passwords from config
+func newPasswordHasher(cfg config.PasswordConfig) auth.Hasher {
+	if cfg.HashAlgorithm == auth.AlgorithmArgon2id {
+		return auth.Argon2idHasher{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+		}
+	}
+	return auth.BcryptHasher{Cost: cfg.BcryptCost}
 }
 
 // Registers auth routes under /v1/auth.
@@ -36,38 +100,74 @@ func (h *AuthHandler) RegisterRoutes(r chi.Router) {
 	r.Post("/register", h.Register)
 	r.Post("/login", h.Login)
 	r.Post("/refresh", h.Refresh)
+	r.Post("/forgot-password", h.ForgotPassword)
+	r.Post("/reset-password", h.ResetPassword)
+	r.Post("/verify-email", h.VerifyEmail)
+	r.Post("/resend-verification", h.ResendVerification)
+	limits := h.config.RateLimit
+	r.With(middleware.RateLimitBy(limits.CheckPassword, limits.Window, middleware.RateLimitKey(limits.CheckPasswordKey))).
+		Post("/check-password", h.CheckPassword)
+	if h.totpSecrets != nil {
+		r.Post("/2fa/verify", h.VerifyTwoFactor)
+	}
+
+	// Routes acting on the authenticated user
+	r.Group(func(protected chi.Router) {
+		protected.Use(h.authMiddleware)
+		protected.Get("/me", h.Me)
+		protected.Put("/me", h.ReplaceProfile)
+		protected.Patch("/me", h.UpdateProfile)
+		protected.Delete("/me", h.DeleteAccount)
+		protected.Get("/me/usage", h.Usage)
+		protected.Post("/change-password", h.ChangePassword)
+		protected.Post("/logout", h.Logout)
+		protected.Post("/logout-all", h.LogoutAll)
+		protected.Get("/api-keys", h.ListAPIKeys)
+		protected.Post("/api-keys", h.CreateAPIKey)
+		protected.Delete("/api-keys/{id}", h.RevokeAPIKey)
+		if h.totpSecrets != nil {
+			protected.Post("/2fa/setup", h.SetupTwoFactor)
+			protected.Post("/2fa/enable", h.EnableTwoFactor)
+			protected.Post("/2fa/disable", h.DisableTwoFactor)
+		}
+	})
 }
 
 // Creates a new user account and returns a token pair on success.
+// The existing-email check can race with a concurrent registration; the
+// unique index on live emails catches that, and both cases answer 409.
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
-		h.log.WithError(err).Error("invalid register payload")
-		utils.BadRequest(w, "Invalid request body")
+		logger.FromContext(r.Context()).WithError(err).Error("invalid register payload")
+		invalidBody(w, r, err)
 		return
 	}
 
-	if req.Email == "" || req.Password == "" || req.Name == "" {
-		utils.BadRequest(w, "Email, password, and name are required")
+	if !validRequest(w, r, h.log, req) {
+		return
+	}
+
+	if !h.validPassword(w, r, "password", req.Password, req.Email) {
 		return
 	}
 
 	// Prevent duplicate accounts by email.
 	existingUser, err := h.repo.User.GetByEmail(r.Context(), req.Email)
 	if err != nil {
-		h.log.WithError(err).Error("failed to check existing user")
-		utils.InternalServerError(w, "Failed to register user")
+		logger.FromContext(r.Context()).WithError(err).Error("failed to check existing user")
+		utils.InternalServerError(w, r, "Failed to register user")
 		return
 	}
 	if existingUser != nil {
-		utils.BadRequest(w, "User with this email already exists")
+		utils.Conflict(w, r, "User with this email already exists")
 		return
 	}
 
-	passwordHash, err := auth.HashPassword(req.Password)
+	passwordHash, err := h.hasher.Hash(req.Password)
 	if err != nil {
-		h.log.WithError(err).Error("password hashing failed")
-		utils.InternalServerError(w, "Failed to register user")
+		logger.FromContext(r.Context()).WithError(err).Error("password hashing failed")
+		utils.InternalServerError(w, r, "Failed to register user")
 		return
 	}
 
@@ -77,30 +177,59 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Name:         req.Name,
 	}
 
-	if err := h.repo.User.Create(r.Context(), user); err != nil {
-		h.log.WithError(err).Error("failed to persist user")
-		utils.InternalServerError(w, "Failed to register user")
+	err = h.repo.User.Create(r.Context(), user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// Registered concurrently since the check above
+		utils.Conflict(w, r, "User with this email already exists")
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to persist user")
+		utils.InternalServerError(w, r, "Failed to register user")
+		return
+	}
+
+	if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+		// The user can ask for another link, so registration still succeeds
+		logger.FromContext(r.Context()).WithError(err).Error("failed to send verification email")
+	}
+
+	// Unverified accounts get no tokens until the email is confirmed
+	if h.config.Account.RequireEmailVerification {
+		utils.JSONSuccess(w, http.StatusCreated, models.PendingVerificationResponse{
+			User: models.User{
+				ID:            user.ID,
+				Email:         user.Email,
+				Name:          user.Name,
+				EmailVerified: user.EmailVerified,
+				CreatedAt:     user.CreatedAt,
+				UpdatedAt:     user.UpdatedAt,
+			},
+			Message: "Check your email to verify your account before logging in",
+		})
 		return
 	}
 
-	accessToken, refreshToken, err :=
-		h.jwtManager.GenerateTokenPair(user.ID, user.Email)
+	tokens, err := h.issueTokens(r.Context(), user, uuid.New(), h.jwtManager.Now())
 	if err != nil {
-		h.log.WithError(err).Error("token generation failed")
-		utils.InternalServerError(w, "Failed to register user")
+		logger.FromContext(r.Context()).WithError(err).Error("token generation failed")
+		utils.InternalServerError(w, r, "Failed to register user")
 		return
 	}
 
 	utils.JSONSuccess(w, http.StatusCreated, models.AuthResponse{
 		User: models.User{
-			ID:        user.ID,
-			Email:     user.Email,
-			Name:      user.Name,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
+			ID:            user.ID,
+			Email:         user.Email,
+			Name:          user.Name,
+			EmailVerified: user.EmailVerified,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
 		},
-		Token:        accessToken,
-		RefreshToken: refreshToken,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.jwtManager.AccessTokenTTL().Seconds()),
 	})
 }
 
@@ -108,118 +237,900 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
-		h.log.WithError(err).Error("invalid login payload")
-		utils.BadRequest(w, "Invalid request body")
+		logger.FromContext(r.Context()).WithError(err).Error("invalid login payload")
+		invalidBody(w, r, err)
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		utils.BadRequest(w, "Email and password are required")
+	if !validRequest(w, r, h.log, req) {
 		return
 	}
 
 	user, err := h.repo.User.GetByEmail(r.Context(), req.Email)
 	if err != nil {
-		h.log.WithError(err).Error("failed to fetch user during login")
-		utils.InternalServerError(w, "Failed to login")
+		logger.FromContext(r.Context()).WithError(err).Error("failed to fetch user during login")
+		utils.InternalServerError(w, r, "Failed to login")
 		return
 	}
 	if user == nil {
-		utils.Unauthorized(w, "Invalid email or password")
+		utils.Unauthorized(w, r, "Invalid email or password")
 		return
 	}
 
-	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
-		h.log.WithError(err).Warn("password verification failed")
-		utils.Unauthorized(w, "Invalid email or password")
+	// A locked account refuses even the right password until the lock expires
+	if h.config.Account.LockoutThreshold > 0 && user.IsLocked(h.jwtManager.Now()) {
+		h.accountLocked(w, r, *user.LockedUntil)
 		return
 	}
 
-	accessToken, refreshToken, err :=
-		h.jwtManager.GenerateTokenPair(user.ID, user.Email)
+	if err := h.hasher.Check(req.Password, user.PasswordHash); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Warn("password verification failed")
+		h.failedLogin(w, r, user, "Invalid email or password")
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		if err := h.repo.User.ResetFailedLogins(r.Context(), user.ID); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to reset failed logins")
+		}
+	}
+
+	if h.config.Account.RequireEmailVerification && !user.EmailVerified {
+		utils.Forbidden(w, r, "Email address not verified. Check your inbox or request a new verification link")
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		h.twoFactorChallenge(w, r, user)
+		return
+	}
+
+	tokens, err := h.issueTokens(r.Context(), user, uuid.New(), h.jwtManager.Now())
 	if err != nil {
-		h.log.WithError(err).Error("token generation failed")
-		utils.InternalServerError(w, "Failed to login")
+		logger.FromContext(r.Context()).WithError(err).Error("token generation failed")
+		utils.InternalServerError(w, r, "Failed to login")
 		return
 	}
 
 	utils.JSONSuccess(w, http.StatusOK, models.AuthResponse{
 		User: models.User{
-			ID:        user.ID,
-			Email:     user.Email,
-			Name:      user.Name,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
+			ID:            user.ID,
+			Email:         user.Email,
+			Name:          user.Name,
+			EmailVerified: user.EmailVerified,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
 		},
-		Token:        accessToken,
-		RefreshToken: refreshToken,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.jwtManager.AccessTokenTTL().Seconds()),
 	})
 }
 
+// failedLogin records a failed credential check against user, responding 423
+// if it locked the account and 401 with message otherwise
+func (h *AuthHandler) failedLogin(w http.ResponseWriter, r *http.Request, user *models.User, message string) {
+	if h.config.Account.LockoutThreshold > 0 {
+		a := h.config.Account
+		lockedUntil, err := h.repo.User.RecordFailedLogin(r.Context(), user.ID,
+			a.LockoutThreshold, a.LockoutWindow, a.LockoutDuration)
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to record failed login")
+		} else if lockedUntil != nil {
+			logger.FromContext(r.Context()).WithUserID(user.ID.String()).Warn("account locked after repeated failed logins")
+			h.accountLocked(w, r, *lockedUntil)
+			return
+		}
+	}
+	utils.Unauthorized(w, r, message)
+}
+
+// accountLocked responds 423 with a Retry-After for when the lock expires
+func (h *AuthHandler) accountLocked(w http.ResponseWriter, r *http.Request, until time.Time) {
+	retryAfter := int(math.Ceil(until.Sub(h.jwtManager.Now()).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	utils.Locked(w, r, "Account temporarily locked after repeated failed logins. Try again later or reset your password")
+}
+
 // Refresh handles token refresh requests
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		RefreshToken string `json:"refresh_token"`
-	}
+	var req models.RefreshTokenRequest
 
 	if err := utils.ParseJSON(r, &req); err != nil {
-		h.log.WithError(err).Error("invalid refresh payload")
-		utils.BadRequest(w, "Invalid request body")
+		logger.FromContext(r.Context()).WithError(err).Error("invalid refresh payload")
+		invalidBody(w, r, err)
 		return
 	}
 
 	if req.RefreshToken == "" {
-		utils.BadRequest(w, "refresh_token is required")
+		logValidationFailure(h.log, r, "refresh_token")
+		utils.BadRequest(w, r, "refresh_token is required")
 		return
 	}
 
 	// Validate refresh token
-	userIDStr, err := h.jwtManager.ValidateRefreshToken(req.RefreshToken)
+	claims, err := h.jwtManager.ValidateRefreshToken(req.RefreshToken)
+	if errors.Is(err, auth.ErrSessionExpired) {
+		logger.FromContext(r.Context()).WithError(err).Info("refresh refused past absolute session lifetime")
+		utils.Unauthorized(w, r, "Session expired, please log in again")
+		return
+	}
 	if err != nil {
-		h.log.WithError(err).Warn("refresh token validation failed")
-		utils.Unauthorized(w, "Invalid or expired refresh token")
+		logger.FromContext(r.Context()).WithError(err).Warn("refresh token validation failed")
+		utils.Unauthorized(w, r, "Invalid or expired refresh token")
 		return
 	}
 
 	// Parse user ID
-	userID, err := uuid.Parse(userIDStr)
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("invalid user ID in refresh token")
+		utils.Unauthorized(w, r, "Invalid token")
+		return
+	}
+
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Warn("refresh token without a valid jti")
+		utils.Unauthorized(w, r, "Invalid or expired refresh token")
+		return
+	}
+
+	record, err := h.repo.RefreshToken.GetByID(r.Context(), jti)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to fetch refresh token record")
+		utils.InternalServerError(w, r, "Failed to refresh token")
+		return
+	}
+	if record == nil || record.UserID != userID || record.RevokedAt != nil {
+		utils.Unauthorized(w, r, "Invalid or expired refresh token")
+		return
+	}
+
+	// Each refresh token is single use. Presenting a spent one means it was
+	// copied, so the whole family is revoked and the user must log in again.
+	fresh, err := h.repo.RefreshToken.MarkUsed(r.Context(), jti)
 	if err != nil {
-		h.log.WithError(err).Error("invalid user ID in refresh token")
-		utils.Unauthorized(w, "Invalid token")
+		logger.FromContext(r.Context()).WithError(err).Error("failed to mark refresh token used")
+		utils.InternalServerError(w, r, "Failed to refresh token")
+		return
+	}
+	if !fresh {
+		if err := h.repo.RefreshToken.RevokeFamily(r.Context(), record.FamilyID); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to revoke refresh token family")
+		}
+		logger.FromContext(r.Context()).WithUserID(userID.String()).Warn("refresh token reuse detected, session revoked")
+		utils.Unauthorized(w, r, "Refresh token reuse detected, please log in again")
 		return
 	}
 
 	// Fetch user from database
 	user, err := h.repo.User.GetByID(r.Context(), userID)
 	if err != nil {
-		h.log.WithError(err).Error("failed to fetch user during refresh")
-		utils.InternalServerError(w, "Failed to refresh token")
+		logger.FromContext(r.Context()).WithError(err).Error("failed to fetch user during refresh")
+		utils.InternalServerError(w, r, "Failed to refresh token")
 		return
 	}
 
 	if user == nil {
-		utils.Unauthorized(w, "User not found")
+		utils.Unauthorized(w, r, "User not found")
 		return
 	}
 
-	// Generate new token pair
-	accessToken, refreshToken, err := h.jwtManager.GenerateTokenPair(user.ID, user.Email)
+	// Rotate within the same family, keeping the original session start
+	tokens, err := h.issueTokens(r.Context(), user, record.FamilyID, claims.SessionStart.Time)
 	if err != nil {
-		h.log.WithError(err).Error("token generation failed during refresh")
-		utils.InternalServerError(w, "Failed to refresh token")
+		logger.FromContext(r.Context()).WithError(err).Error("token generation failed during refresh")
+		utils.InternalServerError(w, r, "Failed to refresh token")
 		return
 	}
 
 	// Return response matching existing AuthResponse format
 	utils.JSONSuccess(w, http.StatusOK, models.AuthResponse{
 		User: models.User{
-			ID:        user.ID,
-			Email:     user.Email,
-			Name:      user.Name,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
+			ID:            user.ID,
+			Email:         user.Email,
+			Name:          user.Name,
+			EmailVerified: user.EmailVerified,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
 		},
-		Token:        accessToken,
-		RefreshToken: refreshToken,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.jwtManager.AccessTokenTTL().Seconds()),
+	})
+}
+
+// ForgotPassword emails a password reset link if the address belongs to an
+// account. It always responds 200 so callers can't probe for registered emails.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		logValidationFailure(h.log, r, "email")
+		utils.BadRequest(w, r, "Email is required")
+		return
+	}
+
+	if err := h.sendPasswordReset(r.Context(), req.Email); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to send password reset")
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.MessageResponse{
+		Message: "If an account exists for that email, a reset link has been sent",
 	})
 }
+
+// sendPasswordReset issues a reset token for the account with email, if any
+func (h *AuthHandler) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := h.repo.User.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	return h.sendUserToken(ctx, user, auth.PurposePasswordReset,
+		h.config.Account.PasswordResetTTL, h.config.Account.PasswordResetURL,
+		"Reset your password", "Use this link to reset your password.")
+}
+
+// sendVerificationEmail issues an email verification token for user
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	return h.sendUserToken(ctx, user, auth.PurposeEmailVerification,
+		h.config.Account.EmailVerificationTTL, h.config.Account.EmailVerificationURL,
+		"Verify your email address", "Use this link to verify your email address.")
+}
+
+// sendUserToken records a new single-use token for purpose, replacing any
+// earlier unused one, and sends it to the user
+func (h *AuthHandler) sendUserToken(
+	ctx context.Context,
+	user *models.User,
+	purpose string,
+	ttl time.Duration,
+	baseURL, subject, intro string,
+) error {
+	token, claims, err := h.jwtManager.GeneratePurposeToken(user.ID, purpose, ttl)
+	if err != nil {
+		return err
+	}
+
+	if err := h.repo.UserToken.InvalidateForUser(ctx, user.ID, purpose); err != nil {
+		return err
+	}
+	if err := h.repo.UserToken.Create(ctx, &models.UserToken{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return err
+	}
+
+	link := token
+	if baseURL != "" {
+		link = baseURL + "?token=" + url.QueryEscape(token)
+	}
+
+	return h.sender.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    intro + " It expires in " + ttl.String() + ".\n\n" + link,
+	})
+}
+
+// consumeUserToken validates a single-use token for purpose and spends it,
+// returning the user it was issued to. ok is false for invalid, expired, or
+// already used tokens.
+func (h *AuthHandler) consumeUserToken(ctx context.Context, token, purpose string) (userID uuid.UUID, ok bool, err error) {
+	claims, err := h.jwtManager.ValidatePurposeToken(token, purpose)
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("single-use token validation failed")
+		return uuid.Nil, false, nil
+	}
+
+	userID, err = uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, false, nil
+	}
+
+	// Consume before changing anything so a concurrent second use fails
+	record, err := h.repo.UserToken.Consume(ctx, auth.HashToken(token), purpose)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	if record == nil || record.UserID != userID {
+		return uuid.Nil, false, nil
+	}
+
+	return userID, true, nil
+}
+
+// resetTokenEmail returns the email of the user a password reset token was
+// issued to, or "" if the token or user isn't valid, without consuming it.
+// It is only looked up when the policy needs it.
+func (h *AuthHandler) resetTokenEmail(ctx context.Context, token string) string {
+	if !h.policy.RejectEmail {
+		return ""
+	}
+	claims, err := h.jwtManager.ValidatePurposeToken(token, auth.PurposePasswordReset)
+	if err != nil {
+		return ""
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return ""
+	}
+	user, err := h.repo.User.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return ""
+	}
+	return user.Email
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword. The
+// token works once, and all existing sessions are signed out.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+
+	v := utils.NewValidator()
+	v.Required("token", req.Token)
+	v.Required("new_password", req.NewPassword)
+	if !v.IsValid() {
+		logValidationFailure(h.log, r, fieldNames(v.Errors)...)
+		utils.ValidationErrors(w, v.List)
+		return
+	}
+
+	if !h.validPassword(w, r, "new_password", req.NewPassword, h.resetTokenEmail(r.Context(), req.Token)) {
+		return
+	}
+
+	userID, ok, err := h.consumeUserToken(r.Context(), req.Token, auth.PurposePasswordReset)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to consume password reset token")
+		utils.InternalServerError(w, r, "Failed to reset password")
+		return
+	}
+	if !ok {
+		utils.BadRequest(w, r, "Invalid or expired reset token")
+		return
+	}
+
+	passwordHash, err := h.hasher.Hash(req.NewPassword)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("password hashing failed")
+		utils.InternalServerError(w, r, "Failed to reset password")
+		return
+	}
+
+	err = h.repo.User.UpdatePassword(r.Context(), userID, passwordHash)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Deleted since the request was authorized
+		utils.NotFound(w, r, "User not found")
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to update password")
+		utils.InternalServerError(w, r, "Failed to reset password")
+		return
+	}
+
+	// Whoever knew the old password may still hold a session
+	if err := h.repo.RefreshToken.RevokeAllForUser(r.Context(), userID); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to revoke sessions after password reset")
+	}
+	// Proving control of the email lifts a lockout
+	if err := h.repo.User.ResetFailedLogins(r.Context(), userID); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to reset failed logins after password reset")
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.MessageResponse{
+		Message: "Password has been reset",
+	})
+}
+
+// ChangePassword replaces the current user's password after checking the
+// current one. With logout_other_sessions set, every refresh token is revoked
+// and the caller is issued a new pair.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	if !validRequest(w, r, h.log, &req) {
+		return
+	}
+
+	user, err := h.repo.User.GetByID(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to get user for password change")
+		utils.InternalServerError(w, r, "Failed to change password")
+		return
+	}
+	if user == nil {
+		utils.NotFound(w, r, "User not found")
+		return
+	}
+
+	if err := h.hasher.Check(req.CurrentPassword, user.PasswordHash); err != nil {
+		logger.FromContext(r.Context()).WithUserID(userID.String()).Warn("password change with wrong current password")
+		utils.BadRequest(w, r, "Current password is incorrect")
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		logValidationFailure(h.log, r, "new_password")
+		utils.ValidationError(w, map[string]string{
+			"new_password": "new_password must differ from the current password",
+		})
+		return
+	}
+	if !h.validPassword(w, r, "new_password", req.NewPassword, user.Email) {
+		return
+	}
+
+	passwordHash, err := h.hasher.Hash(req.NewPassword)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("password hashing failed")
+		utils.InternalServerError(w, r, "Failed to change password")
+		return
+	}
+
+	err = h.repo.User.UpdatePassword(r.Context(), userID, passwordHash)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Deleted since the request was authorized
+		utils.NotFound(w, r, "User not found")
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to update password")
+		utils.InternalServerError(w, r, "Failed to change password")
+		return
+	}
+
+	resp := models.ChangePasswordResponse{Message: "Password has been changed"}
+	if req.LogoutOtherSessions {
+		if err := h.repo.RefreshToken.RevokeAllForUser(r.Context(), userID); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to revoke sessions after password change")
+			utils.InternalServerError(w, r, "Password changed, but other sessions could not be logged out")
+			return
+		}
+
+		tokens, err := h.issueTokens(r.Context(), user, uuid.New(), h.jwtManager.Now())
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("token generation failed")
+			utils.InternalServerError(w, r, "Password changed, please log in again")
+			return
+		}
+		resp.Token = tokens.AccessToken
+		resp.RefreshToken = tokens.RefreshToken
+		resp.TokenType = "Bearer"
+		resp.ExpiresIn = int(h.jwtManager.AccessTokenTTL().Seconds())
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, resp)
+}
+
+// VerifyEmail confirms the user's email address using a token sent at
+// registration or by ResendVerification. Each token works once.
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+
+	if req.Token == "" {
+		logValidationFailure(h.log, r, "token")
+		utils.BadRequest(w, r, "Token is required")
+		return
+	}
+
+	userID, ok, err := h.consumeUserToken(r.Context(), req.Token, auth.PurposeEmailVerification)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to consume email verification token")
+		utils.InternalServerError(w, r, "Failed to verify email")
+		return
+	}
+	if !ok {
+		utils.BadRequest(w, r, "Invalid or expired verification token")
+		return
+	}
+
+	if err := h.repo.User.MarkEmailVerified(r.Context(), userID); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to mark email verified")
+		utils.InternalServerError(w, r, "Failed to verify email")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.MessageResponse{
+		Message: "Email address verified",
+	})
+}
+
+// ResendVerification sends a new verification link to an unverified account.
+// Like ForgotPassword it always responds 200.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req models.ResendVerificationRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		logValidationFailure(h.log, r, "email")
+		utils.BadRequest(w, r, "Email is required")
+		return
+	}
+
+	user, err := h.repo.User.GetByEmail(r.Context(), req.Email)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to fetch user for verification resend")
+	} else if user != nil && !user.EmailVerified {
+		if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to send verification email")
+		}
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.MessageResponse{
+		Message: "If an unverified account exists for that email, a verification link has been sent",
+	})
+}
+
+// CheckPassword rates a candidate password against the policy without
+// storing anything, for live strength meters. The candidate is never logged.
+func (h *AuthHandler) CheckPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.CheckPasswordRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+
+	if req.Password == "" {
+		logValidationFailure(h.log, r, "password")
+		utils.BadRequest(w, r, "Password is required")
+		return
+	}
+
+	failed, score, err := h.policy.Check(r.Context(), req.Password, req.Email)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Warn("password breach lookup failed")
+	}
+	if failed == nil {
+		failed = []string{}
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.CheckPasswordResponse{
+		Valid:       len(failed) == 0,
+		Strength:    auth.StrengthLabel(score),
+		Score:       score,
+		FailedRules: failed,
+	})
+}
+
+// validPassword runs the password policy for the user with email, writing a
+// validation error naming the failed rules if it doesn't pass. A failed
+// breach lookup doesn't block.
+func (h *AuthHandler) validPassword(w http.ResponseWriter, r *http.Request, field, password, email string) bool {
+	failed, _, err := h.policy.Check(r.Context(), password, email)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Warn("password breach lookup failed")
+	}
+	if len(failed) == 0 {
+		return true
+	}
+	logValidationFailure(h.log, r, field)
+	utils.ValidationError(w, map[string]string{
+		field: "password does not meet policy: " + strings.Join(failed, ", "),
+	})
+	return false
+}
+
+// Me returns the current user's profile.
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.repo.User.GetByID(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to get current user")
+		utils.InternalServerError(w, r, "Failed to get profile")
+		return
+	}
+	if user == nil {
+		utils.NotFound(w, r, "User not found")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.UserResponse{User: user})
+}
+
+// UpdateProfile applies a partial update to the current user's name and/or email.
+// Omitted fields are left unchanged; only provided fields are validated.
+func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+
+	if req.Name == nil && req.Email == nil {
+		utils.BadRequest(w, r, "At least one of name or email is required")
+		return
+	}
+
+	v := utils.NewValidator()
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		req.Name = &name
+		v.Required("name", name)
+		v.MaxLength("name", name, 255)
+	}
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		req.Email = &email
+		v.Email("email", email)
+	}
+	if !v.IsValid() {
+		logValidationFailure(h.log, r, fieldNames(v.Errors)...)
+		utils.ValidationErrors(w, v.List)
+		return
+	}
+
+	h.saveProfile(w, r, userID, req.Email, func(ctx context.Context) (*models.User, error) {
+		return h.repo.User.UpdateFields(ctx, userID, repository.UserFields{
+			Name:  req.Name,
+			Email: req.Email,
+		})
+	})
+}
+
+// ReplaceProfile sets both the current user's name and email.
+func (h *AuthHandler) ReplaceProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ReplaceProfileRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.TrimSpace(req.Email)
+	if !validRequest(w, r, h.log, &req) {
+		return
+	}
+
+	h.saveProfile(w, r, userID, &req.Email, func(ctx context.Context) (*models.User, error) {
+		return h.repo.User.Update(ctx, userID, req.Name, req.Email)
+	})
+}
+
+// saveProfile runs save once email, if set, is known not to belong to another
+// account, and responds with the updated user. A changed address is sent a
+// verification link.
+func (h *AuthHandler) saveProfile(w http.ResponseWriter, r *http.Request, userID uuid.UUID, email *string, save func(ctx context.Context) (*models.User, error)) {
+	// Email must stay unique across accounts.
+	if email != nil {
+		existingUser, err := h.repo.User.GetByEmail(r.Context(), *email)
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to check existing user")
+			utils.InternalServerError(w, r, "Failed to update profile")
+			return
+		}
+		if existingUser != nil && existingUser.ID != userID {
+			utils.Conflict(w, r, "Email is already in use")
+			return
+		}
+	}
+
+	user, err := save(r.Context())
+	if errors.Is(err, repository.ErrDuplicate) {
+		// Taken concurrently since the check above
+		utils.Conflict(w, r, "Email is already in use")
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to update profile")
+		utils.InternalServerError(w, r, "Failed to update profile")
+		return
+	}
+	if user == nil {
+		utils.NotFound(w, r, "User not found")
+		return
+	}
+
+	// A changed address starts unverified; send a link for the new one
+	if email != nil && !user.EmailVerified && h.config.Account.RequireEmailVerification {
+		if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to send verification email")
+		}
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.UserResponse{User: user})
+}
+
+// DeleteAccount soft-deletes the current user and their tasks and signs out
+// every session. Outstanding access tokens stop working at once, since the
+// auth middleware's token version lookup finds no live user.
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.repo.User.SoftDelete(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to delete account")
+		utils.InternalServerError(w, r, "Failed to delete account")
+		return
+	}
+	if !deleted {
+		utils.NotFound(w, r, "User not found")
+		return
+	}
+
+	if err := h.repo.RefreshToken.RevokeAllForUser(r.Context(), userID); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to revoke sessions after account deletion")
+	}
+	if claims, ok := middleware.GetClaimsFromContext(r.Context()); ok && claims.ExpiresAt != nil {
+		h.jwtManager.Revoke(claims.ID, claims.ExpiresAt.Time)
+	}
+
+	logger.FromContext(r.Context()).WithUserID(userID.String()).Info("account deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Usage reports the current user's usage against each configured quota
+func (h *AuthHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	taskCount, err := h.repo.Task.CountByUser(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to count tasks for usage")
+		utils.InternalServerError(w, r, "Failed to get usage")
+		return
+	}
+
+	tasks := models.QuotaUsage{Name: "tasks", Used: taskCount}
+	if limit := h.config.Task.MaxPerUser; limit > 0 {
+		tasks.Limit = &limit
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.UsageResponse{
+		Quotas: []models.QuotaUsage{tasks},
+	})
+}
+
+// Logout revokes the access token used for the request and, if provided,
+// the refresh token, so neither can be used again before expiry.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaimsFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, r, "User not authenticated")
+		return
+	}
+
+	var req models.RefreshTokenRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			invalidBody(w, r, err)
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		refreshClaims, err := h.jwtManager.ValidateRefreshToken(req.RefreshToken)
+		if err != nil && !errors.Is(err, auth.ErrSessionExpired) {
+			logger.FromContext(r.Context()).WithError(err).Warn("invalid refresh token on logout")
+			utils.BadRequest(w, r, "Invalid refresh token")
+			return
+		}
+		if refreshClaims != nil {
+			if refreshClaims.Subject != claims.UserID {
+				utils.BadRequest(w, r, "Refresh token does not belong to the current user")
+				return
+			}
+			h.jwtManager.Revoke(refreshClaims.ID, refreshClaims.ExpiresAt.Time)
+			if familyID, err := uuid.Parse(refreshClaims.FamilyID); err == nil {
+				if err := h.repo.RefreshToken.RevokeFamily(r.Context(), familyID); err != nil {
+					logger.FromContext(r.Context()).WithError(err).Error("failed to revoke refresh token family")
+					utils.InternalServerError(w, r, "Failed to logout")
+					return
+				}
+			}
+		}
+	}
+
+	if claims.ExpiresAt != nil {
+		h.jwtManager.Revoke(claims.ID, claims.ExpiresAt.Time)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll signs the current user out everywhere: every refresh token is
+// revoked and the token version bump rejects every access token issued so far,
+// including the one used for this request.
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.repo.WithTx(r.Context(), func(tx *repository.Repository) error {
+		if err := tx.RefreshToken.RevokeAllForUser(r.Context(), userID); err != nil {
+			return err
+		}
+		_, err := tx.User.BumpTokenVersion(r.Context(), userID)
+		return err
+	})
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to revoke all sessions")
+		utils.InternalServerError(w, r, "Failed to logout")
+		return
+	}
+
+	logger.FromContext(r.Context()).WithUserID(userID.String()).Info("all sessions revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueTokens creates a token pair for user and records the refresh token so
+// it can be rotated and checked for reuse
+func (h *AuthHandler) issueTokens(ctx context.Context, user *models.User, familyID uuid.UUID, sessionStart time.Time) (*auth.TokenPair, error) {
+	tokens, err := h.jwtManager.IssueTokenPair(user.ID, user.Email, string(user.Role), user.TokenVersion, familyID, sessionStart)
+	if err != nil {
+		return nil, err
+	}
+
+	jti, err := uuid.Parse(tokens.RefreshClaims.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.RefreshToken.Create(ctx, &models.RefreshToken{
+		ID:        jti,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ExpiresAt: tokens.RefreshClaims.ExpiresAt.Time,
+	}); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
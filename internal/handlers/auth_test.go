@@ -0,0 +1,1175 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/notify"
+	"secure-task-api/internal/repository"
+)
+
+// memoryUserRepo keeps users in memory, with emails unique among them
+type memoryUserRepo struct {
+	fakeUserRepo
+	clock      clock.Clock
+	users      map[uuid.UUID]*models.User
+	lastFailed map[uuid.UUID]time.Time
+}
+
+func newMemoryUserRepo(clk clock.Clock) *memoryUserRepo {
+	return &memoryUserRepo{clock: clk, users: map[uuid.UUID]*models.User{}, lastFailed: map[uuid.UUID]time.Time{}}
+}
+
+// add stores user with password hashed at the minimum bcrypt cost
+func (f *memoryUserRepo) add(t *testing.T, user *models.User, password string) *models.User {
+	t.Helper()
+	hash, err := auth.BcryptHasher{Cost: bcrypt.MinCost}.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	if user.Role == "" {
+		user.Role = models.UserRoleUser
+	}
+	user.PasswordHash = hash
+	f.users[user.ID] = user
+	return user
+}
+
+func (f *memoryUserRepo) Create(ctx context.Context, user *models.User) error {
+	if existing, _ := f.GetByEmail(ctx, user.Email); existing != nil {
+		return repository.ErrDuplicate
+	}
+	user.ID = uuid.New()
+	user.Role = models.UserRoleUser
+	user.CreatedAt = f.clock.Now()
+	user.UpdatedAt = user.CreatedAt
+	stored := *user
+	f.users[user.ID] = &stored
+	return nil
+}
+
+func (f *memoryUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, user := range f.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *memoryUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *memoryUserRepo) UpdateFields(ctx context.Context, id uuid.UUID, fields repository.UserFields) (*models.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, nil
+	}
+	if fields.Email != nil && *fields.Email != user.Email {
+		if existing, _ := f.GetByEmail(ctx, *fields.Email); existing != nil {
+			return nil, repository.ErrDuplicate
+		}
+		user.Email, user.EmailVerified = *fields.Email, false
+	}
+	if fields.Name != nil {
+		user.Name = *fields.Name
+	}
+	user.UpdatedAt = f.clock.Now()
+	copied := *user
+	return &copied, nil
+}
+
+func (f *memoryUserRepo) Update(ctx context.Context, id uuid.UUID, name, email string) (*models.User, error) {
+	return f.UpdateFields(ctx, id, repository.UserFields{Name: &name, Email: &email})
+}
+
+// SoftDelete drops the user, whom lookups then no longer find
+func (f *memoryUserRepo) SoftDelete(ctx context.Context, id uuid.UUID) (bool, error) {
+	if _, ok := f.users[id]; !ok {
+		return false, nil
+	}
+	delete(f.users, id)
+	return true, nil
+}
+
+func (f *memoryUserRepo) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	user, ok := f.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+func (f *memoryUserRepo) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	if user, ok := f.users[id]; ok {
+		user.EmailVerified = true
+	}
+	return nil
+}
+
+// RecordFailedLogin counts failures as UserRepository.RecordFailedLogin does:
+// a failure more than window after the previous one restarts the count, and
+// reaching threshold locks the account and clears the count
+func (f *memoryUserRepo) RecordFailedLogin(ctx context.Context, id uuid.UUID, threshold int, window, lockout time.Duration) (*time.Time, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	now := f.clock.Now()
+	if f.lastFailed[id].Before(now.Add(-window)) {
+		user.FailedLoginAttempts = 0
+	}
+	f.lastFailed[id] = now
+	user.FailedLoginAttempts++
+	if user.FailedLoginAttempts < threshold {
+		return nil, nil
+	}
+	until := now.Add(lockout)
+	user.FailedLoginAttempts, user.LockedUntil = 0, &until
+	return &until, nil
+}
+
+func (f *memoryUserRepo) ResetFailedLogins(ctx context.Context, id uuid.UUID) error {
+	if user, ok := f.users[id]; ok {
+		user.FailedLoginAttempts, user.LockedUntil = 0, nil
+		delete(f.lastFailed, id)
+	}
+	return nil
+}
+
+func (f *memoryUserRepo) GetTokenVersion(ctx context.Context, id uuid.UUID) (int, bool, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return 0, false, nil
+	}
+	return user.TokenVersion, true, nil
+}
+
+func (f *memoryUserRepo) BumpTokenVersion(ctx context.Context, id uuid.UUID) (int, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	user.TokenVersion++
+	return user.TokenVersion, nil
+}
+
+// memoryUserTokenRepo keeps single-use tokens in memory, expiring them by clock
+type memoryUserTokenRepo struct {
+	clock  clock.Clock
+	tokens map[string]*models.UserToken
+}
+
+func newMemoryUserTokenRepo(clk clock.Clock) *memoryUserTokenRepo {
+	return &memoryUserTokenRepo{clock: clk, tokens: map[string]*models.UserToken{}}
+}
+
+func (f *memoryUserTokenRepo) Create(ctx context.Context, token *models.UserToken) error {
+	token.CreatedAt = f.clock.Now()
+	stored := *token
+	f.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (f *memoryUserTokenRepo) active(tokenHash, purpose string) *models.UserToken {
+	token, ok := f.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || !token.ExpiresAt.After(f.clock.Now()) {
+		return nil
+	}
+	return token
+}
+
+func (f *memoryUserTokenRepo) Consume(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error) {
+	token := f.active(tokenHash, purpose)
+	if token == nil {
+		return nil, nil
+	}
+	now := f.clock.Now()
+	token.UsedAt = &now
+	copied := *token
+	return &copied, nil
+}
+
+func (f *memoryUserTokenRepo) GetActive(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error) {
+	token := f.active(tokenHash, purpose)
+	if token == nil {
+		return nil, nil
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (f *memoryUserTokenRepo) InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string) error {
+	now := f.clock.Now()
+	for _, token := range f.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// sessionRepo records refresh tokens and which users had every session revoked
+type sessionRepo struct {
+	fakeRefreshTokenRepo
+	tokens       map[uuid.UUID]*models.RefreshToken
+	revokedUsers []uuid.UUID
+}
+
+func (f *sessionRepo) Create(ctx context.Context, token *models.RefreshToken) error {
+	if f.tokens == nil {
+		f.tokens = map[uuid.UUID]*models.RefreshToken{}
+	}
+	stored := *token
+	f.tokens[token.ID] = &stored
+	return nil
+}
+
+func (f *sessionRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.RefreshToken, error) {
+	token, ok := f.tokens[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (f *sessionRepo) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	token, ok := f.tokens[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+func (f *sessionRepo) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	now := time.Now()
+	for _, token := range f.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *sessionRepo) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	f.revokedUsers = append(f.revokedUsers, userID)
+	now := time.Now()
+	for _, token := range f.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// outbox collects the messages a handler sends
+type outbox struct {
+	sent []notify.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg notify.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// lastToken returns the token linked in the last message sent to email
+func (o *outbox) lastToken(t *testing.T, email string) string {
+	t.Helper()
+	for i := len(o.sent) - 1; i >= 0; i-- {
+		if o.sent[i].To == email {
+			lines := strings.Split(o.sent[i].Body, "\n")
+			return lines[len(lines)-1]
+		}
+	}
+	t.Fatalf("no message sent to %s", email)
+	return ""
+}
+
+// authFixture is the auth routes over in-memory repositories on a fake clock
+type authFixture struct {
+	handler    http.Handler
+	clock      *clock.Fake
+	jwtManager *auth.JWTManager
+	users      *memoryUserRepo
+	userTokens *memoryUserTokenRepo
+	sessions   *sessionRepo
+	tasks      *memoryTaskRepo
+	outbox     *outbox
+}
+
+// newAuthFixture serves the auth routes with cfg, filling in the account
+// settings LoadConfig would default
+func newAuthFixture(t *testing.T, cfg *config.Config) *authFixture {
+	t.Helper()
+	log := testLogger(t)
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	if cfg.Account.PasswordResetTTL == 0 {
+		cfg.Account.PasswordResetTTL = 30 * time.Minute
+	}
+	if cfg.Account.EmailVerificationTTL == 0 {
+		cfg.Account.EmailVerificationTTL = 24 * time.Hour
+	}
+
+	jwtManager := auth.NewJWTManager("test-secret", 15*time.Minute, 24*time.Hour,
+		auth.WithClock(clk), auth.WithRevocationStore(auth.NewMemoryRevocationStore(clk)),
+		auth.WithSessionMaxLifetime(cfg.JWT.SessionMaxLifetime))
+
+	f := &authFixture{
+		clock:      clk,
+		jwtManager: jwtManager,
+		users:      newMemoryUserRepo(clk),
+		userTokens: newMemoryUserTokenRepo(clk),
+		sessions:   &sessionRepo{},
+		tasks:      &memoryTaskRepo{},
+		outbox:     &outbox{},
+	}
+	repo := &repository.Repository{User: f.users, UserToken: f.userTokens, RefreshToken: f.sessions, Task: f.tasks}
+	policy := &auth.PasswordPolicy{MinLength: 8}
+	if cfg.Password.MinLength > 0 {
+		blocklist, err := auth.LoadPasswordBlocklist(cfg.Password.BlocklistFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy = newPasswordPolicy(cfg.Password, blocklist, cfg.Outbound)
+	}
+	h := NewAuthHandler(cfg, repo, f.jwtManager, middleware.AuthMiddleware(f.jwtManager, f.users, log),
+		f.outbox, policy, auth.BcryptHasher{Cost: bcrypt.MinCost}, log)
+	router := chi.NewRouter()
+	router.Route("/auth", h.RegisterRoutes)
+	f.handler = router
+	return f
+}
+
+// post sends body to an auth route without a token
+func (f *authFixture) post(path, body string) (int, string) {
+	rec := sendJSON(f.handler, http.MethodPost, "/auth"+path, "", body)
+	return rec.Code, rec.Body.String()
+}
+
+func TestPasswordReset(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "old-password")
+
+	if code, body := f.post("/forgot-password", `{"email": "user@example.com"}`); code != http.StatusOK {
+		t.Fatalf("forgot-password: status %d, want 200: %s", code, body)
+	}
+	token := f.outbox.lastToken(t, user.Email)
+
+	if code, body := f.post("/reset-password", `{"token": "`+token+`", "new_password": "new-password"}`); code != http.StatusOK {
+		t.Fatalf("reset-password: status %d, want 200: %s", code, body)
+	}
+	if auth.CheckPassword("new-password", f.users.users[user.ID].PasswordHash) != nil {
+		t.Fatal("password not changed")
+	}
+	if len(f.sessions.revokedUsers) != 1 || f.sessions.revokedUsers[0] != user.ID {
+		t.Fatalf("revoked sessions of %v, want the user's", f.sessions.revokedUsers)
+	}
+
+	// The token is single-use
+	if code, _ := f.post("/reset-password", `{"token": "`+token+`", "new_password": "other-password"}`); code != http.StatusBadRequest {
+		t.Fatalf("reused token: status %d, want 400", code)
+	}
+	if auth.CheckPassword("new-password", f.users.users[user.ID].PasswordHash) != nil {
+		t.Fatal("reused token changed the password")
+	}
+
+	if code, body := f.post("/login", `{"email": "user@example.com", "password": "new-password"}`); code != http.StatusOK {
+		t.Fatalf("login with the new password: status %d, want 200: %s", code, body)
+	}
+}
+
+func TestPasswordResetExpiredToken(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com"}, "old-password")
+
+	f.post("/forgot-password", `{"email": "user@example.com"}`)
+	token := f.outbox.lastToken(t, user.Email)
+	f.clock.Advance(31 * time.Minute)
+
+	if code, _ := f.post("/reset-password", `{"token": "`+token+`", "new_password": "new-password"}`); code != http.StatusBadRequest {
+		t.Fatalf("expired token: status %d, want 400", code)
+	}
+	if auth.CheckPassword("old-password", f.users.users[user.ID].PasswordHash) != nil {
+		t.Fatal("expired token changed the password")
+	}
+}
+
+func TestPasswordResetOnlyLatestTokenWorks(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com"}, "old-password")
+
+	f.post("/forgot-password", `{"email": "user@example.com"}`)
+	first := f.outbox.lastToken(t, user.Email)
+	f.clock.Advance(time.Second)
+	f.post("/forgot-password", `{"email": "user@example.com"}`)
+	second := f.outbox.lastToken(t, user.Email)
+
+	if code, _ := f.post("/reset-password", `{"token": "`+first+`", "new_password": "new-password"}`); code != http.StatusBadRequest {
+		t.Fatalf("superseded token: status %d, want 400", code)
+	}
+	if code, body := f.post("/reset-password", `{"token": "`+second+`", "new_password": "new-password"}`); code != http.StatusOK {
+		t.Fatalf("latest token: status %d, want 200: %s", code, body)
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+
+	// Same answer as for a registered email, and nothing sent
+	if code, _ := f.post("/forgot-password", `{"email": "nobody@example.com"}`); code != http.StatusOK {
+		t.Fatalf("unknown email: status %d, want 200", code)
+	}
+	if len(f.outbox.sent) != 0 {
+		t.Fatalf("sent %d messages, want none", len(f.outbox.sent))
+	}
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Account.RequireEmailVerification = true
+	f := newAuthFixture(t, cfg)
+	login := `{"email": "new@example.com", "password": "long-password"}`
+
+	rec := sendJSON(f.handler, http.MethodPost, "/auth/register", "",
+		`{"email": "new@example.com", "password": "long-password", "name": "New User"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), `"token"`) {
+		t.Fatalf("unverified registration issued tokens: %s", rec.Body)
+	}
+	if pending := responseData[models.PendingVerificationResponse](t, rec.Body.Bytes()); pending.User.EmailVerified {
+		t.Fatal("new account already verified")
+	}
+
+	if code, _ := f.post("/login", login); code != http.StatusForbidden {
+		t.Fatalf("login before verifying: status %d, want 403", code)
+	}
+
+	token := f.outbox.lastToken(t, "new@example.com")
+	if code, body := f.post("/verify-email", `{"token": "`+token+`"}`); code != http.StatusOK {
+		t.Fatalf("verify-email: status %d, want 200: %s", code, body)
+	}
+	if code, _ := f.post("/verify-email", `{"token": "`+token+`"}`); code != http.StatusBadRequest {
+		t.Fatalf("reused verification token: status %d, want 400", code)
+	}
+
+	code, body := f.post("/login", login)
+	if code != http.StatusOK {
+		t.Fatalf("login after verifying: status %d, want 200: %s", code, body)
+	}
+	if resp := responseData[models.AuthResponse](t, []byte(body)); resp.Token == "" || !resp.User.EmailVerified {
+		t.Fatalf("login = %+v, want a token for a verified user", resp)
+	}
+}
+
+func TestLoginWithoutVerificationRequired(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	f.users.add(t, &models.User{Email: "user@example.com"}, "long-password")
+
+	if code, body := f.post("/login", `{"email": "user@example.com", "password": "long-password"}`); code != http.StatusOK {
+		t.Fatalf("unverified login with verification off: status %d, want 200: %s", code, body)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Account.RequireEmailVerification = true
+	f := newAuthFixture(t, cfg)
+	f.users.add(t, &models.User{Email: "unverified@example.com"}, "long-password")
+	f.users.add(t, &models.User{Email: "verified@example.com", EmailVerified: true}, "long-password")
+
+	if code, _ := f.post("/resend-verification", `{"email": "unverified@example.com"}`); code != http.StatusOK {
+		t.Fatalf("resend: status %d, want 200", code)
+	}
+	token := f.outbox.lastToken(t, "unverified@example.com")
+	if code, body := f.post("/verify-email", `{"token": "`+token+`"}`); code != http.StatusOK {
+		t.Fatalf("verify with resent token: status %d, want 200: %s", code, body)
+	}
+
+	// Verified and unknown addresses get the same answer and nothing is sent
+	sent := len(f.outbox.sent)
+	for _, email := range []string{"verified@example.com", "nobody@example.com"} {
+		if code, _ := f.post("/resend-verification", `{"email": "`+email+`"}`); code != http.StatusOK {
+			t.Fatalf("resend to %s: status %d, want 200", email, code)
+		}
+	}
+	if len(f.outbox.sent) != sent {
+		t.Fatalf("sent %d more messages, want none", len(f.outbox.sent)-sent)
+	}
+}
+
+// racedUserRepo misses an account registered concurrently at the email
+// check, leaving Create to hit the unique index
+type racedUserRepo struct {
+	*memoryUserRepo
+}
+
+func (racedUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return nil, nil
+}
+
+func TestRegisterDuplicateEmailRace(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	f.users.add(t, &models.User{Email: "taken@example.com"}, "long-password")
+	log := testLogger(t)
+	repo := &repository.Repository{User: racedUserRepo{f.users}, UserToken: f.userTokens, RefreshToken: f.sessions}
+	h := NewAuthHandler(&config.Config{}, repo, f.jwtManager, nil, f.outbox,
+		&auth.PasswordPolicy{MinLength: 8}, auth.BcryptHasher{Cost: bcrypt.MinCost}, log)
+
+	rec := sendJSON(http.HandlerFunc(h.Register), http.MethodPost, "/auth/register", "",
+		`{"email": "taken@example.com", "password": "long-password", "name": "Second"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d, want 409: %s", rec.Code, rec.Body)
+	}
+	if len(f.users.users) != 1 {
+		t.Fatalf("%d users, want the first registration only", len(f.users.users))
+	}
+}
+
+func TestRegisterExistingEmail(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	f.users.add(t, &models.User{Email: "taken@example.com"}, "long-password")
+
+	if code, _ := f.post("/register", `{"email": "taken@example.com", "password": "long-password", "name": "Second"}`); code != http.StatusConflict {
+		t.Fatalf("status %d, want 409", code)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "password")
+	token, err := f.jwtManager.GenerateAccessToken(user.ID, user.Email, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := sendJSON(f.handler, http.MethodGet, "/auth/me", token, ""); rec.Code != http.StatusOK {
+		t.Fatalf("before logout: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if rec := sendJSON(f.handler, http.MethodPost, "/auth/logout", token, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("logout: status %d, want 204: %s", rec.Code, rec.Body)
+	}
+	if rec := sendJSON(f.handler, http.MethodGet, "/auth/me", token, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("after logout: status %d, want 401", rec.Code)
+	}
+}
+
+func TestUsageReflectsCreatedTasks(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{Task: config.TaskConfig{MaxPerUser: 10}})
+	user := f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "password")
+	token, err := f.jwtManager.GenerateAccessToken(user.ID, user.Email, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage := func() models.QuotaUsage {
+		t.Helper()
+		rec := sendJSON(f.handler, http.MethodGet, "/auth/me/usage", token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("usage: status %d, want 200: %s", rec.Code, rec.Body)
+		}
+		resp := responseData[models.UsageResponse](t, rec.Body.Bytes())
+		if len(resp.Quotas) != 1 || resp.Quotas[0].Name != "tasks" {
+			t.Fatalf("quotas = %+v, want the task quota", resp.Quotas)
+		}
+		return resp.Quotas[0]
+	}
+
+	if q := usage(); q.Used != 0 || q.Limit == nil || *q.Limit != 10 {
+		t.Fatalf("before creating: %+v, want 0 of 10", q)
+	}
+
+	tasks := []*models.Task{{Title: "Write report", UserID: user.ID}, {Title: "File expenses", UserID: user.ID}}
+	if err := f.tasks.CreateWithinLimits(context.Background(), user.ID, tasks, repository.TaskLimits{}); err != nil {
+		t.Fatal(err)
+	}
+	// Another user's tasks don't count
+	otherID := uuid.New()
+	if err := f.tasks.CreateWithinLimits(context.Background(), otherID, []*models.Task{{Title: "Other", UserID: otherID}}, repository.TaskLimits{}); err != nil {
+		t.Fatal(err)
+	}
+	if q := usage(); q.Used != 2 || *q.Limit != 10 {
+		t.Fatalf("after creating two: %+v, want 2 of 10", q)
+	}
+}
+
+func TestUpdateProfileFields(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com", Name: "Old Name", EmailVerified: true}, "password")
+	f.users.add(t, &models.User{Email: "taken@example.com"}, "password")
+	token, err := f.jwtManager.GenerateAccessToken(user.ID, user.Email, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := func(body string) *httptest.ResponseRecorder {
+		return sendJSON(f.handler, http.MethodPatch, "/auth/me", token, body)
+	}
+
+	rec := patch(`{"name": "  New Name  "}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("name only: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.UserResponse](t, rec.Body.Bytes()).User; got.Name != "New Name" || got.Email != "user@example.com" || !got.EmailVerified {
+		t.Fatalf("name only: user = %+v, want the trimmed name and the email untouched", got)
+	}
+
+	rec = patch(`{"email": "new@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("email only: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.UserResponse](t, rec.Body.Bytes()).User; got.Email != "new@example.com" || got.Name != "New Name" || got.EmailVerified {
+		t.Fatalf("email only: user = %+v, want the new email unverified and the name untouched", got)
+	}
+
+	// Only provided fields are validated; a provided empty name is not omitted
+	for _, tt := range []struct {
+		body  string
+		code  int
+		field string
+	}{
+		{`{"name": ""}`, http.StatusBadRequest, `"name"`},
+		{`{"name": "   "}`, http.StatusBadRequest, `"name"`},
+		{`{"name": "` + strings.Repeat("n", 256) + `"}`, http.StatusBadRequest, `"name"`},
+		{`{"email": "not-an-email"}`, http.StatusBadRequest, `"email"`},
+		{`{}`, http.StatusBadRequest, "At least one of name or email is required"},
+		{`{"email": "taken@example.com"}`, http.StatusConflict, "Email is already in use"},
+	} {
+		rec := patch(tt.body)
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.field) {
+			t.Errorf("%s: status %d, body %s, want %d mentioning %s", tt.body, rec.Code, rec.Body, tt.code, tt.field)
+		}
+	}
+	if got := f.users.users[user.ID]; got.Name != "New Name" || got.Email != "new@example.com" {
+		t.Fatalf("rejected updates changed the user: %+v", got)
+	}
+}
+
+func TestUpdateProfileDuplicateEmailRace(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com", Name: "User"}, "password")
+	f.users.add(t, &models.User{Email: "taken@example.com"}, "password")
+	log := testLogger(t)
+	repo := &repository.Repository{User: racedUserRepo{f.users}, UserToken: f.userTokens, RefreshToken: f.sessions}
+	h := NewAuthHandler(&config.Config{}, repo, f.jwtManager, middleware.AuthMiddleware(f.jwtManager, nil, log), f.outbox,
+		&auth.PasswordPolicy{MinLength: 8}, auth.BcryptHasher{Cost: bcrypt.MinCost}, log)
+	router := chi.NewRouter()
+	router.Route("/auth", h.RegisterRoutes)
+	token, err := f.jwtManager.GenerateAccessToken(user.ID, user.Email, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The email check misses the other account, so the update hits the unique index
+	rec := sendJSON(router, http.MethodPatch, "/auth/me", token, `{"email": "taken@example.com"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d, want 409: %s", rec.Code, rec.Body)
+	}
+	if f.users.users[user.ID].Email != "user@example.com" {
+		t.Fatal("email changed despite the conflict")
+	}
+}
+
+// login logs in with email and password, failing the test unless it succeeds
+func (f *authFixture) login(t *testing.T, email, password string) models.AuthResponse {
+	t.Helper()
+	code, body := f.post("/login", `{"email": "`+email+`", "password": "`+password+`"}`)
+	if code != http.StatusOK {
+		t.Fatalf("login: status %d, want 200: %s", code, body)
+	}
+	return responseData[models.AuthResponse](t, []byte(body))
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "long-password")
+	// A second session, which the reuse must leave alone
+	other := f.login(t, "user@example.com", "long-password")
+	stolen := f.login(t, "user@example.com", "long-password").RefreshToken
+
+	code, body := f.post("/refresh", `{"refresh_token": "`+stolen+`"}`)
+	if code != http.StatusOK {
+		t.Fatalf("first refresh: status %d, want 200: %s", code, body)
+	}
+	rotated := responseData[models.AuthResponse](t, []byte(body)).RefreshToken
+	if rotated == "" || rotated == stolen {
+		t.Fatalf("refresh returned %q, want a new refresh token", rotated)
+	}
+
+	// The spent token presented again revokes its whole family
+	code, body = f.post("/refresh", `{"refresh_token": "`+stolen+`"}`)
+	if code != http.StatusUnauthorized || !strings.Contains(body, "reuse detected") {
+		t.Fatalf("replayed refresh: status %d, want 401 for reuse: %s", code, body)
+	}
+	if code, _ := f.post("/refresh", `{"refresh_token": "`+rotated+`"}`); code != http.StatusUnauthorized {
+		t.Fatalf("rotated token after reuse: status %d, want 401", code)
+	}
+
+	if code, body := f.post("/refresh", `{"refresh_token": "`+other.RefreshToken+`"}`); code != http.StatusOK {
+		t.Fatalf("other session: status %d, want 200: %s", code, body)
+	}
+}
+
+func TestRefreshSessionMaxLifetime(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.JWT.SessionMaxLifetime = 36 * time.Hour
+	f := newAuthFixture(t, cfg)
+	f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "long-password")
+	token := f.login(t, "user@example.com", "long-password").RefreshToken
+
+	refresh := func() (int, string) {
+		t.Helper()
+		code, body := f.post("/refresh", `{"refresh_token": "`+token+`"}`)
+		if code == http.StatusOK {
+			token = responseData[models.AuthResponse](t, []byte(body)).RefreshToken
+		}
+		return code, body
+	}
+
+	// Rotating keeps each refresh token fresh; the session is capped anyway
+	f.clock.Advance(20 * time.Hour)
+	if code, body := refresh(); code != http.StatusOK {
+		t.Fatalf("after 20h: status %d, want 200: %s", code, body)
+	}
+	f.clock.Advance(16*time.Hour - time.Second)
+	if code, body := refresh(); code != http.StatusOK {
+		t.Fatalf("just under the limit: status %d, want 200: %s", code, body)
+	}
+	f.clock.Advance(2 * time.Second)
+	code, body := refresh()
+	if code != http.StatusUnauthorized || !strings.Contains(body, "Session expired") {
+		t.Fatalf("just over the limit: status %d, want 401 session expired: %s", code, body)
+	}
+}
+
+func TestPasswordPolicyOnRegisterAndReset(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Password = config.PasswordConfig{MinLength: 10, RequireUpper: true, RequireDigit: true, RequireSymbol: true, RejectEmail: true}
+	f := newAuthFixture(t, cfg)
+
+	code, body := f.post("/register", `{"email": "jane@example.com", "password": "weakpass", "name": "Jane"}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("weak password: status %d, want 400: %s", code, body)
+	}
+	for _, rule := range []string{auth.RuleMinLength, auth.RuleUpper, auth.RuleDigit, auth.RuleSymbol} {
+		if !strings.Contains(body, rule) {
+			t.Errorf("error %s does not name the %s rule", body, rule)
+		}
+	}
+	if user, _ := f.users.GetByEmail(context.Background(), "jane@example.com"); user != nil {
+		t.Fatal("user created with a weak password")
+	}
+
+	if code, body := f.post("/register", `{"email": "Jane-Doe.1@example.com", "password": "Jane-Doe.1", "name": "Jane"}`); code != http.StatusBadRequest || !strings.Contains(body, auth.RuleNotEmail) {
+		t.Fatalf("email as password: status %d, want 400 naming %s: %s", code, auth.RuleNotEmail, body)
+	}
+
+	if code, body := f.post("/register", `{"email": "jane@example.com", "password": "Correct-Horse-42", "name": "Jane"}`); code != http.StatusCreated {
+		t.Fatalf("strong password: status %d, want 201: %s", code, body)
+	}
+
+	// Resetting applies the same rules
+	f.users.add(t, &models.User{Email: "reset@example.com", EmailVerified: true}, "Old-Password-1")
+	if code, body := f.post("/forgot-password", `{"email": "reset@example.com"}`); code != http.StatusOK {
+		t.Fatalf("forgot-password: status %d, want 200: %s", code, body)
+	}
+	token := f.outbox.lastToken(t, "reset@example.com")
+	if code, body := f.post("/reset-password", `{"token": "`+token+`", "new_password": "weakpass"}`); code != http.StatusBadRequest || !strings.Contains(body, "new_password") {
+		t.Fatalf("weak reset: status %d, want 400 naming new_password: %s", code, body)
+	}
+}
+
+func TestRegisterRejectsBlocklistedPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# common passwords\npassword123\nletmein-now\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.Password = config.PasswordConfig{MinLength: 8, BlocklistFile: path}
+	f := newAuthFixture(t, cfg)
+
+	code, body := f.post("/register", `{"email": "jane@example.com", "password": "PASSWORD123", "name": "Jane"}`)
+	if code != http.StatusBadRequest || !strings.Contains(body, auth.RuleNotCommon) {
+		t.Fatalf("blocklisted password: status %d, want 400 naming %s: %s", code, auth.RuleNotCommon, body)
+	}
+	if code, body := f.post("/register", `{"email": "jane@example.com", "password": "password1234", "name": "Jane"}`); code != http.StatusCreated {
+		t.Fatalf("unlisted password: status %d, want 201: %s", code, body)
+	}
+}
+
+func TestLogoutAllRejectsEarlierTokens(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "password")
+	laptop := f.login(t, "user@example.com", "password")
+	phone := f.login(t, "user@example.com", "password")
+
+	if rec := sendJSON(f.handler, http.MethodPost, "/auth/logout-all", laptop.Token, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("logout-all: status %d, want 204: %s", rec.Code, rec.Body)
+	}
+	if len(f.sessions.revokedUsers) != 1 || f.sessions.revokedUsers[0] != user.ID {
+		t.Fatalf("revoked sessions of %v, want only the user's", f.sessions.revokedUsers)
+	}
+	for name, token := range map[string]string{"laptop": laptop.Token, "phone": phone.Token} {
+		if rec := sendJSON(f.handler, http.MethodGet, "/auth/me", token, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s token after logout-all: status %d, want 401", name, rec.Code)
+		}
+	}
+
+	// Signing in again issues tokens at the new version
+	fresh := f.login(t, "user@example.com", "password")
+	if rec := sendJSON(f.handler, http.MethodGet, "/auth/me", fresh.Token, ""); rec.Code != http.StatusOK {
+		t.Fatalf("token issued after logout-all: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	// The breach corpus holds one password; the server records the range
+	// prefixes it was asked for
+	const breached = "Summer-2024-Passw0rd!"
+	sum := sha1.Sum([]byte(breached))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var prefixes []string
+	hibp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		prefixes = append(prefixes, prefix)
+		if prefix == hash[:5] {
+			fmt.Fprintf(w, "%s:42\r\n", hash[5:])
+		}
+		fmt.Fprint(w, "0000000000000000000000000000000000A:0\r\n")
+	}))
+	t.Cleanup(hibp.Close)
+
+	cfg := &config.Config{}
+	cfg.Password = config.PasswordConfig{MinLength: 10, RequireUpper: true, RequireDigit: true, RequireSymbol: true, CheckBreached: true, BreachAPIURL: hibp.URL + "/range/"}
+	cfg.Outbound = config.OutboundConfig{MaxAttempts: 1, AttemptTimeout: time.Second}
+	cfg.RateLimit = config.RateLimitConfig{Window: time.Minute, CheckPassword: 3, CheckPasswordKey: []string{"ip"}}
+	f := newAuthFixture(t, cfg)
+
+	check := func(password string) models.CheckPasswordResponse {
+		t.Helper()
+		code, body := f.post("/check-password", `{"password": "`+password+`"}`)
+		if code != http.StatusOK {
+			t.Fatalf("check %q: status %d, want 200: %s", password, code, body)
+		}
+		return responseData[models.CheckPasswordResponse](t, []byte(body))
+	}
+
+	weak := check("password")
+	if weak.Valid || weak.Strength != "weak" || strings.Join(weak.FailedRules, ",") != "min_length,uppercase,digit,symbol" {
+		t.Fatalf("weak password = %+v, want invalid and weak, failing length and character rules", weak)
+	}
+
+	strong := check("Correct-Horse-Battery-9")
+	if !strong.Valid || strong.Strength != "strong" || len(strong.FailedRules) != 0 {
+		t.Fatalf("strong password = %+v, want valid and strong with no failed rules", strong)
+	}
+
+	leaked := check(breached)
+	if leaked.Valid || leaked.Score != 0 || strings.Join(leaked.FailedRules, ",") != auth.RuleBreached {
+		t.Fatalf("breached password = %+v, want invalid with score 0, failing only %s", leaked, auth.RuleBreached)
+	}
+	for _, prefix := range prefixes {
+		if len(prefix) != 5 {
+			t.Fatalf("breach lookup sent %q, want only a five-character hash prefix", prefix)
+		}
+	}
+
+	// Nothing is stored, and the endpoint is rate limited
+	if len(f.users.users) != 0 {
+		t.Fatalf("%d users stored, want none", len(f.users.users))
+	}
+	if code, _ := f.post("/check-password", `{"password": "Another-Password-1"}`); code != http.StatusTooManyRequests {
+		t.Fatalf("over the limit: status %d, want 429", code)
+	}
+}
+
+func TestLoginLockoutThenUnlock(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Account.LockoutThreshold = 3
+	cfg.Account.LockoutWindow = 15 * time.Minute
+	cfg.Account.LockoutDuration = 30 * time.Minute
+	f := newAuthFixture(t, cfg)
+	user := f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "long-password")
+	attempt := func(password string) (int, string) {
+		t.Helper()
+		return f.post("/login", `{"email": "user@example.com", "password": "`+password+`"}`)
+	}
+
+	for i := 1; i < 3; i++ {
+		if code, body := attempt("wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d, want 401: %s", i, code, body)
+		}
+	}
+	rec := sendJSON(f.handler, http.MethodPost, "/auth/login", "", `{"email": "user@example.com", "password": "wrong-password"}`)
+	if rec.Code != http.StatusLocked || rec.Header().Get("Retry-After") != "1800" {
+		t.Fatalf("failure at the threshold: status %d Retry-After %q, want 423 for 1800s: %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body)
+	}
+
+	// Locked, even the right password is refused
+	f.clock.Advance(10 * time.Minute)
+	rec = sendJSON(f.handler, http.MethodPost, "/auth/login", "", `{"email": "user@example.com", "password": "long-password"}`)
+	if rec.Code != http.StatusLocked || rec.Header().Get("Retry-After") != "1200" {
+		t.Fatalf("right password while locked: status %d Retry-After %q, want 423 for 1200s: %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body)
+	}
+
+	// Past the lock the right password gets in and resets the count
+	f.clock.Advance(21 * time.Minute)
+	f.login(t, "user@example.com", "long-password")
+	if stored := f.users.users[user.ID]; stored.FailedLoginAttempts != 0 || stored.LockedUntil != nil {
+		t.Fatalf("after login: %d failures, locked until %v, want both cleared", stored.FailedLoginAttempts, stored.LockedUntil)
+	}
+	for i := 1; i < 3; i++ {
+		if code, body := attempt("wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d after reset: status %d, want 401 with the count restarted: %s", i, code, body)
+		}
+	}
+}
+
+func TestLoginFailuresOutsideWindowDontLock(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Account.LockoutThreshold = 3
+	cfg.Account.LockoutWindow = 15 * time.Minute
+	cfg.Account.LockoutDuration = 30 * time.Minute
+	f := newAuthFixture(t, cfg)
+	f.users.add(t, &models.User{Email: "user@example.com", EmailVerified: true}, "long-password")
+
+	for i := 1; i <= 5; i++ {
+		if code, body := f.post("/login", `{"email": "user@example.com", "password": "wrong-password"}`); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d, want 401 with failures spread out: %s", i, code, body)
+		}
+		f.clock.Advance(16 * time.Minute)
+	}
+	f.login(t, "user@example.com", "long-password")
+}
+
+func TestAuthResponsesReportAccessTokenLifetime(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+
+	check := func(step string, code, wantCode int, body string) models.AuthResponse {
+		t.Helper()
+		if code != wantCode {
+			t.Fatalf("%s: status %d, want %d: %s", step, code, wantCode, body)
+		}
+		resp := responseData[models.AuthResponse](t, []byte(body))
+		if resp.TokenType != "Bearer" || resp.ExpiresIn != 15*60 {
+			t.Fatalf("%s: token_type %q expires_in %d, want Bearer and the 15m access lifetime", step, resp.TokenType, resp.ExpiresIn)
+		}
+		claims, err := f.jwtManager.ValidateToken(resp.Token)
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime != time.Duration(resp.ExpiresIn)*time.Second {
+			t.Fatalf("%s: token lives %s, want expires_in %ds", step, lifetime, resp.ExpiresIn)
+		}
+		return resp
+	}
+
+	code, body := f.post("/register", `{"email": "user@example.com", "password": "long-password", "name": "User"}`)
+	check("register", code, http.StatusCreated, body)
+	code, body = f.post("/login", `{"email": "user@example.com", "password": "long-password"}`)
+	login := check("login", code, http.StatusOK, body)
+	code, body = f.post("/refresh", `{"refresh_token": "`+login.RefreshToken+`"}`)
+	check("refresh", code, http.StatusOK, body)
+}
+
+func TestMeReturnsCurrentUser(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com", Name: "User"}, "long-password")
+	f.users.add(t, &models.User{Email: "other@example.com"}, "long-password")
+	token := f.login(t, "user@example.com", "long-password").Token
+
+	rec := sendJSON(f.handler, http.MethodGet, "/auth/me", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	me := responseData[models.UserResponse](t, rec.Body.Bytes()).User
+	if me == nil || me.ID != user.ID || me.Email != "user@example.com" {
+		t.Fatalf("me = %+v, want the logged-in user", me)
+	}
+	if strings.Contains(rec.Body.String(), "password") || strings.Contains(rec.Body.String(), "$2a$") {
+		t.Fatalf("body %s exposes the password hash", rec.Body)
+	}
+
+	if rec := sendJSON(f.handler, http.MethodGet, "/auth/me", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without a token: status %d, want 401", rec.Code)
+	}
+
+	// The middleware turns away tokens of deleted users; without its user
+	// lookup the handler itself answers 404
+	delete(f.users.users, user.ID)
+	if rec := sendJSON(f.handler, http.MethodGet, "/auth/me", token, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("deleted user: status %d, want 401", rec.Code)
+	}
+	log := testLogger(t)
+	h := NewAuthHandler(&config.Config{}, &repository.Repository{User: f.users}, f.jwtManager, nil, f.outbox,
+		&auth.PasswordPolicy{MinLength: 8}, auth.BcryptHasher{Cost: bcrypt.MinCost}, log)
+	handler := middleware.AuthMiddleware(f.jwtManager, nil, log)(http.HandlerFunc(h.Me))
+	if rec := sendJSON(handler, http.MethodGet, "/auth/me", token, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("deleted user past the middleware: status %d, want 404", rec.Code)
+	}
+}
+
+func TestReplaceProfile(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com", Name: "Old Name"}, "password")
+	other := f.users.add(t, &models.User{Email: "taken@example.com", Name: "Other"}, "password")
+	token, err := f.jwtManager.GenerateAccessToken(user.ID, user.Email, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(body string) *httptest.ResponseRecorder {
+		return sendJSON(f.handler, http.MethodPut, "/auth/me", token, body)
+	}
+
+	rec := put(`{"name": "New Name", "email": "new@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.UserResponse](t, rec.Body.Bytes()).User; got.ID != user.ID || got.Name != "New Name" || got.Email != "new@example.com" {
+		t.Fatalf("update: user = %+v, want the new name and email", got)
+	}
+
+	// Keeping one's own email is not a collision
+	if rec := put(`{"name": "Newer Name", "email": "new@example.com"}`); rec.Code != http.StatusOK {
+		t.Fatalf("own email: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		body string
+		code int
+		want string
+	}{
+		{`{"name": "Name", "email": "taken@example.com"}`, http.StatusConflict, "Email is already in use"},
+		{`{"name": "Name", "email": "not-an-email"}`, http.StatusBadRequest, `"email"`},
+		{`{"email": "fresh@example.com"}`, http.StatusBadRequest, `"name"`},
+	} {
+		rec := put(tt.body)
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: status %d, body %s, want %d mentioning %s", tt.body, rec.Code, rec.Body, tt.code, tt.want)
+		}
+	}
+
+	if got := f.users.users[user.ID]; got.Name != "Newer Name" || got.Email != "new@example.com" {
+		t.Fatalf("user = %+v, want only the accepted updates", got)
+	}
+	if got := f.users.users[other.ID]; got.Name != "Other" || got.Email != "taken@example.com" {
+		t.Fatalf("other user = %+v, want it untouched", got)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	user := f.users.add(t, &models.User{Email: "user@example.com"}, "old-password")
+	session := f.login(t, "user@example.com", "old-password")
+	change := func(body string) *httptest.ResponseRecorder {
+		return sendJSON(f.handler, http.MethodPost, "/auth/change-password", session.Token, body)
+	}
+
+	for _, tt := range []struct {
+		body string
+		want string
+	}{
+		{`{"current_password": "wrong-password", "new_password": "new-password"}`, "Current password is incorrect"},
+		{`{"current_password": "old-password", "new_password": "short"}`, "password does not meet policy"},
+		{`{"current_password": "old-password", "new_password": "old-password"}`, "new_password must differ from the current password"},
+		{`{"current_password": "old-password"}`, "new_password is required"},
+	} {
+		rec := change(tt.body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: status %d, body %s, want 400 saying %s", tt.body, rec.Code, rec.Body, tt.want)
+		}
+	}
+	if code, _ := f.post("/login", `{"email": "user@example.com", "password": "old-password"}`); code != http.StatusOK {
+		t.Fatalf("rejected changes altered the password: login status %d", code)
+	}
+
+	rec := change(`{"current_password": "old-password", "new_password": "new-password"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if resp := responseData[models.ChangePasswordResponse](t, rec.Body.Bytes()); resp.Token != "" || resp.RefreshToken != "" {
+		t.Fatalf("change without logout: response %+v, want no new tokens", resp)
+	}
+	if code, _ := f.post("/login", `{"email": "user@example.com", "password": "old-password"}`); code != http.StatusUnauthorized {
+		t.Fatalf("old password: login status %d, want 401", code)
+	}
+	f.login(t, "user@example.com", "new-password")
+	if len(f.sessions.revokedUsers) != 0 {
+		t.Fatalf("sessions revoked for %v without logout_other_sessions", f.sessions.revokedUsers)
+	}
+
+	// Logging out other sessions revokes them and hands the caller a new pair
+	rec = change(`{"current_password": "new-password", "new_password": "newer-password", "logout_other_sessions": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change with logout: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	resp := responseData[models.ChangePasswordResponse](t, rec.Body.Bytes())
+	if resp.Token == "" || resp.RefreshToken == "" || resp.TokenType != "Bearer" {
+		t.Fatalf("change with logout: response %+v, want a new token pair", resp)
+	}
+	if len(f.sessions.revokedUsers) != 1 || f.sessions.revokedUsers[0] != user.ID {
+		t.Fatalf("revoked sessions of %v, want the user's", f.sessions.revokedUsers)
+	}
+	if code, _ := f.post("/refresh", `{"refresh_token": "`+session.RefreshToken+`"}`); code != http.StatusUnauthorized {
+		t.Fatalf("earlier session refresh: status %d, want 401", code)
+	}
+	if code, body := f.post("/refresh", `{"refresh_token": "`+resp.RefreshToken+`"}`); code != http.StatusOK {
+		t.Fatalf("new session refresh: status %d, want 200: %s", code, body)
+	}
+}
+
+func TestDeletedAccountCannotAuthenticate(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+	f.users.add(t, &models.User{Email: "user@example.com"}, "long-password")
+	session := f.login(t, "user@example.com", "long-password")
+
+	if rec := sendJSON(f.handler, http.MethodDelete, "/auth/me", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("delete without a token: status %d, want 401", rec.Code)
+	}
+	if rec := sendJSON(f.handler, http.MethodDelete, "/auth/me", session.Token, ""); rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Fatalf("delete: status %d body %q, want an empty 204", rec.Code, rec.Body)
+	}
+
+	if rec := sendJSON(f.handler, http.MethodGet, "/auth/me", session.Token, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token after deletion: status %d, want 401", rec.Code)
+	}
+	if code, _ := f.post("/login", `{"email": "user@example.com", "password": "long-password"}`); code != http.StatusUnauthorized {
+		t.Fatalf("login after deletion: status %d, want 401", code)
+	}
+	if code, _ := f.post("/refresh", `{"refresh_token": "`+session.RefreshToken+`"}`); code != http.StatusUnauthorized {
+		t.Fatalf("refresh after deletion: status %d, want 401", code)
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/ical"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+	"secure-task-api/pkg/utils"
+)
+
+// CalendarHandler serves task calendars to calendar apps, which poll a
+// subscription URL and can't send a JWT
+type CalendarHandler struct {
+	repo           *repository.Repository
+	jwtManager     *auth.JWTManager
+	cfg            config.TaskConfig
+	authMiddleware func(http.Handler) http.Handler
+	log            *logger.Logger
+}
+
+// authMiddleware protects creating and revoking subscriptions; the feed
+// itself is authorized by its token.
+func NewCalendarHandler(
+	repo *repository.Repository,
+	jwtManager *auth.JWTManager,
+	cfg config.TaskConfig,
+	authMiddleware func(http.Handler) http.Handler,
+	log *logger.Logger,
+) *CalendarHandler {
+	return &CalendarHandler{
+		repo:           repo,
+		jwtManager:     jwtManager,
+		cfg:            cfg,
+		authMiddleware: authMiddleware,
+		log:            log,
+	}
+}
+
+// Registers calendar routes under /v1/calendar.
+func (h *CalendarHandler) RegisterRoutes(r chi.Router) {
+	// Tokens are JWTs, which contain dots, so the suffix is trimmed by hand
+	r.Get("/{feed}", h.Feed)
+
+	r.Group(func(protected chi.Router) {
+		protected.Use(h.authMiddleware)
+		protected.Post("/subscription", h.CreateSubscription)
+		protected.Delete("/subscription", h.RevokeSubscription)
+	})
+}
+
+// CreateSubscription issues a calendar subscription URL, revoking any earlier one
+func (h *CalendarHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	token, claims, err := h.jwtManager.GeneratePurposeToken(userID, auth.PurposeCalendarFeed, h.cfg.CalendarFeedTTL)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to generate calendar token")
+		utils.InternalServerError(w, r, "Failed to create calendar subscription")
+		return
+	}
+
+	if err := h.repo.UserToken.InvalidateForUser(r.Context(), userID, auth.PurposeCalendarFeed); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to revoke previous calendar subscription")
+		utils.InternalServerError(w, r, "Failed to create calendar subscription")
+		return
+	}
+	if err := h.repo.UserToken.Create(r.Context(), &models.UserToken{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Purpose:   auth.PurposeCalendarFeed,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to store calendar subscription")
+		utils.InternalServerError(w, r, "Failed to create calendar subscription")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusCreated, models.CalendarSubscriptionResponse{
+		URL:       feedURL(r, token),
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+}
+
+// RevokeSubscription invalidates the user's calendar subscription URL
+func (h *CalendarHandler) RevokeSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.UserToken.InvalidateForUser(r.Context(), userID, auth.PurposeCalendarFeed); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to revoke calendar subscription")
+		utils.InternalServerError(w, r, "Failed to revoke calendar subscription")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feed serves the calendar for a subscription token
+func (h *CalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(chi.URLParam(r, "feed"), ".ics")
+
+	userID, ok, err := h.feedUser(r.Context(), token)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to check calendar subscription")
+		utils.InternalServerError(w, r, "Failed to get calendar")
+		return
+	}
+	if !ok {
+		// Same response for unknown, expired and revoked tokens
+		utils.NotFound(w, r, "Calendar not found")
+		return
+	}
+
+	writeTaskCalendar(w, r, h.repo, userID, h.cfg.ExportBatchSize)
+}
+
+// feedUser returns the user a live subscription token belongs to
+func (h *CalendarHandler) feedUser(ctx context.Context, token string) (uuid.UUID, bool, error) {
+	claims, err := h.jwtManager.ValidatePurposeToken(token, auth.PurposeCalendarFeed)
+	if err != nil {
+		return uuid.Nil, false, nil
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, false, nil
+	}
+
+	record, err := h.repo.UserToken.GetActive(ctx, auth.HashToken(token), auth.PurposeCalendarFeed)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	if record == nil || record.UserID != userID {
+		return uuid.Nil, false, nil
+	}
+	return userID, true, nil
+}
+
+// writeTaskCalendar streams the user's tasks as an iCalendar response,
+// reading and flushing batchSize tasks at a time. Once the first batch is
+// sent a failure can only cut the response short, so it is logged instead.
+func writeTaskCalendar(w http.ResponseWriter, r *http.Request, repo *repository.Repository, userID uuid.UUID, batchSize int) {
+	log := logger.FromContext(r.Context())
+	enc := ical.NewEncoder(w, "Tasks")
+	flusher, _ := w.(http.Flusher)
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", ical.ContentType)
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	err := repo.Task.Iterate(r.Context(), userID, batchSize, func(tasks []models.Task) error {
+		start()
+		if err := enc.Encode(tasks); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			log.WithError(err).Error("Failed to fetch tasks for calendar")
+			utils.InternalServerError(w, r, "Failed to get calendar")
+			return
+		}
+		log.WithError(err).Error("Calendar export aborted")
+		return
+	}
+
+	start()
+	if err := enc.Close(); err != nil {
+		log.WithError(err).Error("Failed to finish calendar")
+	}
+}
+
+// feedURL builds the absolute URL of a subscription feed. The request path
+// already carries any base path, so the feed path is derived from it.
+func feedURL(r *http.Request, token string) string {
+	prefix := strings.TrimSuffix(r.URL.Path, "/subscription")
+	return requestOrigin(r) + prefix + "/" + token + ".ics"
+}
+
+// requestOrigin returns the scheme and host r was addressed to, honouring
+// X-Forwarded-Proto from a TLS-terminating proxy
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
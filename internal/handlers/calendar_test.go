@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/ical"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+)
+
+// iterTaskRepo yields one user's tasks through Iterate, recording the size
+// of each batch
+type iterTaskRepo struct {
+	fakeTaskRepo
+	userID  uuid.UUID
+	tasks   []models.Task
+	batches []int
+}
+
+func (f *iterTaskRepo) Iterate(ctx context.Context, userID uuid.UUID, batchSize int, fn func([]models.Task) error) error {
+	if userID != f.userID {
+		return nil
+	}
+	for start := 0; start < len(f.tasks); start += batchSize {
+		end := min(start+batchSize, len(f.tasks))
+		f.batches = append(f.batches, end-start)
+		if err := fn(f.tasks[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calendarServer serves the calendar routes over tasks, returning the
+// handler and a bearer token for their owner
+func calendarServer(t *testing.T, tasks *iterTaskRepo, cfg config.TaskConfig) (http.Handler, string) {
+	t.Helper()
+	log := testLogger(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(tasks.userID, "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CalendarFeedTTL == 0 {
+		cfg.CalendarFeedTTL = 24 * time.Hour
+	}
+	if cfg.ExportBatchSize == 0 {
+		cfg.ExportBatchSize = 100
+	}
+
+	repo := &repository.Repository{Task: tasks, UserToken: newMemoryUserTokenRepo(clock.NewFake(time.Now()))}
+	h := NewCalendarHandler(repo, jwtManager, cfg, middleware.AuthMiddleware(jwtManager, nil, log), log)
+	router := chi.NewRouter()
+	router.Route("/calendar", h.RegisterRoutes)
+	return router, token
+}
+
+func TestCalendarSubscriptionFeed(t *testing.T) {
+	userID := uuid.New()
+	due := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tasks := &iterTaskRepo{userID: userID, tasks: []models.Task{
+		{ID: uuid.New(), UserID: userID, Title: "Pay rent", Status: models.TaskStatusPending, DueDate: due},
+		{ID: uuid.New(), UserID: userID, Title: "Someday", Status: models.TaskStatusPending},
+	}}
+	handler, token := calendarServer(t, tasks, config.TaskConfig{})
+
+	if rec := sendJSON(handler, http.MethodPost, "/calendar/subscription", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("subscribe without a token: status %d, want 401", rec.Code)
+	}
+	subscribe := func() string {
+		t.Helper()
+		rec := sendJSON(handler, http.MethodPost, "/calendar/subscription", token, "")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("subscribe: status %d, want 201: %s", rec.Code, rec.Body)
+		}
+		sub := responseData[models.CalendarSubscriptionResponse](t, rec.Body.Bytes())
+		path, ok := strings.CutPrefix(sub.URL, "http://example.com")
+		if !ok || !strings.HasPrefix(path, "/calendar/") || !strings.HasSuffix(path, ".ics") {
+			t.Fatalf("subscription URL %q, want an absolute .ics feed URL", sub.URL)
+		}
+		return path
+	}
+	feed := subscribe()
+
+	// Calendar apps poll without a JWT
+	rec := sendJSON(handler, http.MethodGet, feed, "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ical.ContentType {
+		t.Fatalf("feed: status %d type %q, want 200 %s: %s", rec.Code, rec.Header().Get("Content-Type"), ical.ContentType, rec.Body)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(body, "END:VCALENDAR\r\n") ||
+		strings.Count(body, "BEGIN:VEVENT") != 1 || !strings.Contains(body, "SUMMARY:Pay rent\r\n") {
+		t.Fatalf("feed %q, want a calendar with the one dated task", body)
+	}
+
+	// A new subscription replaces the old one, and revoking ends it
+	renewed := subscribe()
+	if rec := sendJSON(handler, http.MethodGet, feed, "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("replaced feed: status %d, want 404", rec.Code)
+	}
+	if rec := sendJSON(handler, http.MethodDelete, "/calendar/subscription", token, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d, want 204: %s", rec.Code, rec.Body)
+	}
+	if rec := sendJSON(handler, http.MethodGet, renewed, "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("revoked feed: status %d, want 404", rec.Code)
+	}
+
+	// Access tokens are not feed tokens
+	if rec := sendJSON(handler, http.MethodGet, "/calendar/"+token+".ics", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("access token as feed: status %d, want 404", rec.Code)
+	}
+}
+
+func TestTaskCalendarExport(t *testing.T) {
+	userID := uuid.New()
+	tasks := &iterTaskRepo{userID: userID, tasks: []models.Task{
+		{ID: uuid.New(), UserID: userID, Title: "Pay rent", Status: models.TaskStatusCompleted, DueDate: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)},
+	}}
+	handler, token := taskServerWith(t, tasks, userID, config.TaskConfig{ExportBatchSize: 100})
+
+	if rec := sendJSON(handler, http.MethodGet, "/tasks/calendar.ics", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without a token: status %d, want 401", rec.Code)
+	}
+	rec := sendJSON(handler, http.MethodGet, "/tasks/calendar.ics", token, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ical.ContentType {
+		t.Fatalf("status %d type %q, want 200 %s: %s", rec.Code, rec.Header().Get("Content-Type"), ical.ContentType, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "DTSTART:20240301T090000Z\r\n") || !strings.Contains(body, "X-TASK-STATUS:completed\r\n") {
+		t.Fatalf("calendar %q, want the task's due date and status", body)
+	}
+}
+
+// flushRecorder counts flushes, noting how much of the body each one sent
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushedAt []int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushedAt = append(r.flushedAt, r.Body.Len())
+	r.ResponseRecorder.Flush()
+}
+
+func TestCalendarExportStreamsInBatches(t *testing.T) {
+	userID := uuid.New()
+	due := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tasks := &iterTaskRepo{userID: userID}
+	for i := 0; i < 2350; i++ {
+		tasks.tasks = append(tasks.tasks, models.Task{ID: uuid.New(), UserID: userID, Title: "Task", Status: models.TaskStatusPending, DueDate: due})
+	}
+	handler, token := taskServerWith(t, tasks, userID, config.TaskConfig{ExportBatchSize: 100})
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/calendar.ics", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	if len(tasks.batches) != 24 || tasks.batches[0] != 100 || tasks.batches[23] != 50 {
+		t.Fatalf("read batches %v, want 23 of 100 and one of 50", tasks.batches)
+	}
+	if len(rec.flushedAt) != len(tasks.batches) {
+		t.Fatalf("%d flushes, want one per batch", len(rec.flushedAt))
+	}
+	for i := 1; i < len(rec.flushedAt); i++ {
+		if rec.flushedAt[i] <= rec.flushedAt[i-1] {
+			t.Fatalf("flush %d sent nothing new, want each batch written as it is read", i)
+		}
+	}
+	if n := strings.Count(rec.Body.String(), "BEGIN:VEVENT"); n != len(tasks.tasks) {
+		t.Fatalf("%d events, want all %d tasks", n, len(tasks.tasks))
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"secure-task-api/api"
+)
+
+// docsPage renders Swagger UI for the spec at openapi.json, relative so it
+// works under API_BASE_PATH
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Secure Task Management API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// DocsHandler serves the OpenAPI spec and a Swagger UI page for it
+type DocsHandler struct {
+	spec []byte
+}
+
+// NewDocsHandler creates a docs handler. The spec is embedded in the binary,
+// so it only fails to convert if a broken openapi.yaml was built in.
+func NewDocsHandler() *DocsHandler {
+	spec, err := api.OpenAPIJSON()
+	if err != nil {
+		panic(err)
+	}
+	return &DocsHandler{spec: spec}
+}
+
+// OpenAPI serves the OpenAPI document as JSON
+func (h *DocsHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.spec)
+}
+
+// Docs serves Swagger UI
+func (h *DocsHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(docsPage))
+}
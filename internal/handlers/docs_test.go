@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/repository"
+)
+
+// openAPIDocument is the part of an OpenAPI 3 document the docs test checks
+type openAPIDocument struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas         map[string]json.RawMessage `json:"schemas"`
+		SecuritySchemes map[string]struct {
+			Type   string `json:"type"`
+			Scheme string `json:"scheme"`
+		} `json:"securitySchemes"`
+	} `json:"components"`
+}
+
+// refPattern matches schema references in the JSON spec
+var refPattern = regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`)
+
+func docsRouter(t *testing.T) http.Handler {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.App.Docs = true
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	return NewRouter(cfg, &repository.Repository{Task: fakeTaskRepo{}, User: fakeUserRepo{}}, jwtManager, nil, nil, nil, nil, testLogger(t)).SetupRoutes()
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	rec := httptest.NewRecorder()
+	docsRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d content type %q, want 200 JSON", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Fatalf("openapi %q info %+v, want an OpenAPI 3 document with title and version", doc.OpenAPI, doc.Info)
+	}
+	for _, path := range []string{"/health/live", "/v1/auth/register", "/v1/auth/login", "/v1/tasks", "/v1/tasks/{id}"} {
+		if len(doc.Paths[path]) == 0 {
+			t.Errorf("path %s not documented", path)
+		}
+	}
+	if len(doc.Components.Schemas) == 0 {
+		t.Error("no component schemas")
+	}
+	if bearer := doc.Components.SecuritySchemes["BearerAuth"]; bearer.Type != "http" || bearer.Scheme != "bearer" {
+		t.Fatalf("BearerAuth = %+v, want HTTP bearer auth", bearer)
+	}
+
+	// Every schema reference must point at a defined component
+	for _, ref := range refPattern.FindAllStringSubmatch(rec.Body.String(), -1) {
+		if _, ok := doc.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("reference to undefined schema %s", ref[1])
+		}
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	docsRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status %d content type %q, want 200 HTML", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `url: "openapi.json"`) {
+		t.Fatal("Swagger UI does not load the served spec")
+	}
+}
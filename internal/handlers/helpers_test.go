@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+)
+
+// testLogger returns a logger that only writes fatal entries
+func testLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "fatal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+// fileLogger returns a logger with cfg writing JSON entries to a temporary
+// file, and a function reading back the entries written so far
+func fileLogger(t *testing.T, cfg config.LoggingConfig) (*logger.Logger, func() []map[string]interface{}) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.json")
+	cfg.OutputPaths = []string{path}
+	if cfg.Level == "" {
+		cfg.Level = "info"
+	}
+	log, err := logger.NewLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return log, func() []map[string]interface{} {
+		t.Helper()
+		log.Sync()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("decode %s: %v", line, err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+}
+
+// fakeTaskRepo is a task repository for handler tests. Tests override the
+// methods they exercise; the rest panic if called.
+type fakeTaskRepo struct {
+	repository.TaskRepositoryInterface
+}
+
+// fakeUserRepo is fakeTaskRepo for users
+type fakeUserRepo struct {
+	repository.UserRepositoryInterface
+}
+
+// sendJSON sends body to handler as a JSON request, authenticated with token when set
+func sendJSON(handler http.Handler, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// responseData decodes the data of a success envelope
+func responseData[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var resp models.APIResponse[T]
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+	return resp.Data
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"secure-task-api/internal/notify"
+)
+
+// RouteRegistrar is implemented by handlers that own a group of routes
+type RouteRegistrar interface {
+	RegisterRoutes(r chi.Router)
+}
+
+// Registration mounts a handler's routes under a /v1 prefix. Protected
+// handlers sit behind the auth and idempotency middleware; public ones may
+// still protect individual routes themselves.
+type Registration struct {
+	Prefix    string
+	Handler   RouteRegistrar
+	Protected bool
+}
+
+// registrations lists every resource handler mounted under /v1. New
+// resources are added here rather than in SetupRoutes.
+func (r *Router) registrations(authMiddleware func(http.Handler) http.Handler) []Registration {
+	return []Registration{
+		{
+			Prefix: "/auth",
+			Handler: NewAuthHandler(r.config, r.repo, r.jwtManager, authMiddleware,
+				notify.NewSender(r.config.Mail, r.log), newPasswordPolicy(r.config.Password, r.blocklist, r.config.Outbound),
+				newPasswordHasher(r.config.Password), r.log),
+		},
+		{
+			Prefix:  "/calendar",
+			Handler: NewCalendarHandler(r.repo, r.jwtManager, r.config.Task, authMiddleware, r.log),
+		},
+		{
+			Prefix:    "/tasks",
+			Handler:   NewTaskHandler(r.repo, r.config.Task, r.statsCache, r.webhooks, r.config.App.RequireContentLength, r.config.RateLimit, r.log),
+			Protected: true,
+		},
+		{
+			Prefix:    "/webhooks",
+			Handler:   NewWebhookHandler(r.repo, r.webhooks, r.log),
+			Protected: true,
+		},
+		{
+			Prefix:    "/admin",
+			Handler:   NewAdminHandler(r.repo, r.config.Task, r.log),
+			Protected: true,
+		},
+	}
+}
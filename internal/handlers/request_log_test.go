@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/config"
+)
+
+// requestLogs serves one request to each handler behind StructuredLogger with
+// cfg, returning the request log entries written
+func requestLogs(t *testing.T, cfg config.LoggingConfig, handlers ...http.HandlerFunc) []map[string]interface{} {
+	t.Helper()
+	log, entries := fileLogger(t, cfg)
+	for _, h := range handlers {
+		NewStructuredLogger(log).Middleware(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/tasks", nil))
+	}
+	return entries()
+}
+
+func fastHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func slowHandler(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(30 * time.Millisecond)
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestSlowRequestsLoggedAtWarn(t *testing.T) {
+	entries := requestLogs(t, config.LoggingConfig{SlowRequestThreshold: 20 * time.Millisecond}, fastHandler, slowHandler)
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want one per request", len(entries))
+	}
+	if fast := entries[0]; fast["level"] != "info" || fast["slow"] != nil {
+		t.Errorf("fast request logged as %v, want info without slow", fast)
+	}
+	if slow := entries[1]; slow["level"] != "warn" || slow["slow"] != true {
+		t.Errorf("slow request logged as %v, want warn with slow=true", slow)
+	}
+}
+
+func TestSlowRequestsOnlySilencesFastRequests(t *testing.T) {
+	entries := requestLogs(t, config.LoggingConfig{SlowRequestThreshold: 20 * time.Millisecond, SlowRequestsOnly: true}, fastHandler, slowHandler)
+	if len(entries) != 1 || entries[0]["slow"] != true {
+		t.Fatalf("entries = %v, want only the slow request", entries)
+	}
+}
+
+func TestSlowRequestThresholdDisabled(t *testing.T) {
+	entries := requestLogs(t, config.LoggingConfig{}, slowHandler)
+	if len(entries) != 1 || entries[0]["level"] != "info" {
+		t.Fatalf("entries = %v, want the request at info with no threshold", entries)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// handlerInfo names the function serving a route and where it is defined
+type handlerInfo struct {
+	name   string // e.g. handlers.(*TaskHandler).ListTasks
+	source string // e.g. handlers/task.go:70
+}
+
+// routeHandlers maps "METHOD pattern" for every route on r to the function
+// serving it. Routes whose handler isn't a plain function are skipped.
+// Patterns lose their trailing slash, as chi's RoutePattern reports them.
+// Handlers registered as method values compile to generated wrappers, so
+// the methods of receivers are indexed to find their real source.
+func routeHandlers(r chi.Routes, receivers ...interface{}) map[string]handlerInfo {
+	methods := make(map[string]*runtime.Func)
+	for _, recv := range receivers {
+		t := reflect.TypeOf(recv)
+		for i := 0; i < t.NumMethod(); i++ {
+			if f := runtime.FuncForPC(t.Method(i).Func.Pointer()); f != nil {
+				methods[f.Name()] = f
+			}
+		}
+	}
+
+	handlers := make(map[string]handlerInfo)
+	chi.Walk(r, func(method, route string, handler http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if info, ok := describeHandler(handler, methods); ok {
+			handlers[method+" "+strings.TrimSuffix(route, "/")] = info
+		}
+		return nil
+	})
+	return handlers
+}
+
+// describeHandler resolves an http.HandlerFunc to its function name and source line
+func describeHandler(handler http.Handler, methods map[string]*runtime.Func) (handlerInfo, bool) {
+	fn, ok := handler.(http.HandlerFunc)
+	if !ok {
+		return handlerInfo{}, false
+	}
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return handlerInfo{}, false
+	}
+
+	// Method values show up as pkg/path.(*T).Method-fm
+	fullName := strings.TrimSuffix(f.Name(), "-fm")
+	if m, ok := methods[fullName]; ok {
+		f = m
+	}
+	name := fullName
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	info := handlerInfo{name: name}
+	if file, line := f.FileLine(f.Entry()); !strings.HasPrefix(file, "<") {
+		info.source = filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file)) + ":" + strconv.Itoa(line)
+	}
+	return info, true
+}
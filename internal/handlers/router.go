@@ -2,22 +2,32 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 
 	"secure-task-api/internal/auth"
 	"secure-task-api/internal/config"
+	"secure-task-api/internal/health"
 	"secure-task-api/internal/logger"
 	"secure-task-api/internal/middleware"
 	"secure-task-api/internal/repository"
+	"secure-task-api/internal/stats"
+	"secure-task-api/internal/webhook"
 )
 
 type Router struct {
 	config     *config.Config
 	repo       *repository.Repository
 	jwtManager *auth.JWTManager
+	statsCache *stats.Cache
+	webhooks   *webhook.Dispatcher
+	dbMonitor  *health.Monitor
+	blocklist  auth.PasswordBlocklist
 	log        *logger.Logger
 }
 
@@ -25,12 +35,20 @@ func NewRouter(
 	config *config.Config,
 	repo *repository.Repository,
 	jwtManager *auth.JWTManager,
+	statsCache *stats.Cache,
+	webhooks *webhook.Dispatcher,
+	dbMonitor *health.Monitor,
+	blocklist auth.PasswordBlocklist,
 	log *logger.Logger,
 ) *Router {
 	return &Router{
 		config:     config,
 		repo:       repo,
 		jwtManager: jwtManager,
+		statsCache: statsCache,
+		webhooks:   webhooks,
+		dbMonitor:  dbMonitor,
+		blocklist:  blocklist,
 		log:        log,
 	}
 }
@@ -41,9 +59,34 @@ func (r *Router) SetupRoutes() chi.Router {
 
 	// Global middleware
 	router.Use(chimiddleware.RequestID)
+	router.Use(middleware.RequestIDLogger(r.log))
 	router.Use(chimiddleware.RealIP)
-	router.Use(NewStructuredLogger(r.log).Middleware)
+	requestLogger := NewStructuredLogger(r.log)
+	requestLogger.headers = r.config.Logging.RequestHeaders
+	router.Use(requestLogger.Middleware)
+	// Before Recoverer, so panics are counted as the 500s they become
+	if r.config.Metrics.Enabled {
+		router.Use(middleware.Metrics)
+	}
 	router.Use(chimiddleware.Recoverer)
+	// Before routing, which would otherwise 404 on the trailing slash
+	switch r.config.App.TrailingSlash {
+	case "strip":
+		router.Use(middleware.StripTrailingSlash)
+	case "redirect":
+		router.Use(middleware.RedirectTrailingSlash)
+	}
+	// After Recoverer, which must see panics the timeout re-raises
+	if t := r.config.App.RequestTimeout; t > 0 {
+		router.Use(middleware.TimeoutMiddleware(t))
+	}
+	// Before routing, so preflights reach it instead of a 405
+	if r.config.CORS.Enabled() {
+		router.Use(middleware.CORSMiddleware(r.config.CORS))
+	}
+	if c := r.config.Compression; c.Enabled {
+		router.Use(middleware.Compress(c.Algorithms, c.Level))
+	}
 
 	// ROOT ROUTE - Must be defined before other routes
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -58,30 +101,84 @@ func (r *Router) SetupRoutes() chi.Router {
 	})
 
 	// System endpoints
-	systemHandler := NewSystemHandler(r.repo, r.log)
+	systemHandler := NewSystemHandler(r.repo, r.dbMonitor, r.log)
+	receivers := []interface{}{systemHandler}
 	router.Get("/health", systemHandler.HealthCheck)
+	router.Get("/health/live", systemHandler.Liveness)
+	router.Get("/health/ready", systemHandler.HealthCheck)
+	router.Get("/readyz", systemHandler.Readiness)
 	router.Get("/debug/panic", systemHandler.TriggerPanic)
+	if r.config.Metrics.Enabled {
+		router.Handle("/metrics", promhttp.Handler())
+	}
+	if r.config.App.Docs {
+		docsHandler := NewDocsHandler()
+		receivers = append(receivers, docsHandler)
+		router.Get("/openapi.json", docsHandler.OpenAPI)
+		router.Get("/docs", docsHandler.Docs)
+	}
 
 	// API Routes
 	router.Route("/v1", func(v1 chi.Router) {
-		// Public auth endpoints
-		authHandler := NewAuthHandler(r.repo, r.jwtManager, r.log)
-		v1.Route("/auth", authHandler.RegisterRoutes)
+		if r.dbMonitor != nil && r.config.Database.RejectWhenDown {
+			v1.Use(middleware.RequireReady(r.dbMonitor.Ready, r.config.Database.HealthInterval))
+		}
+		authMiddleware := middleware.AuthMiddleware(r.jwtManager, r.repo.User, r.log)
+		registrations := r.registrations(authMiddleware)
+		for _, reg := range registrations {
+			receivers = append(receivers, reg.Handler)
+		}
+
+		// Public handlers (auth protects its own /me and /logout routes)
+		for _, reg := range registrations {
+			if !reg.Protected {
+				v1.Route(reg.Prefix, reg.Handler.RegisterRoutes)
+			}
+		}
 
 		// Protected routes
+		idempotencyStore := middleware.NewMemoryIdempotencyStore()
+
 		v1.Group(func(protected chi.Router) {
-			protected.Use(middleware.AuthMiddleware(r.jwtManager, r.log))
-			taskHandler := NewTaskHandler(r.repo, r.log)
-			protected.Route("/tasks", taskHandler.RegisterRoutes)
+			// Resource routes also accept an X-API-Key in place of a JWT
+			protected.Use(middleware.APIKeyMiddleware(r.repo.APIKey, authMiddleware, r.log))
+			protected.Use(middleware.Idempotency(idempotencyStore, r.config.Idempotency.TTL))
+			for _, reg := range registrations {
+				if reg.Protected {
+					protected.Route(reg.Prefix, reg.Handler.RegisterRoutes)
+				}
+			}
 		})
 	})
 
+	// Routes are all registered now, so handler names can be resolved
+	if r.config.Logging.RequestHandler {
+		requestLogger.handlers = routeHandlers(router, receivers...)
+		requestLogger.basePath = r.config.App.BasePath
+	}
+
+	// Mount under the configured base path. chi keeps the full path in
+	// r.URL.Path, so URLs built from the request already include the prefix.
+	if basePath := r.config.App.BasePath; basePath != "" {
+		root := chi.NewRouter()
+		root.Mount(basePath, router)
+		return root
+	}
+
 	return router
 }
 
 // StructuredLogger adapts the internal logger to Chi middleware.
 type StructuredLogger struct {
 	log *logger.Logger
+
+	// handlers, when set, annotates each request with the route pattern and
+	// the function that served it
+	handlers map[string]handlerInfo
+	basePath string
+
+	// headers adds the request headers, redacted, to each request log
+	headers bool
 }
 
 func NewStructuredLogger(log *logger.Logger) *StructuredLogger {
@@ -104,6 +201,40 @@ func (l *StructuredLogger) Middleware(next http.Handler) http.Handler {
 			r.UserAgent(),
 			ww.Status(),
 			time.Since(start).Seconds()*1000,
+			l.extraFields(r)...,
 		)
 	})
-}
\ No newline at end of file
+}
+
+// extraFields returns the optional fields logged with each request
+func (l *StructuredLogger) extraFields(r *http.Request) []zap.Field {
+	fields := l.handlerFields(r)
+	if l.headers {
+		fields = append(fields, l.log.Redactor().Headers(r.Header))
+	}
+	return fields
+}
+
+// handlerFields names the route and handler that served r, if enabled and known
+func (l *StructuredLogger) handlerFields(r *http.Request) []zap.Field {
+	if l.handlers == nil {
+		return nil
+	}
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return nil
+	}
+	pattern := rctx.RoutePattern()
+	if pattern == "" {
+		return nil
+	}
+
+	fields := []zap.Field{zap.String("route", pattern)}
+	if info, ok := l.handlers[r.Method+" "+strings.TrimPrefix(pattern, l.basePath)]; ok {
+		fields = append(fields, zap.String("handler", info.name))
+		if info.source != "" {
+			fields = append(fields, zap.String("handler_source", info.source))
+		}
+	}
+	return fields
+}
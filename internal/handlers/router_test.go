@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+)
+
+func TestTrailingSlashUnderBasePath(t *testing.T) {
+	for _, basePath := range []string{"", "/api"} {
+		cfg := &config.Config{}
+		cfg.App.BasePath = basePath
+		cfg.App.TrailingSlash = "strip"
+		jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+		router := NewRouter(cfg, &repository.Repository{Task: fakeTaskRepo{}, User: fakeUserRepo{}}, jwtManager, nil, nil, nil, nil, testLogger(t)).SetupRoutes()
+
+		for _, path := range []string{"/health/live", "/health/live/", "/health/live//?verbose=1"} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, basePath+path, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("base %q, GET %s: status %d, want 200", basePath, basePath+path, rec.Code)
+			}
+		}
+	}
+}
+
+// routeParam matches a chi URL parameter, with or without a pattern
+var routeParam = regexp.MustCompile(`\{[^}]+\}`)
+
+func TestRegisteredRoutesReachable(t *testing.T) {
+	for _, basePath := range []string{"", "/api"} {
+		cfg := &config.Config{}
+		cfg.App.BasePath = basePath
+		jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+		r := NewRouter(cfg, &repository.Repository{Task: fakeTaskRepo{}, User: fakeUserRepo{}}, jwtManager, nil, nil, nil, nil, testLogger(t))
+		router := r.SetupRoutes()
+
+		mounted := make(map[string]int)
+		err := chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			for _, reg := range r.registrations(nil) {
+				if prefix := basePath + "/v1" + reg.Prefix; route == prefix || strings.HasPrefix(route, prefix+"/") {
+					mounted[reg.Prefix]++
+				}
+			}
+			if !strings.HasPrefix(route, basePath+"/v1/") {
+				return nil
+			}
+
+			// Unauthenticated and with no body, routes mostly answer 400 or
+			// 401. A 404 only counts if a handler wrote it, in the JSON error
+			// envelope; the router's own is plain text.
+			target := routeParam.ReplaceAllString(route, uuid.NewString())
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+			routed := rec.Code != http.StatusMethodNotAllowed &&
+				(rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), `"error"`))
+			if !routed {
+				t.Errorf("base %q, %s %s: status %d, want the route reachable", basePath, method, target, rec.Code)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, reg := range r.registrations(nil) {
+			if mounted[reg.Prefix] == 0 {
+				t.Errorf("base %q: no routes under %s/v1%s", basePath, basePath, reg.Prefix)
+			}
+		}
+		if basePath != "" {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tasks", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("base %q: GET /v1/tasks outside the base path: status %d, want 404", basePath, rec.Code)
+			}
+		}
+	}
+}
+
+func TestPaginationLinksUnderBasePath(t *testing.T) {
+	userID := uuid.New()
+	users := newMemoryUserRepo(clock.NewFake(time.Now()))
+	users.users[userID] = &models.User{ID: userID, Email: "user@example.com", Role: "user"}
+
+	cfg := &config.Config{}
+	cfg.App.BasePath = "/api"
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(userID, "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(cfg, &repository.Repository{Task: &pagedTaskRepo{total: 25}, User: users}, jwtManager, nil, nil, nil, nil, testLogger(t)).SetupRoutes()
+
+	rec := sendJSON(router, http.MethodGet, "/api/v1/tasks?page=2&limit=10", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	want := `<http://example.com/api/v1/tasks?limit=10&page=3>; rel="next", ` +
+		`<http://example.com/api/v1/tasks?limit=10&page=1>; rel="prev"`
+	if got := rec.Header().Get("Link"); got != want {
+		t.Fatalf("Link %q, want %q", got, want)
+	}
+}
+
+func TestRequestLogHandlerAnnotation(t *testing.T) {
+	userID := uuid.New()
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(userID, "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, enabled := range []bool{false, true} {
+		users := newMemoryUserRepo(clock.NewFake(time.Now()))
+		users.users[userID] = &models.User{ID: userID, Email: "user@example.com", Role: "user"}
+		cfg := &config.Config{}
+		cfg.App.BasePath = "/api"
+		cfg.Logging.RequestHandler = enabled
+		log, entries := fileLogger(t, config.LoggingConfig{})
+		router := NewRouter(cfg, &repository.Repository{Task: &pagedTaskRepo{total: 1}, User: users}, jwtManager, nil, nil, nil, nil, log).SetupRoutes()
+
+		if rec := sendJSON(router, http.MethodGet, "/api/v1/tasks", token, ""); rec.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+		}
+		var request map[string]interface{}
+		for _, entry := range entries() {
+			if entry["msg"] == "HTTP Request" {
+				request = entry
+			}
+		}
+		if request == nil {
+			t.Fatal("no request log written")
+		}
+
+		if !enabled {
+			for _, key := range []string{"route", "handler", "handler_source"} {
+				if _, ok := request[key]; ok {
+					t.Errorf("disabled: request log has %s", key)
+				}
+			}
+			continue
+		}
+		if request["route"] != "/api/v1/tasks" {
+			t.Errorf("route = %v, want the /api/v1/tasks pattern", request["route"])
+		}
+		if request["handler"] != "handlers.(*TaskHandler).ListTasks" {
+			t.Errorf("handler = %v, want handlers.(*TaskHandler).ListTasks", request["handler"])
+		}
+		if source, _ := request["handler_source"].(string); !strings.HasPrefix(source, "handlers/task.go:") {
+			t.Errorf("handler_source = %v, want a line in handlers/task.go", request["handler_source"])
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	for _, enabled := range []bool{false, true} {
+		cfg := &config.Config{}
+		cfg.Metrics.Enabled = enabled
+		router := NewRouter(cfg, &repository.Repository{Task: fakeTaskRepo{}, User: fakeUserRepo{}}, jwtManager, nil, nil, nil, nil, testLogger(t)).SetupRoutes()
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health/live", nil))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if !enabled {
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("disabled: /metrics status %d, want 404", rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("/metrics status %d, want 200", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			`http_requests_total{method="GET",route="/health/live",status="200"}`,
+			`http_request_duration_seconds_count{method="GET",route="/health/live"}`,
+			"http_requests_in_flight",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("/metrics missing %s", want)
+			}
+		}
+	}
+}
+
+// missingTaskRepo has no tasks
+type missingTaskRepo struct {
+	fakeTaskRepo
+}
+
+func (missingTaskRepo) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	return nil, nil
+}
+
+func TestErrorResponsesCarryRequestID(t *testing.T) {
+	userID := uuid.New()
+	users := newMemoryUserRepo(clock.NewFake(time.Now()))
+	users.users[userID] = &models.User{ID: userID, Email: "user@example.com", Role: "user"}
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(userID, "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(&config.Config{}, &repository.Repository{Task: missingTaskRepo{}, User: users}, jwtManager, nil, nil, nil, nil, testLogger(t)).SetupRoutes()
+
+	tests := []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		// From the auth middleware, a handler, and request validation
+		{http.MethodGet, "/v1/tasks", "", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/tasks/" + uuid.NewString(), token, "", http.StatusNotFound},
+		{http.MethodGet, "/v1/tasks/not-a-uuid", token, "", http.StatusBadRequest},
+		{http.MethodPost, "/v1/tasks", token, `{"title": ""}`, http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		id := "req-" + strconv.Itoa(i)
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", id)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d: %s", tt.method, tt.path, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		var body struct {
+			RequestID string `json:"request_id"`
+			Path      string `json:"path"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.RequestID != id || rec.Header().Get("X-Request-ID") != id {
+			t.Errorf("%s %s: request_id %q, header %q, want %s: %s", tt.method, tt.path, body.RequestID, rec.Header().Get("X-Request-ID"), id, rec.Body)
+		}
+		if (body.Path != "" || tt.status == http.StatusNotFound) && body.Path != tt.path {
+			t.Errorf("%s %s: path %q, want the request path", tt.method, tt.path, body.Path)
+		}
+	}
+}
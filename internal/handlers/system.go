@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"secure-task-api/internal/health"
 	"secure-task-api/internal/logger"
 	"secure-task-api/internal/models"
 	"secure-task-api/internal/repository"
@@ -13,36 +18,66 @@ import (
 
 // SystemHandler handles system endpoints like health checks
 type SystemHandler struct {
-	repo *repository.Repository
-	log  *logger.Logger
+	repo    *repository.Repository
+	checks  []health.Check
+	monitor *health.Monitor
+	log     *logger.Logger
 }
 
-// NewSystemHandler creates a new system handler
-func NewSystemHandler(repo *repository.Repository, log *logger.Logger) *SystemHandler {
+// NewSystemHandler creates a new system handler. monitor may be nil when the
+// database isn't monitored in the background.
+func NewSystemHandler(repo *repository.Repository, monitor *health.Monitor, log *logger.Logger) *SystemHandler {
 	return &SystemHandler{
 		repo: repo,
-		log:  log,
+		checks: []health.Check{
+			{Name: "database", Required: true, Fn: repo.Task.HealthCheck},
+		},
+		monitor: monitor,
+		log:     log,
 	}
 }
 
 // RegisterRoutes registers system routes
 func (h *SystemHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/health", h.HealthCheck)
+	r.Get("/health/live", h.Liveness)
+	r.Get("/health/ready", h.HealthCheck)
+	r.Get("/readyz", h.Readiness)
 	r.Get("/debug/panic", h.TriggerPanic)
 }
 
-// HealthCheck checks the health of the service
+// Liveness reports that the process is up and serving, without touching
+// dependencies, for liveness probes
+func (h *SystemHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, models.HealthResponse{
+		Status:    "alive",
+		Timestamp: time.Now(),
+	})
+}
+
+// HealthCheck checks the database connection within health.DefaultTimeout and
+// reports connection pool stats, for readiness probes. While the background
+// monitor finds the database down it reports unhealthy without pinging it
+// again. /health is kept as an alias of /health/ready.
 func (h *SystemHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(r.Context(), health.DefaultTimeout)
+	defer cancel()
 
 	// Check database connection
-	err := h.repo.Task.HealthCheck(ctx)
+	var err error
+	if !h.monitor.Ready() {
+		err = errDatabaseDown
+	} else {
+		err = h.repo.Task.HealthCheck(ctx)
+	}
+	pool := poolStats(h.repo.Task.PoolStats())
 	if err != nil {
-		h.log.WithError(err).Error("Database health check failed")
+		logger.FromContext(r.Context()).WithError(err).Error("Database health check failed")
 		utils.JSONResponse(w, http.StatusServiceUnavailable, models.HealthResponse{
 			Status:    "unhealthy",
 			Timestamp: time.Now(),
 			Database:  "disconnected",
+			Pool:      pool,
 		})
 		return
 	}
@@ -51,11 +86,51 @@ func (h *SystemHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 		Status:    "healthy",
 		Timestamp: time.Now(),
 		Database:  "connected",
+		Pool:      pool,
 	})
 }
 
+// errDatabaseDown reports the background monitor's last check failing
+var errDatabaseDown = errors.New("database unreachable at last background check")
+
+// poolStats converts sql.DBStats to its response form
+func poolStats(s sql.DBStats) *models.DBPoolStats {
+	return &models.DBPoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMS:     float64(s.WaitDuration.Microseconds()) / 1000,
+	}
+}
+
+// Readiness runs all dependency checks in parallel and reports per-check status
+// and latency, returning 503 if any required check fails
+func (h *SystemHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := health.Run(r.Context(), h.checks)
+
+	// Only the status is served; why a check failed goes to the log
+	for _, res := range report.Checks {
+		if res.Status != "up" {
+			logger.FromContext(r.Context()).With(
+				zap.String("check", res.Name),
+				zap.Bool("required", res.Required),
+				zap.String("error", res.Error),
+			).Warn("Readiness check failed")
+		}
+	}
+
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+
+	utils.JSONResponse(w, status, report)
+}
+
 // TriggerPanic triggers a panic for testing Sentry integration
 func (h *SystemHandler) TriggerPanic(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Panic endpoint triggered for testing")
+	logger.FromContext(r.Context()).Info("Panic endpoint triggered for testing")
 	panic("Test panic for Sentry integration")
 }
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"secure-task-api/internal/health"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+)
+
+// pingTaskRepo fails its health check while down is set, noting the
+// deadline it was given
+type pingTaskRepo struct {
+	fakeTaskRepo
+	down     *atomic.Bool
+	deadline *time.Time
+}
+
+func (f pingTaskRepo) HealthCheck(ctx context.Context) error {
+	if f.deadline != nil {
+		*f.deadline, _ = ctx.Deadline()
+	}
+	if f.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (f pingTaskRepo) PoolStats() sql.DBStats {
+	return sql.DBStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2, WaitCount: 4, WaitDuration: 1500 * time.Microsecond}
+}
+
+func TestHealthReadyFollowsMonitor(t *testing.T) {
+	log := testLogger(t)
+	down := &atomic.Bool{}
+	tasks := pingTaskRepo{down: down}
+	monitor := health.NewMonitor(health.Check{Name: "database", Fn: tasks.HealthCheck}, time.Second, log)
+	router := chi.NewRouter()
+	NewSystemHandler(&repository.Repository{Task: tasks}, monitor, log).RegisterRoutes(router)
+
+	get := func() int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		return rec.Code
+	}
+
+	if status := get(); status != http.StatusOK {
+		t.Fatalf("before the outage: status %d, want 200", status)
+	}
+
+	down.Store(true)
+	monitor.Probe(context.Background())
+	// The monitor's verdict stands even once the ping would pass again
+	down.Store(false)
+	if status := get(); status != http.StatusServiceUnavailable {
+		t.Fatalf("during the outage: status %d, want 503", status)
+	}
+
+	monitor.Probe(context.Background())
+	if status := get(); status != http.StatusOK {
+		t.Fatalf("after recovery: status %d, want 200", status)
+	}
+}
+
+func TestReadyzHidesCheckErrors(t *testing.T) {
+	log := testLogger(t)
+	down := &atomic.Bool{}
+	down.Store(true)
+	tasks := pingTaskRepo{down: down}
+	monitor := health.NewMonitor(health.Check{Name: "database", Fn: tasks.HealthCheck}, time.Second, log)
+	router := chi.NewRouter()
+	NewSystemHandler(&repository.Repository{Task: tasks}, monitor, log).RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", rec.Code)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "connection refused") {
+		t.Fatalf("body %s leaks the ping error", body)
+	}
+	if !strings.Contains(body, `"status":"down"`) {
+		t.Fatalf("body %s, want the database check reported down", body)
+	}
+}
+
+func TestLivenessAndReadinessProbes(t *testing.T) {
+	for _, down := range []bool{false, true} {
+		var deadline time.Time
+		tasks := pingTaskRepo{down: &atomic.Bool{}, deadline: &deadline}
+		tasks.down.Store(down)
+		router := chi.NewRouter()
+		NewSystemHandler(&repository.Repository{Task: tasks}, nil, testLogger(t)).RegisterRoutes(router)
+
+		get := func(path string) (int, models.HealthResponse) {
+			t.Helper()
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			var resp models.HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%s: decode %s: %v", path, rec.Body, err)
+			}
+			return rec.Code, resp
+		}
+
+		// Liveness never consults the database
+		deadline = time.Time{}
+		if code, resp := get("/health/live"); code != http.StatusOK || resp.Status != "alive" || resp.Database != "" || resp.Pool != nil {
+			t.Fatalf("down=%v: /health/live %d %+v, want 200 alive without database", down, code, resp)
+		}
+		if !deadline.IsZero() {
+			t.Fatalf("down=%v: /health/live pinged the database", down)
+		}
+
+		wantCode, wantStatus, wantDB := http.StatusOK, "healthy", "connected"
+		if down {
+			wantCode, wantStatus, wantDB = http.StatusServiceUnavailable, "unhealthy", "disconnected"
+		}
+		wantPool := models.DBPoolStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2, WaitCount: 4, WaitDurationMS: 1.5}
+		for _, path := range []string{"/health/ready", "/health"} {
+			start := time.Now()
+			code, resp := get(path)
+			if code != wantCode || resp.Status != wantStatus || resp.Database != wantDB {
+				t.Errorf("down=%v: %s %d %+v, want %d %s %s", down, path, code, resp, wantCode, wantStatus, wantDB)
+			}
+			if resp.Pool == nil || *resp.Pool != wantPool {
+				t.Errorf("down=%v: %s pool %+v, want %+v", down, path, resp.Pool, wantPool)
+			}
+			if deadline.IsZero() || deadline.After(start.Add(health.DefaultTimeout+time.Second)) {
+				t.Errorf("down=%v: %s pinged with deadline %v, want one within %s", down, path, deadline, health.DefaultTimeout)
+			}
+		}
+	}
+}
@@ -1,96 +1,256 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 
+	"secure-task-api/internal/config"
 	"secure-task-api/internal/logger"
 	"secure-task-api/internal/middleware"
 	"secure-task-api/internal/models"
 	"secure-task-api/internal/repository"
+	"secure-task-api/internal/stats"
+	"secure-task-api/internal/webhook"
 	"secure-task-api/pkg/utils"
 )
 
 type TaskHandler struct {
-	repo *repository.Repository
-	log  *logger.Logger
+	repo                 *repository.Repository
+	cfg                  config.TaskConfig
+	stats                *stats.Cache
+	webhooks             *webhook.Dispatcher
+	requireContentLength bool
+	rateLimit            config.RateLimitConfig
+	log                  *logger.Logger
 }
 
-func NewTaskHandler(repo *repository.Repository, log *logger.Logger) *TaskHandler {
+// requireContentLength makes the bulk endpoints reject bodies of undeclared
+// length, and rateLimit.Bulk caps how often each key may call them.
+func NewTaskHandler(
+	repo *repository.Repository,
+	cfg config.TaskConfig,
+	statsCache *stats.Cache,
+	webhooks *webhook.Dispatcher,
+	requireContentLength bool,
+	rateLimit config.RateLimitConfig,
+	log *logger.Logger,
+) *TaskHandler {
 	return &TaskHandler{
-		repo: repo,
-		log:  log,
+		repo:                 repo,
+		cfg:                  cfg,
+		stats:                statsCache,
+		webhooks:             webhooks,
+		requireContentLength: requireContentLength,
+		rateLimit:            rateLimit,
+		log:                  log,
 	}
 }
 
+// maxBulkIDs caps how many tasks one bulk request may touch
+const maxBulkIDs = 500
+
+// maxImportTasks caps how many tasks one import may create
+const maxImportTasks = 500
+
+// staleTaskMessage answers an update based on an outdated task version
+const staleTaskMessage = "Task was modified by someone else; reload it and try again"
+
+// tagPattern is the format of a task tag: lowercase, no spaces, 1-32 characters
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
 func (h *TaskHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/", h.ListTasks)
 	r.Post("/", h.CreateTask)
+	r.Get("/stats", h.GetStats)
+	r.Get("/overdue", h.ListOverdueTasks)
+	r.Get("/calendar.ics", h.Calendar)
+
+	// Large-payload endpoints
+	r.Group(func(bulk chi.Router) {
+		if h.requireContentLength {
+			bulk.Use(middleware.RequireContentLength)
+		}
+		if limits := h.rateLimit; limits.Bulk > 0 {
+			bulk.Use(middleware.RateLimitBy(limits.Bulk, limits.Window, middleware.RateLimitKey(limits.BulkKey)))
+		}
+		bulk.Patch("/bulk-status", h.BulkUpdateStatus)
+		bulk.Post("/import", h.ImportTasks)
+	})
+
 	r.Get("/{id}", h.GetTask)
 	r.Put("/{id}", h.UpdateTask)
+	r.Patch("/{id}/status", h.UpdateTaskStatus)
 	r.Delete("/{id}", h.DeleteTask)
+	r.Post("/{id}/restore", h.RestoreTask)
+	r.Post("/{id}/share", h.ShareTask)
+	r.Delete("/{id}/collaborators/{userID}", h.RemoveCollaborator)
 }
 
 func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := middleware.GetUserIDFromContext(r.Context())
+	userID, ok := currentUserID(w, r)
 	if !ok {
-		utils.Unauthorized(w, "User not authenticated")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		h.log.WithError(err).Error("Invalid user ID in context")
-		utils.InternalServerError(w, "Invalid user context")
+	page, limit, ok := paginationParams(w, r, h.log)
+	if !ok {
 		return
 	}
 
-	page, limit := utils.GetPaginationParams(r)
+	filter, sort, ok := taskListQuery(w, r, h.log, h.cfg.GroupByStatus)
+	if !ok {
+		return
+	}
 
-	tasks, total, err := h.repo.Task.GetAll(r.Context(), userID, page, limit)
+	tasks, total, err := h.repo.Task.GetAll(r.Context(), userID, filter, sort, page, limit)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to fetch tasks")
-		utils.InternalServerError(w, "Failed to get tasks")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch tasks")
+		utils.InternalServerError(w, r, "Failed to get tasks")
 		return
 	}
 
-	totalPages := (total + limit - 1) / limit
+	resp := models.TaskListResponse{
+		Tasks:      tasks,
+		Pagination: pagination(page, limit, total),
+	}
 
-	utils.JSONSuccess(w, http.StatusOK, models.TaskListResponse{
-		Tasks: tasks,
-		Pagination: models.Pagination{
-			Page:       page,
-			Limit:      limit,
-			Total:      total,
-			TotalPages: totalPages,
-		},
-	})
+	if utils.GetQueryParam(r, "include_counts", "false") == "true" {
+		counts, err := h.repo.Task.CountVisibleByStatus(r.Context(), userID, filter)
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("Failed to count tasks")
+			utils.InternalServerError(w, r, "Failed to get tasks")
+			return
+		}
+		resp.Counts = &models.StatusCounts{
+			Pending:    counts[models.TaskStatusPending],
+			InProgress: counts[models.TaskStatusInProgress],
+			Completed:  counts[models.TaskStatusCompleted],
+		}
+	}
+
+	setPaginationHeaders(w, r, resp.Pagination)
+	utils.JSONSuccess(w, http.StatusOK, resp)
 }
 
-func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := middleware.GetUserIDFromContext(r.Context())
+// ListOverdueTasks lists the user's own and shared tasks that are past their
+// due date and not completed, most overdue first
+func (h *TaskHandler) ListOverdueTasks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	page, limit, ok := paginationParams(w, r, h.log)
 	if !ok {
-		utils.Unauthorized(w, "User not authenticated")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
+	tasks, total, err := h.repo.Task.GetOverdue(r.Context(), userID, page, limit)
 	if err != nil {
-		h.log.WithError(err).Error("Invalid user ID in context")
-		utils.InternalServerError(w, "Invalid user context")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch overdue tasks")
+		utils.InternalServerError(w, r, "Failed to get overdue tasks")
+		return
+	}
+
+	p := pagination(page, limit, total)
+	setPaginationHeaders(w, r, p)
+	utils.JSONSuccess(w, http.StatusOK, models.TaskListResponse{
+		Tasks:      tasks,
+		Pagination: p,
+	})
+}
+
+// taskListQuery parses the filter and sort query parameters of a task list,
+// writing a 400 and returning false if any is invalid
+func taskListQuery(w http.ResponseWriter, r *http.Request, log *logger.Logger, groupByStatusDefault bool) (filter models.TaskFilter, sort models.TaskSort, ok bool) {
+	filter = models.TaskFilter{
+		Status: models.TaskStatus(utils.GetQueryParam(r, "status", "")),
+		Search: strings.TrimSpace(utils.GetQueryParam(r, "q", "")),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		logValidationFailure(log, r, "status")
+		utils.BadRequest(w, r, "Invalid status filter")
+		return filter, sort, false
+	}
+
+	if raw := utils.GetQueryParam(r, "tags", ""); raw != "" {
+		for _, tag := range strings.Split(raw, ",") {
+			if !tagPattern.MatchString(tag) {
+				logValidationFailure(log, r, "tags")
+				utils.BadRequest(w, r, "tags must be a comma-separated list of valid tags")
+				return filter, sort, false
+			}
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+	switch utils.GetQueryParam(r, "tags_match", "all") {
+	case "any":
+		filter.TagsMatchAny = true
+	case "all":
+	default:
+		logValidationFailure(log, r, "tags_match")
+		utils.BadRequest(w, r, "tags_match must be all or any")
+		return filter, sort, false
+	}
+
+	groupByStatus, err := strconv.ParseBool(utils.GetQueryParam(r, "group_by_status", strconv.FormatBool(groupByStatusDefault)))
+	if err != nil {
+		logValidationFailure(log, r, "group_by_status")
+		utils.BadRequest(w, r, "group_by_status must be true or false")
+		return filter, sort, false
+	}
+	sort = models.TaskSort{
+		GroupByStatus: groupByStatus,
+		Field:         models.TaskSortField(utils.GetQueryParam(r, "sort_by", string(models.TaskSortCreatedAt))),
+	}
+	if !sort.Field.IsValid() {
+		logValidationFailure(log, r, "sort_by")
+		utils.BadRequest(w, r, "sort_by must be one of created_at, updated_at, due_date, title")
+		return filter, sort, false
+	}
+	switch utils.GetQueryParam(r, "order", "desc") {
+	case "asc":
+		sort.Asc = true
+	case "desc":
+	default:
+		logValidationFailure(log, r, "order")
+		utils.BadRequest(w, r, "order must be asc or desc")
+		return filter, sort, false
+	}
+
+	return filter, sort, true
+}
+
+func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
 		return
 	}
 
 	var req models.CreateTaskRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
-		utils.BadRequest(w, "Invalid request body")
+		invalidBody(w, r, err)
+		return
+	}
+
+	if !validRequest(w, r, h.log, req) {
+		return
+	}
+
+	if !h.validDescription(w, r, req.Description) {
 		return
 	}
 
-	if req.Title == "" {
-		utils.BadRequest(w, "Title is required")
+	tags, ok := h.validTags(w, r, req.Tags)
+	if !ok {
 		return
 	}
 
@@ -99,147 +259,755 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		Description: req.Description,
 		Status:      models.TaskStatusPending,
 		DueDate:     req.DueDate,
+		Tags:        tags,
 		UserID:      userID,
 	}
 
-	if err := h.repo.Task.Create(r.Context(), task); err != nil {
-		h.log.WithError(err).Error("Failed to create task")
-		utils.InternalServerError(w, "Failed to create task")
+	// The quota and title checks run with the insert, so concurrent
+	// requests can't all pass them
+	err := h.repo.Task.CreateWithinLimits(r.Context(), userID, []*models.Task{task}, h.limits())
+	if errors.Is(err, repository.ErrTaskQuotaExceeded) {
+		utils.Forbidden(w, r, "Task quota exceeded")
+		return
+	}
+	if errors.Is(err, repository.ErrTaskTitleTaken) {
+		utils.Conflict(w, r, "A task with this title already exists")
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to create task")
+		utils.InternalServerError(w, r, "Failed to create task")
 		return
 	}
+	h.stats.Invalidate(userID)
+	h.webhooks.Publish(webhook.EventTaskCreated, task)
 
-	utils.JSONSuccess(w, http.StatusCreated, map[string]interface{}{
-		"task": task,
-	})
+	utils.JSONSuccess(w, http.StatusCreated, models.TaskResponse{Task: task})
 }
 
 func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := middleware.GetUserIDFromContext(r.Context())
+	userID, ok := currentUserID(w, r)
 	if !ok {
-		utils.Unauthorized(w, "User not authenticated")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		h.log.WithError(err).Error("Invalid user ID in context")
-		utils.InternalServerError(w, "Invalid user context")
+	taskID, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
 		return
 	}
 
-	taskIDStr := chi.URLParam(r, "id")
-	taskID, err := uuid.Parse(taskIDStr)
+	// Owners may look up their own soft-deleted tasks for auditing
+	getTask := h.repo.Task.GetByID
+	if utils.GetQueryParam(r, "include_deleted", "false") == "true" {
+		getTask = h.repo.Task.GetByIDIncludingDeleted
+	}
+
+	task, err := getTask(r.Context(), taskID, userID)
 	if err != nil {
-		utils.BadRequest(w, "Invalid task ID")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch task")
+		utils.InternalServerError(w, r, "Failed to get task")
+		return
+	}
+
+	if task == nil {
+		utils.NotFound(w, r, "Task not found")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.TaskResponse{Task: task})
+}
+
+func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	taskID, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateTaskRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+
+	if !validRequest(w, r, h.log, req) {
+		return
+	}
+
+	if req.Title == nil && req.Description == nil && req.DueDate == nil && req.Status == nil && req.Tags == nil {
+		utils.BadRequest(w, r, "At least one field to update is required")
+		return
+	}
+
+	if req.Description != nil && !h.validDescription(w, r, *req.Description) {
 		return
 	}
 
+	fields := repository.TaskFields{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      req.Status,
+		DueDate:     req.DueDate,
+	}
+	if req.Tags != nil {
+		tags, ok := h.validTags(w, r, *req.Tags)
+		if !ok {
+			return
+		}
+		fields.Tags = &tags
+	}
+
 	task, err := h.repo.Task.GetByID(r.Context(), taskID, userID)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to fetch task")
-		utils.InternalServerError(w, "Failed to get task")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch task")
+		utils.InternalServerError(w, r, "Failed to update task")
 		return
 	}
 
 	if task == nil {
-		utils.NotFound(w, "Task not found")
+		utils.NotFound(w, r, "Task not found")
+		return
+	}
+	if task.Version != req.Version {
+		utils.Conflict(w, r, staleTaskMessage)
+		return
+	}
+	if req.Status != nil && !h.transitions().Allowed(task.Status, *req.Status) {
+		utils.Conflict(w, r, "Cannot change status from "+task.Status.String()+" to "+req.Status.String())
 		return
 	}
 
-	utils.JSONSuccess(w, http.StatusOK, map[string]interface{}{
-		"task": task,
-	})
+	// Collaborators edit the owner's task, so titles are unique among the owner's
+	if req.Title != nil && h.cfg.UniqueTitles {
+		exists, err := h.repo.Task.TitleExists(r.Context(), task.UserID, *req.Title,
+			uuid.NullUUID{UUID: task.ID, Valid: true})
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("Failed to check task title")
+			utils.InternalServerError(w, r, "Failed to update task")
+			return
+		}
+		if exists {
+			utils.Conflict(w, r, "A task with this title already exists")
+			return
+		}
+	}
+
+	updated, err := h.repo.Task.Update(r.Context(), task.ID, task.UserID, req.Version, fields)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		// Deleted concurrently
+		utils.NotFound(w, r, "Task not found")
+		return
+	}
+	if errors.Is(err, repository.ErrTaskVersionConflict) {
+		utils.Conflict(w, r, staleTaskMessage)
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to update task")
+		utils.InternalServerError(w, r, "Failed to update task")
+		return
+	}
+	updated.Role = task.Role
+	h.stats.Invalidate(task.UserID)
+	h.webhooks.Publish(updateEvent(task.Status, updated.Status), updated)
+
+	utils.JSONSuccess(w, http.StatusOK, models.TaskResponse{Task: updated})
 }
 
-func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := middleware.GetUserIDFromContext(r.Context())
+// UpdateTaskStatus changes only a task's status, so clients toggling it needn't
+// send, or risk overwriting, the rest of the task. Collaborators may change it
+// too; setting the status a task already has is a no-op.
+func (h *TaskHandler) UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	taskID, ok := uuidParam(w, r, h.log, "id")
 	if !ok {
-		utils.Unauthorized(w, "User not authenticated")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
+	var req models.UpdateTaskStatusRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	if !req.Status.IsValid() {
+		logValidationFailure(h.log, r, "status")
+		utils.ValidationError(w, map[string]string{
+			"status": "status must be one of pending, in_progress, completed",
+		})
+		return
+	}
+
+	task, changed, err := h.repo.Task.UpdateStatus(r.Context(), taskID, userID, req.Status, h.transitions())
+	var transitionErr *repository.TransitionError
+	if errors.As(err, &transitionErr) {
+		utils.Conflict(w, r, "Cannot change status from "+transitionErr.From.String()+" to "+transitionErr.To.String())
+		return
+	}
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		utils.NotFound(w, r, "Task not found")
+		return
+	}
 	if err != nil {
-		h.log.WithError(err).Error("Invalid user ID in context")
-		utils.InternalServerError(w, "Invalid user context")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to update task status")
+		utils.InternalServerError(w, r, "Failed to update task")
+		return
+	}
+	if changed {
+		h.stats.Invalidate(task.UserID)
+		h.webhooks.Publish(updateEvent("", task.Status), task)
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.TaskResponse{Task: task})
+}
+
+// DeleteTask soft-deletes a task. Only its owner may delete it.
+func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	taskID, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
 		return
 	}
 
-	taskIDStr := chi.URLParam(r, "id")
-	taskID, err := uuid.Parse(taskIDStr)
+	if !h.ownedTask(w, r, taskID, userID, "Only the task owner can delete it", "Failed to delete task") {
+		return
+	}
+
+	err := h.repo.Task.Delete(r.Context(), taskID, userID)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		// Deleted concurrently
+		utils.NotFound(w, r, "Task not found")
+		return
+	}
 	if err != nil {
-		utils.BadRequest(w, "Invalid task ID")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to delete task")
+		utils.InternalServerError(w, r, "Failed to delete task")
 		return
 	}
+	h.stats.Invalidate(userID)
+	h.publishStored(r, webhook.EventTaskDeleted, taskID, userID, true)
 
-	var req models.UpdateTaskRequest
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreTask undoes a soft delete. Restoring a task that isn't deleted, or
+// that belongs to someone else, is a 404.
+func (h *TaskHandler) RestoreTask(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	taskID, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
+		return
+	}
+
+	deleted, err := h.repo.Task.GetByIDIncludingDeleted(r.Context(), taskID, userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch task")
+		utils.InternalServerError(w, r, "Failed to restore task")
+		return
+	}
+	if deleted == nil || deleted.DeletedAt == nil {
+		utils.NotFound(w, r, "Deleted task not found")
+		return
+	}
+
+	// A restored task counts against the same limits as a new one
+	if h.cfg.MaxPerUser > 0 {
+		count, err := h.repo.Task.CountByUser(r.Context(), userID)
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("Failed to count tasks")
+			utils.InternalServerError(w, r, "Failed to restore task")
+			return
+		}
+		if count >= h.cfg.MaxPerUser {
+			utils.Forbidden(w, r, "Task quota exceeded")
+			return
+		}
+	}
+	if h.cfg.UniqueTitles {
+		exists, err := h.repo.Task.TitleExists(r.Context(), userID, deleted.Title, uuid.NullUUID{})
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("Failed to check task title")
+			utils.InternalServerError(w, r, "Failed to restore task")
+			return
+		}
+		if exists {
+			utils.Conflict(w, r, "A task with this title already exists")
+			return
+		}
+	}
+
+	task, err := h.repo.Task.Restore(r.Context(), taskID, userID)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		// Restored concurrently
+		utils.NotFound(w, r, "Deleted task not found")
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to restore task")
+		utils.InternalServerError(w, r, "Failed to restore task")
+		return
+	}
+	h.stats.Invalidate(userID)
+
+	utils.JSONSuccess(w, http.StatusOK, models.TaskResponse{Task: task})
+}
+
+// ShareTask lets another user, named by email, see and edit a task. Only its
+// owner may share it. Sharing again with the same user is a no-op.
+func (h *TaskHandler) ShareTask(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	taskID, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
+		return
+	}
+
+	var req models.ShareTaskRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
-		utils.BadRequest(w, "Invalid request body")
+		invalidBody(w, r, err)
 		return
 	}
 
-	task, err := h.repo.Task.GetByID(r.Context(), taskID, userID)
+	if !validRequest(w, r, h.log, req) {
+		return
+	}
+
+	if !h.ownedTask(w, r, taskID, userID, "Only the task owner can share it", "Failed to share task") {
+		return
+	}
+
+	collaborator, err := h.repo.User.GetByEmail(r.Context(), req.Email)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch user")
+		utils.InternalServerError(w, r, "Failed to share task")
+		return
+	}
+	if collaborator == nil {
+		utils.NotFound(w, r, "User not found")
+		return
+	}
+	if collaborator.ID == userID {
+		utils.BadRequest(w, r, "You already own this task")
+		return
+	}
+
+	added, err := h.repo.Task.AddCollaborator(r.Context(), taskID, userID, collaborator.ID)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		// Deleted concurrently
+		utils.NotFound(w, r, "Task not found")
+		return
+	}
 	if err != nil {
-		h.log.WithError(err).Error("Failed to fetch task")
-		utils.InternalServerError(w, "Failed to update task")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to share task")
+		utils.InternalServerError(w, r, "Failed to share task")
 		return
 	}
 
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	utils.JSONSuccess(w, status, models.CollaboratorResponse{
+		Collaborator: models.Collaborator{
+			UserID: collaborator.ID,
+			Email:  collaborator.Email,
+			Name:   collaborator.Name,
+		},
+	})
+}
+
+// RemoveCollaborator stops sharing a task with a user. Only its owner may
+// remove collaborators.
+func (h *TaskHandler) RemoveCollaborator(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	taskID, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
+		return
+	}
+
+	collaboratorID, ok := uuidParam(w, r, h.log, "userID")
+	if !ok {
+		return
+	}
+
+	if !h.ownedTask(w, r, taskID, userID, "Only the task owner can remove collaborators", "Failed to remove collaborator") {
+		return
+	}
+
+	err := h.repo.Task.RemoveCollaborator(r.Context(), taskID, userID, collaboratorID)
+	if errors.Is(err, repository.ErrCollaboratorNotFound) {
+		utils.NotFound(w, r, "Collaborator not found")
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to remove collaborator")
+		utils.InternalServerError(w, r, "Failed to remove collaborator")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedTask reports whether the user owns the live task taskID,
+// writing 404 if they can't see it and 403 with forbidden if they only
+// collaborate on it. failure is the message for a failed lookup.
+func (h *TaskHandler) ownedTask(w http.ResponseWriter, r *http.Request, taskID, userID uuid.UUID, forbidden, failure string) bool {
+	task, err := h.repo.Task.GetByID(r.Context(), taskID, userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to fetch task")
+		utils.InternalServerError(w, r, failure)
+		return false
+	}
 	if task == nil {
-		utils.NotFound(w, "Task not found")
+		utils.NotFound(w, r, "Task not found")
+		return false
+	}
+	if task.Role != models.TaskRoleOwner {
+		utils.Forbidden(w, r, forbidden)
+		return false
+	}
+	return true
+}
+
+// BulkUpdateStatus sets the status of many of the user's tasks at once and
+// reports the outcome for each requested id
+func (h *TaskHandler) BulkUpdateStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.BulkStatusRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
 		return
 	}
 
-	if req.Title != "" {
-		task.Title = req.Title
+	if len(req.IDs) == 0 {
+		logValidationFailure(h.log, r, "ids")
+		utils.BadRequest(w, r, "ids must contain at least one task ID")
+		return
 	}
-	if req.Description != "" {
-		task.Description = req.Description
+	if len(req.IDs) > maxBulkIDs {
+		logValidationFailure(h.log, r, "ids")
+		utils.BadRequest(w, r, "ids must contain at most "+strconv.Itoa(maxBulkIDs)+" task IDs")
+		return
 	}
-	if req.Status.IsValid() {
-		task.Status = req.Status
+	if !req.Status.IsValid() {
+		logValidationFailure(h.log, r, "status")
+		utils.BadRequest(w, r, "Invalid status")
+		return
 	}
-	if req.DueDate != nil {
-		task.DueDate = *req.DueDate
+
+	ids := make([]uuid.UUID, len(req.IDs))
+	for i, raw := range req.IDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			logValidationFailure(h.log, r, "ids")
+			utils.ValidationError(w, map[string]string{
+				"ids": "ids[" + strconv.Itoa(i) + "] must be a valid UUID",
+			})
+			return
+		}
+		ids[i] = id
 	}
 
-	if err := h.repo.Task.Update(r.Context(), task); err != nil {
-		h.log.WithError(err).Error("Failed to update task")
-		utils.InternalServerError(w, "Failed to update task")
+	results, err := h.repo.Task.BulkUpdateStatus(r.Context(), userID, ids, req.Status, h.transitions())
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to bulk update task status")
+		utils.InternalServerError(w, r, "Failed to update tasks")
 		return
 	}
+	h.stats.Invalidate(userID)
+	if h.webhooks.Enabled() {
+		event := updateEvent("", req.Status)
+		for _, result := range results {
+			if result.Result == models.BulkResultUpdated {
+				h.publishStored(r, event, result.ID, userID, false)
+			}
+		}
+	}
 
-	utils.JSONSuccess(w, http.StatusOK, map[string]interface{}{
-		"task": task,
-	})
+	utils.JSONSuccess(w, http.StatusOK, models.BulkStatusResponse{Results: results})
 }
 
-func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := middleware.GetUserIDFromContext(r.Context())
+// ImportTasks creates many tasks from a JSON array in one transaction. Every
+// row must be valid unless partial=true, which skips invalid rows and imports
+// the rest. Either way the response lists the rejected rows.
+func (h *TaskHandler) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
 	if !ok {
-		utils.Unauthorized(w, "User not authenticated")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
+	partial, err := strconv.ParseBool(utils.GetQueryParam(r, "partial", "false"))
+	if err != nil {
+		logValidationFailure(h.log, r, "partial")
+		utils.BadRequest(w, r, "partial must be true or false")
+		return
+	}
+
+	var rows []models.ImportTaskRequest
+	if err := utils.ParseJSON(r, &rows); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	if len(rows) == 0 {
+		logValidationFailure(h.log, r, "tasks")
+		utils.BadRequest(w, r, "At least one task is required")
+		return
+	}
+	if len(rows) > maxImportTasks {
+		utils.PayloadTooLarge(w, r, "At most "+strconv.Itoa(maxImportTasks)+" tasks may be imported at once")
+		return
+	}
+
+	tasks, rowErrors, err := h.importableTasks(r, userID, rows)
 	if err != nil {
-		h.log.WithError(err).Error("Invalid user ID in context")
-		utils.InternalServerError(w, "Invalid user context")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to check task titles")
+		utils.InternalServerError(w, r, "Failed to import tasks")
+		return
+	}
+	if len(rowErrors) > 0 {
+		logValidationFailure(h.log, r, "tasks")
+	}
+	if len(tasks) == 0 || (len(rowErrors) > 0 && !partial) {
+		utils.JSONResponse(w, http.StatusBadRequest, models.ValidationErrorResponse[[]models.ImportRowError]{
+			Error:     "Validation Error",
+			Message:   "Invalid tasks, none were imported",
+			Errors:    rowErrors,
+			RequestID: w.Header().Get(utils.RequestIDHeader),
+		})
 		return
 	}
 
-	taskIDStr := chi.URLParam(r, "id")
-	taskID, err := uuid.Parse(taskIDStr)
+	err = h.repo.Task.CreateWithinLimits(r.Context(), userID, tasks, h.limits())
+	if errors.Is(err, repository.ErrTaskQuotaExceeded) {
+		utils.Forbidden(w, r, "Task quota exceeded")
+		return
+	}
+	if errors.Is(err, repository.ErrTaskTitleTaken) {
+		// Taken concurrently since the rows were checked
+		utils.Conflict(w, r, "A task with this title already exists")
+		return
+	}
 	if err != nil {
-		utils.BadRequest(w, "Invalid task ID")
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to import tasks")
+		utils.InternalServerError(w, r, "Failed to import tasks")
 		return
 	}
+	h.stats.Invalidate(userID)
+	for _, task := range tasks {
+		h.webhooks.Publish(webhook.EventTaskCreated, task)
+	}
+
+	if rowErrors == nil {
+		rowErrors = []models.ImportRowError{}
+	}
+	utils.JSONSuccess(w, http.StatusCreated, models.ImportTasksResponse{
+		Created: len(tasks),
+		Errors:  rowErrors,
+	})
+}
+
+// updateEvent is the webhook event for an update moving a task from one
+// status to another
+func updateEvent(from, to models.TaskStatus) string {
+	if to == models.TaskStatusCompleted && from != models.TaskStatusCompleted {
+		return webhook.EventTaskCompleted
+	}
+	return webhook.EventTaskUpdated
+}
 
-	if err := h.repo.Task.Delete(r.Context(), taskID, userID); err != nil {
-		h.log.WithError(err).Error("Failed to delete task")
-		utils.InternalServerError(w, "Failed to delete task")
+// publishStored sends event for a task after a change that didn't return it,
+// loading the task as stored. Nothing is loaded when webhooks are off, and a
+// failed load only skips the event, since the change itself succeeded.
+func (h *TaskHandler) publishStored(r *http.Request, event string, taskID, userID uuid.UUID, includeDeleted bool) {
+	if !h.webhooks.Enabled() {
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	get := h.repo.Task.GetByID
+	if includeDeleted {
+		get = h.repo.Task.GetByIDIncludingDeleted
+	}
+	task, err := get(r.Context(), taskID, userID)
+	if err != nil || task == nil {
+		logger.FromContext(r.Context()).WithError(err).Warn("failed to load task for webhook",
+			zap.String("event", event),
+			zap.String("task_id", taskID.String()),
+		)
+		return
+	}
+	h.webhooks.Publish(event, task)
+}
+
+// importableTasks validates each import row as CreateTask would, returning
+// the tasks for the valid rows and the problems with the others. With unique
+// titles, a title repeated within the import counts as taken.
+func (h *TaskHandler) importableTasks(r *http.Request, userID uuid.UUID, rows []models.ImportTaskRequest) ([]*models.Task, []models.ImportRowError, error) {
+	var tasks []*models.Task
+	var rowErrors []models.ImportRowError
+	titles := make(map[string]bool)
+
+	for i, row := range rows {
+		problems := utils.ValidateStruct(row)
+		if problem := h.descriptionProblem(row.Description); problem != "" {
+			problems["description"] = problem
+		}
+		tags, problem := h.normalizeTags(row.Tags)
+		if problem != "" {
+			problems["tags"] = problem
+		}
+
+		if _, failed := problems["title"]; !failed && h.cfg.UniqueTitles {
+			title := strings.ToLower(strings.TrimSpace(row.Title))
+			exists := titles[title]
+			if !exists {
+				var err error
+				if exists, err = h.repo.Task.TitleExists(r.Context(), userID, row.Title, uuid.NullUUID{}); err != nil {
+					return nil, nil, err
+				}
+			}
+			if exists {
+				problems["title"] = "A task with this title already exists"
+			}
+			titles[title] = true
+		}
+
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: i, Errors: problems})
+			continue
+		}
+
+		status := row.Status
+		if status == "" {
+			status = models.TaskStatusPending
+		}
+		tasks = append(tasks, &models.Task{
+			Title:       row.Title,
+			Description: row.Description,
+			Status:      status,
+			DueDate:     row.DueDate,
+			Tags:        tags,
+			UserID:      userID,
+		})
+	}
+
+	return tasks, rowErrors, nil
+}
+
+// Calendar exports the user's tasks with due dates as an iCalendar file
+func (h *TaskHandler) Calendar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	writeTaskCalendar(w, r, h.repo, userID, h.cfg.ExportBatchSize)
+}
+
+// GetStats returns per-status task counts. Cached values are served when the
+// stats cache is enabled, unless fresh=true forces a recompute.
+func (h *TaskHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	fresh := utils.GetQueryParam(r, "fresh", "false") == "true"
+
+	taskStats, err := h.stats.Get(r.Context(), userID, fresh)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("Failed to compute task stats")
+		utils.InternalServerError(w, r, "Failed to get task stats")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.TaskStatsResponse{Stats: taskStats})
+}
+
+// transitions returns the configured task status rules
+func (h *TaskHandler) transitions() models.TaskTransitions {
+	return models.TaskTransitions{AllowReopen: h.cfg.AllowReopen}
+}
+
+// limits returns the configured per-user rules for new tasks
+func (h *TaskHandler) limits() repository.TaskLimits {
+	return repository.TaskLimits{MaxPerUser: h.cfg.MaxPerUser, UniqueTitles: h.cfg.UniqueTitles}
+}
+
+// validDescription enforces the configured maximum description length,
+// counted in runes, writing a validation error if it is exceeded
+func (h *TaskHandler) validDescription(w http.ResponseWriter, r *http.Request, description string) bool {
+	problem := h.descriptionProblem(description)
+	if problem == "" {
+		return true
+	}
+	logValidationFailure(h.log, r, "description")
+	utils.ValidationError(w, map[string]string{"description": problem})
+	return false
+}
+
+// descriptionProblem describes why description is too long, or returns ""
+func (h *TaskHandler) descriptionProblem(description string) string {
+	if utf8.RuneCountInString(description) <= h.cfg.MaxDescriptionLength {
+		return ""
+	}
+	return "description must be at most " + strconv.Itoa(h.cfg.MaxDescriptionLength) + " characters"
+}
+
+// validTags checks each tag's format and the configured maximum per task,
+// writing a validation error if either fails. Repeated tags are dropped.
+func (h *TaskHandler) validTags(w http.ResponseWriter, r *http.Request, tags []string) ([]string, bool) {
+	unique, problem := h.normalizeTags(tags)
+	if problem == "" {
+		return unique, true
+	}
+	logValidationFailure(h.log, r, "tags")
+	utils.ValidationError(w, map[string]string{"tags": problem})
+	return nil, false
+}
+
+// normalizeTags drops repeated tags, describing the first problem with the
+// rest if there is one
+func (h *TaskHandler) normalizeTags(tags []string) ([]string, string) {
+	seen := make(map[string]bool, len(tags))
+	unique := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return nil, "tags[" + strconv.Itoa(i) + "] must be 1-32 lowercase letters, digits, '-' or '_'"
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+
+	if len(unique) > h.cfg.MaxTags {
+		return nil, "tags must contain at most " + strconv.Itoa(h.cfg.MaxTags) + " tags"
+	}
+	return unique, ""
 }
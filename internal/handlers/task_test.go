@@ -0,0 +1,1305 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+	"secure-task-api/internal/stats"
+	"secure-task-api/pkg/utils"
+)
+
+// statusTaskRepo records status updates to tasks it holds
+type statusTaskRepo struct {
+	fakeTaskRepo
+	tasks   map[uuid.UUID]*models.Task
+	updates int
+}
+
+func (f *statusTaskRepo) UpdateStatus(ctx context.Context, id, userID uuid.UUID, status models.TaskStatus, transitions models.TaskTransitions) (*models.Task, bool, error) {
+	task, ok := f.tasks[id]
+	if !ok || task.UserID != userID {
+		return nil, false, repository.ErrTaskNotFound
+	}
+	f.updates++
+	if !transitions.Allowed(task.Status, status) {
+		return nil, false, &repository.TransitionError{From: task.Status, To: status}
+	}
+	changed := task.Status != status
+	task.Status = status
+	return task, changed, nil
+}
+
+func (f *statusTaskRepo) BulkUpdateStatus(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, status models.TaskStatus, transitions models.TaskTransitions) ([]models.BulkStatusResult, error) {
+	f.updates++
+	results := make([]models.BulkStatusResult, len(ids))
+	for i, id := range ids {
+		result := models.BulkResultUpdated
+		if task, ok := f.tasks[id]; !ok || task.UserID != userID {
+			result = models.BulkResultNotFound
+		} else if task.Status == status {
+			result = models.BulkResultSkipped
+		} else if !transitions.Allowed(task.Status, status) {
+			result = models.BulkResultRejected
+		} else {
+			task.Status = status
+		}
+		results[i] = models.BulkStatusResult{ID: id, Result: result}
+	}
+	return results, nil
+}
+
+// memoryTaskRepo keeps the tasks created through it, enforcing limits as
+// TaskRepository.CreateWithinLimits does
+type memoryTaskRepo struct {
+	fakeTaskRepo
+	tasks []*models.Task
+}
+
+func (f *memoryTaskRepo) CreateWithinLimits(ctx context.Context, userID uuid.UUID, tasks []*models.Task, limits repository.TaskLimits) error {
+	count, _ := f.CountByUser(ctx, userID)
+	if limits.MaxPerUser > 0 && count+len(tasks) > limits.MaxPerUser {
+		return repository.ErrTaskQuotaExceeded
+	}
+	if limits.UniqueTitles {
+		for _, task := range tasks {
+			for _, existing := range f.tasks {
+				if existing.UserID == userID && strings.EqualFold(strings.TrimSpace(existing.Title), strings.TrimSpace(task.Title)) {
+					return repository.ErrTaskTitleTaken
+				}
+			}
+		}
+	}
+	for _, task := range tasks {
+		task.ID = uuid.New()
+		task.Version = 1
+		f.tasks = append(f.tasks, task)
+	}
+	return nil
+}
+
+func (f *memoryTaskRepo) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	n := 0
+	for _, task := range f.tasks {
+		if task.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// taskServer serves the task routes for userID over repo, returning the
+// handler and a bearer token for the user
+func taskServer(t *testing.T, repo repository.TaskRepositoryInterface, userID uuid.UUID) (http.Handler, string) {
+	t.Helper()
+	return taskServerWith(t, repo, userID, config.TaskConfig{})
+}
+
+// taskServerWith is taskServer with cfg
+func taskServerWith(t *testing.T, repo repository.TaskRepositoryInterface, userID uuid.UUID, cfg config.TaskConfig) (http.Handler, string) {
+	t.Helper()
+	log := testLogger(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(userID, "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewTaskHandler(&repository.Repository{Task: repo}, cfg, stats.NewCache(nil, false, 0, log), nil, false, config.RateLimitConfig{}, log)
+	router := chi.NewRouter()
+	router.Use(middleware.AuthMiddleware(jwtManager, nil, log))
+	router.Route("/tasks", h.RegisterRoutes)
+	return router, token
+}
+
+func TestUpdateTaskStatus(t *testing.T) {
+	userID := uuid.New()
+	task := &models.Task{ID: uuid.New(), UserID: userID, Title: "Write report", Status: models.TaskStatusPending}
+	repo := &statusTaskRepo{tasks: map[uuid.UUID]*models.Task{task.ID: task}}
+	handler, token := taskServer(t, repo, userID)
+
+	rec := sendJSON(handler, http.MethodPatch, "/tasks/"+task.ID.String()+"/status", token, `{"status": "completed"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp models.APIResponse[models.TaskResponse]
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Task.Status != models.TaskStatusCompleted || resp.Data.Task.Title != "Write report" {
+		t.Fatalf("task = %+v, want completed with its title kept", resp.Data.Task)
+	}
+
+	rec = sendJSON(handler, http.MethodPatch, "/tasks/"+uuid.NewString()+"/status", token, `{"status": "completed"}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing task: status %d, want 404", rec.Code)
+	}
+}
+
+func TestUpdateTaskStatusRejectsInvalidStatus(t *testing.T) {
+	userID := uuid.New()
+	task := &models.Task{ID: uuid.New(), UserID: userID, Status: models.TaskStatusPending}
+	repo := &statusTaskRepo{tasks: map[uuid.UUID]*models.Task{task.ID: task}}
+	handler, token := taskServer(t, repo, userID)
+
+	for _, body := range []string{`{"status": "done"}`, `{"status": ""}`, `{}`} {
+		rec := sendJSON(handler, http.MethodPatch, "/tasks/"+task.ID.String()+"/status", token, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status %d, want 400", body, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "status") {
+			t.Errorf("body %s: error %s does not name the status field", body, rec.Body)
+		}
+	}
+	if repo.updates != 0 {
+		t.Fatalf("invalid statuses reached the repository %d times", repo.updates)
+	}
+}
+
+func TestCreateTaskQuota(t *testing.T) {
+	userID := uuid.New()
+	repo := &memoryTaskRepo{}
+	handler, token := taskServerWith(t, repo, userID, config.TaskConfig{MaxPerUser: 2})
+
+	for _, title := range []string{"Write report", "File expenses"} {
+		if rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "`+title+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("create %q: status %d, want 201: %s", title, rec.Code, rec.Body)
+		}
+	}
+
+	rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "Book flights"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("over quota: status %d, want 403: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "Task quota exceeded") {
+		t.Fatalf("body %s, want the quota message", rec.Body)
+	}
+	if len(repo.tasks) != 2 {
+		t.Fatalf("%d tasks stored, want 2", len(repo.tasks))
+	}
+
+	// Other users have their own quota
+	other, otherToken := taskServerWith(t, repo, uuid.New(), config.TaskConfig{MaxPerUser: 2})
+	if rec := sendJSON(other, http.MethodPost, "/tasks", otherToken, `{"title": "Book flights"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("other user: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCreateTaskDescriptionLengthBoundary(t *testing.T) {
+	repo := &memoryTaskRepo{}
+	handler, token := taskServerWith(t, repo, uuid.New(), config.TaskConfig{MaxDescriptionLength: 5})
+
+	// Five runes but six bytes: the limit counts runes
+	if rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "Write report", "description": "héllo"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("at the limit: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+
+	rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "Write report", "description": "héllo!"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "description must be at most 5 characters") {
+		t.Fatalf("over the limit: status %d, want 400 naming the limit: %s", rec.Code, rec.Body)
+	}
+	if len(repo.tasks) != 1 {
+		t.Fatalf("%d tasks stored, want only the one within the limit", len(repo.tasks))
+	}
+}
+
+func TestCreateTaskUniqueTitles(t *testing.T) {
+	userID := uuid.New()
+	repo := &memoryTaskRepo{}
+	handler, token := taskServerWith(t, repo, userID, config.TaskConfig{UniqueTitles: true})
+
+	if rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "Buy milk"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("first: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+	for _, title := range []string{"buy milk", "BUY MILK", "Buy milk "} {
+		rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "`+title+`"}`)
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("%q: status %d, want 409: %s", title, rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), "A task with this title already exists") {
+			t.Fatalf("%q: body %s, want the title conflict message", title, rec.Body)
+		}
+	}
+	if rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "Buy bread"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("different title: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+
+	// With the policy off, variants are separate tasks
+	handler, token = taskServerWith(t, repo, userID, config.TaskConfig{})
+	if rec := sendJSON(handler, http.MethodPost, "/tasks", token, `{"title": "buy milk"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("policy off: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBulkUpdateStatusMixedResults(t *testing.T) {
+	userID := uuid.New()
+	pending := &models.Task{ID: uuid.New(), UserID: userID, Status: models.TaskStatusPending}
+	started := &models.Task{ID: uuid.New(), UserID: userID, Status: models.TaskStatusInProgress}
+	done := &models.Task{ID: uuid.New(), UserID: userID, Status: models.TaskStatusCompleted}
+	foreign := &models.Task{ID: uuid.New(), UserID: uuid.New(), Status: models.TaskStatusPending}
+	repo := &statusTaskRepo{tasks: map[uuid.UUID]*models.Task{}}
+	for _, task := range []*models.Task{pending, started, done, foreign} {
+		repo.tasks[task.ID] = task
+	}
+	handler, token := taskServer(t, repo, userID)
+
+	ids := []uuid.UUID{pending.ID, started.ID, done.ID, foreign.ID, uuid.New()}
+	want := []string{models.BulkResultUpdated, models.BulkResultSkipped, models.BulkResultRejected, models.BulkResultNotFound, models.BulkResultNotFound}
+	body, _ := json.Marshal(map[string]any{"ids": ids, "status": models.TaskStatusInProgress})
+	rec := sendJSON(handler, http.MethodPatch, "/tasks/bulk-status", token, string(body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	results := responseData[models.BulkStatusResponse](t, rec.Body.Bytes()).Results
+	if len(results) != len(ids) {
+		t.Fatalf("%d results, want %d", len(results), len(ids))
+	}
+	for i, result := range results {
+		if result.ID != ids[i] || result.Result != want[i] {
+			t.Errorf("result %d = %+v, want %s for %s", i, result, want[i], ids[i])
+		}
+	}
+	if foreign.Status != models.TaskStatusPending {
+		t.Fatal("another user's task was updated")
+	}
+}
+
+func TestBulkUpdateStatusIDCap(t *testing.T) {
+	userID := uuid.New()
+	repo := &statusTaskRepo{tasks: map[uuid.UUID]*models.Task{}}
+	handler, token := taskServer(t, repo, userID)
+
+	send := func(n int) *httptest.ResponseRecorder {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = uuid.NewString()
+		}
+		body, _ := json.Marshal(map[string]any{"ids": ids, "status": models.TaskStatusCompleted})
+		return sendJSON(handler, http.MethodPatch, "/tasks/bulk-status", token, string(body))
+	}
+
+	if rec := send(0); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no ids: status %d, want 400", rec.Code)
+	}
+	if rec := send(maxBulkIDs); rec.Code != http.StatusOK {
+		t.Fatalf("%d ids: status %d, want 200: %s", maxBulkIDs, rec.Code, rec.Body)
+	}
+	rec := send(maxBulkIDs + 1)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "at most 500") {
+		t.Fatalf("%d ids: status %d, want 400 naming the cap: %s", maxBulkIDs+1, rec.Code, rec.Body)
+	}
+	if repo.updates != 1 {
+		t.Fatalf("repository called %d times, want only for the request within the cap", repo.updates)
+	}
+}
+
+// pagedTaskRepo lists a page of a user's total tasks
+type pagedTaskRepo struct {
+	fakeTaskRepo
+	total int
+}
+
+func (f *pagedTaskRepo) GetAll(ctx context.Context, userID uuid.UUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	tasks := make([]models.Task, 0, limit)
+	for i := (page - 1) * limit; i < page*limit && i < f.total; i++ {
+		tasks = append(tasks, models.Task{ID: uuid.New(), UserID: userID})
+	}
+	return tasks, f.total, nil
+}
+
+func TestListTasksPaginationHeaders(t *testing.T) {
+	handler, token := taskServer(t, &pagedTaskRepo{total: 45}, uuid.New())
+	list := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := sendJSON(handler, http.MethodGet, "/tasks?"+query, token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %s: status %d, want 200: %s", query, rec.Code, rec.Body)
+		}
+		return rec
+	}
+
+	rec := list("page=2&limit=10&status=pending&q=report")
+	if got := rec.Header().Get("X-Total-Count"); got != "45" {
+		t.Fatalf("X-Total-Count %q, want 45", got)
+	}
+	want := `<http://example.com/tasks?limit=10&page=3&q=report&status=pending>; rel="next", ` +
+		`<http://example.com/tasks?limit=10&page=1&q=report&status=pending>; rel="prev"`
+	if got := rec.Header().Get("Link"); got != want {
+		t.Fatalf("Link %q, want %q", got, want)
+	}
+
+	if link := list("page=1&limit=10").Header().Get("Link"); strings.Contains(link, `rel="prev"`) || !strings.Contains(link, `rel="next"`) {
+		t.Fatalf("first page Link %q, want only next", link)
+	}
+	if link := list("page=5&limit=10").Header().Get("Link"); strings.Contains(link, `rel="next"`) || !strings.Contains(link, `rel="prev"`) {
+		t.Fatalf("last page Link %q, want only prev", link)
+	}
+	if link := list("page=1&limit=50").Header().Get("Link"); link != "" {
+		t.Fatalf("single page Link %q, want none", link)
+	}
+}
+
+func TestListTasksOverMaxLimit(t *testing.T) {
+	t.Cleanup(func() { utils.SetPaginationOptions(utils.PaginationOptions{}) })
+	handler, token := taskServer(t, &pagedTaskRepo{total: 500}, uuid.New())
+
+	utils.SetPaginationOptions(utils.PaginationOptions{MaxLimit: 100})
+	rec := sendJSON(handler, http.MethodGet, "/tasks?limit=1000", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("lenient: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("X-Pagination-Max-Limit") != "100" || !strings.Contains(rec.Header().Get("Warning"), "limit capped at 100") {
+		t.Fatalf("lenient: headers %v, want the cap announced", rec.Header())
+	}
+	if resp := responseData[models.TaskListResponse](t, rec.Body.Bytes()); len(resp.Tasks) != 100 || resp.Pagination.Limit != 100 {
+		t.Fatalf("lenient: %d tasks at limit %d, want 100", len(resp.Tasks), resp.Pagination.Limit)
+	}
+
+	utils.SetPaginationOptions(utils.PaginationOptions{MaxLimit: 100, Strict: true})
+	rec = sendJSON(handler, http.MethodGet, "/tasks?limit=1000", token, "")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "limit must be at most 100") {
+		t.Fatalf("strict: status %d, want 400 explaining the cap: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("X-Pagination-Max-Limit") != "100" {
+		t.Fatalf("strict: X-Pagination-Max-Limit %q, want 100", rec.Header().Get("X-Pagination-Max-Limit"))
+	}
+
+	rec = sendJSON(handler, http.MethodGet, "/tasks?limit=100", token, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Warning") != "" {
+		t.Fatalf("strict at the cap: status %d warning %q, want 200 without a warning", rec.Code, rec.Header().Get("Warning"))
+	}
+}
+
+// countingTaskRepo lists no tasks and tallies them by status, recording the
+// filter each call was given
+type countingTaskRepo struct {
+	fakeTaskRepo
+	counts      map[models.TaskStatus]int
+	listFilter  models.TaskFilter
+	countFilter *models.TaskFilter
+}
+
+func (f *countingTaskRepo) GetAll(ctx context.Context, userID uuid.UUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	f.listFilter = filter
+	return []models.Task{}, 0, nil
+}
+
+func (f *countingTaskRepo) CountVisibleByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error) {
+	f.countFilter = &filter
+	return f.counts, nil
+}
+
+func TestListTasksIncludeCounts(t *testing.T) {
+	repo := &countingTaskRepo{counts: map[models.TaskStatus]int{models.TaskStatusPending: 3, models.TaskStatusCompleted: 2}}
+	handler, token := taskServer(t, repo, uuid.New())
+
+	rec := sendJSON(handler, http.MethodGet, "/tasks", token, "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"counts"`) || repo.countFilter != nil {
+		t.Fatalf("without include_counts: status %d, want 200 with no counts queried: %s", rec.Code, rec.Body)
+	}
+
+	rec = sendJSON(handler, http.MethodGet, "/tasks?include_counts=true", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("include_counts: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	resp := responseData[models.TaskListResponse](t, rec.Body.Bytes())
+	if want := (models.StatusCounts{Pending: 3, Completed: 2}); resp.Counts == nil || *resp.Counts != want {
+		t.Fatalf("counts %+v, want %+v", resp.Counts, want)
+	}
+
+	// The tally gets the same filter as the page; the repository drops status
+	rec = sendJSON(handler, http.MethodGet, "/tasks?include_counts=true&status=pending&q=report", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("filtered: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	want := models.TaskFilter{Status: models.TaskStatusPending, Search: "report"}
+	if repo.countFilter == nil || repo.countFilter.Status != want.Status || repo.countFilter.Search != want.Search {
+		t.Fatalf("counted with %+v, want %+v", repo.countFilter, want)
+	}
+	if repo.listFilter.Status != want.Status || repo.listFilter.Search != want.Search {
+		t.Fatalf("listed with %+v, want %+v", repo.listFilter, want)
+	}
+}
+
+// deletedTaskRepo holds one soft-deleted task, found only by its owner and
+// only when deleted tasks are included
+type deletedTaskRepo struct {
+	fakeTaskRepo
+	task models.Task
+}
+
+func (f *deletedTaskRepo) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	return nil, nil
+}
+
+func (f *deletedTaskRepo) GetByIDIncludingDeleted(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	if id != f.task.ID || userID != f.task.UserID {
+		return nil, nil
+	}
+	task := f.task
+	return &task, nil
+}
+
+func TestGetTaskIncludeDeleted(t *testing.T) {
+	ownerID := uuid.New()
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := &deletedTaskRepo{task: models.Task{ID: uuid.New(), UserID: ownerID, Title: "Gone", Status: models.TaskStatusPending, DeletedAt: &deletedAt}}
+	handler, token := taskServer(t, repo, ownerID)
+	target := "/tasks/" + repo.task.ID.String()
+
+	if rec := sendJSON(handler, http.MethodGet, target, token, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("without include_deleted: status %d, want 404: %s", rec.Code, rec.Body)
+	}
+
+	rec := sendJSON(handler, http.MethodGet, target+"?include_deleted=true", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("include_deleted: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	resp := responseData[models.TaskResponse](t, rec.Body.Bytes())
+	if resp.Task == nil || resp.Task.DeletedAt == nil || !resp.Task.DeletedAt.Equal(deletedAt) {
+		t.Fatalf("task %+v, want it with its deletion timestamp", resp.Task)
+	}
+
+	otherHandler, otherToken := taskServer(t, repo, uuid.New())
+	if rec := sendJSON(otherHandler, http.MethodGet, target+"?include_deleted=true", otherToken, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("another user with include_deleted: status %d, want 404: %s", rec.Code, rec.Body)
+	}
+}
+
+// sortTaskRepo lists no tasks, recording the sort it was asked for
+type sortTaskRepo struct {
+	fakeTaskRepo
+	sort *models.TaskSort
+}
+
+func (f *sortTaskRepo) GetAll(ctx context.Context, userID uuid.UUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	f.sort = &sort
+	return []models.Task{}, 0, nil
+}
+
+func TestListTasksGroupByStatus(t *testing.T) {
+	for _, tt := range []struct {
+		configured bool
+		query      string
+		want       bool
+	}{
+		{false, "", false},
+		{true, "", true},
+		{false, "?group_by_status=true", true},
+		{true, "?group_by_status=false", false},
+	} {
+		repo := &sortTaskRepo{}
+		handler, token := taskServerWith(t, repo, uuid.New(), config.TaskConfig{GroupByStatus: tt.configured})
+		rec := sendJSON(handler, http.MethodGet, "/tasks"+tt.query, token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("default %v, %q: status %d, want 200: %s", tt.configured, tt.query, rec.Code, rec.Body)
+		}
+		if repo.sort == nil || repo.sort.GroupByStatus != tt.want {
+			t.Fatalf("default %v, %q: sort %+v, want grouping %v", tt.configured, tt.query, repo.sort, tt.want)
+		}
+	}
+
+	repo := &sortTaskRepo{}
+	handler, token := taskServer(t, repo, uuid.New())
+	rec := sendJSON(handler, http.MethodGet, "/tasks?group_by_status=sometimes", token, "")
+	if rec.Code != http.StatusBadRequest || repo.sort != nil {
+		t.Fatalf("invalid group_by_status: status %d, want 400 before listing: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestListTasksSortParams(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  models.TaskSort
+	}{
+		{"", models.TaskSort{Field: models.TaskSortCreatedAt}},
+		{"?sort_by=updated_at", models.TaskSort{Field: models.TaskSortUpdatedAt}},
+		{"?sort_by=due_date&order=asc", models.TaskSort{Field: models.TaskSortDueDate, Asc: true}},
+		{"?sort_by=title&order=desc", models.TaskSort{Field: models.TaskSortTitle}},
+	} {
+		repo := &sortTaskRepo{}
+		handler, token := taskServer(t, repo, uuid.New())
+		rec := sendJSON(handler, http.MethodGet, "/tasks"+tt.query, token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d, want 200: %s", tt.query, rec.Code, rec.Body)
+		}
+		if repo.sort == nil || *repo.sort != tt.want {
+			t.Fatalf("%q: sort %+v, want %+v", tt.query, repo.sort, tt.want)
+		}
+	}
+
+	for _, query := range []string{"?sort_by=password_hash", "?sort_by=id", "?order=up"} {
+		repo := &sortTaskRepo{}
+		handler, token := taskServer(t, repo, uuid.New())
+		rec := sendJSON(handler, http.MethodGet, "/tasks"+query, token, "")
+		if rec.Code != http.StatusBadRequest || repo.sort != nil {
+			t.Fatalf("%q: status %d, want 400 before listing: %s", query, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestListTasksTrimsSearchTerm(t *testing.T) {
+	repo := &countingTaskRepo{}
+	handler, token := taskServer(t, repo, uuid.New())
+
+	for query, want := range map[string]string{"?q=%20%20report%20": "report", "?q=%20%20": "", "": ""} {
+		rec := sendJSON(handler, http.MethodGet, "/tasks"+query, token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d, want 200: %s", query, rec.Code, rec.Body)
+		}
+		if repo.listFilter.Search != want {
+			t.Fatalf("%q: searched %q, want %q", query, repo.listFilter.Search, want)
+		}
+	}
+}
+
+// trashTaskRepo holds tasks that may be soft-deleted, restoring them as
+// TaskRepository.Restore does
+type trashTaskRepo struct {
+	fakeTaskRepo
+	tasks []*models.Task
+}
+
+func (f *trashTaskRepo) find(id, userID uuid.UUID) *models.Task {
+	for _, task := range f.tasks {
+		if task.ID == id && task.UserID == userID {
+			return task
+		}
+	}
+	return nil
+}
+
+func (f *trashTaskRepo) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	task := f.find(id, userID)
+	if task == nil || task.DeletedAt != nil {
+		return nil, nil
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (f *trashTaskRepo) GetByIDIncludingDeleted(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	task := f.find(id, userID)
+	if task == nil {
+		return nil, nil
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (f *trashTaskRepo) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	n := 0
+	for _, task := range f.tasks {
+		if task.UserID == userID && task.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *trashTaskRepo) TitleExists(ctx context.Context, userID uuid.UUID, title string, exclude uuid.NullUUID) (bool, error) {
+	for _, task := range f.tasks {
+		if task.UserID == userID && task.DeletedAt == nil && strings.EqualFold(task.Title, title) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *trashTaskRepo) Restore(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	task := f.find(id, userID)
+	if task == nil || task.DeletedAt == nil {
+		return nil, repository.ErrTaskNotFound
+	}
+	task.DeletedAt = nil
+	copied := *task
+	return &copied, nil
+}
+
+func TestRestoreTask(t *testing.T) {
+	ownerID := uuid.New()
+	deletedAt := time.Now().Add(-time.Hour)
+	trashed := &models.Task{ID: uuid.New(), UserID: ownerID, Title: "Write report", Status: models.TaskStatusPending, DeletedAt: &deletedAt}
+	live := &models.Task{ID: uuid.New(), UserID: ownerID, Title: "Book flights", Status: models.TaskStatusPending}
+	repo := &trashTaskRepo{tasks: []*models.Task{trashed, live}}
+	handler, token := taskServer(t, repo, ownerID)
+
+	// Another user can't restore it, nor tell it exists
+	other, otherToken := taskServer(t, repo, uuid.New())
+	if rec := sendJSON(other, http.MethodPost, "/tasks/"+trashed.ID.String()+"/restore", otherToken, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("another user's task: status %d, want 404: %s", rec.Code, rec.Body)
+	}
+	if trashed.DeletedAt == nil {
+		t.Fatal("another user restored the task")
+	}
+
+	rec := sendJSON(handler, http.MethodPost, "/tasks/"+trashed.ID.String()+"/restore", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if resp := responseData[models.TaskResponse](t, rec.Body.Bytes()); resp.Task == nil || resp.Task.ID != trashed.ID || resp.Task.DeletedAt != nil {
+		t.Fatalf("restored %+v, want the task without a deletion timestamp", resp.Task)
+	}
+	if rec := sendJSON(handler, http.MethodGet, "/tasks/"+trashed.ID.String(), token, ""); rec.Code != http.StatusOK {
+		t.Fatalf("get after restore: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	for name, id := range map[string]uuid.UUID{"restored again": trashed.ID, "never deleted": live.ID, "unknown": uuid.New()} {
+		if rec := sendJSON(handler, http.MethodPost, "/tasks/"+id.String()+"/restore", token, ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: status %d, want 404: %s", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestRestoreTaskRespectsLimits(t *testing.T) {
+	ownerID := uuid.New()
+	deletedAt := time.Now().Add(-time.Hour)
+	trashed := &models.Task{ID: uuid.New(), UserID: ownerID, Title: "Write report", DeletedAt: &deletedAt}
+	target := "/tasks/" + trashed.ID.String() + "/restore"
+
+	full := &trashTaskRepo{tasks: []*models.Task{trashed, {ID: uuid.New(), UserID: ownerID, Title: "Book flights"}}}
+	handler, token := taskServerWith(t, full, ownerID, config.TaskConfig{MaxPerUser: 1})
+	if rec := sendJSON(handler, http.MethodPost, target, token, ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("over quota: status %d, want 403: %s", rec.Code, rec.Body)
+	}
+
+	retitled := &trashTaskRepo{tasks: []*models.Task{trashed, {ID: uuid.New(), UserID: ownerID, Title: "write report"}}}
+	handler, token = taskServerWith(t, retitled, ownerID, config.TaskConfig{UniqueTitles: true})
+	if rec := sendJSON(handler, http.MethodPost, target, token, ""); rec.Code != http.StatusConflict {
+		t.Fatalf("title taken: status %d, want 409: %s", rec.Code, rec.Body)
+	}
+	if trashed.DeletedAt == nil {
+		t.Fatal("task restored despite the limits")
+	}
+}
+
+// overdueTaskRepo serves the overdue tasks of one user
+type overdueTaskRepo struct {
+	fakeTaskRepo
+	userID uuid.UUID
+	tasks  []models.Task
+}
+
+func (f *overdueTaskRepo) GetOverdue(ctx context.Context, userID uuid.UUID, page, limit int) ([]models.Task, int, error) {
+	if userID != f.userID {
+		return nil, 0, nil
+	}
+	return f.tasks, len(f.tasks), nil
+}
+
+func TestListOverdueTasks(t *testing.T) {
+	userID := uuid.New()
+	due := time.Now().Add(-time.Hour)
+	repo := &overdueTaskRepo{userID: userID, tasks: []models.Task{{ID: uuid.New(), UserID: userID, Title: "Late", Status: models.TaskStatusPending, DueDate: due}}}
+	handler, token := taskServer(t, repo, userID)
+
+	// Served by the overdue route, not taken for a task ID
+	rec := sendJSON(handler, http.MethodGet, "/tasks/overdue", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	resp := responseData[models.TaskListResponse](t, rec.Body.Bytes())
+	if len(resp.Tasks) != 1 || resp.Tasks[0].Title != "Late" || resp.Pagination.Total != 1 {
+		t.Fatalf("overdue = %+v, want the late task", resp)
+	}
+}
+
+func TestContentLengthRequiredOnlyOnLargePayloadRoutes(t *testing.T) {
+	userID := uuid.New()
+	log := testLogger(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(userID, "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &memoryTaskRepo{}
+	h := NewTaskHandler(&repository.Repository{Task: repo}, config.TaskConfig{}, stats.NewCache(nil, false, 0, log), nil, true, config.RateLimitConfig{}, log)
+	router := chi.NewRouter()
+	router.Use(middleware.AuthMiddleware(jwtManager, nil, log))
+	router.Route("/tasks", h.RegisterRoutes)
+
+	send := func(method, target, body string, chunked bool) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if chunked {
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	importBody := `{"tasks": [{"title": "Imported"}]}`
+	if code := send(http.MethodPost, "/tasks/import", importBody, true); code != http.StatusLengthRequired {
+		t.Fatalf("chunked import: status %d, want 411", code)
+	}
+	if code := send(http.MethodPatch, "/tasks/bulk-status", `{"ids": ["`+uuid.NewString()+`"], "status": "completed"}`, true); code != http.StatusLengthRequired {
+		t.Fatalf("chunked bulk status: status %d, want 411", code)
+	}
+	if code := send(http.MethodPost, "/tasks/import", importBody, false); code == http.StatusLengthRequired {
+		t.Fatal("import with a declared length: 411")
+	}
+	if code := send(http.MethodPost, "/tasks", `{"title": "Chunked"}`, true); code != http.StatusCreated {
+		t.Fatalf("chunked create: status %d, want 201 as normal endpoints don't require a length", code)
+	}
+}
+
+// sharingTaskRepo holds tasks and who they are shared with, answering
+// lookups with the caller's role as TaskRepository does
+type sharingTaskRepo struct {
+	fakeTaskRepo
+	tasks   map[uuid.UUID]*models.Task
+	shared  map[uuid.UUID][]uuid.UUID
+	deleted []uuid.UUID
+}
+
+// visible returns a copy of the task with the user's role, or nil if they
+// neither own nor collaborate on it
+func (f *sharingTaskRepo) visible(id, userID uuid.UUID) *models.Task {
+	task, ok := f.tasks[id]
+	if !ok {
+		return nil
+	}
+	seen := *task
+	if task.UserID == userID {
+		seen.Role = models.TaskRoleOwner
+		return &seen
+	}
+	for _, collaborator := range f.shared[id] {
+		if collaborator == userID {
+			seen.Role = models.TaskRoleCollaborator
+			return &seen
+		}
+	}
+	return nil
+}
+
+func (f *sharingTaskRepo) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	return f.visible(id, userID), nil
+}
+
+func (f *sharingTaskRepo) GetAll(ctx context.Context, userID uuid.UUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	var tasks []models.Task
+	for id := range f.tasks {
+		if task := f.visible(id, userID); task != nil {
+			tasks = append(tasks, *task)
+		}
+	}
+	return tasks, len(tasks), nil
+}
+
+func (f *sharingTaskRepo) AddCollaborator(ctx context.Context, taskID, ownerID, userID uuid.UUID) (bool, error) {
+	if task, ok := f.tasks[taskID]; !ok || task.UserID != ownerID {
+		return false, repository.ErrTaskNotFound
+	}
+	for _, collaborator := range f.shared[taskID] {
+		if collaborator == userID {
+			return false, nil
+		}
+	}
+	f.shared[taskID] = append(f.shared[taskID], userID)
+	return true, nil
+}
+
+func (f *sharingTaskRepo) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	f.deleted = append(f.deleted, id)
+	delete(f.tasks, id)
+	return nil
+}
+
+func TestShareTaskWithCollaborator(t *testing.T) {
+	users := newMemoryUserRepo(clock.Real{})
+	owner := &models.User{ID: uuid.New(), Email: "owner@example.com", Name: "Owner"}
+	friend := &models.User{ID: uuid.New(), Email: "friend@example.com", Name: "Friend"}
+	users.users[owner.ID], users.users[friend.ID] = owner, friend
+	task := &models.Task{ID: uuid.New(), UserID: owner.ID, Title: "Plan trip", Status: models.TaskStatusPending}
+	repo := &sharingTaskRepo{tasks: map[uuid.UUID]*models.Task{task.ID: task}, shared: map[uuid.UUID][]uuid.UUID{}}
+
+	log := testLogger(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	tokenFor := func(user *models.User) string {
+		token, err := jwtManager.GenerateAccessToken(user.ID, user.Email, string(models.UserRoleUser), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	h := NewTaskHandler(&repository.Repository{Task: repo, User: users}, config.TaskConfig{}, stats.NewCache(nil, false, 0, log), nil, false, config.RateLimitConfig{}, log)
+	handler := chi.NewRouter()
+	handler.Use(middleware.AuthMiddleware(jwtManager, nil, log))
+	handler.Route("/tasks", h.RegisterRoutes)
+	ownerToken, friendToken := tokenFor(owner), tokenFor(friend)
+	sharePath := "/tasks/" + task.ID.String() + "/share"
+
+	// Before sharing the friend can't see the task
+	if rec := sendJSON(handler, http.MethodGet, "/tasks/"+task.ID.String(), friendToken, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unshared task: status %d, want 404", rec.Code)
+	}
+	if rec := sendJSON(handler, http.MethodPost, sharePath, friendToken, `{"email": "owner@example.com"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("sharing an unseen task: status %d, want 404", rec.Code)
+	}
+
+	rec := sendJSON(handler, http.MethodPost, sharePath, ownerToken, `{"email": "friend@example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("share: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.CollaboratorResponse](t, rec.Body.Bytes()).Collaborator; got.UserID != friend.ID || got.Email != friend.Email || got.Name != friend.Name {
+		t.Fatalf("collaborator %+v, want the friend", got)
+	}
+	if rec := sendJSON(handler, http.MethodPost, sharePath, ownerToken, `{"email": "friend@example.com"}`); rec.Code != http.StatusOK {
+		t.Fatalf("sharing again: status %d, want 200", rec.Code)
+	}
+
+	// The collaborator sees the task in their list with their role
+	rec = sendJSON(handler, http.MethodGet, "/tasks", friendToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	list := responseData[models.TaskListResponse](t, rec.Body.Bytes()).Tasks
+	if len(list) != 1 || list[0].ID != task.ID || list[0].Role != models.TaskRoleCollaborator {
+		t.Fatalf("collaborator's tasks %+v, want the shared task as collaborator", list)
+	}
+	rec = sendJSON(handler, http.MethodGet, "/tasks/"+task.ID.String(), ownerToken, "")
+	if got := responseData[models.TaskResponse](t, rec.Body.Bytes()).Task; got.Role != models.TaskRoleOwner {
+		t.Fatalf("owner's task role %q, want owner", got.Role)
+	}
+
+	// ...but may neither delete nor reshare it
+	if rec := sendJSON(handler, http.MethodDelete, "/tasks/"+task.ID.String(), friendToken, ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("collaborator delete: status %d, want 403", rec.Code)
+	}
+	if rec := sendJSON(handler, http.MethodPost, sharePath, friendToken, `{"email": "owner@example.com"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("collaborator share: status %d, want 403", rec.Code)
+	}
+	if len(repo.deleted) != 0 || repo.tasks[task.ID] == nil {
+		t.Fatalf("deleted %v, want the task kept", repo.deleted)
+	}
+
+	for body, want := range map[string]int{
+		`{"email": "nobody@example.com"}`: http.StatusNotFound,
+		`{"email": "owner@example.com"}`:  http.StatusBadRequest,
+		`{"email": "not-an-email"}`:       http.StatusBadRequest,
+	} {
+		if rec := sendJSON(handler, http.MethodPost, sharePath, ownerToken, body); rec.Code != want {
+			t.Errorf("share %s: status %d, want %d", body, rec.Code, want)
+		}
+	}
+
+	if rec := sendJSON(handler, http.MethodDelete, "/tasks/"+task.ID.String(), ownerToken, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("owner delete: status %d, want 204", rec.Code)
+	}
+}
+
+// taggedTaskRepo is memoryTaskRepo listing the user's tasks that match the
+// filter's tags as TaskRepository.GetAll does
+type taggedTaskRepo struct {
+	memoryTaskRepo
+}
+
+func (f *taggedTaskRepo) GetAll(ctx context.Context, userID uuid.UUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	var tasks []models.Task
+	for _, task := range f.tasks {
+		if task.UserID != userID {
+			continue
+		}
+		shared := 0
+		for _, tag := range filter.Tags {
+			for _, have := range task.Tags {
+				if tag == have {
+					shared++
+				}
+			}
+		}
+		if len(filter.Tags) > 0 && ((!filter.TagsMatchAny && shared < len(filter.Tags)) || (filter.TagsMatchAny && shared == 0)) {
+			continue
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, len(tasks), nil
+}
+
+func TestTaskTags(t *testing.T) {
+	repo := &taggedTaskRepo{}
+	handler, token := taskServerWith(t, repo, uuid.New(), config.TaskConfig{MaxTags: 3})
+
+	for body, want := range map[string][]string{
+		`{"title": "Pay rent", "tags": ["home", "bills"]}`:      {"home", "bills"},
+		`{"title": "Fix sink", "tags": ["home", "home"]}`:       {"home"},
+		`{"title": "File expenses", "tags": ["work", "bills"]}`: {"work", "bills"},
+		`{"title": "Untagged"}`:                                 {},
+	} {
+		rec := sendJSON(handler, http.MethodPost, "/tasks", token, body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: status %d, want 201: %s", body, rec.Code, rec.Body)
+		}
+		if got := responseData[models.TaskResponse](t, rec.Body.Bytes()).Task.Tags; len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Fatalf("create %s: tags %v, want %v", body, got, want)
+		}
+	}
+
+	for body, want := range map[string]string{
+		`{"title": "Bad", "tags": ["Home"]}`:             "tags[0] must be 1-32 lowercase letters",
+		`{"title": "Bad", "tags": ["ok", "two words"]}`:  "tags[1] must be 1-32 lowercase letters",
+		`{"title": "Bad", "tags": ["a", "b", "c", "d"]}`: "tags must contain at most 3 tags",
+	} {
+		rec := sendJSON(handler, http.MethodPost, "/tasks", token, body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("create %s: status %d, want 400 with %q: %s", body, rec.Code, want, rec.Body)
+		}
+	}
+	if len(repo.tasks) != 4 {
+		t.Fatalf("%d tasks stored, want the 4 valid ones", len(repo.tasks))
+	}
+
+	for query, want := range map[string][]string{
+		"?tags=home":                      {"Fix sink", "Pay rent"},
+		"?tags=home,bills":                {"Pay rent"},
+		"?tags=home,bills&tags_match=all": {"Pay rent"},
+		"?tags=home,bills&tags_match=any": {"File expenses", "Fix sink", "Pay rent"},
+		"?tags=travel":                    {},
+	} {
+		rec := sendJSON(handler, http.MethodGet, "/tasks"+query, token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %s: status %d, want 200: %s", query, rec.Code, rec.Body)
+		}
+		var got []string
+		for _, task := range responseData[models.TaskListResponse](t, rec.Body.Bytes()).Tasks {
+			got = append(got, task.Title)
+		}
+		sort.Strings(got)
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("list %s: %v, want %v", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"?tags=Home", "?tags=home,", "?tags=home&tags_match=some"} {
+		if rec := sendJSON(handler, http.MethodGet, "/tasks"+query, token, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("list %s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestImportTasks(t *testing.T) {
+	repo := &memoryTaskRepo{}
+	handler, token := taskServerWith(t, repo, uuid.New(), config.TaskConfig{MaxTags: 3})
+
+	valid := `[
+		{"title": "Pay rent", "due_date": "2024-04-01T00:00:00Z", "tags": ["home"]},
+		{"title": "File taxes", "status": "in_progress"},
+		{"title": "Book flights", "status": "completed"}
+	]`
+	rec := sendJSON(handler, http.MethodPost, "/tasks/import", token, valid)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("valid batch: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.ImportTasksResponse](t, rec.Body.Bytes()); got.Created != 3 || got.Errors == nil || len(got.Errors) != 0 {
+		t.Fatalf("valid batch summary %+v, want 3 created and no errors", got)
+	}
+	if len(repo.tasks) != 3 || repo.tasks[0].Status != models.TaskStatusPending || repo.tasks[1].Status != models.TaskStatusInProgress ||
+		!reflect.DeepEqual(repo.tasks[0].Tags, []string{"home"}) {
+		t.Fatalf("stored %+v, want the rows with status defaulted to pending", repo.tasks)
+	}
+
+	oneBad := `[{"title": "Water plants"}, {"title": "", "status": "done"}, {"title": "Call mum"}]`
+
+	// Strict mode rejects the whole batch, reporting the bad row
+	rec = sendJSON(handler, http.MethodPost, "/tasks/import", token, oneBad)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("strict: status %d, want 400: %s", rec.Code, rec.Body)
+	}
+	var strict models.ValidationErrorResponse[[]models.ImportRowError]
+	if err := json.Unmarshal(rec.Body.Bytes(), &strict); err != nil {
+		t.Fatal(err)
+	}
+	if len(strict.Errors) != 1 || strict.Errors[0].Row != 1 || strict.Errors[0].Errors["title"] == "" || strict.Errors[0].Errors["status"] == "" {
+		t.Fatalf("strict errors %+v, want row 1's title and status", strict.Errors)
+	}
+	if len(repo.tasks) != 3 {
+		t.Fatalf("strict: %d tasks stored, want none imported", len(repo.tasks)-3)
+	}
+
+	// Partial mode imports the valid rows around it
+	rec = sendJSON(handler, http.MethodPost, "/tasks/import?partial=true", token, oneBad)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("partial: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+	partial := responseData[models.ImportTasksResponse](t, rec.Body.Bytes())
+	if partial.Created != 2 || len(partial.Errors) != 1 || partial.Errors[0].Row != 1 {
+		t.Fatalf("partial summary %+v, want 2 created and row 1 rejected", partial)
+	}
+	if len(repo.tasks) != 5 || repo.tasks[3].Title != "Water plants" || repo.tasks[4].Title != "Call mum" {
+		t.Fatalf("partial: stored %+v, want the two valid rows added", repo.tasks[3:])
+	}
+
+	// Partial mode still fails when no row is valid
+	if rec := sendJSON(handler, http.MethodPost, "/tasks/import?partial=true", token, `[{"title": ""}]`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("partial with no valid rows: status %d, want 400", rec.Code)
+	}
+
+	over := "[" + strings.Repeat(`{"title": "t"},`, maxImportTasks) + `{"title": "t"}]`
+	if rec := sendJSON(handler, http.MethodPost, "/tasks/import", token, over); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("%d rows: status %d, want 413: %s", maxImportTasks+1, rec.Code, rec.Body)
+	}
+	for _, body := range []string{`[]`, `{"title": "not an array"}`} {
+		if rec := sendJSON(handler, http.MethodPost, "/tasks/import", token, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("import %s: status %d, want 400", body, rec.Code)
+		}
+	}
+	if len(repo.tasks) != 5 {
+		t.Fatalf("%d tasks stored, want 5", len(repo.tasks))
+	}
+}
+
+// deletedDuringUpdateRepo finds the task but loses it to a concurrent delete
+// before Update runs
+type deletedDuringUpdateRepo struct {
+	fakeTaskRepo
+	task *models.Task
+}
+
+func (f *deletedDuringUpdateRepo) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	return f.task, nil
+}
+
+func (f *deletedDuringUpdateRepo) Update(ctx context.Context, id, ownerID uuid.UUID, version int, fields repository.TaskFields) (*models.Task, error) {
+	return nil, repository.ErrTaskNotFound
+}
+
+func TestUpdateTaskDeletedConcurrently(t *testing.T) {
+	userID := uuid.New()
+	task := &models.Task{ID: uuid.New(), UserID: userID, Title: "Write report", Status: models.TaskStatusPending, Version: 1, Role: models.TaskRoleOwner}
+	handler, token := taskServer(t, &deletedDuringUpdateRepo{task: task}, userID)
+
+	rec := sendJSON(handler, http.MethodPut, "/tasks/"+task.ID.String(), token, `{"title": "Write the report", "version": 1}`)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Task not found") {
+		t.Fatalf("status %d, want 404: %s", rec.Code, rec.Body)
+	}
+}
+
+// versionedTaskRepo holds one task and applies updates only at its current
+// version, as TaskRepository.Update does. editedMeanwhile bumps the version
+// between the handler's read and its update.
+type versionedTaskRepo struct {
+	fakeTaskRepo
+	task            models.Task
+	editedMeanwhile bool
+}
+
+func (f *versionedTaskRepo) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	if id != f.task.ID || userID != f.task.UserID {
+		return nil, nil
+	}
+	task := f.task
+	return &task, nil
+}
+
+func (f *versionedTaskRepo) Update(ctx context.Context, id, ownerID uuid.UUID, version int, fields repository.TaskFields) (*models.Task, error) {
+	if f.editedMeanwhile {
+		f.task.Version++
+	}
+	if version != f.task.Version {
+		return nil, repository.ErrTaskVersionConflict
+	}
+	if fields.Title != nil {
+		f.task.Title = *fields.Title
+	}
+	if fields.Description != nil {
+		f.task.Description = *fields.Description
+	}
+	if fields.Status != nil {
+		f.task.Status = *fields.Status
+	}
+	f.task.Version++
+	task := f.task
+	return &task, nil
+}
+
+func TestUpdateTaskVersion(t *testing.T) {
+	userID := uuid.New()
+	repo := &versionedTaskRepo{task: models.Task{ID: uuid.New(), UserID: userID, Title: "Write report", Status: models.TaskStatusPending, Version: 1}}
+	handler, token := taskServer(t, repo, userID)
+	path := "/tasks/" + repo.task.ID.String()
+
+	rec := sendJSON(handler, http.MethodPut, path, token, `{"title": "First edit", "version": 1}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("current version: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.TaskResponse](t, rec.Body.Bytes()).Task; got.Version != 2 || got.Title != "First edit" {
+		t.Fatalf("updated %+v, want version 2 with the new title", got)
+	}
+
+	// A second client still holding version 1 is turned away
+	rec = sendJSON(handler, http.MethodPut, path, token, `{"title": "Second edit", "version": 1}`)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), staleTaskMessage) {
+		t.Fatalf("stale version: status %d, want 409: %s", rec.Code, rec.Body)
+	}
+	if repo.task.Title != "First edit" || repo.task.Version != 2 {
+		t.Fatalf("stored %+v, want the first edit kept", repo.task)
+	}
+
+	if rec := sendJSON(handler, http.MethodPut, path, token, `{"title": "No version"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing version: status %d, want 400", rec.Code)
+	}
+
+	// An edit landing between the read and the update is caught by the update
+	repo.editedMeanwhile = true
+	rec = sendJSON(handler, http.MethodPut, path, token, `{"title": "Raced edit", "version": 2}`)
+	if rec.Code != http.StatusConflict || repo.task.Title != "First edit" {
+		t.Fatalf("concurrent edit: status %d, title %q, want 409 and the title kept", rec.Code, repo.task.Title)
+	}
+}
+
+func TestUpdateTaskClearsOnlyFieldsSent(t *testing.T) {
+	userID := uuid.New()
+	repo := &versionedTaskRepo{task: models.Task{ID: uuid.New(), UserID: userID, Title: "Write report", Description: "Quarterly numbers", Status: models.TaskStatusPending, Version: 1}}
+	handler, token := taskServer(t, repo, userID)
+	path := "/tasks/" + repo.task.ID.String()
+
+	// Omitting the description leaves it as it was
+	rec := sendJSON(handler, http.MethodPut, path, token, `{"title": "Write the report", "version": 1}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retitle: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.TaskResponse](t, rec.Body.Bytes()).Task; got.Description != "Quarterly numbers" || got.Title != "Write the report" {
+		t.Fatalf("retitled %+v, want the description kept", got)
+	}
+
+	// Sending it empty clears it, leaving the title
+	rec = sendJSON(handler, http.MethodPut, path, token, `{"description": "", "version": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("clear: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := responseData[models.TaskResponse](t, rec.Body.Bytes()).Task; got.Description != "" || got.Title != "Write the report" {
+		t.Fatalf("cleared %+v, want an empty description and the title kept", got)
+	}
+	if repo.task.Description != "" {
+		t.Fatalf("stored description %q, want it cleared", repo.task.Description)
+	}
+
+	if rec := sendJSON(handler, http.MethodPut, path, token, `{"version": 3}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no fields: status %d, want 400", rec.Code)
+	}
+}
+
+func TestTaskStatusTransitions(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		from, to    models.TaskStatus
+		allowReopen bool
+		want        int
+	}{
+		{models.TaskStatusPending, models.TaskStatusInProgress, false, http.StatusOK},
+		{models.TaskStatusInProgress, models.TaskStatusCompleted, false, http.StatusOK},
+		{models.TaskStatusInProgress, models.TaskStatusPending, false, http.StatusConflict},
+		{models.TaskStatusCompleted, models.TaskStatusPending, false, http.StatusConflict},
+		{models.TaskStatusCompleted, models.TaskStatusInProgress, false, http.StatusConflict},
+		{models.TaskStatusCompleted, models.TaskStatusPending, true, http.StatusOK},
+		{models.TaskStatusCompleted, models.TaskStatusInProgress, true, http.StatusOK},
+		// Reopening doesn't allow moving back any other way
+		{models.TaskStatusInProgress, models.TaskStatusPending, true, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		cfg := config.TaskConfig{AllowReopen: tt.allowReopen}
+		message := "Cannot change status from " + tt.from.String() + " to " + tt.to.String()
+
+		// Both a full update and the status-only endpoint enforce the rules
+		repo := &versionedTaskRepo{task: models.Task{ID: uuid.New(), UserID: userID, Title: "Write report", Status: tt.from, Version: 1}}
+		handler, token := taskServerWith(t, repo, userID, cfg)
+		rec := sendJSON(handler, http.MethodPut, "/tasks/"+repo.task.ID.String(), token, `{"status": "`+string(tt.to)+`", "version": 1}`)
+		if rec.Code != tt.want || (tt.want == http.StatusConflict && !strings.Contains(rec.Body.String(), message)) {
+			t.Errorf("PUT %s -> %s (reopen %v): status %d, want %d: %s", tt.from, tt.to, tt.allowReopen, rec.Code, tt.want, rec.Body)
+		}
+		if tt.want == http.StatusConflict && repo.task.Status != tt.from {
+			t.Errorf("PUT %s -> %s: stored %s, want it unchanged", tt.from, tt.to, repo.task.Status)
+		}
+
+		task := &models.Task{ID: uuid.New(), UserID: userID, Status: tt.from}
+		handler, token = taskServerWith(t, &statusTaskRepo{tasks: map[uuid.UUID]*models.Task{task.ID: task}}, userID, cfg)
+		rec = sendJSON(handler, http.MethodPatch, "/tasks/"+task.ID.String()+"/status", token, `{"status": "`+string(tt.to)+`"}`)
+		if rec.Code != tt.want || (tt.want == http.StatusConflict && !strings.Contains(rec.Body.String(), message)) {
+			t.Errorf("PATCH %s -> %s (reopen %v): status %d, want %d: %s", tt.from, tt.to, tt.allowReopen, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+// statsTaskRepo counts the live tasks it holds for their owner, and which of
+// them are overdue at now
+type statsTaskRepo struct {
+	fakeTaskRepo
+	tasks []models.Task
+	now   time.Time
+}
+
+func (f *statsTaskRepo) CountByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error) {
+	counts := make(map[models.TaskStatus]int)
+	for _, task := range f.tasks {
+		if task.UserID == userID && task.DeletedAt == nil {
+			counts[task.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func (f *statsTaskRepo) CountOverdue(ctx context.Context, userID uuid.UUID) (int, error) {
+	n := 0
+	for _, task := range f.tasks {
+		if task.UserID == userID && task.DeletedAt == nil && task.Status != models.TaskStatusCompleted && task.DueDate.Before(f.now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestGetStatsCountsMixedStatuses(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	deletedAt := now.Add(-time.Minute)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	repo := &statsTaskRepo{now: now, tasks: []models.Task{
+		{UserID: userID, Status: models.TaskStatusPending, DueDate: past},
+		{UserID: userID, Status: models.TaskStatusPending, DueDate: future},
+		{UserID: userID, Status: models.TaskStatusPending, DueDate: future},
+		{UserID: userID, Status: models.TaskStatusInProgress, DueDate: past},
+		{UserID: userID, Status: models.TaskStatusCompleted, DueDate: past},
+		{UserID: userID, Status: models.TaskStatusPending, DueDate: past, DeletedAt: &deletedAt},
+		{UserID: uuid.New(), Status: models.TaskStatusInProgress, DueDate: past},
+	}}
+
+	log := testLogger(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(userID, "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewTaskHandler(&repository.Repository{Task: repo}, config.TaskConfig{}, stats.NewCache(repo, false, 0, log), nil, false, config.RateLimitConfig{}, log)
+	handler := chi.NewRouter()
+	handler.Use(middleware.AuthMiddleware(jwtManager, nil, log))
+	handler.Route("/tasks", h.RegisterRoutes)
+
+	rec := sendJSON(handler, http.MethodGet, "/tasks/stats", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	got := responseData[models.TaskStatsResponse](t, rec.Body.Bytes()).Stats
+	if got.Pending != 3 || got.InProgress != 1 || got.Completed != 1 || got.Overdue != 2 || got.Total != 5 {
+		t.Fatalf("stats %+v, want 3 pending, 1 in progress, 1 completed, 2 overdue of 5", got)
+	}
+}
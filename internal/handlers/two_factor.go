@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+	"secure-task-api/pkg/utils"
+)
+
+// SetupTwoFactor generates a TOTP secret for the current user to enroll in an
+// authenticator app. Two-factor authentication stays off until EnableTwoFactor
+// confirms a code; calling setup again replaces the pending secret.
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		utils.Conflict(w, r, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to generate TOTP secret")
+		utils.InternalServerError(w, r, "Failed to set up two-factor authentication")
+		return
+	}
+	sealed, err := h.totpSecrets.Seal(secret)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to encrypt TOTP secret")
+		utils.InternalServerError(w, r, "Failed to set up two-factor authentication")
+		return
+	}
+
+	saved, err := h.repo.User.SetTOTPSecret(r.Context(), user.ID, sealed)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to save TOTP secret")
+		utils.InternalServerError(w, r, "Failed to set up two-factor authentication")
+		return
+	}
+	if !saved {
+		// Enabled by a concurrent request since the user was loaded
+		utils.Conflict(w, r, "Two-factor authentication is already enabled")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: auth.TOTPURL(h.config.TwoFactor.Issuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor turns on two-factor authentication once the user proves
+// their authenticator app holds the secret from SetupTwoFactor
+func (h *AuthHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorCodeRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	if !validRequest(w, r, h.log, &req) {
+		return
+	}
+
+	user, ok := h.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		utils.Conflict(w, r, "Two-factor authentication is already enabled")
+		return
+	}
+	if user.TOTPSecret == "" {
+		utils.BadRequest(w, r, "Two-factor authentication has not been set up")
+		return
+	}
+
+	counter, ok := h.checkTOTP(w, r, user, req.Code)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.User.EnableTOTP(r.Context(), user.ID, counter); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to enable two-factor authentication")
+		utils.InternalServerError(w, r, "Failed to enable two-factor authentication")
+		return
+	}
+
+	logger.FromContext(r.Context()).WithUserID(user.ID.String()).Info("two-factor authentication enabled")
+	utils.JSONSuccess(w, http.StatusOK, models.MessageResponse{Message: "Two-factor authentication enabled"})
+}
+
+// DisableTwoFactor turns off two-factor authentication after checking a
+// current code, and forgets the secret
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorCodeRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	if !validRequest(w, r, h.log, &req) {
+		return
+	}
+
+	user, ok := h.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		utils.BadRequest(w, r, "Two-factor authentication is not enabled")
+		return
+	}
+
+	counter, ok := h.checkTOTP(w, r, user, req.Code)
+	if !ok {
+		return
+	}
+	if !h.useTOTPCounter(w, r, user.ID, counter) {
+		return
+	}
+
+	if err := h.repo.User.DisableTOTP(r.Context(), user.ID); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to disable two-factor authentication")
+		utils.InternalServerError(w, r, "Failed to disable two-factor authentication")
+		return
+	}
+
+	logger.FromContext(r.Context()).WithUserID(user.ID.String()).Info("two-factor authentication disabled")
+	utils.JSONSuccess(w, http.StatusOK, models.MessageResponse{Message: "Two-factor authentication disabled"})
+}
+
+// twoFactorChallenge answers a correct password on an account with two-factor
+// authentication with a short-lived challenge token instead of a session
+func (h *AuthHandler) twoFactorChallenge(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if h.totpSecrets == nil {
+		// Enabled while TOTP_ENCRYPTION_KEY was set, so the secret can't be read now
+		logger.FromContext(r.Context()).WithUserID(user.ID.String()).Error("two-factor login attempted without TOTP_ENCRYPTION_KEY")
+		utils.InternalServerError(w, r, "Failed to login")
+		return
+	}
+
+	ttl := h.config.TwoFactor.ChallengeTTL
+	token, _, err := h.jwtManager.GeneratePurposeToken(user.ID, auth.PurposeTwoFactor, ttl)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to generate two-factor challenge")
+		utils.InternalServerError(w, r, "Failed to login")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(ttl.Seconds()),
+	})
+}
+
+// VerifyTwoFactor completes a login started with a correct password by
+// checking a TOTP code against the challenge token Login returned. Wrong
+// codes count towards account lockout, and each code works once.
+func (h *AuthHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorVerifyRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		invalidBody(w, r, err)
+		return
+	}
+	if !validRequest(w, r, h.log, &req) {
+		return
+	}
+
+	claims, err := h.jwtManager.ValidatePurposeToken(req.ChallengeToken, auth.PurposeTwoFactor)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Warn("two-factor challenge validation failed")
+		utils.Unauthorized(w, r, "Invalid or expired challenge token")
+		return
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		utils.Unauthorized(w, r, "Invalid or expired challenge token")
+		return
+	}
+
+	user, err := h.repo.User.GetByID(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to fetch user during two-factor login")
+		utils.InternalServerError(w, r, "Failed to login")
+		return
+	}
+	if user == nil || !user.TwoFactorEnabled {
+		utils.Unauthorized(w, r, "Invalid or expired challenge token")
+		return
+	}
+
+	if h.config.Account.LockoutThreshold > 0 && user.IsLocked(h.jwtManager.Now()) {
+		h.accountLocked(w, r, *user.LockedUntil)
+		return
+	}
+
+	secret, err := h.totpSecrets.Open(user.TOTPSecret)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to decrypt TOTP secret")
+		utils.InternalServerError(w, r, "Failed to login")
+		return
+	}
+	counter, ok := auth.VerifyTOTP(secret, req.Code, h.jwtManager.Now())
+	if !ok {
+		logger.FromContext(r.Context()).WithUserID(user.ID.String()).Warn("two-factor code verification failed")
+		h.failedLogin(w, r, user, "Invalid two-factor code")
+		return
+	}
+	if !h.useTOTPCounter(w, r, user.ID, counter) {
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		if err := h.repo.User.ResetFailedLogins(r.Context(), user.ID); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to reset failed logins")
+		}
+	}
+
+	tokens, err := h.issueTokens(r.Context(), user, uuid.New(), h.jwtManager.Now())
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("token generation failed")
+		utils.InternalServerError(w, r, "Failed to login")
+		return
+	}
+
+	utils.JSONSuccess(w, http.StatusOK, models.AuthResponse{
+		User: models.User{
+			ID:               user.ID,
+			Email:            user.Email,
+			Name:             user.Name,
+			EmailVerified:    user.EmailVerified,
+			TwoFactorEnabled: user.TwoFactorEnabled,
+			CreatedAt:        user.CreatedAt,
+			UpdatedAt:        user.UpdatedAt,
+		},
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.jwtManager.AccessTokenTTL().Seconds()),
+	})
+}
+
+// twoFactorUser loads the authenticated user for a two-factor settings change
+func (h *AuthHandler) twoFactorUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	user, err := h.repo.User.GetByID(r.Context(), userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to get user for two-factor change")
+		utils.InternalServerError(w, r, "Failed to update two-factor authentication")
+		return nil, false
+	}
+	if user == nil {
+		utils.NotFound(w, r, "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// checkTOTP verifies code against user's stored secret, responding 400 if it
+// is wrong
+func (h *AuthHandler) checkTOTP(w http.ResponseWriter, r *http.Request, user *models.User, code string) (int64, bool) {
+	secret, err := h.totpSecrets.Open(user.TOTPSecret)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to decrypt TOTP secret")
+		utils.InternalServerError(w, r, "Failed to update two-factor authentication")
+		return 0, false
+	}
+
+	counter, ok := auth.VerifyTOTP(secret, code, h.jwtManager.Now())
+	if !ok {
+		logger.FromContext(r.Context()).WithUserID(user.ID.String()).Warn("two-factor code verification failed")
+		utils.BadRequest(w, r, "Invalid two-factor code")
+		return 0, false
+	}
+	return counter, true
+}
+
+// useTOTPCounter records counter as spent, responding 401 if that code or a
+// later one was already used
+func (h *AuthHandler) useTOTPCounter(w http.ResponseWriter, r *http.Request, userID uuid.UUID, counter int64) bool {
+	used, err := h.repo.User.UseTOTPCounter(r.Context(), userID, counter)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to record TOTP use")
+		utils.InternalServerError(w, r, "Failed to verify two-factor code")
+		return false
+	}
+	if !used {
+		logger.FromContext(r.Context()).WithUserID(userID.String()).Warn("two-factor code replayed")
+		utils.Unauthorized(w, r, "Two-factor code has already been used")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+)
+
+// twoFactorUserRepo holds one user and their two-factor state
+type twoFactorUserRepo struct {
+	fakeUserRepo
+	user        *models.User
+	lastCounter int64
+}
+
+func (f *twoFactorUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if id != f.user.ID {
+		return nil, nil
+	}
+	user := *f.user
+	return &user, nil
+}
+
+func (f *twoFactorUserRepo) SetTOTPSecret(ctx context.Context, id uuid.UUID, encryptedSecret string) (bool, error) {
+	if f.user.TwoFactorEnabled {
+		return false, nil
+	}
+	f.user.TOTPSecret = encryptedSecret
+	return true, nil
+}
+
+func (f *twoFactorUserRepo) EnableTOTP(ctx context.Context, id uuid.UUID, counter int64) error {
+	f.user.TwoFactorEnabled = true
+	f.lastCounter = counter
+	return nil
+}
+
+func (f *twoFactorUserRepo) UseTOTPCounter(ctx context.Context, id uuid.UUID, counter int64) (bool, error) {
+	if counter <= f.lastCounter {
+		return false, nil
+	}
+	f.lastCounter = counter
+	return true, nil
+}
+
+// fakeRefreshTokenRepo accepts every refresh token it is given
+type fakeRefreshTokenRepo struct {
+	repository.RefreshTokenRepositoryInterface
+}
+
+func (fakeRefreshTokenRepo) Create(ctx context.Context, token *models.RefreshToken) error {
+	return nil
+}
+
+// twoFactorServer serves the auth routes with two-factor authentication on
+func twoFactorServer(t *testing.T, users *twoFactorUserRepo, jwtManager *auth.JWTManager) http.Handler {
+	t.Helper()
+	log := testLogger(t)
+	cfg := &config.Config{}
+	cfg.TwoFactor = config.TwoFactorConfig{
+		EncryptionKey: strings.Repeat("k", 32),
+		Issuer:        "Secure Task API",
+		ChallengeTTL:  5 * time.Minute,
+	}
+
+	repo := &repository.Repository{User: users, RefreshToken: fakeRefreshTokenRepo{}}
+	h := NewAuthHandler(cfg, repo, jwtManager, middleware.AuthMiddleware(jwtManager, nil, log), nil, nil, nil, log)
+	router := chi.NewRouter()
+	router.Route("/auth", h.RegisterRoutes)
+	return router
+}
+
+// totpCode returns secret's code at now
+func totpCode(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+	code, err := auth.TOTPCode(secret, auth.TOTPCounter(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTwoFactorSetupEnableAndVerify(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour, auth.WithClock(clk))
+	users := &twoFactorUserRepo{user: &models.User{ID: uuid.New(), Email: "user@example.com", Role: models.UserRoleUser}}
+	handler := twoFactorServer(t, users, jwtManager)
+	token, err := jwtManager.GenerateAccessToken(users.user.ID, users.user.Email, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := sendJSON(handler, http.MethodPost, "/auth/2fa/setup", token, `{}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("setup: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var setup models.APIResponse[models.TwoFactorSetupResponse]
+	if err := json.Unmarshal(rec.Body.Bytes(), &setup); err != nil {
+		t.Fatal(err)
+	}
+	secret := setup.Data.Secret
+	if secret == "" || !strings.HasPrefix(setup.Data.OTPAuthURL, "otpauth://totp/") {
+		t.Fatalf("setup = %+v, want a secret and otpauth URL", setup.Data)
+	}
+	if users.user.TOTPSecret == "" || strings.Contains(users.user.TOTPSecret, secret) {
+		t.Fatal("TOTP secret not stored encrypted")
+	}
+
+	rec = sendJSON(handler, http.MethodPost, "/auth/2fa/enable", token, `{"code": "`+totpCode(t, secret, clk.Now())+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enable: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if !users.user.TwoFactorEnabled {
+		t.Fatal("two-factor authentication not enabled")
+	}
+
+	// Login answers the password with this challenge; the next code completes it
+	challenge, _, err := jwtManager.GeneratePurposeToken(users.user.ID, auth.PurposeTwoFactor, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(30 * time.Second)
+	verify := `{"challenge_token": "` + challenge + `", "code": "` + totpCode(t, secret, clk.Now()) + `"}`
+	rec = sendJSON(handler, http.MethodPost, "/auth/2fa/verify", "", verify)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var login models.APIResponse[models.AuthResponse]
+	if err := json.Unmarshal(rec.Body.Bytes(), &login); err != nil {
+		t.Fatal(err)
+	}
+	if login.Data.Token == "" || login.Data.RefreshToken == "" {
+		t.Fatalf("verify = %+v, want a token pair", login.Data)
+	}
+
+	rec = sendJSON(handler, http.MethodPost, "/auth/2fa/verify", "", verify)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed code: status %d, want 401", rec.Code)
+	}
+}
+
+func TestTwoFactorEnableRejectsWrongCode(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour, auth.WithClock(clk))
+	users := &twoFactorUserRepo{user: &models.User{ID: uuid.New(), Email: "user@example.com"}}
+	handler := twoFactorServer(t, users, jwtManager)
+	token, err := jwtManager.GenerateAccessToken(users.user.ID, users.user.Email, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := sendJSON(handler, http.MethodPost, "/auth/2fa/setup", token, `{}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("setup: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var setup models.APIResponse[models.TwoFactorSetupResponse]
+	if err := json.Unmarshal(rec.Body.Bytes(), &setup); err != nil {
+		t.Fatal(err)
+	}
+
+	// A code from well outside the allowed drift is as wrong as a made-up one
+	stale := totpCode(t, setup.Data.Secret, clk.Now().Add(-5*time.Minute))
+	for _, code := range []string{stale, "abcdef"} {
+		rec = sendJSON(handler, http.MethodPost, "/auth/2fa/enable", token, `{"code": "`+code+`"}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("code %q: status %d, want 400", code, rec.Code)
+		}
+	}
+	if users.user.TwoFactorEnabled {
+		t.Fatal("two-factor authentication enabled with a wrong code")
+	}
+}
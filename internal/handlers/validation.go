@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/pkg/utils"
+)
+
+// currentUserID returns the authenticated user's ID, responding 401 if the
+// request didn't pass through the auth middleware
+func currentUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, ok := middleware.GetUserUUIDFromContext(r.Context())
+	if !ok {
+		utils.Unauthorized(w, r, "User not authenticated")
+	}
+	return id, ok
+}
+
+// uuidParam parses the named URL parameter as a UUID, responding with a
+// validation error naming the parameter if it isn't one
+func uuidParam(w http.ResponseWriter, r *http.Request, log *logger.Logger, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, name))
+	if err != nil {
+		logValidationFailure(log, r, name)
+		utils.ValidationError(w, map[string]string{
+			name: name + " must be a valid UUID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// logValidationFailure records the names of the fields that failed validation,
+// keyed by route pattern so ids in the path don't fragment the logs.
+func logValidationFailure(log *logger.Logger, r *http.Request, fields ...string) {
+	endpoint := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		endpoint = rctx.RoutePattern()
+	}
+	log.ValidationFailure(r.Method+" "+endpoint, chimiddleware.GetReqID(r.Context()), fields)
+}
+
+// fieldNames returns the sorted keys of a validation error map
+func fieldNames(errs map[string]string) []string {
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// paginationParams reads the page and limit of a list request. Over the
+// maximum, the limit is capped with X-Pagination-Max-Limit and Warning
+// headers saying so, or answered with 400 in strict mode.
+func paginationParams(w http.ResponseWriter, r *http.Request, log *logger.Logger) (page, limit int, ok bool) {
+	page, limit, capped, err := utils.GetPaginationParams(r)
+	maxLimit := strconv.Itoa(utils.MaxPageLimit())
+	if err != nil {
+		logValidationFailure(log, r, "limit")
+		w.Header().Set("X-Pagination-Max-Limit", maxLimit)
+		utils.ValidationError(w, map[string]string{
+			"limit": "limit must be at most " + maxLimit,
+		})
+		return 0, 0, false
+	}
+	if capped {
+		w.Header().Set("X-Pagination-Max-Limit", maxLimit)
+		w.Header().Set("Warning", `299 - "limit capped at `+maxLimit+`"`)
+	}
+	return page, limit, true
+}
+
+// invalidBody responds to a ParseJSON error: 413 for an oversized body,
+// otherwise 400
+func invalidBody(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, utils.ErrBodyTooLarge) {
+		utils.PayloadTooLarge(w, r, "Request body too large")
+		return
+	}
+	if errors.Is(err, utils.ErrUnsupportedMediaType) {
+		utils.UnsupportedMediaType(w, r, "Content-Type must be application/json")
+		return
+	}
+	utils.BadRequest(w, r, "Invalid request body")
+}
+
+// validRequest checks req against its validate tags, responding with the
+// per-field errors if any fail
+func validRequest(w http.ResponseWriter, r *http.Request, log *logger.Logger, req interface{}) bool {
+	errs := utils.ValidateStructAll(req)
+	if len(errs) == 0 {
+		return true
+	}
+	logValidationFailure(log, r, failedFields(errs)...)
+	utils.ValidationErrors(w, errs)
+	return false
+}
+
+// failedFields returns the sorted, distinct fields of a validation error list
+func failedFields(errs []utils.FieldError) []string {
+	seen := make(map[string]string, len(errs))
+	for _, e := range errs {
+		seen[e.Field] = e.Message
+	}
+	return fieldNames(seen)
+}
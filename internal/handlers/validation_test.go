@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/middleware"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+	"secure-task-api/internal/stats"
+	"secure-task-api/pkg/utils"
+)
+
+// validationLogServer serves the task routes logging validation failures at
+// level, returning the handler, a token and the log entries reader
+func validationLogServer(t *testing.T, level string) (http.Handler, string, func() []map[string]interface{}) {
+	t.Helper()
+	log, entries := fileLogger(t, config.LoggingConfig{Level: "debug", ValidationFailures: level})
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	token, err := jwtManager.GenerateAccessToken(uuid.New(), "user@example.com", "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewTaskHandler(&repository.Repository{Task: &memoryTaskRepo{}}, config.TaskConfig{}, stats.NewCache(nil, false, 0, log), nil, false, config.RateLimitConfig{}, log)
+	router := chi.NewRouter()
+	router.Use(chimiddleware.RequestID, middleware.AuthMiddleware(jwtManager, nil, log))
+	router.Route("/tasks", h.RegisterRoutes)
+	return router, token, entries
+}
+
+func TestValidationFailureLogsFieldsNotValues(t *testing.T) {
+	handler, token, entries := validationLogServer(t, "info")
+	secret := strings.Repeat("hunter2-", 40)
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title": "`+secret+`", "description": "call me on 555-0100"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(chimiddleware.RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", rec.Code, rec.Body)
+	}
+	var failure map[string]interface{}
+	for _, entry := range entries() {
+		if entry["msg"] == "request validation failed" {
+			failure = entry
+		}
+	}
+	if failure == nil {
+		t.Fatal("no validation failure logged")
+	}
+	if failure["level"] != "info" || failure["endpoint"] != "POST /tasks" || failure["request_id"] != "req-123" {
+		t.Fatalf("entry %v, want info for POST /tasks with the request ID", failure)
+	}
+	if fields, _ := json.Marshal(failure["fields"]); string(fields) != `["title"]` {
+		t.Fatalf("entry fields %s, want only the failing title", fields)
+	}
+	logged, _ := json.Marshal(failure)
+	if strings.Contains(string(logged), "hunter2") || strings.Contains(string(logged), "555-0100") {
+		t.Fatalf("entry %s leaks the submitted values", logged)
+	}
+}
+
+func TestValidationFailureLoggingLevels(t *testing.T) {
+	for _, tc := range []struct {
+		setting string
+		level   string
+	}{
+		{"debug", "debug"},
+		{"info", "info"},
+		{"off", ""},
+	} {
+		handler, token, entries := validationLogServer(t, tc.setting)
+		sendJSON(handler, http.MethodGet, "/tasks/not-a-uuid", token, "")
+
+		var levels []string
+		for _, entry := range entries() {
+			if entry["msg"] == "request validation failed" {
+				levels = append(levels, entry["level"].(string))
+			}
+		}
+		if tc.level == "" && len(levels) != 0 || tc.level != "" && (len(levels) != 1 || levels[0] != tc.level) {
+			t.Errorf("LOG_VALIDATION_FAILURES=%s: logged at %v, want %q", tc.setting, levels, tc.level)
+		}
+	}
+}
+
+func TestMalformedIDsAnswerValidationEnvelope(t *testing.T) {
+	handler, token := taskServer(t, fakeTaskRepo{}, uuid.New())
+	valid := uuid.NewString()
+
+	// fakeTaskRepo panics if called, so each request must stop at the ID
+	for _, tc := range []struct {
+		method, target, body, field string
+	}{
+		{http.MethodGet, "/tasks/not-a-uuid", "", "id"},
+		{http.MethodPut, "/tasks/123", `{"title": "x"}`, "id"},
+		{http.MethodPatch, "/tasks/not-a-uuid/status", `{"status": "completed"}`, "id"},
+		{http.MethodDelete, "/tasks/not-a-uuid", "", "id"},
+		{http.MethodPost, "/tasks/not-a-uuid/restore", "", "id"},
+		{http.MethodDelete, "/tasks/" + valid + "/collaborators/not-a-uuid", "", "userID"},
+	} {
+		rec := sendJSON(handler, tc.method, tc.target, token, tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s %s: status %d, want 400: %s", tc.method, tc.target, rec.Code, rec.Body)
+		}
+		var resp models.ValidationErrorResponse[map[string]string]
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != "Validation Error" || len(resp.Errors) != 1 || resp.Errors[tc.field] != tc.field+" must be a valid UUID" {
+			t.Fatalf("%s %s: body %s, want the validation envelope naming %s", tc.method, tc.target, rec.Body, tc.field)
+		}
+	}
+}
+
+func TestMalformedUserIDInTokenRejected(t *testing.T) {
+	signed := func(userID string) string {
+		t.Helper()
+		claims := auth.Claims{
+			UserID: userID,
+			Email:  "user@example.com",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    auth.DefaultIssuer,
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	handler, _ := taskServer(t, fakeTaskRepo{}, uuid.New())
+
+	// The same token with a well-formed subject gets past authentication
+	if rec := sendJSON(handler, http.MethodGet, "/tasks/not-a-uuid", signed(uuid.NewString()), ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("well-formed subject: status %d, want the 400 for the path ID: %s", rec.Code, rec.Body)
+	}
+	if rec := sendJSON(handler, http.MethodGet, "/tasks/not-a-uuid", signed("not-a-uuid"), ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("malformed subject: status %d, want 401 before any handler runs: %s", rec.Code, rec.Body)
+	}
+}
+
+// validationErrors decodes a 400 validation response's errors by field
+func validationErrors(t *testing.T, code int, body string) map[string]string {
+	t.Helper()
+	if code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", code, body)
+	}
+	var resp models.ValidationErrorResponse[map[string]string]
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+	if resp.Error != "Validation Error" {
+		t.Fatalf("body %s, want the validation envelope", body)
+	}
+	return resp.Errors
+}
+
+func TestAuthRequestsAnswerErrorsByField(t *testing.T) {
+	f := newAuthFixture(t, &config.Config{})
+
+	for _, tc := range []struct {
+		path, body string
+		want       map[string]string
+	}{
+		{"/register", `{}`, map[string]string{
+			"email":    "email is required",
+			"password": "password is required",
+			"name":     "name is required",
+		}},
+		{"/register", `{"email": "not-an-email", "password": "secret-password", "name": "  "}`, map[string]string{
+			"email": "email must be a valid email address",
+			"name":  "name is required",
+		}},
+		{"/login", `{"email": "user@example.com"}`, map[string]string{
+			"password": "password is required",
+		}},
+		{"/login", `{"password": "secret"}`, map[string]string{
+			"email": "email is required",
+		}},
+	} {
+		code, body := f.post(tc.path, tc.body)
+		if got := validationErrors(t, code, body); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %s: errors %v, want %v", tc.path, tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestTaskRequestsAnswerErrorsByField(t *testing.T) {
+	// fakeTaskRepo panics if called, so each request must stop at validation
+	handler, token := taskServer(t, fakeTaskRepo{}, uuid.New())
+	target := "/tasks/" + uuid.NewString()
+
+	for _, tc := range []struct {
+		method, target, body string
+		want                 map[string]string
+	}{
+		{http.MethodPost, "/tasks", `{"description": "no title"}`, map[string]string{
+			"title": "title is required",
+		}},
+		{http.MethodPost, "/tasks", `{"title": "` + strings.Repeat("x", 256) + `"}`, map[string]string{
+			"title": "title must be at most 255 characters",
+		}},
+		{http.MethodPut, target, `{"title": "", "version": 3}`, map[string]string{
+			"title": "title must be at least 1 characters",
+		}},
+		{http.MethodPut, target, `{"status": "archived"}`, map[string]string{
+			"status":  "status must be one of pending, in_progress, completed",
+			"version": "version is required",
+		}},
+	} {
+		rec := sendJSON(handler, tc.method, tc.target, token, tc.body)
+		if got := validationErrors(t, rec.Code, rec.Body.String()); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %s %s: errors %v, want %v", tc.method, tc.target, tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestOversizedAndTrailingBodies(t *testing.T) {
+	utils.SetJSONOptions(utils.JSONOptions{MaxBodyBytes: 128})
+	t.Cleanup(func() { utils.SetJSONOptions(utils.JSONOptions{}) })
+	// fakeTaskRepo panics if called, so each request must stop at the body
+	handler, token := taskServer(t, fakeTaskRepo{}, uuid.New())
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"title": "` + strings.Repeat("x", 200) + `"}`, http.StatusRequestEntityTooLarge},
+		{`{"title": "a"} {"title": "b"}`, http.StatusBadRequest},
+		{`{"title": "a"}garbage`, http.StatusBadRequest},
+	} {
+		rec := sendJSON(handler, http.MethodPost, "/tasks", token, tc.body)
+		if rec.Code != tc.want || !strings.Contains(rec.Body.String(), `"error"`) {
+			t.Errorf("body %.40q: status %d, want %d with a JSON error: %s", tc.body, rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestValidationErrorsAsArray(t *testing.T) {
+	utils.SetValidationErrorFormat(utils.ValidationErrorsArray)
+	t.Cleanup(func() { utils.SetValidationErrorFormat(utils.ValidationErrorsMap) })
+	f := newAuthFixture(t, &config.Config{})
+
+	code, body := f.post("/register", `{"email": "not-an-email"}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", code, body)
+	}
+	var resp models.ValidationErrorResponse[[]utils.FieldError]
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+	want := []utils.FieldError{
+		{Field: "email", Message: "email must be a valid email address"},
+		{Field: "password", Message: "password is required"},
+		{Field: "name", Message: "name is required"},
+	}
+	if !reflect.DeepEqual(resp.Errors, want) {
+		t.Fatalf("errors %v, want %v in struct field order", resp.Errors, want)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+	"secure-task-api/internal/repository"
+	"secure-task-api/internal/webhook"
+	"secure-task-api/pkg/utils"
+)
+
+// WebhookHandler lets task owners see and replay webhook deliveries that
+// failed after every retry
+type WebhookHandler struct {
+	repo     *repository.Repository
+	webhooks *webhook.Dispatcher
+	log      *logger.Logger
+}
+
+func NewWebhookHandler(repo *repository.Repository, webhooks *webhook.Dispatcher, log *logger.Logger) *WebhookHandler {
+	return &WebhookHandler{repo: repo, webhooks: webhooks, log: log}
+}
+
+// Registers webhook routes under /v1/webhooks
+func (h *WebhookHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/deliveries", h.ListDeliveries)
+	r.Post("/deliveries/{id}/replay", h.ReplayDelivery)
+}
+
+// ListDeliveries lists the current user's failed deliveries, newest first,
+// optionally only those with the given status
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	page, limit, ok := paginationParams(w, r, h.log)
+	if !ok {
+		return
+	}
+
+	status := utils.GetQueryParam(r, "status", "")
+	if status != "" {
+		v := utils.NewValidator()
+		v.OneOf("status", status, models.WebhookDeliveryStatuses)
+		if !v.IsValid() {
+			logValidationFailure(h.log, r, "status")
+			utils.ValidationError(w, v.Errors)
+			return
+		}
+	}
+
+	deliveries, total, err := h.repo.WebhookDelivery.ListByUser(r.Context(), userID, status, page, limit)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to list webhook deliveries")
+		utils.InternalServerError(w, r, "Failed to get webhook deliveries")
+		return
+	}
+	for i := range deliveries {
+		deliveries[i].Target = webhook.RedactTarget(deliveries[i].Target)
+	}
+
+	p := pagination(page, limit, total)
+	setPaginationHeaders(w, r, p)
+	utils.JSONSuccess(w, http.StatusOK, models.WebhookDeliveryListResponse{
+		Deliveries: deliveries,
+		Pagination: p,
+	})
+}
+
+// ReplayDelivery sends one of the current user's failed deliveries again.
+// Receivers see the original X-Webhook-ID, so a replay racing another is
+// delivered twice at worst and deduplicated there.
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := uuidParam(w, r, h.log, "id")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.repo.WebhookDelivery.GetByID(r.Context(), id, userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to fetch webhook delivery")
+		utils.InternalServerError(w, r, "Failed to replay webhook delivery")
+		return
+	}
+	if delivery == nil {
+		utils.NotFound(w, r, "Webhook delivery not found")
+		return
+	}
+	if delivery.Status != models.WebhookDeliveryFailed {
+		utils.Conflict(w, r, "Webhook delivery was already replayed")
+		return
+	}
+
+	if err := h.webhooks.Replay(r.Context(), delivery); err != nil {
+		if errors.Is(err, webhook.ErrDisabled) {
+			utils.ServiceUnavailable(w, r, "Task webhooks are disabled")
+			return
+		}
+		log := logger.FromContext(r.Context())
+		log.WithError(log.Redactor().Error(err)).Warn("webhook replay failed")
+		if _, err := h.repo.WebhookDelivery.RecordFailedReplay(r.Context(), id, userID,
+			webhook.FailureReason(delivery.Target, err)); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("failed to record webhook replay failure")
+		}
+		utils.BadGateway(w, r, "Webhook delivery failed again")
+		return
+	}
+
+	replayed, err := h.repo.WebhookDelivery.MarkReplayed(r.Context(), id, userID)
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("failed to mark webhook delivery replayed")
+		utils.InternalServerError(w, r, "Failed to replay webhook delivery")
+		return
+	}
+	if replayed == nil {
+		utils.Conflict(w, r, "Webhook delivery was already replayed")
+		return
+	}
+
+	logger.FromContext(r.Context()).WithUserID(userID.String()).Info("webhook delivery replayed")
+	replayed.Target = webhook.RedactTarget(replayed.Target)
+	utils.JSONSuccess(w, http.StatusOK, models.WebhookDeliveryResponse{Delivery: replayed})
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Check is a single dependency health check
+type Check struct {
+	Name     string
+	Required bool          // A failing required check makes the service unready
+	Timeout  time.Duration // Per-check deadline, defaults to DefaultTimeout
+	Fn       func(ctx context.Context) error
+}
+
+// DefaultTimeout applies to checks that don't set their own
+const DefaultTimeout = 2 * time.Second
+
+// Result is the outcome of one check
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latency_ms"`
+
+	// Error is why the check failed, for logs only: driver errors can name
+	// hosts, users and databases, so it is never served
+	Error string `json:"-"`
+}
+
+// Report aggregates the results of all checks
+type Report struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Checks    []Result  `json:"checks"`
+}
+
+// Healthy reports whether every required check passed
+func (r Report) Healthy() bool {
+	return r.Status == "ready"
+}
+
+// Run executes all checks concurrently, each bounded by its own timeout, and
+// returns once every check has finished or timed out
+func Run(ctx context.Context, checks []Check) Report {
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = runOne(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:    "ready",
+		Timestamp: time.Now(),
+		Checks:    results,
+	}
+	for _, res := range results {
+		if res.Required && res.Status != "up" {
+			report.Status = "not_ready"
+			break
+		}
+	}
+
+	return report
+}
+
+// runOne runs a check, giving up at its deadline even if Fn ignores ctx
+func runOne(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{
+		Name:      check.Name,
+		Status:    "up",
+		Required:  check.Required,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = "down"
+		res.Error = err.Error()
+	}
+	return res
+}
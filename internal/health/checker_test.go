@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sleepCheck passes after d, ignoring its context as a stuck driver would
+func sleepCheck(name string, d time.Duration) Check {
+	return Check{Name: name, Required: true, Fn: func(ctx context.Context) error {
+		time.Sleep(d)
+		return nil
+	}}
+}
+
+func TestRunChecksInParallel(t *testing.T) {
+	start := time.Now()
+	report := Run(context.Background(), []Check{
+		sleepCheck("database", 200*time.Millisecond),
+		sleepCheck("cache", 200*time.Millisecond),
+		sleepCheck("queue", 200*time.Millisecond),
+	})
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Fatalf("three 200ms checks took %v, want them run in parallel", elapsed)
+	}
+	if !report.Healthy() || len(report.Checks) != 3 {
+		t.Fatalf("report = %+v, want three passing checks", report)
+	}
+}
+
+func TestRunTimesOutCheckIgnoringContext(t *testing.T) {
+	stuck := sleepCheck("database", 5*time.Second)
+	stuck.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	report := Run(context.Background(), []Check{stuck})
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("Run took %v, want it to give up at the 50ms timeout", elapsed)
+	}
+	if report.Healthy() || report.Checks[0].Status != "down" {
+		t.Fatalf("report = %+v, want the stuck check down", report)
+	}
+	if !strings.Contains(report.Checks[0].Error, context.DeadlineExceeded.Error()) {
+		t.Fatalf("error = %q, want the deadline", report.Checks[0].Error)
+	}
+}
+
+func TestRunOptionalFailureStaysReady(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		sleepCheck("database", 0),
+		{Name: "cache", Fn: func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.5:6379: connection refused") }},
+	})
+	if !report.Healthy() {
+		t.Fatalf("report = %+v, want ready with only an optional check down", report)
+	}
+	if report.Checks[1].Status != "down" {
+		t.Fatalf("cache status %q, want down", report.Checks[1].Status)
+	}
+}
+
+func TestReportOmitsErrorDetail(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		{Name: "database", Required: true, Fn: func(ctx context.Context) error {
+			return errors.New(`password authentication failed for user "tasks"`)
+		}},
+	})
+	body, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "password authentication") {
+		t.Fatalf("report JSON %s leaks the check error", body)
+	}
+	if report.Checks[0].Error == "" {
+		t.Fatal("error detail dropped, want it kept for logging")
+	}
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"secure-task-api/internal/logger"
+)
+
+// Monitor runs a check in the background and keeps a ready flag that is set
+// while it passes, so requests can learn about an outage without each waiting
+// on a dead dependency. A nil Monitor is always ready.
+type Monitor struct {
+	check    Check
+	interval time.Duration
+	log      *logger.Logger
+	ready    atomic.Bool
+}
+
+// NewMonitor creates a Monitor running check every interval. It starts out
+// ready, since the service only starts once its dependencies are reachable.
+func NewMonitor(check Check, interval time.Duration, log *logger.Logger) *Monitor {
+	m := &Monitor{check: check, interval: interval, log: log}
+	m.ready.Store(true)
+	return m
+}
+
+// Ready reports whether the last check passed
+func (m *Monitor) Ready() bool {
+	return m == nil || m.ready.Load()
+}
+
+// Run probes every interval until ctx is cancelled. It is a no-op for a
+// zero interval.
+func (m *Monitor) Run(ctx context.Context) {
+	if m == nil || m.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Probe(ctx)
+		}
+	}
+}
+
+// Probe runs the check once, updates the ready flag and returns it. Changes
+// are logged: a warning when the check starts failing, info on recovery.
+func (m *Monitor) Probe(ctx context.Context) bool {
+	res := runOne(ctx, m.check)
+	if ctx.Err() != nil {
+		// Shutting down; a cancelled check says nothing about the dependency
+		return m.Ready()
+	}
+
+	ready := res.Status == "up"
+	if m.ready.Swap(ready) != ready {
+		if ready {
+			m.log.Info("dependency recovered, ready again", zap.String("check", res.Name))
+		} else {
+			m.log.WithError(errors.New(res.Error)).Warn("dependency check failed, not ready", zap.String("check", res.Name))
+		}
+	}
+	return ready
+}
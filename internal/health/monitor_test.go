@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+)
+
+// flakyPing fails while down is set, as a database ping does during an outage
+type flakyPing struct {
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (p *flakyPing) check() Check {
+	return Check{Name: "database", Required: true, Fn: func(ctx context.Context) error {
+		p.calls.Add(1)
+		if p.down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}}
+}
+
+func testLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "fatal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+func TestProbeFlipsReadiness(t *testing.T) {
+	ping := &flakyPing{}
+	m := NewMonitor(ping.check(), time.Second, testLogger(t))
+	if !m.Ready() {
+		t.Fatal("monitor starts unready")
+	}
+
+	ping.down.Store(true)
+	if m.Probe(context.Background()) || m.Ready() {
+		t.Fatal("still ready after a failed ping")
+	}
+
+	ping.down.Store(false)
+	if !m.Probe(context.Background()) || !m.Ready() {
+		t.Fatal("not ready again after the ping recovered")
+	}
+}
+
+func TestProbeIgnoresCancelledChecks(t *testing.T) {
+	m := NewMonitor(Check{Name: "database", Fn: func(ctx context.Context) error { return ctx.Err() }}, time.Second, testLogger(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if !m.Probe(ctx) || !m.Ready() {
+		t.Fatal("shutdown marked the dependency down")
+	}
+}
+
+func TestRunTracksOutageAndRecovery(t *testing.T) {
+	ping := &flakyPing{}
+	m := NewMonitor(ping.check(), 5*time.Millisecond, testLogger(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for m.Ready() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Ready() stayed %v, want %v", !want, want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	ping.down.Store(true)
+	waitFor(false)
+	ping.down.Store(false)
+	waitFor(true)
+}
+
+func TestNilMonitorIsReady(t *testing.T) {
+	var m *Monitor
+	if !m.Ready() {
+		t.Fatal("nil monitor not ready")
+	}
+	m.Run(context.Background())
+}
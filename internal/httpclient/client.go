@@ -0,0 +1,184 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"secure-task-api/internal/config"
+)
+
+// Client wraps an http.Client with retries using exponential backoff and full
+// jitter. It is meant for calls to third-party integrations; requests to our
+// own database or handlers never go through it.
+type Client struct {
+	http      *http.Client
+	cfg       config.OutboundConfig
+	retryable map[int]bool
+}
+
+// New creates a Client with the given retry policy
+func New(cfg config.OutboundConfig) *Client {
+	retryable := make(map[int]bool, len(cfg.RetryableStatuses))
+	for _, code := range cfg.RetryableStatuses {
+		retryable[code] = true
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &Client{
+		http:      &http.Client{},
+		cfg:       cfg,
+		retryable: retryable,
+	}
+}
+
+// Do sends req, retrying on transport errors and retryable status codes until
+// MaxAttempts is reached or req's context is done. Requests with a body are
+// only retried when req.GetBody is set, as http.NewRequest does for in-memory
+// bodies. The last response is returned as-is, so a caller may still receive
+// a retryable status once attempts run out.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attempts := c.cfg.MaxAttempts
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(ctx, attempt, lastErr); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.try(ctx, req, attempt)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		if !c.retryable[resp.StatusCode] || attempt == attempts-1 {
+			return resp, nil
+		}
+
+		lastErr = &statusError{code: resp.StatusCode, retryAfter: retryAfter(resp)}
+		drain(resp)
+	}
+
+	return nil, fmt.Errorf("outbound request failed after %d attempts: %w", attempts, lastErr)
+}
+
+// try performs a single attempt under its own timeout. The timeout is
+// released when the returned response body is closed.
+func (c *Client) try(ctx context.Context, req *http.Request, attempt int) (*http.Response, error) {
+	attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+	if c.cfg.AttemptTimeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, c.cfg.AttemptTimeout)
+	}
+
+	r := req.Clone(attemptCtx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	resp, err := c.http.Do(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// wait sleeps for the backoff before the given retry, honouring Retry-After
+// when the server sent one. It returns early if ctx is done.
+func (c *Client) wait(ctx context.Context, attempt int, lastErr error) error {
+	delay := c.backoff(attempt)
+
+	var se *statusError
+	if errors.As(lastErr, &se) && se.retryAfter > 0 {
+		delay = se.retryAfter
+		if c.cfg.BackoffMax > 0 && delay > c.cfg.BackoffMax {
+			delay = c.cfg.BackoffMax
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns a random delay in [0, min(BackoffMax, BackoffBase*2^(attempt-1))).
+// Full jitter keeps many clients that failed together from retrying together.
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.cfg.BackoffBase
+	for i := 1; i < attempt && (c.cfg.BackoffMax <= 0 || ceiling < c.cfg.BackoffMax); i++ {
+		ceiling *= 2
+	}
+	if c.cfg.BackoffMax > 0 && ceiling > c.cfg.BackoffMax {
+		ceiling = c.cfg.BackoffMax
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// statusError records a retryable response so the final error explains why
+// the attempts were used up
+type statusError struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("retryable status %d", e.code)
+}
+
+// retryAfter reads a Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// drain discards a bit of the body so the connection can be reused, then closes it
+func drain(resp *http.Response) {
+	_, _ = io.CopyN(io.Discard, resp.Body, 4<<10)
+	resp.Body.Close()
+}
+
+// cancelOnClose releases an attempt's timeout once the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
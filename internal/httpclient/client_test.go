@@ -0,0 +1,141 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/config"
+)
+
+// flakyServer answers the first failures requests with status, then 200. It
+// fails the test if a retried POST arrives without its body.
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if body, _ := io.ReadAll(r.Body); r.Method == http.MethodPost && string(body) != "payload" {
+			t.Errorf("attempt %d: body %q, want payload", n, body)
+		}
+		if n <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func testConfig(maxAttempts int) config.OutboundConfig {
+	return config.OutboundConfig{
+		MaxAttempts:       maxAttempts,
+		AttemptTimeout:    time.Second,
+		BackoffBase:       time.Millisecond,
+		BackoffMax:        5 * time.Millisecond,
+		RetryableStatuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+	}
+}
+
+func post(t *testing.T, ctx context.Context, client *Client, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		t.Cleanup(func() { resp.Body.Close() })
+	}
+	return resp, err
+}
+
+func TestDoRetriesFlakyServer(t *testing.T) {
+	server, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+
+	resp, err := post(t, context.Background(), New(testConfig(3)), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("server saw %d attempts, want 3", n)
+	}
+}
+
+func TestDoReturnsLastResponseWhenAttemptsRunOut(t *testing.T) {
+	server, calls := flakyServer(t, 5, http.StatusBadGateway)
+
+	resp, err := post(t, context.Background(), New(testConfig(3)), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("status %d, want the last 502", resp.StatusCode)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("server saw %d attempts, want 3", n)
+	}
+}
+
+func TestDoDoesNotRetryOtherStatuses(t *testing.T) {
+	server, calls := flakyServer(t, 1, http.StatusBadRequest)
+
+	resp, err := post(t, context.Background(), New(testConfig(3)), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest || calls.Load() != 1 {
+		t.Fatalf("status %d after %d attempts, want 400 after 1", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoRetriesTimedOutAttempt(t *testing.T) {
+	var calls atomic.Int32
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			<-unblock
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(unblock) })
+
+	cfg := testConfig(2)
+	cfg.AttemptTimeout = 50 * time.Millisecond
+	resp, err := post(t, context.Background(), New(cfg), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("status %d after %d attempts, want 200 after 2", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoStopsRetryingWhenContextDone(t *testing.T) {
+	server, calls := flakyServer(t, 100, http.StatusServiceUnavailable)
+
+	cfg := testConfig(100)
+	cfg.BackoffBase, cfg.BackoffMax = time.Second, time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := post(t, ctx, New(cfg), server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := calls.Load(); n > 2 {
+		t.Fatalf("server saw %d attempts after the context ended, want at most 2", n)
+	}
+}
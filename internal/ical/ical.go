@@ -0,0 +1,133 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"secure-task-api/internal/models"
+)
+
+// ContentType is the media type of an iCalendar feed
+const ContentType = "text/calendar; charset=utf-8"
+
+// timestampFormat is the RFC 5545 UTC date-time form
+const timestampFormat = "20060102T150405Z"
+
+// maxLineOctets is the longest content line allowed before folding
+const maxLineOctets = 75
+
+// textEscaper escapes TEXT values per RFC 5545 section 3.3.11
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// Encoder streams tasks as an iCalendar with one VEVENT per task due date.
+// Tasks without a due date are skipped. The calendar header is written with
+// the first batch, and Close writes the footer.
+type Encoder struct {
+	bw      *bufio.Writer
+	name    string
+	now     string
+	started bool
+}
+
+// NewEncoder returns an Encoder writing a calendar named calendarName to w
+func NewEncoder(w io.Writer, calendarName string) *Encoder {
+	return &Encoder{
+		bw:   bufio.NewWriter(w),
+		name: calendarName,
+		now:  time.Now().UTC().Format(timestampFormat),
+	}
+}
+
+// Encode writes a batch of tasks and flushes them to the underlying writer
+func (e *Encoder) Encode(tasks []models.Task) error {
+	e.start()
+	for _, task := range tasks {
+		if task.DueDate.IsZero() {
+			continue
+		}
+		e.writeEvent(task)
+	}
+	return e.bw.Flush()
+}
+
+// Close ends the calendar. It must be called once after the last batch.
+func (e *Encoder) Close() error {
+	e.start()
+	writeLine(e.bw, "END:VCALENDAR")
+	return e.bw.Flush()
+}
+
+// start writes the calendar header the first time it is called
+func (e *Encoder) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+
+	writeLine(e.bw, "BEGIN:VCALENDAR")
+	writeLine(e.bw, "VERSION:2.0")
+	writeLine(e.bw, "PRODID:-//secure-task-api//Tasks//EN")
+	writeLine(e.bw, "CALSCALE:GREGORIAN")
+	writeLine(e.bw, "METHOD:PUBLISH")
+	writeLine(e.bw, "X-WR-CALNAME:"+escape(e.name))
+}
+
+// writeEvent writes the VEVENT for a task's due date
+func (e *Encoder) writeEvent(task models.Task) {
+	due := task.DueDate.UTC().Format(timestampFormat)
+	stamp := e.now
+	if !task.UpdatedAt.IsZero() {
+		stamp = task.UpdatedAt.UTC().Format(timestampFormat)
+	}
+
+	writeLine(e.bw, "BEGIN:VEVENT")
+	writeLine(e.bw, "UID:"+task.ID.String()+"@secure-task-api")
+	writeLine(e.bw, "DTSTAMP:"+stamp)
+	writeLine(e.bw, "DTSTART:"+due)
+	writeLine(e.bw, "DTEND:"+due)
+	writeLine(e.bw, "SUMMARY:"+escape(task.Title))
+	if task.Description != "" {
+		writeLine(e.bw, "DESCRIPTION:"+escape(task.Description))
+	}
+	writeLine(e.bw, "CATEGORIES:"+escape(task.Status.String()))
+	writeLine(e.bw, "X-TASK-STATUS:"+escape(task.Status.String()))
+	writeLine(e.bw, "END:VEVENT")
+}
+
+// escape escapes a TEXT property value
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writeLine writes a CRLF-terminated content line, folding it so no line
+// exceeds 75 octets and multi-byte characters are never split
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8Start(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines lose one octet to the leading space
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// utf8Start reports whether b begins a UTF-8 sequence
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
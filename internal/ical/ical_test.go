@@ -0,0 +1,129 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/models"
+)
+
+// unfold splits a calendar into content lines, joining folded continuations
+func unfold(t *testing.T, data string) []string {
+	t.Helper()
+	if !strings.HasSuffix(data, "\r\n") {
+		t.Fatalf("calendar %q does not end with CRLF", data)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Fatalf("line %q is %d octets, want at most %d", line, len(line), maxLineOctets)
+		}
+		if strings.HasPrefix(line, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestEncoderWritesOneEventPerDueTask(t *testing.T) {
+	due := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	updated := time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)
+	dueTask := models.Task{
+		ID:          uuid.New(),
+		Title:       "Pay rent, water; gas",
+		Description: "Line one\nLine two",
+		Status:      models.TaskStatusInProgress,
+		DueDate:     due,
+		UpdatedAt:   updated,
+	}
+	longTask := models.Task{ID: uuid.New(), Title: strings.Repeat("é", 60), Status: models.TaskStatusPending, DueDate: due}
+	undated := models.Task{ID: uuid.New(), Title: "Someday", Status: models.TaskStatusPending}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, "Tasks")
+	if err := enc.Encode([]models.Task{dueTask, undated}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode([]models.Task{longTask}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := unfold(t, buf.String())
+
+	if lines[0] != "BEGIN:VCALENDAR" || lines[1] != "VERSION:2.0" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Fatalf("calendar %v, want it wrapped in a version 2.0 VCALENDAR", lines)
+	}
+	if n := strings.Count(buf.String(), "BEGIN:VCALENDAR"); n != 1 {
+		t.Fatalf("%d calendar headers across batches, want 1", n)
+	}
+
+	// Events by UID, checking each is closed before the next opens
+	events := make(map[string]map[string]string)
+	var current map[string]string
+	for _, line := range lines {
+		switch {
+		case line == "BEGIN:VEVENT":
+			if current != nil {
+				t.Fatal("VEVENT opened inside another")
+			}
+			current = make(map[string]string)
+		case line == "END:VEVENT":
+			events[current["UID"]] = current
+			current = nil
+		case current != nil:
+			name, value, _ := strings.Cut(line, ":")
+			current[name] = value
+		}
+	}
+	if current != nil {
+		t.Fatal("VEVENT left open")
+	}
+	if len(events) != 2 {
+		t.Fatalf("%d events, want one per task with a due date", len(events))
+	}
+	if _, ok := events[undated.ID.String()+"@secure-task-api"]; ok {
+		t.Fatal("task without a due date exported")
+	}
+
+	event := events[dueTask.ID.String()+"@secure-task-api"]
+	want := map[string]string{
+		"DTSTAMP":       "20240220T120000Z",
+		"DTSTART":       "20240301T083000Z",
+		"DTEND":         "20240301T083000Z",
+		"SUMMARY":       `Pay rent\, water\; gas`,
+		"DESCRIPTION":   `Line one\nLine two`,
+		"X-TASK-STATUS": "in_progress",
+	}
+	for name, value := range want {
+		if event[name] != value {
+			t.Errorf("%s = %q, want %q", name, event[name], value)
+		}
+	}
+
+	long := events[longTask.ID.String()+"@secure-task-api"]
+	if long["SUMMARY"] != longTask.Title {
+		t.Fatalf("folded SUMMARY = %q, want the title intact", long["SUMMARY"])
+	}
+	if _, ok := long["DESCRIPTION"]; ok {
+		t.Fatal("empty description exported")
+	}
+}
+
+func TestEncoderEmptyCalendar(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, "Tasks").Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := unfold(t, buf.String())
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" || strings.Contains(buf.String(), "VEVENT") {
+		t.Fatalf("calendar %v, want an empty VCALENDAR", lines)
+	}
+}
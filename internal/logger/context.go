@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// nopLogger is returned by FromContext when no logger was attached
+var nopLogger = &Logger{zap.NewNop(), zap.NewNop(), &options{}}
+
+// NewContext returns a copy of ctx carrying l, for FromContext
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger attached to ctx by NewContext. For requests
+// that is the service logger decorated with the request ID. Without one it
+// returns a logger that discards everything.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return nopLogger
+}
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// logfmtEncoding is the LOG_ENCODING value for logfmt output
+const logfmtEncoding = "logfmt"
+
+var (
+	registerLogfmtOnce sync.Once
+	registerLogfmtErr  error
+
+	logfmtPool = buffer.NewPool()
+)
+
+// registerLogfmtEncoder makes "logfmt" usable as a zap encoding
+func registerLogfmtEncoder() error {
+	registerLogfmtOnce.Do(func() {
+		registerLogfmtErr = zap.RegisterEncoder(logfmtEncoding, func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+			return newLogfmtEncoder(cfg), nil
+		})
+	})
+	return registerLogfmtErr
+}
+
+// logfmtEncoder writes entries as space-separated key=value pairs. Entry
+// keys come from the EncoderConfig; nested objects and arrays are written
+// as quoted JSON, and namespaces prefix keys with "name.".
+type logfmtEncoder struct {
+	cfg    zapcore.EncoderConfig
+	buf    *buffer.Buffer // Encoded context fields, each preceded by a space
+	prefix string         // Current namespace
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) *logfmtEncoder {
+	return &logfmtEncoder{cfg: cfg, buf: logfmtPool.Get()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get(), prefix: e.prefix}
+	clone.buf.Write(e.buf.Bytes())
+	return clone
+}
+
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	line := logfmtPool.Get()
+
+	if e.cfg.TimeKey != "" {
+		writePair(line, e.cfg.TimeKey, ent.Time.Format(time.RFC3339Nano))
+	}
+	if e.cfg.LevelKey != "" {
+		writePair(line, e.cfg.LevelKey, ent.Level.String())
+	}
+	if e.cfg.NameKey != "" && ent.LoggerName != "" {
+		writePair(line, e.cfg.NameKey, ent.LoggerName)
+	}
+	if e.cfg.CallerKey != "" && ent.Caller.Defined {
+		writePair(line, e.cfg.CallerKey, ent.Caller.TrimmedPath())
+	}
+	if e.cfg.MessageKey != "" {
+		writePair(line, e.cfg.MessageKey, ent.Message)
+	}
+
+	// Context fields, then this entry's fields, without touching e
+	fieldEnc := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get(), prefix: e.prefix}
+	for _, f := range fields {
+		f.AddTo(fieldEnc)
+	}
+	line.Write(e.buf.Bytes())
+	line.Write(fieldEnc.buf.Bytes())
+	fieldEnc.buf.Free()
+
+	if e.cfg.StacktraceKey != "" && ent.Stack != "" {
+		writePair(line, e.cfg.StacktraceKey, ent.Stack)
+	}
+
+	// Drop the leading space of the first pair
+	out := logfmtPool.Get()
+	if b := line.Bytes(); len(b) > 0 {
+		out.Write(b[1:])
+	}
+	line.Free()
+	out.AppendByte('\n')
+	return out, nil
+}
+
+// writePair appends " key=value", quoting the value when logfmt requires it
+func writePair(buf *buffer.Buffer, key, value string) {
+	buf.AppendByte(' ')
+	buf.AppendString(logfmtKey(key))
+	buf.AppendByte('=')
+	if needsQuoting(value) {
+		buf.AppendString(strconv.Quote(value))
+	} else {
+		buf.AppendString(value)
+	}
+}
+
+// logfmtKey replaces characters a logfmt key can't contain
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// needsQuoting reports whether a value must be quoted to parse back as one token
+func needsQuoting(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *logfmtEncoder) add(key, value string) {
+	writePair(e.buf, e.prefix+key, value)
+}
+
+// addJSON writes a value as quoted JSON, for nested objects and arrays
+func (e *logfmtEncoder) addJSON(key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	e.add(key, string(b))
+	return nil
+}
+
+func (e *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(key, arr); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddObject(key, obj); err != nil {
+		return err
+	}
+	return e.addJSON(key, m.Fields[key])
+}
+
+func (e *logfmtEncoder) AddReflected(key string, value interface{}) error {
+	return e.addJSON(key, value)
+}
+
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.prefix += key + "."
+}
+
+func (e *logfmtEncoder) AddBinary(key string, value []byte) {
+	e.add(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, value []byte) {
+	e.add(key, string(value))
+}
+
+func (e *logfmtEncoder) AddBool(key string, value bool) {
+	e.add(key, strconv.FormatBool(value))
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, value complex128) {
+	e.add(key, fmt.Sprint(value))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, value complex64) {
+	e.add(key, fmt.Sprint(value))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	e.add(key, value.String())
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, value float64) {
+	e.add(key, formatFloat(value, 64))
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, value float32) {
+	e.add(key, formatFloat(float64(value), 32))
+}
+
+// formatFloat writes NaN and infinities the way the JSON encoder does
+func formatFloat(value float64, bits int) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, bits)
+}
+
+func (e *logfmtEncoder) AddInt(key string, value int)     { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt32(key string, value int32) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt16(key string, value int16) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt8(key string, value int8)   { e.AddInt64(key, int64(value)) }
+
+func (e *logfmtEncoder) AddInt64(key string, value int64) {
+	e.add(key, strconv.FormatInt(value, 10))
+}
+
+func (e *logfmtEncoder) AddString(key, value string) {
+	e.add(key, value)
+}
+
+func (e *logfmtEncoder) AddTime(key string, value time.Time) {
+	e.add(key, value.Format(time.RFC3339Nano))
+}
+
+func (e *logfmtEncoder) AddUint(key string, value uint)       { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint32(key string, value uint32)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint16(key string, value uint16)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint8(key string, value uint8)     { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUintptr(key string, value uintptr) { e.AddUint64(key, uint64(value)) }
+
+func (e *logfmtEncoder) AddUint64(key string, value uint64) {
+	e.add(key, strconv.FormatUint(value, 10))
+}
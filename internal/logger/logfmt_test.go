@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"secure-task-api/internal/config"
+)
+
+// encodeLogfmt encodes one entry with the given context and entry fields
+func encodeLogfmt(t *testing.T, context []zapcore.Field, fields ...zapcore.Field) string {
+	t.Helper()
+	enc := newLogfmtEncoder(zapcore.EncoderConfig{LevelKey: "level", MessageKey: "msg"})
+	for _, f := range context {
+		f.AddTo(enc)
+	}
+	buf, err := enc.EncodeEntry(zapcore.Entry{Level: zapcore.WarnLevel, Message: "request done"}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestLogfmtEncodesPairs(t *testing.T) {
+	got := encodeLogfmt(t, []zapcore.Field{zap.String("service", "api")},
+		zap.Int("status", 404),
+		zap.Bool("cached", false),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.Float64("ratio", 0.25),
+		zap.String("path", "/v1/tasks"),
+	)
+	want := "level=warn msg=\"request done\" service=api status=404 cached=false took=1.5s ratio=0.25 path=/v1/tasks\n"
+	if got != want {
+		t.Fatalf("line %q, want %q", got, want)
+	}
+}
+
+func TestLogfmtQuotesValuesThatWouldSplit(t *testing.T) {
+	for value, want := range map[string]string{
+		"plain":        "v=plain",
+		"two words":    `v="two words"`,
+		"a=b":          `v="a=b"`,
+		`say "hi"`:     `v="say \"hi\""`,
+		"":             `v=""`,
+		"line\nbreak":  `v="line\nbreak"`,
+		`back\slash`:   `v="back\\slash"`,
+		"ünïcode-only": "v=ünïcode-only",
+	} {
+		got := encodeLogfmt(t, nil, zap.String("v", value))
+		if !strings.HasSuffix(got, " "+want+"\n") {
+			t.Errorf("value %q: line %q, want it ending %s", value, got, want)
+		}
+	}
+}
+
+func TestLogfmtNestedValuesAndNamespaces(t *testing.T) {
+	got := encodeLogfmt(t, nil,
+		zap.Strings("fields", []string{"title", "due_date"}),
+		zap.Namespace("http"),
+		zap.String("method", "GET"),
+		zap.String("bad key=x", "y"),
+	)
+	for _, want := range []string{
+		`fields="[\"title\",\"due_date\"]"`,
+		"http.method=GET",
+		"http.bad_key_x=y",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("line %q, want it to contain %s", got, want)
+		}
+	}
+}
+
+// logfmtLine matches a whole line of unquoted or quoted key=value pairs
+var logfmtLine = regexp.MustCompile(`^([^\s="]+=([^\s="]+|"(\\.|[^"\\])*")( |$))+$`)
+
+func TestNewLoggerWritesLogfmt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(config.LoggingConfig{Level: "info", Encoding: "logfmt", OutputPaths: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Info("task created", zap.String("task_id", "123"), zap.String("title", "Pay rent"))
+	log.Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSuffix(string(data), "\n")
+	if strings.Contains(line, "\n") || !logfmtLine.MatchString(line) {
+		t.Fatalf("output %q, want one logfmt line", data)
+	}
+	for _, want := range []string{"level=info", `msg="task created"`, "task_id=123", `title="Pay rent"`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("line %q, want it to contain %s", line, want)
+		}
+	}
+}
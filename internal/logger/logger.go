@@ -1,6 +1,9 @@
 package logger
 
 import (
+	"fmt"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"secure-task-api/internal/config"
@@ -8,26 +11,55 @@ import (
 
 type Logger struct {
 	*zap.Logger
+	// unsampled writes to the same outputs with the same fields, bypassing
+	// sampling, for entries that must never be dropped
+	unsampled *zap.Logger
+	opts      *options
+}
+
+// options holds logger behaviour shared by all derived loggers
+type options struct {
+	validationFailures     bool
+	validationFailureLevel zapcore.Level
+
+	slowRequestThreshold time.Duration
+	slowRequestsOnly     bool
+
+	redactor *Redactor
 }
 
 func NewLogger(cfg config.LoggingConfig) (*Logger, error) {
 	var zapConfig zap.Config
 
-	if cfg.Level == "development" {
+	if cfg.Development {
 		zapConfig = zap.NewDevelopmentConfig()
 	} else {
 		zapConfig = zap.NewProductionConfig()
 	}
 
-	// Set log level
-	level, err := zapcore.ParseLevel(cfg.Level)
-	if err != nil {
-		level = zapcore.InfoLevel
+	// Set log level, independent of the preset
+	level := zapcore.InfoLevel
+	if cfg.Level != "" {
+		parsed, err := zapcore.ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL %q: %w", cfg.Level, err)
+		}
+		level = parsed
 	}
 	zapConfig.Level = zap.NewAtomicLevelAt(level)
 
-	// Set encoding
-	zapConfig.Encoding = cfg.Encoding
+	// Set encoding; unset keeps the preset's (console in development)
+	if cfg.Encoding != "" {
+		zapConfig.Encoding = cfg.Encoding
+	}
+	if err := config.ValidateLogEncoding(zapConfig.Encoding); err != nil {
+		return nil, err
+	}
+	if zapConfig.Encoding == logfmtEncoding {
+		if err := registerLogfmtEncoder(); err != nil {
+			return nil, err
+		}
+	}
 
 	// Set output paths
 	if len(cfg.OutputPaths) > 0 {
@@ -37,16 +69,61 @@ func NewLogger(cfg config.LoggingConfig) (*Logger, error) {
 		zapConfig.ErrorOutputPaths = cfg.ErrorOutputPaths
 	}
 
+	// Rotate file outputs so they can't fill the disk
+	if cfg.Rotation.MaxSizeMB > 0 {
+		if err := registerRotateSink(cfg.Rotation); err != nil {
+			return nil, err
+		}
+		zapConfig.OutputPaths = rotatePaths(zapConfig.OutputPaths)
+		zapConfig.ErrorOutputPaths = rotatePaths(zapConfig.ErrorOutputPaths)
+	}
+
+	// Sampling replaces the preset's. It is applied after building, as
+	// zap.Config would, so an unsampled logger can share the outputs.
+	zapConfig.Sampling = nil
+
+	// The preset's own stack trace level is replaced by the configured one
+	zapConfig.DisableStacktrace = true
+	buildOpts := []zap.Option{zap.AddCallerSkip(1)}
+	if cfg.Stacktrace {
+		stackLevel, err := zapcore.ParseLevel(cfg.StacktraceLevel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_STACKTRACE_LEVEL %q: %w", cfg.StacktraceLevel, err)
+		}
+		buildOpts = append(buildOpts, zap.AddStacktrace(stackLevel))
+	}
+
 	// Build logger
-	logger, err := zapConfig.Build(
-		zap.AddCallerSkip(1),
-		zap.AddStacktrace(zapcore.ErrorLevel),
-	)
+	unsampled, err := zapConfig.Build(buildOpts...)
 	if err != nil {
 		return nil, err
 	}
+	logger := unsampled
+	if s := cfg.Sampling; s.Enabled {
+		logger = unsampled.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, time.Second, s.Initial, s.Thereafter)
+		}))
+	}
 
-	return &Logger{logger}, nil
+	redactKeys := cfg.RedactKeys
+	if len(redactKeys) == 0 {
+		redactKeys = DefaultRedactKeys
+	}
+	opts := &options{
+		slowRequestThreshold: cfg.SlowRequestThreshold,
+		slowRequestsOnly:     cfg.SlowRequestsOnly,
+		redactor:             NewRedactor(redactKeys),
+	}
+	switch cfg.ValidationFailures {
+	case "debug":
+		opts.validationFailures = true
+		opts.validationFailureLevel = zapcore.DebugLevel
+	case "info":
+		opts.validationFailures = true
+		opts.validationFailureLevel = zapcore.InfoLevel
+	}
+
+	return &Logger{logger, unsampled, opts}, nil
 }
 
 func (l *Logger) Sync() error {
@@ -54,29 +131,71 @@ func (l *Logger) Sync() error {
 }
 
 func (l *Logger) With(fields ...zap.Field) *Logger {
-	return &Logger{l.Logger.With(fields...)}
+	return &Logger{l.Logger.With(fields...), l.unsampled.With(fields...), l.opts}
 }
 
 func (l *Logger) WithError(err error) *Logger {
-	return &Logger{l.Logger.With(zap.Error(err))}
+	return l.With(zap.Error(err))
 }
 
 func (l *Logger) WithRequestID(requestID string) *Logger {
-	return &Logger{l.Logger.With(zap.String("request_id", requestID))}
+	return l.With(zap.String("request_id", requestID))
 }
 
 func (l *Logger) WithUserID(userID string) *Logger {
-	return &Logger{l.Logger.With(zap.String("user_id", userID))}
+	return l.With(zap.String("user_id", userID))
 }
 
-// RequestLogger logs HTTP requests
-func (l *Logger) RequestLogger(method, path, remoteAddr, userAgent string, status int, duration float64) {
-	l.Info("HTTP Request",
+// Redactor masks sensitive fields, such as passwords and tokens, in anything
+// logged from a request: use it for every header and body that gets logged
+func (l *Logger) Redactor() *Redactor {
+	if l.opts == nil || l.opts.redactor == nil {
+		return NewRedactor(DefaultRedactKeys)
+	}
+	return l.opts.redactor
+}
+
+// ValidationFailure logs which fields failed validation on an endpoint when
+// enabled via LOG_VALIDATION_FAILURES. Only field names are logged, never values.
+func (l *Logger) ValidationFailure(endpoint, requestID string, fields []string) {
+	if l.opts == nil || !l.opts.validationFailures {
+		return
+	}
+	if ce := l.Check(l.opts.validationFailureLevel, "request validation failed"); ce != nil {
+		ce.Write(
+			zap.String("endpoint", endpoint),
+			zap.String("request_id", requestID),
+			zap.Strings("fields", fields),
+		)
+	}
+}
+
+// RequestLogger logs HTTP requests, with any extra fields appended. Requests
+// slower than SLOW_REQUEST_THRESHOLD are logged at warn with slow=true. Slow
+// requests and client and server errors bypass sampling, so they are never
+// dropped.
+func (l *Logger) RequestLogger(method, path, remoteAddr, userAgent string, status int, duration float64, extra ...zap.Field) {
+	slow := l.opts != nil && l.opts.slowRequestThreshold > 0 &&
+		duration >= float64(l.opts.slowRequestThreshold)/float64(time.Millisecond)
+	if !slow && status < 400 && l.opts != nil && l.opts.slowRequestsOnly {
+		return
+	}
+
+	fields := append([]zap.Field{
 		zap.String("method", method),
 		zap.String("path", path),
 		zap.String("remote_addr", remoteAddr),
 		zap.String("user_agent", userAgent),
 		zap.Int("status", status),
 		zap.Float64("duration_ms", duration),
-	)
+	}, extra...)
+	if slow {
+		l.unsampled.Warn("HTTP Request", append(fields, zap.Bool("slow", true))...)
+		return
+	}
+	if status >= 400 {
+		l.unsampled.Info("HTTP Request", fields...)
+		return
+	}
+	l.Info("HTTP Request", fields...)
 }
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+
+	"secure-task-api/internal/config"
+)
+
+func TestNewLoggerLevelIndependentOfMode(t *testing.T) {
+	for _, development := range []bool{false, true} {
+		for _, tc := range []struct {
+			level string
+			want  []string
+		}{
+			{"debug", []string{"debug", "info", "warn"}},
+			{"info", []string{"info", "warn"}},
+			{"warn", []string{"warn"}},
+			{"error", nil},
+		} {
+			// JSON so the entries can be read back in either mode
+			log, entries := fileLogger(t, config.LoggingConfig{Level: tc.level, Development: development, Encoding: "json"})
+			log.Debug("debug")
+			log.Info("info")
+			log.Warn("warn")
+
+			got := entries()
+			if len(got) != len(tc.want) {
+				t.Fatalf("development=%v level %s: %d entries, want %v", development, tc.level, len(got), tc.want)
+			}
+			for i, entry := range got {
+				// The development preset names the message key M
+				msg, ok := entry["msg"]
+				if !ok {
+					msg = entry["M"]
+				}
+				if msg != tc.want[i] {
+					t.Errorf("development=%v level %s: entry %d = %v, want %s", development, tc.level, i, msg, tc.want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestNewLoggerRejectsModeAsLevel(t *testing.T) {
+	if _, err := NewLogger(config.LoggingConfig{Level: "development"}); err == nil {
+		t.Fatal("level development accepted, want it rejected as a severity")
+	}
+}
+
+func TestNewLoggerRejectsUnknownEncoding(t *testing.T) {
+	_, err := NewLogger(config.LoggingConfig{Level: "info", Encoding: "xml"})
+	want := `invalid LOG_ENCODING "xml": must be one of json, console, logfmt`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("err = %v, want it to contain %s", err, want)
+	}
+}
+
+// requestEntries logs n identical requests answered with status and counts
+// the entries written
+func requestEntries(t *testing.T, cfg config.LoggingConfig, status, n int) int {
+	t.Helper()
+	log, entries := fileLogger(t, cfg)
+	for i := 0; i < n; i++ {
+		log.RequestLogger("GET", "/v1/tasks", "192.0.2.1", "test", status, 1)
+	}
+	return len(entries())
+}
+
+func TestSamplingDropsRepeatedRequestLogs(t *testing.T) {
+	sampled := config.LoggingConfig{Level: "info", Sampling: config.LogSamplingConfig{Enabled: true, Initial: 2, Thereafter: 1000}}
+	if got := requestEntries(t, sampled, 200, 10); got != 2 {
+		t.Fatalf("sampled 200s: %d entries, want the first 2", got)
+	}
+
+	unsampled := config.LoggingConfig{Level: "info"}
+	if got := requestEntries(t, unsampled, 200, 10); got != 10 {
+		t.Fatalf("unsampled 200s: %d entries, want all 10", got)
+	}
+}
+
+func TestSamplingKeepsErrorRequestLogs(t *testing.T) {
+	sampled := config.LoggingConfig{Level: "info", Sampling: config.LogSamplingConfig{Enabled: true, Initial: 2, Thereafter: 1000}}
+	for _, status := range []int{404, 500, 503} {
+		if got := requestEntries(t, sampled, status, 10); got != 10 {
+			t.Errorf("sampled %ds: %d entries, want all 10", status, got)
+		}
+	}
+}
+
+func TestStacktraceConfigurable(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		cfg       config.LoggingConfig
+		wantStack []string
+	}{
+		{"disabled", config.LoggingConfig{Stacktrace: false, StacktraceLevel: "warn"}, nil},
+		{"error level", config.LoggingConfig{Stacktrace: true, StacktraceLevel: "error"}, []string{"error"}},
+		{"warn level", config.LoggingConfig{Stacktrace: true, StacktraceLevel: "warn"}, []string{"warn", "error"}},
+	} {
+		log, entries := fileLogger(t, tc.cfg)
+		log.Info("info")
+		log.Warn("warn")
+		log.Error("error")
+
+		var stacked []string
+		for _, entry := range entries() {
+			if _, ok := entry["stacktrace"]; ok {
+				stacked = append(stacked, entry["msg"].(string))
+			}
+		}
+		if strings.Join(stacked, ",") != strings.Join(tc.wantStack, ",") {
+			t.Errorf("%s: stack traces on %v, want %v", tc.name, stacked, tc.wantStack)
+		}
+	}
+}
+
+func TestStacktraceRejectsInvalidLevel(t *testing.T) {
+	if _, err := NewLogger(config.LoggingConfig{Level: "info", Stacktrace: true, StacktraceLevel: "loud"}); err == nil {
+		t.Fatal("stack trace level loud accepted, want an error")
+	}
+}
+
+func TestSamplingCanBeDisabled(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		sampling config.LogSamplingConfig
+		want     int
+	}{
+		{"sampled", config.LogSamplingConfig{Enabled: true, Initial: 3, Thereafter: 1000}, 3},
+		{"disabled", config.LogSamplingConfig{Enabled: false, Initial: 3, Thereafter: 1000}, 20},
+	} {
+		log, entries := fileLogger(t, config.LoggingConfig{Level: "debug", Sampling: tc.sampling})
+		for i := 0; i < 20; i++ {
+			log.Debug("cache miss")
+		}
+		if got := len(entries()); got != tc.want {
+			t.Errorf("%s: %d of 20 repeated debug entries written, want %d", tc.name, got, tc.want)
+		}
+	}
+}
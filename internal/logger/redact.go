@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the value of a sensitive field in logs
+const Redacted = "***"
+
+// DefaultRedactKeys are the field and header names masked when LOG_REDACT_KEYS
+// is unset
+var DefaultRedactKeys = []string{
+	"password", "token", "refresh_token", "authorization", "cookie", "set-cookie", "x-api-key", "secret",
+}
+
+// Redactor masks the values of sensitive keys. Keys match ignoring case, and
+// also as a suffix after _ or -, so "password" covers new_password too.
+type Redactor struct {
+	keys map[string]struct{}
+}
+
+// NewRedactor creates a Redactor for keys
+func NewRedactor(keys []string) *Redactor {
+	set := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		set[strings.ToLower(strings.TrimSpace(key))] = struct{}{}
+	}
+	return &Redactor{keys: set}
+}
+
+// Sensitive reports whether values under key must not be logged
+func (r *Redactor) Sensitive(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := r.keys[key]; ok {
+		return true
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] != '_' && key[i] != '-' {
+			continue
+		}
+		if _, ok := r.keys[key[i+1:]]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns value, or Redacted if key is sensitive
+func (r *Redactor) Redact(key, value string) string {
+	if r.Sensitive(key) {
+		return Redacted
+	}
+	return value
+}
+
+// Headers returns a field logging h with sensitive header values masked
+func (r *Redactor) Headers(h http.Header) zap.Field {
+	return zap.Object("headers", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for name, values := range h {
+			enc.AddString(name, r.Redact(name, strings.Join(values, ", ")))
+		}
+		return nil
+	}))
+}
+
+// URL returns raw with its password and the values of sensitive query
+// parameters masked. A URL that doesn't parse is left out, since there is no
+// telling what it holds.
+func (r *Redactor) URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[unparsed]"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if r.Sensitive(key) {
+				query[key] = []string{Redacted}
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// Error returns err with the URL of an outbound request it failed, if any,
+// masked as URL does, for logging HTTP client errors
+func (r *Redactor) Error(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), urlErr.URL, r.URL(urlErr.URL)))
+}
+
+// Body returns a field logging a JSON body with the values of sensitive keys
+// masked at any depth. A body that isn't JSON is left out, since there is no
+// telling what it holds.
+func (r *Redactor) Body(body []byte) zap.Field {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return zap.String("body", "[unparsed]")
+	}
+	return zap.Any("body", r.redactValue(v))
+}
+
+// redactValue masks sensitive keys in a decoded JSON value
+func (r *Redactor) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if r.Sensitive(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = r.redactValue(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = r.redactValue(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"secure-task-api/internal/config"
+)
+
+// rotateScheme is the zap sink scheme for size-rotated log files
+const rotateScheme = "rotate"
+
+var (
+	registerRotateOnce sync.Once
+	registerRotateErr  error
+
+	// rotation holds the settings used by the rotate sink. zap sinks are
+	// registered globally, so the most recently built logger's settings apply.
+	rotationMu sync.Mutex
+	rotation   config.LogRotationConfig
+)
+
+// registerRotateSink makes "rotate:<path>" output paths write through lumberjack
+func registerRotateSink(cfg config.LogRotationConfig) error {
+	rotationMu.Lock()
+	rotation = cfg
+	rotationMu.Unlock()
+
+	registerRotateOnce.Do(func() {
+		registerRotateErr = zap.RegisterSink(rotateScheme, func(u *url.URL) (zap.Sink, error) {
+			path := u.Opaque
+			if path == "" {
+				path = u.Path
+			}
+
+			rotationMu.Lock()
+			cfg := rotation
+			rotationMu.Unlock()
+
+			return &rotatingSink{Logger: &lumberjack.Logger{
+				Filename:   path,
+				MaxSize:    cfg.MaxSizeMB,
+				MaxAge:     cfg.MaxAgeDays,
+				MaxBackups: cfg.MaxBackups,
+				Compress:   cfg.Compress,
+			}}, nil
+		})
+	})
+	return registerRotateErr
+}
+
+// rotatePaths rewrites plain file paths to use the rotate sink, leaving
+// stdout, stderr and URLs with an explicit scheme unchanged
+func rotatePaths(paths []string) []string {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		out[i] = p
+		if isFilePath(p) {
+			out[i] = rotateScheme + ":" + strings.TrimPrefix(p, "file://")
+		}
+	}
+	return out
+}
+
+// isFilePath reports whether path names a log file rather than a stream or custom sink
+func isFilePath(path string) bool {
+	if path == "stdout" || path == "stderr" || path == "" {
+		return false
+	}
+	if strings.HasPrefix(path, "file://") {
+		return true
+	}
+	return !strings.Contains(path, "://") && !strings.HasPrefix(path, rotateScheme+":")
+}
+
+// rotatingSink adapts lumberjack to zap.Sink; Sync is a no-op since
+// lumberjack writes straight to the file
+type rotatingSink struct {
+	*lumberjack.Logger
+}
+
+func (s *rotatingSink) Sync() error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"secure-task-api/internal/config"
+)
+
+// logBytes writes about n bytes of info entries to log
+func logBytes(log *Logger, n int) {
+	line := strings.Repeat("x", 1024)
+	for written := 0; written < n; written += len(line) {
+		log.Info(line)
+	}
+	log.Sync()
+}
+
+func TestRotationTriggersAtSizeThreshold(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	log, err := NewLogger(config.LoggingConfig{
+		Level:       "info",
+		Encoding:    "json",
+		OutputPaths: []string{path},
+		Rotation:    config.LogRotationConfig{MaxSizeMB: 1, MaxBackups: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logBytes(log, 512*1024)
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("below the threshold: %d files, want only the active one", len(files))
+	}
+
+	logBytes(log, 1024*1024)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("past the threshold: %d files, want the active one and a backup", len(files))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 1024*1024 {
+		t.Fatalf("active file %d bytes, want it under the 1MB threshold after rotating", info.Size())
+	}
+}
+
+func TestRotatePathsLeavesStreamsAlone(t *testing.T) {
+	got := rotatePaths([]string{"stdout", "stderr", "/var/log/app.log", "file:///var/log/err.log", "rotate:/var/log/x.log", "custom://sink"})
+	want := []string{"stdout", "stderr", "rotate:/var/log/app.log", "rotate:/var/log/err.log", "rotate:/var/log/x.log", "custom://sink"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rotatePaths = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+	"secure-task-api/pkg/utils"
+)
+
+// APIKeyHeader carries an API key in place of a bearer token
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyKey contextKey = "api_key"
+
+// APIKeyStore looks up API keys by hash, as repository.APIKeyRepository does
+type APIKeyStore interface {
+	Use(ctx context.Context, keyHash string) (*models.APIKey, *models.User, error)
+}
+
+// APIKeyMiddleware authenticates requests presenting X-API-Key, attaching the
+// owning user to the context like AuthMiddleware, and hands every other
+// request to fallback. Keys without the write scope may only GET and HEAD.
+func APIKeyMiddleware(keys APIKeyStore, fallback func(http.Handler) http.Handler, log *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withFallback := fallback(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get(APIKeyHeader)
+			if raw == "" {
+				withFallback.ServeHTTP(w, r)
+				return
+			}
+
+			key, owner, err := keys.Use(r.Context(), auth.HashToken(raw))
+			if err != nil {
+				log.WithError(err).Error("API key lookup failed")
+				utils.InternalServerError(w, r, "Failed to check API key")
+				return
+			}
+			if key == nil {
+				log.Warn("unknown or revoked API key")
+				unauthorized(w, "invalid or revoked API key")
+				return
+			}
+
+			scope := models.APIKeyScopeWrite
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = models.APIKeyScopeRead
+			}
+			if !hasScope(key.Scopes, scope) {
+				utils.Forbidden(w, r, "API key lacks the "+scope+" scope")
+				return
+			}
+
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, userIDKey, owner.ID.String())
+			ctx = context.WithValue(ctx, userUUIDKey, owner.ID)
+			ctx = context.WithValue(ctx, emailKey, owner.Email)
+			ctx = context.WithValue(ctx, roleKey, string(owner.Role))
+			ctx = context.WithValue(ctx, apiKeyKey, key)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// helper used by handlers to read the API key a request authenticated with
+func GetAPIKeyFromContext(ctx context.Context) (*models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey).(*models.APIKey)
+	return key, ok
+}
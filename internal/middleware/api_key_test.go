@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+)
+
+// fakeAPIKeyStore holds keys by hash, as the repository does, and forgets
+// revoked ones
+type fakeAPIKeyStore struct {
+	owner *models.User
+	keys  map[string]*models.APIKey
+}
+
+func (f *fakeAPIKeyStore) add(scopes ...string) string {
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		panic(err)
+	}
+	f.keys[auth.HashToken(key)] = &models.APIKey{ID: uuid.New(), UserID: f.owner.ID, Prefix: prefix, Scopes: scopes}
+	return key
+}
+
+func (f *fakeAPIKeyStore) revoke(key string) {
+	delete(f.keys, auth.HashToken(key))
+}
+
+func (f *fakeAPIKeyStore) Use(ctx context.Context, keyHash string) (*models.APIKey, *models.User, error) {
+	key, ok := f.keys[keyHash]
+	if !ok {
+		return nil, nil, nil
+	}
+	return key, f.owner, nil
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "fatal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeAPIKeyStore{owner: &models.User{ID: uuid.New(), Role: models.UserRoleUser}, keys: map[string]*models.APIKey{}}
+	readWrite := store.add(models.APIKeyScopeRead, models.APIKeyScopeWrite)
+	readOnly := store.add(models.APIKeyScopeRead)
+	revoked := store.add(models.APIKeyScopeRead, models.APIKeyScopeWrite)
+	store.revoke(revoked)
+
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	tasks := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := GetUserUUIDFromContext(r.Context()); !ok || id != store.owner.ID {
+			t.Errorf("%s %s: user %v in context, want the key's owner", r.Method, r.URL.Path, id)
+		}
+	})
+	handler := APIKeyMiddleware(store, AuthMiddleware(jwtManager, nil, log), log)(tasks)
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		status int
+	}{
+		{"valid key reads", http.MethodGet, readWrite, http.StatusOK},
+		{"valid key writes", http.MethodPost, readWrite, http.StatusOK},
+		{"read-only key reads", http.MethodGet, readOnly, http.StatusOK},
+		{"read-only key writes", http.MethodPost, readOnly, http.StatusForbidden},
+		{"revoked key", http.MethodGet, revoked, http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "sta_unknown", http.StatusUnauthorized},
+		{"no key or token", http.MethodGet, "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/v1/tasks", nil)
+		if tt.key != "" {
+			req.Header.Set(APIKeyHeader, tt.key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+}
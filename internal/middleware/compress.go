@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressibleTypes are the content types worth compressing
+var compressibleTypes = []string{
+	"application/json",
+	"text/",
+	"application/x-ndjson",
+}
+
+// Compress encodes responses with the enabled algorithm the client ranks
+// highest in Accept-Encoding, breaking ties by the order of algorithms.
+// level is a compress/flate level (-1 default, 1 fastest to 9 best).
+func Compress(algorithms []string, level int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), algorithms)
+			w.Header().Add("Vary", "Accept-Encoding")
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, level: level}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the enabled algorithm with the highest client q-value
+func negotiateEncoding(header string, algorithms []string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, alg := range algorithms {
+		q, ok := accepted[alg]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = alg, q
+		}
+	}
+	return best
+}
+
+// compressWriter defers the compress decision until the content type is known
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	level       int
+	writer      io.WriteCloser
+	decided     bool
+	wroteHeader bool
+}
+
+func (cw *compressWriter) decide() {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || !isCompressible(h.Get("Content-Type")) {
+		return
+	}
+
+	var err error
+	switch cw.encoding {
+	case "gzip":
+		cw.writer, err = gzip.NewWriterLevel(cw.ResponseWriter, cw.level)
+	case "deflate":
+		cw.writer, err = flate.NewWriter(cw.ResponseWriter, cw.level)
+	}
+	if err != nil {
+		cw.writer = nil
+		return
+	}
+
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	// Bodyless responses are never compressed
+	if code != http.StatusNoContent && code != http.StatusNotModified {
+		cw.decide()
+	} else {
+		cw.decided = true
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush pushes buffered compressed data to the client so streaming works
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the compressed stream
+func (cw *compressWriter) Close() error {
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	enabled := []string{"gzip", "deflate"}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"GZIP", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0.5, deflate;q=0.8", "deflate"},
+		{"br", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header, enabled); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// compressed serves body as JSON through Compress, asked for with acceptEncoding
+func compressed(t *testing.T, acceptEncoding, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := Compress([]string{"gzip", "deflate"}, 5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompressEncodesNegotiatedAlgorithm(t *testing.T) {
+	body := `{"data":"` + strings.Repeat("task ", 100) + `"}`
+
+	rec := compressed(t, "gzip", body)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Fatalf("gunzipped body %q, want %q", got, body)
+	}
+
+	rec = compressed(t, "deflate", body)
+	if rec.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("Content-Encoding %q, want deflate", rec.Header().Get("Content-Encoding"))
+	}
+	if got, _ := io.ReadAll(flate.NewReader(rec.Body)); string(got) != body {
+		t.Fatalf("inflated body %q, want %q", got, body)
+	}
+
+	rec = compressed(t, "", body)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != body {
+		t.Fatalf("without Accept-Encoding: encoding %q, body %q, want it sent as is", rec.Header().Get("Content-Encoding"), rec.Body)
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Vary %q, want Accept-Encoding", rec.Header().Get("Vary"))
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"secure-task-api/pkg/utils"
+)
+
+// RequireContentLength rejects requests whose body length is not declared up
+// front (e.g. chunked transfer encoding) with 411 Length Required. It is meant
+// for large-payload endpoints where body limits must be applied predictably.
+func RequireContentLength(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ContentLength is -1 when the length is unknown
+		if r.ContentLength < 0 {
+			utils.LengthRequired(w, r, "Content-Length header is required for this endpoint")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
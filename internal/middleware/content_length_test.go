@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireContentLength(t *testing.T) {
+	var reached []byte
+	handler := RequireContentLength(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	chunked := httptest.NewRequest(http.MethodPost, "/tasks/import", strings.NewReader(`{"tasks": []}`))
+	chunked.ContentLength = -1
+	chunked.TransferEncoding = []string{"chunked"}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, chunked)
+	if rec.Code != http.StatusLengthRequired || !strings.Contains(rec.Body.String(), "Content-Length header is required") {
+		t.Fatalf("chunked: status %d, want 411 with the reason: %s", rec.Code, rec.Body)
+	}
+	if reached != nil {
+		t.Fatal("chunked request reached the handler")
+	}
+
+	for _, body := range []string{`{"tasks": []}`, ""} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks/import", strings.NewReader(body)))
+		if rec.Code != http.StatusNoContent || string(reached) != body {
+			t.Errorf("declared length %d: status %d body %q, want it passed on", len(body), rec.Code, reached)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"secure-task-api/internal/config"
+)
+
+// exposedHeaders are the response headers, beyond the CORS-safelisted ones,
+// that browsers let cross-origin scripts read
+const exposedHeaders = "Link, X-Total-Count, X-Pagination-Max-Limit, Warning, X-Request-ID"
+
+// CORSMiddleware adds CORS headers for requests from allowed origins and
+// answers preflight requests with 204. The request's origin is echoed back
+// only if it is in the allow-list; other origins get no CORS headers, so
+// browsers block them. It must run before routing so preflights for any
+// path are handled.
+func CORSMiddleware(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	allowAll := false
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.ToLower(origin)] = true
+	}
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// Responses differ by origin, so caches must key on it
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin != "" && (allowAll || allowed[strings.ToLower(origin)]) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				if preflight {
+					w.Header().Set("Access-Control-Allow-Methods", methods)
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				} else {
+					w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+			}
+
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
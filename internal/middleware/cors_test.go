@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/config"
+)
+
+func TestCORSCredentialedRequests(t *testing.T) {
+	handler := CORSMiddleware(config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/v1/tasks", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, preflight)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight: status %d, want 204", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "3600",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("preflight: %s = %q, want %q", header, got, want)
+		}
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+	other.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, other)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unlisted origin: Access-Control-Allow-Origin = %q, want none", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("unlisted origin: Access-Control-Allow-Credentials = %q, want none", got)
+	}
+}
+
+// corsHandler wraps a handler that records whether it ran with CORSMiddleware
+func corsHandler(cfg config.CORSConfig) (http.Handler, *bool) {
+	called := new(bool)
+	return CORSMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*called = true
+		w.WriteHeader(http.StatusOK)
+	})), called
+}
+
+var testCORS = config.CORSConfig{
+	AllowedOrigins: []string{"https://app.example.com"},
+	AllowedMethods: []string{"GET", "POST", "DELETE"},
+	AllowedHeaders: []string{"Authorization", "Content-Type"},
+	MaxAge:         10 * time.Minute,
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	handler, called := corsHandler(testCORS)
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+	req.Header.Set("Origin", "https://APP.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !*called || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, handler called %v, want the request passed through", rec.Code, *called)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://APP.example.com" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want the request origin echoed", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != exposedHeaders {
+		t.Fatalf("Access-Control-Expose-Headers = %q, want %q", got, exposedHeaders)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Fatalf("Access-Control-Allow-Credentials = %q, want none unless enabled", got)
+	}
+	if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != "Origin" {
+		t.Fatalf("Vary = %v, want Origin", got)
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	handler, called := corsHandler(testCORS)
+	for _, method := range []string{http.MethodGet, http.MethodOptions} {
+		req := httptest.NewRequest(method, "/v1/tasks", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "DELETE")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		for header := range rec.Header() {
+			if strings.HasPrefix(header, "Access-Control-") {
+				t.Errorf("%s from an unlisted origin: %s set, want no CORS headers", method, header)
+			}
+		}
+	}
+	if !*called {
+		t.Fatal("simple request from an unlisted origin not passed through; browsers, not the server, block it")
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler, called := corsHandler(testCORS)
+	req := httptest.NewRequest(http.MethodOptions, "/v1/tasks/123", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	req.Header.Set("Access-Control-Request-Headers", "Authorization")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent || *called {
+		t.Fatalf("status %d, handler called %v, want 204 answered by the middleware", rec.Code, *called)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, DELETE",
+		"Access-Control-Allow-Headers": "Authorization, Content-Type",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// A plain OPTIONS request is not a preflight and reaches the router
+	plain := httptest.NewRequest(http.MethodOptions, "/v1/tasks", nil)
+	plain.Header.Set("Origin", "https://app.example.com")
+	handler.ServeHTTP(httptest.NewRecorder(), plain)
+	if !*called {
+		t.Fatal("OPTIONS without Access-Control-Request-Method answered as a preflight")
+	}
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	handler, _ := corsHandler(config.CORSConfig{AllowedOrigins: []string{"*"}})
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://anywhere.example.com" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want any origin echoed", got)
+	}
+}
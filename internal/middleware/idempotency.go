@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"secure-task-api/pkg/utils"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentResponse is a recorded response replayed for repeated requests
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyState describes what Begin found for a key
+type IdempotencyState int
+
+const (
+	// IdempotencyStarted means the key was unused and is now marked pending
+	IdempotencyStarted IdempotencyState = iota
+	// IdempotencyInProgress means another request with the key is still running
+	IdempotencyInProgress
+	// IdempotencyCompleted means a response was recorded and should be replayed
+	IdempotencyCompleted
+)
+
+// IdempotencyStore tracks idempotency keys within a dedup window
+type IdempotencyStore interface {
+	// Begin atomically claims key with a pending marker, or reports its current state
+	Begin(key string, ttl time.Duration) (IdempotencyState, *IdempotentResponse)
+	// Complete finalizes a pending key with the response to replay
+	Complete(key string, resp *IdempotentResponse, ttl time.Duration)
+	// Abandon releases a pending key so the request can be retried
+	Abandon(key string)
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+type idempotencyEntry struct {
+	resp      *IdempotentResponse // nil while pending
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]*idempotencyEntry)}
+}
+
+func (s *MemoryIdempotencyStore) Begin(key string, ttl time.Duration) (IdempotencyState, *IdempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expiresAt) {
+		if e.resp == nil {
+			return IdempotencyInProgress, nil
+		}
+		return IdempotencyCompleted, e.resp
+	}
+
+	s.entries[key] = &idempotencyEntry{expiresAt: now.Add(ttl)}
+	return IdempotencyStarted, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, resp *IdempotentResponse, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &idempotencyEntry{resp: resp, expiresAt: time.Now().Add(ttl)}
+}
+
+func (s *MemoryIdempotencyStore) Abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// sweep drops expired entries at most once a minute; callers hold the lock
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Idempotency replays the recorded response for a repeated Idempotency-Key on
+// unsafe methods within ttl, and answers 409 while the first request is still
+// running. Keys are scoped to the authenticated user, so it must run after
+// AuthMiddleware. Server errors are not recorded, leaving the key retryable.
+func Idempotency(store IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID, _ := GetUserIDFromContext(r.Context())
+			scoped := userID + " " + r.Method + " " + r.URL.Path + " " + key
+
+			state, recorded := store.Begin(scoped, ttl)
+			switch state {
+			case IdempotencyInProgress:
+				utils.Conflict(w, r, "A request with this Idempotency-Key is still in progress")
+				return
+			case IdempotencyCompleted:
+				if recorded.ContentType != "" {
+					w.Header().Set("Content-Type", recorded.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(recorded.Status)
+				w.Write(recorded.Body)
+				return
+			}
+
+			rec := &captureWriter{ResponseWriter: w}
+			defer func() {
+				// A panic or server error leaves the key free for a retry
+				if p := recover(); p != nil {
+					store.Abandon(scoped)
+					panic(p)
+				}
+				if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+					store.Abandon(scoped)
+					return
+				}
+				store.Complete(scoped, &IdempotentResponse{
+					Status:      rec.status,
+					ContentType: rec.Header().Get("Content-Type"),
+					Body:        rec.body.Bytes(),
+				}, ttl)
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// captureWriter records the status and body while passing them through
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyConcurrentIdenticalRequests(t *testing.T) {
+	var calls atomic.Int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := Idempotency(NewMemoryIdempotencyStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(entered)
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/tasks", nil)
+		req.Header.Set(IdempotencyKeyHeader, "create-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := make(chan *httptest.ResponseRecorder, 1)
+	go func() { first <- send() }()
+	<-entered
+
+	// Double-submits while the first is still running must not run the handler
+	const repeats = 10
+	var wg sync.WaitGroup
+	statuses := make([]int, repeats)
+	for i := 0; i < repeats; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i] = send().Code
+		}(i)
+	}
+	wg.Wait()
+	for i, status := range statuses {
+		if status != http.StatusConflict {
+			t.Errorf("repeat %d while in progress: status %d, want 409", i, status)
+		}
+	}
+
+	close(release)
+	if rec := <-first; rec.Code != http.StatusCreated {
+		t.Fatalf("first request: status %d, want 201", rec.Code)
+	}
+
+	replay := send()
+	if replay.Code != http.StatusCreated || replay.Body.String() != `{"id":"1"}` {
+		t.Fatalf("repeat after completion: %d %s, want the recorded 201", replay.Code, replay.Body)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("repeat after completion not marked Idempotent-Replayed")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyServerErrorLeavesKeyRetryable(t *testing.T) {
+	var calls atomic.Int32
+	handler := Idempotency(NewMemoryIdempotencyStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for _, want := range []int{http.StatusInternalServerError, http.StatusCreated} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/tasks", nil)
+		req.Header.Set(IdempotencyKeyHeader, "create-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("status %d, want %d", rec.Code, want)
+		}
+	}
+}
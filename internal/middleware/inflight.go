@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// RequestTracker counts requests in flight and completed, so shutdown can
+// report how many were drained
+type RequestTracker struct {
+	active atomic.Int64
+	served atomic.Int64
+}
+
+func NewRequestTracker() *RequestTracker {
+	return &RequestTracker{}
+}
+
+// Middleware counts each request while it runs
+func (t *RequestTracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.active.Add(1)
+		defer func() {
+			t.active.Add(-1)
+			t.served.Add(1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Active returns the number of requests currently being served
+func (t *RequestTracker) Active() int64 {
+	return t.active.Load()
+}
+
+// Served returns the number of requests completed so far
+func (t *RequestTracker) Served() int64 {
+	return t.served.Load()
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gathered returns the default registry's series of the named metric
+func gathered(t *testing.T, name string) []*dto.Metric {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()
+		}
+	}
+	return nil
+}
+
+// labelled returns the series of metrics whose labels include all of labels
+func labelled(metrics []*dto.Metric, labels map[string]string) *dto.Metric {
+	for _, m := range metrics {
+		matched := 0
+		for _, pair := range m.GetLabel() {
+			if v, ok := labels[pair.GetName()]; ok && v == pair.GetValue() {
+				matched++
+			}
+		}
+		if matched == len(labels) {
+			return m
+		}
+	}
+	return nil
+}
+
+// requestCount reads http_requests_total for one method, route and status
+func requestCount(t *testing.T, method, route, status string) float64 {
+	t.Helper()
+	m := labelled(gathered(t, "http_requests_total"), map[string]string{"method": method, "route": route, "status": status})
+	return m.GetCounter().GetValue()
+}
+
+func TestMetricsCountsRequestsByRoutePattern(t *testing.T) {
+	var inFlight float64
+	router := chi.NewRouter()
+	router.Use(Metrics)
+	router.Get("/metered/{id}", func(w http.ResponseWriter, r *http.Request) {
+		inFlight = gathered(t, "http_requests_in_flight")[0].GetGauge().GetValue()
+	})
+	router.Post("/metered/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	ok := requestCount(t, "GET", "/metered/{id}", "200")
+	created := requestCount(t, "POST", "/metered/{id}", "201")
+	unmatched := requestCount(t, "GET", unmatchedRoute, "404")
+	ids := []string{uuid.NewString(), uuid.NewString(), uuid.NewString(), uuid.NewString()}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/metered/"+ids[0], nil),
+		httptest.NewRequest(http.MethodGet, "/metered/"+ids[1], nil),
+		httptest.NewRequest(http.MethodPost, "/metered/"+ids[2], nil),
+		httptest.NewRequest(http.MethodGet, "/missing/"+ids[3], nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := requestCount(t, "GET", "/metered/{id}", "200") - ok; got != 2 {
+		t.Errorf("GET 200 counted %v times, want 2", got)
+	}
+	if got := requestCount(t, "POST", "/metered/{id}", "201") - created; got != 1 {
+		t.Errorf("POST 201 counted %v times, want 1", got)
+	}
+	if got := requestCount(t, "GET", unmatchedRoute, "404") - unmatched; got != 1 {
+		t.Errorf("unmatched 404 counted %v times, want 1", got)
+	}
+	if inFlight < 1 {
+		t.Errorf("in flight during a request: %v, want at least 1", inFlight)
+	}
+
+	// IDs never become label values
+	for _, name := range []string{"http_requests_total", "http_request_duration_seconds"} {
+		for _, m := range gathered(t, name) {
+			for _, pair := range m.GetLabel() {
+				for _, id := range ids {
+					if strings.Contains(pair.GetValue(), id) {
+						t.Errorf("%s has %s label %q, want only patterns", name, pair.GetName(), pair.GetValue())
+					}
+				}
+			}
+		}
+	}
+	h := labelled(gathered(t, "http_request_duration_seconds"), map[string]string{"method": "GET", "route": "/metered/{id}"})
+	if h.GetHistogram().GetSampleCount() < 2 {
+		t.Errorf("latency observed %d times, want at least 2", h.GetHistogram().GetSampleCount())
+	}
+}
@@ -3,22 +3,38 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"strings"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"secure-task-api/internal/auth"
 	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+	"secure-task-api/pkg/utils"
 )
 
 // private context keys to avoid collisions with other packages
 type contextKey string
 
 const (
-	userIDKey contextKey = "user_id"
-	emailKey  contextKey = "email"
+	userIDKey   contextKey = "user_id"
+	emailKey    contextKey = "email"
+	claimsKey   contextKey = "claims"
+	userUUIDKey contextKey = "user_uuid"
+	roleKey     contextKey = "role"
 )
 
-// AuthMiddleware validates the JWT and attaches user data to the request context
-func AuthMiddleware(jwtManager *auth.JWTManager, log *logger.Logger) func(http.Handler) http.Handler {
+// TokenVersionStore reports users' current token versions, as
+// repository.UserRepository does. ok is false for unknown or deleted users.
+type TokenVersionStore interface {
+	GetTokenVersion(ctx context.Context, userID uuid.UUID) (version int, ok bool, err error)
+}
+
+// AuthMiddleware validates the JWT and attaches user data to the request
+// context. With versions, tokens older than the user's token version are
+// rejected; nil skips the check.
+func AuthMiddleware(jwtManager *auth.JWTManager, versions TokenVersionStore, log *logger.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -41,27 +57,122 @@ func AuthMiddleware(jwtManager *auth.JWTManager, log *logger.Logger) func(http.H
 				return
 			}
 
+			// parse the subject once so handlers get a known-good ID
+			userID, err := uuid.Parse(claims.UserID)
+			if err != nil {
+				log.WithError(err).Warn("token carries a malformed user ID")
+				unauthorized(w, "invalid or expired token")
+				return
+			}
+
+			if versions != nil {
+				version, ok, err := versions.GetTokenVersion(r.Context(), userID)
+				if err != nil {
+					log.WithError(err).Error("token version lookup failed")
+					utils.InternalServerError(w, r, "Failed to check token")
+					return
+				}
+				if !ok || claims.TokenVersion != version {
+					log.WithUserID(userID.String()).Warn("token issued before the user's sessions were revoked")
+					unauthorized(w, "invalid or expired token")
+					return
+				}
+			}
+
 			// store authenticated user data in context for downstream handlers
 			ctx := r.Context()
 			ctx = context.WithValue(ctx, userIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, userUUIDKey, userID)
 			ctx = context.WithValue(ctx, emailKey, claims.Email)
+			ctx = context.WithValue(ctx, roleKey, claims.Role)
+			ctx = context.WithValue(ctx, claimsKey, claims)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// StripTrailingSlash normalizes URLs like `/tasks/` to `/tasks`
+// RequireRole returns 403 unless the authenticated user's role is one of roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := GetRoleFromContext(r.Context())
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			utils.Forbidden(w, r, "Insufficient permissions")
+		})
+	}
+}
+
+// StripTrailingSlash normalizes URLs like `/tasks/` and `/tasks//` to
+// `/tasks` before routing, leaving `/` and the query string alone
 func StripTrailingSlash(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		if len(path) > 1 && path[len(path)-1] == '/' {
-			r.URL.Path = path[:len(path)-1]
+		if hasTrailingSlash(r.URL.Path) {
+			r.URL.Path = trimTrailingSlashes(r.URL.Path)
+			// Chi routes on RawPath when the path has encoded characters
+			r.URL.RawPath = trimTrailingSlashes(r.URL.RawPath)
+		}
+		// Below a Mount, such as the API base path, chi routes on the
+		// remaining path instead
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && hasTrailingSlash(rctx.RoutePath) {
+			rctx.RoutePath = trimTrailingSlashes(rctx.RoutePath)
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// RedirectTrailingSlash is StripTrailingSlash for clients and caches that should
+// see the canonical URL: it redirects instead, keeping the query string.
+// GET and HEAD get a 301; other methods a 308, so the method and body are kept.
+func RedirectTrailingSlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasTrailingSlash(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Relative, and with a single leading slash so a path like
+		// //evil.example/ can't redirect to another host
+		target := url.URL{
+			Path:     "/" + strings.TrimLeft(trimTrailingSlashes(r.URL.Path), "/"),
+			RawPath:  trimTrailingSlashes(r.URL.RawPath),
+			RawQuery: r.URL.RawQuery,
+		}
+		if target.RawPath != "" {
+			target.RawPath = "/" + strings.TrimLeft(target.RawPath, "/")
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, target.String(), status)
+	})
+}
+
+// hasTrailingSlash reports whether path other than the root ends in a slash
+func hasTrailingSlash(path string) bool {
+	return len(path) > 1 && path[len(path)-1] == '/'
+}
+
+// trimTrailingSlashes removes every trailing slash, leaving a bare "/" for
+// paths made only of slashes. An empty path stays empty.
+func trimTrailingSlashes(path string) string {
+	if path == "" {
+		return ""
+	}
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return "/"
+}
+
 // extracts the token part from `Authorization: Bearer <token>`
 func bearerToken(header string) string {
 	parts := strings.SplitN(header, " ", 2)
@@ -73,9 +184,11 @@ func bearerToken(header string) string {
 
 // sends a consistent unauthorized response
 func unauthorized(w http.ResponseWriter, msg string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnauthorized)
-	w.Write([]byte(`{"error":"unauthorized","message":"` + msg + `"}`))
+	utils.JSONResponse(w, http.StatusUnauthorized, models.AuthErrorResponse{
+		Error:     "unauthorized",
+		Message:   msg,
+		RequestID: w.Header().Get(utils.RequestIDHeader),
+	})
 }
 
 // helper used by handlers to read user ID from context
@@ -84,8 +197,26 @@ func GetUserIDFromContext(ctx context.Context) (string, bool) {
 	return id, ok
 }
 
+// helper used by handlers to read the parsed user ID from context
+func GetUserUUIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(userUUIDKey).(uuid.UUID)
+	return id, ok
+}
+
 // helper used by handlers to read email from context
 func GetEmailFromContext(ctx context.Context) (string, bool) {
 	email, ok := ctx.Value(emailKey).(string)
 	return email, ok
 }
+
+// helper used by handlers to read the user's role from context
+func GetRoleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(roleKey).(string)
+	return role, ok
+}
+
+// helper used by handlers to read the validated access token claims from context
+func GetClaimsFromContext(ctx context.Context) (*auth.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*auth.Claims)
+	return claims, ok
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"secure-task-api/internal/auth"
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+)
+
+// slashRouter serves /, /tasks and /tasks/{id} behind StripTrailingSlash,
+// mounted under basePath when it is set, as the API router is
+func slashRouter(basePath string) http.Handler {
+	router := chi.NewRouter()
+	router.Use(StripTrailingSlash)
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	}
+	router.Get("/", echo)
+	router.Get("/tasks", echo)
+	router.Get("/tasks/{id}", echo)
+
+	if basePath == "" {
+		return router
+	}
+	root := chi.NewRouter()
+	root.Mount(basePath, router)
+	return root
+}
+
+func TestStripTrailingSlash(t *testing.T) {
+	tests := []struct {
+		basePath string
+		target   string
+		want     string
+	}{
+		{"", "/", "/?"},
+		{"", "/tasks", "/tasks?"},
+		{"", "/tasks/", "/tasks?"},
+		{"", "//", "/?"},
+		{"", "/tasks//", "/tasks?"},
+		{"", "/tasks/?status=pending&page=2", "/tasks?status=pending&page=2"},
+		{"", "/tasks/a%2Fb/", "/tasks/a/b?"},
+		{"/api", "/api/", "/api?"},
+		{"/api", "/api/tasks", "/api/tasks?"},
+		{"/api", "/api/tasks/", "/api/tasks?"},
+		{"/api", "/api/tasks//?status=pending", "/api/tasks?status=pending"},
+		{"/api", "/api/tasks/42/", "/api/tasks/42?"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		slashRouter(tt.basePath).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("base %q, GET %s: status %d, want 200", tt.basePath, tt.target, rec.Code)
+			continue
+		}
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("base %q, GET %s: handler saw %q, want %q", tt.basePath, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	handler := RedirectTrailingSlash(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		method   string
+		target   string
+		status   int
+		location string
+	}{
+		{http.MethodGet, "/", http.StatusOK, ""},
+		{http.MethodGet, "/tasks/?page=2", http.StatusMovedPermanently, "/tasks?page=2"},
+		{http.MethodPost, "/tasks//", http.StatusPermanentRedirect, "/tasks"},
+		{http.MethodGet, "//evil.example/", http.StatusMovedPermanently, "/evil.example"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s %s: Location %q, want %q", tt.method, tt.target, got, tt.location)
+		}
+	}
+}
+
+func TestAuthMiddlewareRejectsJunkTokens(t *testing.T) {
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "fatal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtManager := auth.NewJWTManager("test-secret", time.Minute, time.Hour)
+	reached := false
+	handler := AuthMiddleware(jwtManager, nil, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	rng := rand.New(rand.NewSource(1))
+	headers := []string{"", "Bearer", "Bearer ", "Bearer a.b.c", "Basic dXNlcjpwYXNz", "Bearer " + strings.Repeat("x.", 5000)}
+	for i := 0; i < 200; i++ {
+		b := make([]byte, rng.Intn(120))
+		rng.Read(b)
+		headers = append(headers, "Bearer "+string(b))
+	}
+
+	for _, header := range headers {
+		req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+		req.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized || reached {
+			t.Fatalf("Authorization %.60q: status %d, want 401", header, rec.Code)
+		}
+	}
+}
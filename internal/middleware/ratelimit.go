@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"secure-task-api/pkg/utils"
+)
+
+// KeyFunc returns the key a request is counted against
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP counts requests per client IP. It relies on RealIP running first.
+func KeyByIP(r *http.Request) string {
+	return clientIP(r)
+}
+
+// KeyByUser counts requests per authenticated user, with all anonymous
+// requests sharing one key. It relies on AuthMiddleware running first.
+func KeyByUser(r *http.Request) string {
+	if userID, ok := GetUserIDFromContext(r.Context()); ok {
+		return userID
+	}
+	return "-"
+}
+
+// KeyByRoute counts requests per method and matched route pattern
+func KeyByRoute(r *http.Request) string {
+	pattern := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		pattern = rctx.RoutePattern()
+	}
+	return r.Method + " " + pattern
+}
+
+// CompositeKey counts requests per combination of the given keys, so for
+// example users behind one NAT are limited separately but each still per IP
+func CompositeKey(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = key(r)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// RateLimitKey builds a KeyFunc from config.RateLimitKeys names such as
+// ["ip", "user"]. Unknown names are ignored; config validates them on load.
+func RateLimitKey(parts []string) KeyFunc {
+	var keys []KeyFunc
+	for _, part := range parts {
+		switch part {
+		case "ip":
+			keys = append(keys, KeyByIP)
+		case "user":
+			keys = append(keys, KeyByUser)
+		case "route":
+			keys = append(keys, KeyByRoute)
+		}
+	}
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	return CompositeKey(keys...)
+}
+
+// RateLimit allows each client IP at most limit requests per window. See RateLimitBy.
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	return RateLimitBy(limit, window, KeyByIP)
+}
+
+// RateLimitBy allows each key at most limit requests per window, answering
+// the rest with 429 and a Retry-After header. Counts are kept in memory, so
+// each instance limits independently.
+func RateLimitBy(limit int, window time.Duration, key KeyFunc) func(http.Handler) http.Handler {
+	limiter := newFixedWindowLimiter(limit, window)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter := limiter.allow(key(r), time.Now())
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.5)))
+				utils.TooManyRequests(w, r, "Too many requests, please try again later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the request's remote address without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// fixedWindowLimiter counts requests per key in fixed time windows
+type fixedWindowLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	counts    map[string]*windowCount
+	lastSweep time.Time
+}
+
+type windowCount struct {
+	start time.Time
+	n     int
+}
+
+func newFixedWindowLimiter(limit int, window time.Duration) *fixedWindowLimiter {
+	return &fixedWindowLimiter{
+		limit:  limit,
+		window: window,
+		counts: make(map[string]*windowCount),
+	}
+}
+
+// allow records a request for key and reports whether it is within the
+// limit, and if not how long until the window resets
+func (l *fixedWindowLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop expired windows at most once per window so the map can't grow unbounded
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, c := range l.counts {
+			if now.Sub(c.start) >= l.window {
+				delete(l.counts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.counts[key]
+	if !ok || now.Sub(c.start) >= l.window {
+		c = &windowCount{start: now}
+		l.counts[key] = c
+	}
+
+	if c.n >= l.limit {
+		return false, c.start.Add(l.window).Sub(now)
+	}
+	c.n++
+	return true, 0
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// limitedRouter serves GET /tasks and /stats behind a 2-per-minute limit on key
+func limitedRouter(key []string) http.Handler {
+	router := chi.NewRouter()
+	router.Use(RateLimitBy(2, time.Minute, RateLimitKey(key)))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router.Get("/tasks", ok)
+	router.Get("/stats", ok)
+	return router
+}
+
+// limitedStatus sends GET path from ip as user, "" meaning anonymous
+func limitedStatus(handler http.Handler, ip, user, path string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":4711"
+	if user != "" {
+		req = req.WithContext(context.WithValue(req.Context(), userIDKey, user))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestCompositeKeySeparatesUsersBehindOneIP(t *testing.T) {
+	handler := limitedRouter([]string{"ip", "user"})
+	const nat = "203.0.113.7"
+
+	for i := 0; i < 2; i++ {
+		if code := limitedStatus(handler, nat, "alice", "/tasks"); code != http.StatusOK {
+			t.Fatalf("alice request %d: status %d, want 200", i+1, code)
+		}
+	}
+	if code := limitedStatus(handler, nat, "alice", "/tasks"); code != http.StatusTooManyRequests {
+		t.Fatalf("alice over the limit: status %d, want 429", code)
+	}
+
+	// Bob shares alice's IP but has a separate count, as alice does from another IP
+	for i := 0; i < 2; i++ {
+		if code := limitedStatus(handler, nat, "bob", "/tasks"); code != http.StatusOK {
+			t.Fatalf("bob request %d behind the same IP: status %d, want 200", i+1, code)
+		}
+	}
+	if code := limitedStatus(handler, nat, "bob", "/tasks"); code != http.StatusTooManyRequests {
+		t.Fatalf("bob over the limit: status %d, want 429", code)
+	}
+	if code := limitedStatus(handler, "198.51.100.1", "alice", "/tasks"); code != http.StatusOK {
+		t.Fatalf("alice from another IP: status %d, want 200", code)
+	}
+}
+
+func TestIPKeySharesCountBehindOneIP(t *testing.T) {
+	handler := limitedRouter([]string{"ip"})
+	const nat = "203.0.113.7"
+
+	limitedStatus(handler, nat, "alice", "/tasks")
+	limitedStatus(handler, nat, "bob", "/tasks")
+	if code := limitedStatus(handler, nat, "carol", "/tasks"); code != http.StatusTooManyRequests {
+		t.Fatalf("third user behind the IP: status %d, want 429", code)
+	}
+	if code := limitedStatus(handler, "198.51.100.1", "carol", "/tasks"); code != http.StatusOK {
+		t.Fatalf("another IP: status %d, want 200", code)
+	}
+}
+
+func TestRouteKeyCountsEachRouteSeparately(t *testing.T) {
+	handler := limitedRouter([]string{"ip", "route"})
+	const ip = "203.0.113.7"
+
+	limitedStatus(handler, ip, "", "/tasks")
+	limitedStatus(handler, ip, "", "/tasks")
+	if code := limitedStatus(handler, ip, "", "/tasks"); code != http.StatusTooManyRequests {
+		t.Fatalf("/tasks over the limit: status %d, want 429", code)
+	}
+	if code := limitedStatus(handler, ip, "", "/stats"); code != http.StatusOK {
+		t.Fatalf("/stats from the same IP: status %d, want 200", code)
+	}
+}
+
+func TestCompositeKeyJoinsParts(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.RemoteAddr = "203.0.113.7:4711"
+	if got := RateLimitKey([]string{"ip", "user"})(req); got != "203.0.113.7|-" {
+		t.Fatalf("anonymous key %q, want 203.0.113.7|-", got)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey, "alice"))
+	if got := RateLimitKey([]string{"user", "ip"})(req); got != "alice|203.0.113.7" {
+		t.Fatalf("key %q, want alice|203.0.113.7", got)
+	}
+}
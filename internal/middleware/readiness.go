@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"secure-task-api/pkg/utils"
+)
+
+// RequireReady answers requests with 503 and a Retry-After of retryAfter while
+// ready reports false, such as during a database outage, rather than letting
+// each one fail on its own
+func RequireReady(ready func() bool, retryAfter time.Duration) func(http.Handler) http.Handler {
+	seconds := strconv.Itoa(max(1, int(retryAfter.Seconds()+0.5)))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !ready() {
+				w.Header().Set("Retry-After", seconds)
+				utils.ServiceUnavailable(w, r, "Service temporarily unavailable, please try again later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequireReadyFollowsReadiness(t *testing.T) {
+	var ready atomic.Bool
+	ready.Store(true)
+	handler := RequireReady(ready.Load, 5*time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, up := range []bool{true, false, true} {
+		ready.Store(up)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tasks", nil))
+
+		want, retryAfter := http.StatusOK, ""
+		if !up {
+			want, retryAfter = http.StatusServiceUnavailable, "5"
+		}
+		if rec.Code != want {
+			t.Errorf("ready %v: status %d, want %d", up, rec.Code, want)
+		}
+		if got := rec.Header().Get("Retry-After"); got != retryAfter {
+			t.Errorf("ready %v: Retry-After %q, want %q", up, got, retryAfter)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"secure-task-api/internal/logger"
+	"secure-task-api/pkg/utils"
+)
+
+// RequestIDLogger echoes the request ID chi's RequestID middleware assigned in
+// the X-Request-ID response header, where error responses also pick it up, and
+// attaches log decorated with it to the context for logger.FromContext. It
+// must run after chi's RequestID.
+func RequestIDLogger(log *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := chimiddleware.GetReqID(r.Context())
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(utils.RequestIDHeader, id)
+			ctx := logger.NewContext(r.Context(), log.WithRequestID(id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
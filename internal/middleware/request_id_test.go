@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+	"secure-task-api/pkg/utils"
+)
+
+func TestRequestIDInHeaderErrorBodyAndLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "info", OutputPaths: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("looking up task")
+		if r.URL.Path == "/invalid" {
+			utils.ValidationError(w, map[string]string{"title": "title is required"})
+			return
+		}
+		utils.NotFound(w, r, "Task not found")
+	})
+	handler := chimiddleware.RequestID(RequestIDLogger(log)(failing))
+
+	for _, target := range []string{"/missing", "/invalid"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(chimiddleware.RequestIDHeader, "req-"+target[1:])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		want := "req-" + target[1:]
+		if got := rec.Header().Get(utils.RequestIDHeader); got != want {
+			t.Errorf("%s: %s header %q, want %q", target, utils.RequestIDHeader, got, want)
+		}
+		var body struct {
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.RequestID != want {
+			t.Errorf("%s: error body request_id %q, want %q: %s", target, body.RequestID, want, rec.Body)
+		}
+	}
+
+	log.Sync()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d log lines, want 2: %s", len(lines), data)
+	}
+	for i, want := range []string{"req-missing", "req-invalid"} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["request_id"] != want {
+			t.Errorf("log entry %d request_id %v, want %s", i, entry["request_id"], want)
+		}
+	}
+}
+
+func TestRequestIDGeneratedWithoutClientID(t *testing.T) {
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "fatal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := chimiddleware.RequestID(RequestIDLogger(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.NotFound(w, r, "Task not found")
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	id := rec.Header().Get(utils.RequestIDHeader)
+	if id == "" || !strings.Contains(rec.Body.String(), `"request_id":"`+id+`"`) {
+		t.Fatalf("generated request ID %q not echoed in the body: %s", id, rec.Body)
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"secure-task-api/internal/models"
+)
+
+// TimeoutMiddleware gives each request a context deadline of timeout, so
+// repository calls made with r.Context() are cancelled when it passes. If the
+// handler hasn't started its response by then, the client gets a complete 503
+// straight away and anything the handler writes afterwards is discarded; a
+// response already under way (a streamed export) is left to the handler.
+//
+// The handler runs on its own goroutine, which is still waited for so chi's
+// pooled routing state isn't reused under it; a handler that ignores its
+// context keeps the connection until it returns. Panics are re-raised on the
+// request's goroutine, so recovery middleware must run before this one.
+func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			// Headers set so far, such as X-Request-ID, are visible to the handler
+			tw := &timeoutWriter{w: w, header: w.Header().Clone()}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				return
+			case <-ctx.Done():
+				// A client that went away needs no response
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					tw.timeout(r)
+				}
+			}
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+			}
+		})
+	}
+}
+
+// timeoutWriter holds a handler's headers until it responds, and drops its
+// writes once the request has timed out
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu       sync.Mutex
+	started  bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.started {
+		return
+	}
+	tw.start()
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.started {
+		tw.start()
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.started || tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// start copies the handler's headers to the real response. Callers hold mu.
+func (tw *timeoutWriter) start() {
+	dst := tw.w.Header()
+	for k, v := range tw.header {
+		dst[k] = append([]string(nil), v...)
+	}
+	tw.started = true
+}
+
+// timeout sends the 503 unless the handler has already started its response.
+// It is sent whole, with a length, so the client needn't wait for the handler.
+func (tw *timeoutWriter) timeout(r *http.Request) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.started {
+		return
+	}
+	tw.timedOut = true
+
+	body, _ := json.Marshal(models.ErrorResponse{
+		Error:      http.StatusText(http.StatusServiceUnavailable),
+		Message:    "Request timed out, please try again later",
+		Timestamp:  time.Now(),
+		StatusCode: http.StatusServiceUnavailable,
+		RequestID:  chimiddleware.GetReqID(r.Context()),
+		Path:       r.URL.Path,
+	})
+	body = append(body, '\n')
+	h := tw.w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	tw.w.WriteHeader(http.StatusServiceUnavailable)
+	tw.w.Write(body)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/models"
+)
+
+func TestTimeoutMiddlewareAnswersBeforeSlowHandler(t *testing.T) {
+	finish := make(chan struct{})
+	handlerErr := make(chan error, 1)
+	server := httptest.NewServer(TimeoutMiddleware(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A slow handler that ignores its context and writes late
+		<-finish
+		handlerErr <- r.Context().Err()
+		w.Write([]byte("too late"))
+	})))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		select {
+		case <-finish:
+		default:
+			close(finish)
+		}
+	})
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("response took %v, want it at the timeout", elapsed)
+	}
+
+	// The handler is still blocked, so the 503 came first
+	select {
+	case <-handlerErr:
+		t.Fatal("handler completed before the timeout response")
+	default:
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", resp.StatusCode)
+	}
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		t.Fatalf("body %q is not a JSON error: %v", body, err)
+	}
+	if errResp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("body status_code %d, want 503", errResp.StatusCode)
+	}
+
+	close(finish)
+	if err := <-handlerErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("handler context err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestTimeoutMiddlewarePassesFastResponses(t *testing.T) {
+	handler := TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/tasks", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("got %d %s, want the handler's 201", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type %q, want the handler's", ct)
+	}
+}
+
+func TestTimeoutMiddlewareReraisesPanics(t *testing.T) {
+	handler := TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Fatalf("recovered %v, want the handler's panic", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,14 +16,151 @@ const (
 	TaskStatusCompleted  TaskStatus = "completed"
 )
 
+// TaskRole is the caller's relationship to a task
+type TaskRole string
+
+const (
+	TaskRoleOwner        TaskRole = "owner"
+	TaskRoleCollaborator TaskRole = "collaborator" // Shared with the caller; may edit but not share or delete
+)
+
+// UserRole controls what a user is authorized to do
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin" // May view every user and their tasks
+)
+
+// TaskSortField is a task list field clients may sort by
+type TaskSortField string
+
+const (
+	TaskSortCreatedAt TaskSortField = "created_at"
+	TaskSortUpdatedAt TaskSortField = "updated_at"
+	TaskSortDueDate   TaskSortField = "due_date"
+	TaskSortTitle     TaskSortField = "title"
+)
+
 // User represents a user in the system
 type User struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	Name         string    `json:"name" db:"name"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID            uuid.UUID `json:"id" db:"id"`
+	Email         string    `json:"email" db:"email"`
+	PasswordHash  string    `json:"-" db:"password_hash"`
+	Name          string    `json:"name" db:"name"`
+	EmailVerified bool      `json:"email_verified" db:"email_verified"`
+	Role          UserRole  `json:"role" db:"role"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+
+	FailedLoginAttempts int        `json:"-" db:"failed_login_attempts"`
+	LockedUntil         *time.Time `json:"-" db:"locked_until"`
+
+	TwoFactorEnabled bool   `json:"two_factor_enabled" db:"totp_enabled"`
+	TOTPSecret       string `json:"-" db:"totp_secret"` // Encrypted; set once setup starts
+
+	// TokenVersion is stamped into access tokens; bumping it invalidates them all
+	TokenVersion int `json:"-" db:"token_version"`
+}
+
+// IsLocked reports whether login is refused at now after repeated failures
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+// RefreshToken records an issued refresh token by its jti
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	FamilyID  uuid.UUID  `json:"family_id" db:"family_id"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// UserToken records a single-use token sent to a user, stored by hash
+type UserToken struct {
+	TokenHash string     `json:"-" db:"token_hash"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Purpose   string     `json:"purpose" db:"purpose"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// API key scopes: read allows GET and HEAD requests, write every other method
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+// APIKey is a long-lived credential for server-to-server clients, stored by
+// hash. Prefix is the start of the key, to tell keys apart in listings.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreateAPIKeyRequest represents the request payload for creating an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required"`
+}
+
+// APIKeyCreatedResponse is the only response that includes the full key
+type APIKeyCreatedResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyListResponse represents the response payload for listing API keys
+type APIKeyListResponse struct {
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryFailed   = "failed"   // Still failing after every retry
+	WebhookDeliveryReplayed = "replayed" // Delivered by a later replay
+)
+
+// WebhookDeliveryStatuses lists the statuses deliveries can be listed by
+var WebhookDeliveryStatuses = []string{WebhookDeliveryFailed, WebhookDeliveryReplayed}
+
+// WebhookDelivery is a task webhook event that failed after every retry,
+// kept so the task's owner can see why and replay it. ID is the event's
+// X-Webhook-ID, which a replay sends again so receivers can deduplicate.
+type WebhookDelivery struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	UserID        uuid.UUID       `json:"user_id" db:"user_id"`
+	TaskID        uuid.UUID       `json:"task_id" db:"task_id"`
+	Event         string          `json:"event" db:"event"`
+	Target        string          `json:"target" db:"target"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Status        string          `json:"status" db:"status"`
+	Error         string          `json:"error" db:"error"`
+	Attempts      int             `json:"attempts" db:"attempts"` // Deliveries and replays, each with its retries
+	LastAttemptAt time.Time       `json:"last_attempt_at" db:"last_attempt_at"`
+	ReplayedAt    *time.Time      `json:"replayed_at,omitempty" db:"replayed_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// WebhookDeliveryResponse represents the response payload for a single webhook delivery
+type WebhookDeliveryResponse struct {
+	Delivery *WebhookDelivery `json:"delivery"`
+}
+
+// WebhookDeliveryListResponse represents the response payload for listing webhook deliveries
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Pagination Pagination        `json:"pagination"`
 }
 
 // Task represents a task in the system
@@ -36,19 +174,132 @@ type Task struct {
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"` // Set by the server while completed
+	Tags        []string   `json:"tags" db:"tags"`
+	Version     int        `json:"version" db:"version"` // Incremented on every edit
+	Role        TaskRole   `json:"role,omitempty"`
+}
+
+// Collaborator is a user a task has been shared with
+type Collaborator struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+	Name   string    `json:"name"`
+}
+
+// CollaboratorResponse represents the response payload for sharing a task
+type CollaboratorResponse struct {
+	Collaborator Collaborator `json:"collaborator"`
 }
 
 // RegisterRequest represents the request payload for user registration
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"` // Strength rules come from the password policy
 	Name     string `json:"name" validate:"required"`
 }
 
 // LoginRequest represents the request payload for user login
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RefreshTokenRequest carries a refresh token to rotate, or to revoke on logout
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TwoFactorCodeRequest carries a code from the user's authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TwoFactorVerifyRequest completes a login that requires a second factor
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// TwoFactorSetupResponse holds a new TOTP secret to enroll in an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+// TwoFactorChallengeResponse answers a correct password on an account with
+// two-factor authentication; the challenge token is exchanged at /2fa/verify
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"` // Always true
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"` // Seconds until ChallengeToken expires
+}
+
+// ForgotPasswordRequest represents the request payload for starting a password reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request payload for completing a password reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ChangePasswordRequest represents the request payload for changing the
+// current user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+	// LogoutOtherSessions revokes every refresh token; the caller gets a new pair
+	LogoutOtherSessions bool `json:"logout_other_sessions"`
+}
+
+// ChangePasswordResponse represents the response payload for a password
+// change. Tokens are only set when other sessions were logged out.
+type ChangePasswordResponse struct {
+	Message      string `json:"message"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+}
+
+// VerifyEmailRequest represents the request payload for confirming an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequest represents the request payload for resending a verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// CheckPasswordRequest represents the request payload for a password strength check
+type CheckPasswordRequest struct {
 	Password string `json:"password" validate:"required"`
+	Email    string `json:"email"` // Optional; enables the not_email rule
+}
+
+// CheckPasswordResponse reports how a candidate password fares against the policy
+type CheckPasswordResponse struct {
+	Valid       bool     `json:"valid"`
+	Strength    string   `json:"strength"`
+	Score       int      `json:"score"`
+	FailedRules []string `json:"failed_rules"`
+}
+
+// UpdateProfileRequest represents the request payload for a partial profile update.
+// Nil fields are left unchanged.
+type UpdateProfileRequest struct {
+	Name  *string `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Email *string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+// ReplaceProfileRequest represents the request payload for a full profile update
+type ReplaceProfileRequest struct {
+	Name  string `json:"name" validate:"required,max=255"`
+	Email string `json:"email" validate:"required,email"`
 }
 
 // AuthResponse represents the response payload for authentication
@@ -56,29 +307,143 @@ type AuthResponse struct {
 	User         User   `json:"user"`
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // Always "Bearer"
+	ExpiresIn    int    `json:"expires_in"` // Seconds until Token expires
+}
+
+// PendingVerificationResponse represents the response payload for a
+// registration that must verify its email before logging in
+type PendingVerificationResponse struct {
+	User    User   `json:"user"`
+	Message string `json:"message"`
+}
+
+// UserResponse represents the response payload for a single user
+type UserResponse struct {
+	User *User `json:"user"`
 }
 
 // CreateTaskRequest represents the request payload for creating a task
 type CreateTaskRequest struct {
-	Title       string    `json:"title" validate:"required,min=1,max=255"`
-	Description string    `json:"description" validate:"required,min=1"`
-	DueDate     time.Time `json:"due_date" validate:"required"`
+	Title       string    `json:"title" validate:"required,max=255"`
+	Description string    `json:"description"`
+	DueDate     time.Time `json:"due_date"`
+	Tags        []string  `json:"tags,omitempty"`
 }
 
-// UpdateTaskRequest represents the request payload for updating a task
+// UpdateTaskRequest represents the request payload for updating a task.
+// Omitted fields are left unchanged.
 type UpdateTaskRequest struct {
-	Title       string     `json:"title,omitempty" validate:"omitempty,min=1,max=255"`
-	Description string     `json:"description,omitempty" validate:"omitempty,min=1"`
-	DueDate     *time.Time `json:"due_date,omitempty"`
-	Status      TaskStatus `json:"status,omitempty" validate:"omitempty,oneof=pending in_progress completed"`
+	Title       *string     `json:"title,omitempty" validate:"omitempty,min=1,max=255"`
+	Description *string     `json:"description,omitempty"` // "" clears it
+	DueDate     *time.Time  `json:"due_date,omitempty"`
+	Status      *TaskStatus `json:"status,omitempty" validate:"omitempty,oneof=pending in_progress completed"`
+	Tags        *[]string   `json:"tags,omitempty"`              // Replaces every tag; [] clears them
+	Version     int         `json:"version" validate:"required"` // The version last read; a stale one is rejected
+}
+
+// UpdateTaskStatusRequest represents the request payload for changing only a task's status
+type UpdateTaskStatusRequest struct {
+	Status TaskStatus `json:"status"`
+}
+
+// BulkStatusRequest represents the request payload for updating many tasks' status
+type BulkStatusRequest struct {
+	IDs    []string   `json:"ids" validate:"required"`
+	Status TaskStatus `json:"status" validate:"required,oneof=pending in_progress completed"`
+}
+
+// ImportTaskRequest is one task in a bulk import
+type ImportTaskRequest struct {
+	Title       string     `json:"title" validate:"required,max=255"`
+	Description string     `json:"description"`
+	DueDate     time.Time  `json:"due_date"`
+	Status      TaskStatus `json:"status,omitempty" validate:"omitempty,oneof=pending in_progress completed"` // Defaults to pending
+	Tags        []string   `json:"tags,omitempty"`
+}
+
+// ImportRowError explains why one task of an import was rejected
+type ImportRowError struct {
+	Row    int               `json:"row"` // Zero-based index in the request
+	Errors map[string]string `json:"errors"`
+}
+
+// ImportTasksResponse summarizes a bulk import
+type ImportTasksResponse struct {
+	Created int              `json:"created"`
+	Errors  []ImportRowError `json:"errors"` // Rows skipped in partial mode
+}
+
+// TaskResponse represents the response payload for a single task
+type TaskResponse struct {
+	Task *Task `json:"task"`
+}
+
+// BulkStatusResponse represents the response payload for a bulk status update
+type BulkStatusResponse struct {
+	Results []BulkStatusResult `json:"results"`
+}
+
+// ShareTaskRequest names the user to share a task with
+type ShareTaskRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// Per-task outcomes of a bulk status update
+const (
+	BulkResultUpdated  = "updated"
+	BulkResultNotFound = "not_found"
+	BulkResultSkipped  = "skipped" // Already had the target status
+	BulkResultRejected = "invalid_transition"
+)
+
+// BulkStatusResult is the outcome of a bulk status update for one task
+type BulkStatusResult struct {
+	ID     uuid.UUID `json:"id"`
+	Result string    `json:"result"`
+}
+
+// TaskFilter narrows the set of tasks returned by a list query.
+// Zero values mean no filtering on that field.
+type TaskFilter struct {
+	Status TaskStatus
+	Search string // Keyword matched against title and description; empty means no search
+
+	// Tags keeps tasks having all of them, or any of them with TagsMatchAny
+	Tags         []string
+	TagsMatchAny bool
+}
+
+// TaskSort controls task list ordering
+type TaskSort struct {
+	// GroupByStatus lists pending and in-progress tasks before completed ones,
+	// keeping the primary order within each group
+	GroupByStatus bool
+
+	Field TaskSortField // Empty means created_at
+	Asc   bool          // Descending unless set
 }
 
 // TaskListResponse represents the response payload for listing tasks
 type TaskListResponse struct {
-	Tasks      []Task     `json:"tasks"`
+	Tasks      []Task        `json:"tasks"`
+	Pagination Pagination    `json:"pagination"`
+	Counts     *StatusCounts `json:"counts,omitempty"`
+}
+
+// UserListResponse represents the response payload for listing users
+type UserListResponse struct {
+	Users      []User     `json:"users"`
 	Pagination Pagination `json:"pagination"`
 }
 
+// StatusCounts represents per-status task totals
+type StatusCounts struct {
+	Pending    int `json:"pending"`
+	InProgress int `json:"in_progress"`
+	Completed  int `json:"completed"`
+}
+
 // Pagination represents pagination metadata
 type Pagination struct {
 	Page       int `json:"page"`
@@ -87,11 +452,68 @@ type Pagination struct {
 	TotalPages int `json:"total_pages"`
 }
 
-// HealthResponse represents the health check response
+// TaskStats represents per-status task counts for a user
+type TaskStats struct {
+	Pending    int       `json:"pending"`
+	InProgress int       `json:"in_progress"`
+	Completed  int       `json:"completed"`
+	Overdue    int       `json:"overdue"`
+	Total      int       `json:"total"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// TaskStatsResponse represents the response payload for task stats
+type TaskStatsResponse struct {
+	Stats TaskStats `json:"stats"`
+}
+
+// QuotaUsage represents a user's current usage of one quota
+type QuotaUsage struct {
+	Name  string `json:"name"`
+	Used  int    `json:"used"`
+	Limit *int   `json:"limit"` // nil means unlimited
+}
+
+// UsageResponse represents the response payload for quota usage
+type UsageResponse struct {
+	Quotas []QuotaUsage `json:"quotas"`
+}
+
+// HealthResponse represents the health check response. Liveness responses
+// carry only the status and timestamp.
 type HealthResponse struct {
-	Status    string    `json:"status"`
-	Timestamp time.Time `json:"timestamp"`
-	Database  string    `json:"database"`
+	Status    string       `json:"status"`
+	Timestamp time.Time    `json:"timestamp"`
+	Database  string       `json:"database,omitempty"`
+	Pool      *DBPoolStats `json:"pool,omitempty"`
+}
+
+// DBPoolStats summarizes the database connection pool
+type DBPoolStats struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitDurationMS     float64 `json:"wait_duration_ms"`
+}
+
+// APIResponse is the envelope around every successful response's payload
+type APIResponse[T any] struct {
+	Success bool `json:"success"` // Always true
+	Data    T    `json:"data"`
+}
+
+// MessageResponse represents a response payload that only carries a message
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// CalendarSubscriptionResponse represents the response payload for a new
+// calendar subscription
+type CalendarSubscriptionResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // ErrorResponse represents an error response
@@ -100,6 +522,25 @@ type ErrorResponse struct {
 	Message    string    `json:"message"`
 	Timestamp  time.Time `json:"timestamp"`
 	StatusCode int       `json:"status_code"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Path       string    `json:"path,omitempty"`
+}
+
+// ValidationErrorResponse represents a 400 for invalid input. E is the shape
+// of Errors: a field-to-message map, a list of field errors, or per-row
+// import errors.
+type ValidationErrorResponse[E any] struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Errors    E      `json:"errors"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// AuthErrorResponse represents a 401 from the authentication middleware
+type AuthErrorResponse struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // IsValid checks if a TaskStatus is valid
@@ -111,7 +552,46 @@ func (s TaskStatus) IsValid() bool {
 	return false
 }
 
+// CanTransitionTo reports whether a task may move from s to next. Tasks only
+// move forward, pending to in_progress to completed, possibly skipping
+// in_progress; staying put is always allowed. Reopening completed tasks is
+// left to TaskTransitions.
+func (s TaskStatus) CanTransitionTo(next TaskStatus) bool {
+	if s == next {
+		return true
+	}
+	switch s {
+	case TaskStatusPending:
+		return next == TaskStatusInProgress || next == TaskStatusCompleted
+	case TaskStatusInProgress:
+		return next == TaskStatusCompleted
+	}
+	return false
+}
+
+// TaskTransitions is the configured set of allowed status changes
+type TaskTransitions struct {
+	AllowReopen bool // Completed tasks may go back to pending or in_progress
+}
+
+// Allowed reports whether a task may move from one status to another
+func (t TaskTransitions) Allowed(from, to TaskStatus) bool {
+	if from.CanTransitionTo(to) {
+		return true
+	}
+	return t.AllowReopen && from == TaskStatusCompleted && to.IsValid()
+}
+
 // String returns the string representation of TaskStatus
 func (s TaskStatus) String() string {
 	return string(s)
 }
+
+// IsValid checks if a TaskSortField is one clients may sort by
+func (f TaskSortField) IsValid() bool {
+	switch f {
+	case TaskSortCreatedAt, TaskSortUpdatedAt, TaskSortDueDate, TaskSortTitle:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// snakeCase matches a snake_case JSON key
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// responseTypes are the response payloads the API serves
+var responseTypes = []interface{}{
+	APIResponse[TaskResponse]{},
+	APIResponse[TaskListResponse]{},
+	ErrorResponse{},
+	ValidationErrorResponse[map[string]string]{},
+	ValidationErrorResponse[[]ImportRowError]{},
+	AuthErrorResponse{},
+	MessageResponse{},
+	AuthResponse{},
+	PendingVerificationResponse{},
+	UserResponse{},
+	UserListResponse{},
+	ChangePasswordResponse{},
+	CheckPasswordResponse{},
+	TwoFactorSetupResponse{},
+	TwoFactorChallengeResponse{},
+	APIKeyCreatedResponse{},
+	APIKeyListResponse{},
+	TaskResponse{},
+	TaskListResponse{},
+	TaskStatsResponse{},
+	BulkStatusResponse{},
+	ImportTasksResponse{},
+	CollaboratorResponse{},
+	UsageResponse{},
+	HealthResponse{},
+	CalendarSubscriptionResponse{},
+	WebhookDeliveryResponse{},
+	WebhookDeliveryListResponse{},
+}
+
+func TestResponseFieldsHaveSnakeCaseTags(t *testing.T) {
+	seen := make(map[reflect.Type]bool)
+	for _, v := range responseTypes {
+		checkJSONTags(t, reflect.TypeOf(v), reflect.TypeOf(v).Name(), seen)
+	}
+}
+
+// checkJSONTags fails for every exported field of typ, and of the types of
+// this module it contains, without a snake_case json tag
+func checkJSONTags(t *testing.T, typ reflect.Type, path string, seen map[reflect.Type]bool) {
+	t.Helper()
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] || !strings.HasPrefix(typ.PkgPath(), "secure-task-api/") {
+		return
+	}
+	seen[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, ok := field.Tag.Lookup("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && !ok {
+			// Embedded fields are flattened into the parent object
+			checkJSONTags(t, field.Type, path, seen)
+			continue
+		}
+		if name == "-" {
+			continue
+		}
+		if !snakeCase.MatchString(name) {
+			t.Errorf("%s.%s has json tag %q, want a snake_case name", path, field.Name, tag)
+		}
+		checkJSONTags(t, field.Type, path+"."+field.Name, seen)
+	}
+}
+
+func TestTaskStatusTransitions(t *testing.T) {
+	statuses := []TaskStatus{TaskStatusPending, TaskStatusInProgress, TaskStatusCompleted}
+	forward := map[[2]TaskStatus]bool{
+		{TaskStatusPending, TaskStatusPending}:       true,
+		{TaskStatusPending, TaskStatusInProgress}:    true,
+		{TaskStatusPending, TaskStatusCompleted}:     true,
+		{TaskStatusInProgress, TaskStatusInProgress}: true,
+		{TaskStatusInProgress, TaskStatusCompleted}:  true,
+		{TaskStatusCompleted, TaskStatusCompleted}:   true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got := from.CanTransitionTo(to); got != forward[[2]TaskStatus{from, to}] {
+				t.Errorf("%s -> %s: CanTransitionTo = %v", from, to, got)
+			}
+			if got := (TaskTransitions{}).Allowed(from, to); got != forward[[2]TaskStatus{from, to}] {
+				t.Errorf("%s -> %s without reopening: Allowed = %v", from, to, got)
+			}
+			reopen := forward[[2]TaskStatus{from, to}] || from == TaskStatusCompleted
+			if got := (TaskTransitions{AllowReopen: true}).Allowed(from, to); got != reopen {
+				t.Errorf("%s -> %s with reopening: Allowed = %v, want %v", from, to, got, reopen)
+			}
+		}
+	}
+	if (TaskTransitions{AllowReopen: true}).Allowed(TaskStatusCompleted, "archived") {
+		t.Error("reopening allowed a move to an unknown status")
+	}
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/logger"
+)
+
+const resetLink = "https://app.example.com/reset-password?token=s3cret-reset-token"
+
+func TestLogSenderOmitsBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "info", OutputPaths: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewLogSender(log).Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Use this link to reset your password.\n\n" + resetLink,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Sync()
+
+	logged, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(logged), "s3cret-reset-token") {
+		t.Fatalf("log %s contains the reset token", logged)
+	}
+	if !strings.Contains(string(logged), "user@example.com") || !strings.Contains(string(logged), "Reset your password") {
+		t.Fatalf("log %s does not name the recipient and subject", logged)
+	}
+}
+
+// smtpServer accepts one SMTP session on a local port and hands the
+// envelope and message it received to the returned channel
+func smtpServer(t *testing.T) (host string, port int, received <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		var got []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO" || cmd == "HELO":
+				reply("250 localhost")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"), strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				got = append(got, line)
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					got = append(got, data)
+				}
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				lines <- got
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, lines
+}
+
+func TestSMTPSenderDelivers(t *testing.T) {
+	host, port, received := smtpServer(t)
+	sender := NewSender(config.MailConfig{
+		Sender:   config.MailSenderSMTP,
+		From:     "noreply@example.com",
+		SMTPHost: host,
+		SMTPPort: port,
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := sender.Send(ctx, Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Use this link to reset your password.\n\n" + resetLink,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	select {
+	case got = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	session := strings.Join(got, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<noreply@example.com>",
+		"RCPT TO:<user@example.com>",
+		"Subject: Reset your password",
+		"To: user@example.com",
+		resetLink,
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("session %q is missing %q", session, want)
+		}
+	}
+}
+
+func TestSMTPSenderRejectsHeaderInjection(t *testing.T) {
+	sender := NewSMTPSender(config.MailConfig{SMTPHost: "127.0.0.1", SMTPPort: 1, From: "noreply@example.com"})
+	err := sender.Send(context.Background(), Message{
+		To:      "user@example.com\r\nBcc: victim@example.com",
+		Subject: "Reset your password",
+	})
+	if err == nil {
+		t.Fatal("recipient with a line break was accepted")
+	}
+}
+
+func TestNewSenderLogsByDefault(t *testing.T) {
+	if _, ok := NewSender(config.MailConfig{Sender: config.MailSenderLog}, nil).(*LogSender); !ok {
+		t.Fatal("log sender not chosen")
+	}
+	if _, ok := NewSender(config.MailConfig{Sender: config.MailSenderSMTP, SMTPHost: "mail.example.com", SMTPPort: 587}, nil).(*SMTPSender); !ok {
+		t.Fatal("SMTP sender not chosen")
+	}
+}
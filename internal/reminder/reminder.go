@@ -0,0 +1,135 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/httpclient"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+)
+
+// Store finds tasks coming due and records which were reminded
+type Store interface {
+	GetDueSoon(ctx context.Context, within time.Duration) ([]models.Task, error)
+	MarkRemindersSent(ctx context.Context, ids []uuid.UUID) error
+}
+
+// EventDueSoon is the event name sent to the webhook
+const EventDueSoon = "task.due_soon"
+
+// Payload is the JSON body POSTed to the webhook for each reminder
+type Payload struct {
+	Event string      `json:"event"`
+	Task  models.Task `json:"task"`
+}
+
+// Scheduler periodically logs a reminder for each task coming due, and posts
+// it to a webhook when one is configured. A task is reminded once per due
+// date; one whose delivery fails is retried on the next pass.
+type Scheduler struct {
+	store  Store
+	cfg    config.ReminderConfig
+	client *httpclient.Client
+	log    *logger.Logger
+}
+
+// NewScheduler creates a reminder scheduler backed by store. client is only
+// used when cfg has a webhook URL.
+func NewScheduler(store Store, cfg config.ReminderConfig, client *httpclient.Client, log *logger.Logger) *Scheduler {
+	return &Scheduler{store: store, cfg: cfg, client: client, log: log}
+}
+
+// Run sends due reminders every interval until ctx is cancelled. It is a
+// no-op when reminders are disabled.
+func (s *Scheduler) Run(ctx context.Context) {
+	if !s.cfg.Enabled || s.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendDue(ctx)
+		}
+	}
+}
+
+// sendDue reminds every task coming due and marks the delivered ones sent
+func (s *Scheduler) sendDue(ctx context.Context) {
+	tasks, err := s.store.GetDueSoon(ctx, s.cfg.Window)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.WithError(err).Error("failed to fetch tasks due soon")
+		}
+		return
+	}
+
+	sent := make([]uuid.UUID, 0, len(tasks))
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.remind(ctx, task); err != nil {
+			s.log.WithError(s.log.Redactor().Error(err)).Warn("failed to send task reminder",
+				zap.String("task_id", task.ID.String()))
+			continue
+		}
+		sent = append(sent, task.ID)
+	}
+	if len(sent) == 0 {
+		return
+	}
+
+	// Recorded even if shutdown has begun, so delivered reminders aren't repeated
+	if err := s.store.MarkRemindersSent(context.WithoutCancel(ctx), sent); err != nil {
+		s.log.WithError(err).Error("failed to record sent task reminders")
+	}
+}
+
+// remind logs the reminder for task and delivers it to the webhook
+func (s *Scheduler) remind(ctx context.Context, task models.Task) error {
+	s.log.WithUserID(task.UserID.String()).Info("task due soon",
+		zap.String("task_id", task.ID.String()),
+		zap.Time("due_date", task.DueDate),
+	)
+	if s.cfg.WebhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(Payload{Event: EventDueSoon, Task: task})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("reminder webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/httpclient"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+)
+
+// memoryStore serves the tasks it holds as due soon until they are marked sent
+type memoryStore struct {
+	mu     sync.Mutex
+	tasks  []models.Task
+	within []time.Duration
+	sent   map[uuid.UUID]int
+}
+
+func (s *memoryStore) GetDueSoon(ctx context.Context, within time.Duration) ([]models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.within = append(s.within, within)
+	var due []models.Task
+	for _, task := range s.tasks {
+		if s.sent[task.ID] == 0 {
+			due = append(due, task)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) MarkRemindersSent(ctx context.Context, ids []uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.sent[id]++
+	}
+	return nil
+}
+
+// passes returns how many times the store was queried
+func (s *memoryStore) passes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.within)
+}
+
+func testLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+	log, err := logger.NewLogger(config.LoggingConfig{Level: "fatal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+func TestSchedulerRemindsOnceAndRetriesFailedDeliveries(t *testing.T) {
+	delivered, failing := models.Task{ID: uuid.New(), Title: "Pay rent"}, models.Task{ID: uuid.New(), Title: "File taxes"}
+	var mu sync.Mutex
+	posts := map[uuid.UUID]int{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Event != EventDueSoon {
+			t.Errorf("payload %+v, %v, want a %s event", payload, err, EventDueSoon)
+		}
+		mu.Lock()
+		posts[payload.Task.ID]++
+		mu.Unlock()
+		if payload.Task.ID == failing.ID {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer webhook.Close()
+
+	store := &memoryStore{tasks: []models.Task{delivered, failing}, sent: map[uuid.UUID]int{}}
+	cfg := config.ReminderConfig{Enabled: true, Interval: 5 * time.Millisecond, Window: time.Hour, WebhookURL: webhook.URL}
+	s := NewScheduler(store, cfg, httpclient.New(config.OutboundConfig{AttemptTimeout: time.Second}), testLogger(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); store.passes() < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler never made three passes")
+		}
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler kept running after cancellation")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	if store.sent[delivered.ID] != 1 || posts[delivered.ID] != 1 {
+		t.Fatalf("delivered task marked %d and posted %d times, want once each", store.sent[delivered.ID], posts[delivered.ID])
+	}
+	if store.sent[failing.ID] != 0 || posts[failing.ID] < 2 {
+		t.Fatalf("failing task marked %d and posted %d times, want it unmarked and retried", store.sent[failing.ID], posts[failing.ID])
+	}
+	for _, within := range store.within {
+		if within != time.Hour {
+			t.Fatalf("queried within %v, want the configured window", within)
+		}
+	}
+}
+
+func TestSchedulerDisabledDoesNothing(t *testing.T) {
+	store := &memoryStore{sent: map[uuid.UUID]int{}}
+	s := NewScheduler(store, config.ReminderConfig{Interval: time.Millisecond, Window: time.Hour}, nil, testLogger(t))
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("disabled scheduler didn't return")
+	}
+	if store.passes() != 0 {
+		t.Fatalf("disabled scheduler queried %d times", store.passes())
+	}
+}
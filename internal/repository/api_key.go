@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/models"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db    DBTX
+	clock clock.Clock
+}
+
+// NewAPIKeyRepository creates a new APIKeyRepository that stamps keys with
+// times from clk
+func NewAPIKeyRepository(db DBTX, clk clock.Clock) *APIKeyRepository {
+	return &APIKeyRepository{db: db, clock: clk}
+}
+
+// Create records a new API key by its hash
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, key_hash, prefix, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return r.db.QueryRowContext(ctx, query,
+		key.UserID, key.Name, key.KeyHash, key.Prefix, key.Scopes, r.clock.Now(),
+	).Scan(&key.ID, &key.CreatedAt)
+}
+
+// ListByUser returns the user's unrevoked API keys, newest first
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, scopes, last_used_at, created_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, scanTextArray(&key.Scopes),
+			&key.LastUsedAt, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Revoke stops one of the user's API keys working. It returns false if the
+// user has no such unrevoked key.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id, userID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID, r.clock.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Use looks up an unrevoked key belonging to a live user by its hash and
+// records that it was used, returning the key and the owner's ID, email and
+// role. It returns nil for unknown or revoked keys.
+func (r *APIKeyRepository) Use(ctx context.Context, keyHash string) (*models.APIKey, *models.User, error) {
+	query := `
+		UPDATE api_keys k
+		SET last_used_at = $2
+		FROM users u
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+		  AND u.id = k.user_id AND u.deleted_at IS NULL
+		RETURNING k.id, k.user_id, k.name, k.prefix, k.scopes, k.last_used_at, k.created_at, u.email, u.role`
+
+	var key models.APIKey
+	var owner models.User
+	err := r.db.QueryRowContext(ctx, query, keyHash, r.clock.Now()).Scan(
+		&key.ID, &key.UserID, &key.Name, &key.Prefix, scanTextArray(&key.Scopes), &key.LastUsedAt, &key.CreatedAt,
+		&owner.Email, &owner.Role,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	owner.ID = key.UserID
+	return &key, &owner, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Error kinds callers can test for with errors.Is. The more specific errors,
+// such as ErrTaskNotFound, wrap one of these.
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("duplicate")
+	ErrConflict  = errors.New("conflict")
+)
+
+// ErrUserNotFound is returned when a write targets a user that doesn't exist
+// or was deleted
+var ErrUserNotFound = fmt.Errorf("user %w", ErrNotFound)
+
+// Postgres error codes mapped to error kinds
+const (
+	pgUniqueViolation      = "23505"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// dbError classifies a database error: unique violations are ErrDuplicate and
+// serialization failures and deadlocks ErrConflict, naming the constraint or
+// operation involved. The driver error stays wrapped too. Anything else is
+// returned as is.
+func dbError(err error, op string) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return fmt.Errorf("%s: %w on %s: %w", op, ErrDuplicate, pgErr.ConstraintName, err)
+	case pgSerializationFailure, pgDeadlockDetected:
+		return fmt.Errorf("%s: %w: %w", op, ErrConflict, err)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/models"
+)
+
+func TestDBErrorClassifiesPostgresErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{&pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}, ErrDuplicate},
+		{fmt.Errorf("exec: %w", &pgconn.PgError{Code: "23505"}), ErrDuplicate},
+		{&pgconn.PgError{Code: "40001"}, ErrConflict},
+		{&pgconn.PgError{Code: "40P01"}, ErrConflict},
+	}
+	for _, tt := range tests {
+		got := dbError(tt.err, "create user")
+		if !errors.Is(got, tt.want) {
+			t.Errorf("dbError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+		var pgErr *pgconn.PgError
+		if !errors.As(got, &pgErr) {
+			t.Errorf("dbError(%v) = %v, lost the driver error", tt.err, got)
+		}
+	}
+
+	// Other errors are passed on untouched
+	for _, err := range []error{nil, errors.New("connection refused"), &pgconn.PgError{Code: "23503"}} {
+		if got := dbError(err, "create user"); got != err {
+			t.Errorf("dbError(%v) = %v, want it unchanged", err, got)
+		}
+	}
+}
+
+func TestCreateUserDuplicateEmail(t *testing.T) {
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		return fakeResult{err: &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}}
+	})
+	r := NewUserRepository(db, nil, clock.Real{})
+
+	err := r.Create(context.Background(), &models.User{Email: "taken@example.com", PasswordHash: "hash", Name: "Second"})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("err = %v, want %v", err, ErrDuplicate)
+	}
+	if !strings.Contains(err.Error(), "users_email_key") {
+		t.Fatalf("err = %v, want the constraint named", err)
+	}
+}
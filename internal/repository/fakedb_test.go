@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResult answers one statement: rows for queries, affected for Exec,
+// or err for either
+type fakeResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// fakeStatement is a statement the fake database was sent
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeDB is a database/sql driver answering each statement with respond,
+// for repository tests without Postgres. It records every statement, and
+// BEGIN, COMMIT and ROLLBACK for transactions.
+type fakeDB struct {
+	mu         sync.Mutex
+	respond    func(query string, args []driver.Value) fakeResult
+	statements []fakeStatement
+}
+
+// newFakeDB opens a *sql.DB over a fakeDB answering with respond
+func newFakeDB(t *testing.T, respond func(query string, args []driver.Value) fakeResult) (*sql.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{respond: respond}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+// record logs a statement and returns the response to it
+func (f *fakeDB) record(query string, args []driver.NamedValue) fakeResult {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.mu.Lock()
+	f.statements = append(f.statements, fakeStatement{query: query, args: values})
+	f.mu.Unlock()
+	if f.respond == nil {
+		return fakeResult{}
+	}
+	return f.respond(query, values)
+}
+
+// sent returns the statements sent so far
+func (f *fakeDB) sent() []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeStatement(nil), f.statements...)
+}
+
+// sentMatching returns the statements sent so far containing substr
+func (f *fakeDB) sentMatching(substr string) []fakeStatement {
+	var matched []fakeStatement
+	for _, s := range f.sent() {
+		if strings.Contains(s.query, substr) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+func (f *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                            { return fakeDriver{f} }
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{db: d.db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+// CheckNamedValue passes on as is the values database/sql can't convert,
+// such as the []string tags pgx sends as arrays
+func (c *fakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value); err == nil {
+		nv.Value = v
+	}
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.record("BEGIN", nil)
+	return fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.db.record(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeRows{columns: res.columns, rows: res.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.db.record(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+type fakeTx struct{ conn *fakeConn }
+
+func (tx fakeTx) Commit() error {
+	tx.conn.db.record("COMMIT", nil)
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.conn.db.record("ROLLBACK", nil)
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return nv
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// rowsOf answers a query with values as its rows, naming as many columns as
+// the first row has
+func rowsOf(values ...[]driver.Value) fakeResult {
+	if len(values) == 0 {
+		return fakeResult{columns: []string{"?"}}
+	}
+	columns := make([]string, len(values[0]))
+	for i := range columns {
+		columns[i] = "?"
+	}
+	return fakeResult{columns: columns, rows: values}
+}
+
+// noRows answers a query with no rows, which QueryRow reports as sql.ErrNoRows
+func noRows() fakeResult {
+	return fakeResult{columns: []string{"?"}}
+}
@@ -3,8 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"secure-task-api/internal/clock"
 	"secure-task-api/internal/models"
 )
 
@@ -13,28 +15,126 @@ type UserRepositoryInterface interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	List(ctx context.Context, page, limit int) ([]models.User, int, error)
+	Update(ctx context.Context, id uuid.UUID, name, email string) (*models.User, error)
+	UpdateFields(ctx context.Context, id uuid.UUID, fields UserFields) (*models.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	SoftDelete(ctx context.Context, id uuid.UUID) (bool, error)
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	RecordFailedLogin(ctx context.Context, id uuid.UUID, threshold int, window, lockout time.Duration) (*time.Time, error)
+	ResetFailedLogins(ctx context.Context, id uuid.UUID) error
+	GetTokenVersion(ctx context.Context, id uuid.UUID) (int, bool, error)
+	BumpTokenVersion(ctx context.Context, id uuid.UUID) (int, error)
+	SetTOTPSecret(ctx context.Context, id uuid.UUID, encryptedSecret string) (bool, error)
+	EnableTOTP(ctx context.Context, id uuid.UUID, counter int64) error
+	DisableTOTP(ctx context.Context, id uuid.UUID) error
+	UseTOTPCounter(ctx context.Context, id uuid.UUID, counter int64) (bool, error)
 }
 
 // TaskRepositoryInterface defines the interface for task repository
 type TaskRepositoryInterface interface {
 	Create(ctx context.Context, task *models.Task) error
+	BulkCreate(ctx context.Context, tasks []*models.Task) error
+	CreateWithinLimits(ctx context.Context, userID uuid.UUID, tasks []*models.Task, limits TaskLimits) error
 	GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error)
-	GetAll(ctx context.Context, userID uuid.UUID, page, limit int) ([]models.Task, int, error)
-	Update(ctx context.Context, task *models.Task) error
+	GetByIDIncludingDeleted(ctx context.Context, id, userID uuid.UUID) (*models.Task, error)
+	GetAll(ctx context.Context, userID uuid.UUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error)
+	ListAll(ctx context.Context, ownerID uuid.NullUUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error)
+	GetOverdue(ctx context.Context, userID uuid.UUID, page, limit int) ([]models.Task, int, error)
+	Update(ctx context.Context, id, ownerID uuid.UUID, version int, fields TaskFields) (*models.Task, error)
 	Delete(ctx context.Context, id, userID uuid.UUID) error
+	Restore(ctx context.Context, id, userID uuid.UUID) (*models.Task, error)
+	AddCollaborator(ctx context.Context, taskID, ownerID, userID uuid.UUID) (bool, error)
+	RemoveCollaborator(ctx context.Context, taskID, ownerID, userID uuid.UUID) error
+	UpdateStatus(ctx context.Context, id, userID uuid.UUID, status models.TaskStatus, transitions models.TaskTransitions) (*models.Task, bool, error)
+	BulkUpdateStatus(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, status models.TaskStatus, transitions models.TaskTransitions) ([]models.BulkStatusResult, error)
+	GetDueSoon(ctx context.Context, within time.Duration) ([]models.Task, error)
+	MarkRemindersSent(ctx context.Context, ids []uuid.UUID) error
+	Iterate(ctx context.Context, userID uuid.UUID, batchSize int, fn func([]models.Task) error) error
+	CountByUser(ctx context.Context, userID uuid.UUID) (int, error)
+	CountByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error)
+	CountVisibleByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error)
+	CountOverdue(ctx context.Context, userID uuid.UUID) (int, error)
+	TitleExists(ctx context.Context, userID uuid.UUID, title string, excludeID uuid.NullUUID) (bool, error)
 	HealthCheck(ctx context.Context) error
+	PoolStats() sql.DBStats
+}
+
+// RefreshTokenRepositoryInterface defines the interface for refresh token repository
+type RefreshTokenRepositoryInterface interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.RefreshToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// UserTokenRepositoryInterface defines the interface for single-use user token repository
+type UserTokenRepositoryInterface interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	Consume(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error)
+	GetActive(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error)
+	InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string) error
+}
+
+// APIKeyRepositoryInterface defines the interface for API key repository
+type APIKeyRepositoryInterface interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	Revoke(ctx context.Context, id, userID uuid.UUID) (bool, error)
+	Use(ctx context.Context, keyHash string) (*models.APIKey, *models.User, error)
+}
+
+// WebhookDeliveryRepositoryInterface defines the interface for failed webhook delivery repository
+type WebhookDeliveryRepositoryInterface interface {
+	Create(ctx context.Context, d *models.WebhookDelivery) error
+	ListByUser(ctx context.Context, userID uuid.UUID, status string, page, limit int) ([]models.WebhookDelivery, int, error)
+	GetByID(ctx context.Context, id, userID uuid.UUID) (*models.WebhookDelivery, error)
+	MarkReplayed(ctx context.Context, id, userID uuid.UUID) (*models.WebhookDelivery, error)
+	RecordFailedReplay(ctx context.Context, id, userID uuid.UUID, reason string) (*models.WebhookDelivery, error)
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
 }
 
 // Repository aggregates all repository interfaces
 type Repository struct {
-	User UserRepositoryInterface
-	Task TaskRepositoryInterface
+	User            UserRepositoryInterface
+	Task            TaskRepositoryInterface
+	RefreshToken    RefreshTokenRepositoryInterface
+	UserToken       UserTokenRepositoryInterface
+	APIKey          APIKeyRepositoryInterface
+	WebhookDelivery WebhookDeliveryRepositoryInterface
+
+	db   DBTX
+	opts Options
+}
+
+// Options configures optional repository behaviour
+type Options struct {
+	// EmailHashKey enables storing and looking up users by a keyed email hash
+	EmailHashKey []byte
+	// Clock supplies every time the repositories write or compare against,
+	// instead of the database's NOW(); nil is the system clock
+	Clock clock.Clock
 }
 
 // NewRepository creates a new repository instance
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *sql.DB, opts Options) *Repository {
+	return newRepository(db, opts)
+}
+
+// newRepository creates the repositories bound to db, a pool or a transaction
+func newRepository(db DBTX, opts Options) *Repository {
+	if opts.Clock == nil {
+		opts.Clock = clock.Real{}
+	}
 	return &Repository{
-		User: NewUserRepository(db),
-		Task: NewTaskRepository(db),
+		User:            NewUserRepository(db, opts.EmailHashKey, opts.Clock),
+		Task:            NewTaskRepository(db, opts.Clock),
+		RefreshToken:    NewRefreshTokenRepository(db, opts.Clock),
+		UserToken:       NewUserTokenRepository(db, opts.Clock),
+		APIKey:          NewAPIKeyRepository(db, opts.Clock),
+		WebhookDelivery: NewWebhookDeliveryRepository(db, opts.Clock),
+		db:              db,
+		opts:            opts,
 	}
 }
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/models"
+)
+
+// RefreshTokenRepository handles database operations for issued refresh tokens
+type RefreshTokenRepository struct {
+	db    DBTX
+	clock clock.Clock
+}
+
+// NewRefreshTokenRepository creates a new RefreshTokenRepository that stamps
+// tokens with times from clk
+func NewRefreshTokenRepository(db DBTX, clk clock.Clock) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db, clock: clk}
+}
+
+// Create records a newly issued refresh token
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+
+	return r.db.QueryRowContext(ctx, query,
+		token.ID, token.UserID, token.FamilyID, token.ExpiresAt, r.clock.Now(),
+	).Scan(&token.CreatedAt)
+}
+
+// GetByID retrieves a refresh token record by its jti
+func (r *RefreshTokenRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE id = $1`
+
+	var token models.RefreshToken
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.ExpiresAt,
+		&token.UsedAt, &token.RevokedAt, &token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkUsed atomically spends a refresh token. It returns false if the token
+// was already used or revoked, which signals reuse.
+func (r *RefreshTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = $2
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, r.clock.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// RevokeFamily revokes every token rotated from the same login
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, familyID, r.clock.Now())
+	return err
+}
+
+// RevokeAllForUser revokes every outstanding refresh token for a user
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, userID, r.clock.Now())
+	return err
+}
@@ -3,51 +3,172 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"secure-task-api/internal/clock"
 	"secure-task-api/internal/models"
 )
 
+// ErrTaskNotFound is returned when no task matches the id, owner and state
+var ErrTaskNotFound = fmt.Errorf("task %w", ErrNotFound)
+
+// ErrTaskVersionConflict is returned when a task changed since the version
+// an update was based on
+var ErrTaskVersionConflict = fmt.Errorf("task version %w", ErrConflict)
+
+// ErrCollaboratorNotFound is returned when a task isn't shared with the user
+var ErrCollaboratorNotFound = fmt.Errorf("collaborator %w", ErrNotFound)
+
+// ErrTaskQuotaExceeded is returned when new tasks would take their owner past
+// TaskLimits.MaxPerUser
+var ErrTaskQuotaExceeded = errors.New("task quota exceeded")
+
+// ErrTaskTitleTaken is returned when a new task's title matches one of its
+// owner's live tasks under TaskLimits.UniqueTitles
+var ErrTaskTitleTaken = fmt.Errorf("task title %w", ErrDuplicate)
+
 // TaskRepository handles database operations for tasks
 type TaskRepository struct {
-	db *sql.DB
+	db    DBTX
+	clock clock.Clock
 }
 
-// NewTaskRepository creates a new TaskRepository
-func NewTaskRepository(db *sql.DB) *TaskRepository {
-	return &TaskRepository{db: db}
+// NewTaskRepository creates a new TaskRepository that reads the time from
+// clk, both for the timestamps it writes and for due date checks
+func NewTaskRepository(db DBTX, clk clock.Clock) *TaskRepository {
+	return &TaskRepository{db: db, clock: clk}
 }
 
 // Create inserts a new task into the database
 func (r *TaskRepository) Create(ctx context.Context, task *models.Task) error {
 	query := `
-		INSERT INTO tasks (id, title, description, status, due_date, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING created_at, updated_at`
+		INSERT INTO tasks (id, title, description, status, due_date, user_id, tags, created_at, updated_at, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at, updated_at, version`
 
 	task.ID = uuid.New()
-	now := time.Now()
+	task.Role = models.TaskRoleOwner
+	if task.Tags == nil {
+		task.Tags = []string{}
+	}
+	now := r.clock.Now()
+	task.CompletedAt = completedAt(task.Status, now)
 
 	err := r.db.QueryRowContext(ctx, query,
-		task.ID, task.Title, task.Description, task.Status, task.DueDate, task.UserID, now, now,
-	).Scan(&task.CreatedAt, &task.UpdatedAt)
+		task.ID, task.Title, task.Description, task.Status, task.DueDate, task.UserID, task.Tags, now, now, task.CompletedAt,
+	).Scan(&task.CreatedAt, &task.UpdatedAt, &task.Version)
 
-	return err
+	return dbError(err, "create task")
+}
+
+// BulkCreate inserts tasks in one transaction, so either all are created or
+// none are. IDs and timestamps are set on each task as in Create.
+func (r *TaskRepository) BulkCreate(ctx context.Context, tasks []*models.Task) error {
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO tasks (id, title, description, status, due_date, user_id, tags, created_at, updated_at, completed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING created_at, updated_at, version`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		now := r.clock.Now()
+		for _, task := range tasks {
+			task.ID = uuid.New()
+			task.Role = models.TaskRoleOwner
+			if task.Tags == nil {
+				task.Tags = []string{}
+			}
+			task.CompletedAt = completedAt(task.Status, now)
+			if err := stmt.QueryRowContext(ctx,
+				task.ID, task.Title, task.Description, task.Status, task.DueDate, task.UserID, task.Tags, now, now, task.CompletedAt,
+			).Scan(&task.CreatedAt, &task.UpdatedAt, &task.Version); err != nil {
+				return dbError(err, "create task")
+			}
+		}
+		return nil
+	})
+}
+
+// TaskLimits are the per-owner rules CreateWithinLimits enforces
+type TaskLimits struct {
+	MaxPerUser   int  // Live tasks an owner may have, 0 means unlimited
+	UniqueTitles bool // Titles must differ from the owner's live tasks, ignoring case and surrounding whitespace
+}
+
+// CreateWithinLimits inserts tasks owned by userID as BulkCreate does, if
+// limits allow them. The owner's user row is locked until the tasks are
+// inserted, so concurrent creates are checked one after the other instead of
+// all passing against the same count. It returns ErrTaskQuotaExceeded or
+// ErrTaskTitleTaken if limits don't allow the tasks, and ErrUserNotFound if
+// the owner doesn't exist. Without limits it is BulkCreate.
+func (r *TaskRepository) CreateWithinLimits(ctx context.Context, userID uuid.UUID, tasks []*models.Task, limits TaskLimits) error {
+	if limits == (TaskLimits{}) {
+		return r.BulkCreate(ctx, tasks)
+	}
+
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		var locked int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&locked)
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		txTasks := NewTaskRepository(tx, r.clock)
+		if limits.MaxPerUser > 0 {
+			count, err := txTasks.CountByUser(ctx, userID)
+			if err != nil {
+				return err
+			}
+			if count+len(tasks) > limits.MaxPerUser {
+				return ErrTaskQuotaExceeded
+			}
+		}
+		if limits.UniqueTitles {
+			for _, task := range tasks {
+				exists, err := txTasks.TitleExists(ctx, userID, task.Title, uuid.NullUUID{})
+				if err != nil {
+					return err
+				}
+				if exists {
+					return ErrTaskTitleTaken
+				}
+			}
+		}
+		return txTasks.BulkCreate(ctx, tasks)
+	})
+}
+
+// completedAt returns the completed_at of a new task with status
+func completedAt(status models.TaskStatus, now time.Time) *time.Time {
+	if status != models.TaskStatusCompleted {
+		return nil
+	}
+	return &now
 }
 
-// GetByID retrieves a single task by ID and user
+// GetByID retrieves a single task the user owns or collaborates on, with
+// Role set to the user's role
 func (r *TaskRepository) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
 	query := `
-		SELECT id, title, description, status, due_date, user_id, created_at, updated_at, deleted_at
+		SELECT id, title, description, status, due_date, user_id, created_at, updated_at, deleted_at, completed_at, tags, version, ` + taskRoleColumn(2) + `
 		FROM tasks
-		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+		WHERE id = $1 AND ` + visibleTaskCondition(2) + ` AND deleted_at IS NULL`
 
 	var task models.Task
 	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
 		&task.ID, &task.Title, &task.Description, &task.Status, &task.DueDate,
-		&task.UserID, &task.CreatedAt, &task.UpdatedAt, &task.DeletedAt,
+		&task.UserID, &task.CreatedAt, &task.UpdatedAt, &task.DeletedAt, &task.CompletedAt, scanTextArray(&task.Tags), &task.Version, &task.Role,
 	)
 
 	if err == sql.ErrNoRows {
@@ -60,23 +181,78 @@ func (r *TaskRepository) GetByID(ctx context.Context, id, userID uuid.UUID) (*mo
 	return &task, nil
 }
 
-// GetAll retrieves all tasks for a user with pagination
-func (r *TaskRepository) GetAll(ctx context.Context, userID uuid.UUID, page, limit int) ([]models.Task, int, error) {
+// GetByIDIncludingDeleted retrieves a task like GetByID, except that its owner
+// also gets it once soft-deleted, for auditing. DeletedAt is set on deleted
+// tasks; collaborators only see live ones.
+func (r *TaskRepository) GetByIDIncludingDeleted(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	query := `
+		SELECT id, title, description, status, due_date, user_id, created_at, updated_at, deleted_at, completed_at, tags, version, ` + taskRoleColumn(2) + `
+		FROM tasks
+		WHERE id = $1 AND (user_id = $2 OR (deleted_at IS NULL AND ` + visibleTaskCondition(2) + `))`
+
+	var task models.Task
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&task.ID, &task.Title, &task.Description, &task.Status, &task.DueDate,
+		&task.UserID, &task.CreatedAt, &task.UpdatedAt, &task.DeletedAt, &task.CompletedAt, scanTextArray(&task.Tags), &task.Version, &task.Role,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+// GetAll retrieves the tasks a user owns or collaborates on matching filter,
+// with pagination
+func (r *TaskRepository) GetAll(ctx context.Context, userID uuid.UUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	where, args := taskFilterClause(visibleTaskCondition(1), userID, filter, false)
+	return r.listPage(ctx, where, args, taskRoleColumn(1), sort, page, limit)
+}
+
+// ListAll retrieves a page of live tasks across every user, or only those
+// owned by ownerID when it is set, for administrators. Role is left empty.
+func (r *TaskRepository) ListAll(ctx context.Context, ownerID uuid.NullUUID, filter models.TaskFilter, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	where, args := taskFilterClause(anyOwnerCondition, ownerID, filter, false)
+	return r.listPage(ctx, where, args, "''", sort, page, limit)
+}
+
+// GetOverdue retrieves a page of the live, uncompleted tasks a user owns or
+// collaborates on whose due date has passed, most overdue first
+func (r *TaskRepository) GetOverdue(ctx context.Context, userID uuid.UUID, page, limit int) ([]models.Task, int, error) {
+	where := visibleTaskCondition(1) + " AND deleted_at IS NULL AND status <> $2 AND due_date < $3"
+	args := []interface{}{userID, models.TaskStatusCompleted, r.clock.Now()}
+	sort := models.TaskSort{Field: models.TaskSortDueDate, Asc: true}
+	return r.listPage(ctx, where, args, taskRoleColumn(1), sort, page, limit)
+}
+
+// listPage runs a paginated task query over where, selecting roleColumn as
+// each task's role, and returns the page with the total number of matches
+func (r *TaskRepository) listPage(ctx context.Context, where string, args []interface{}, roleColumn string, sort models.TaskSort, page, limit int) ([]models.Task, int, error) {
+	orderBy, err := taskOrderClause(sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var total int
-	countQuery := `SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND deleted_at IS NULL`
-	if err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total); err != nil {
+	countQuery := `SELECT COUNT(*) FROM tasks WHERE ` + where
+	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	offset := (page - 1) * limit
-	query := `
-		SELECT id, title, description, status, due_date, user_id, created_at, updated_at
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT id, title, description, status, due_date, user_id, created_at, updated_at, completed_at, tags, version, %s
 		FROM tasks
-		WHERE user_id = $1 AND deleted_at IS NULL
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3`
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, roleColumn, where, orderBy, len(args)-1, len(args))
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -86,7 +262,7 @@ func (r *TaskRepository) GetAll(ctx context.Context, userID uuid.UUID, page, lim
 	for rows.Next() {
 		var task models.Task
 		if err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Status,
-			&task.DueDate, &task.UserID, &task.CreatedAt, &task.UpdatedAt); err != nil {
+			&task.DueDate, &task.UserID, &task.CreatedAt, &task.UpdatedAt, &task.CompletedAt, scanTextArray(&task.Tags), &task.Version, &task.Role); err != nil {
 			return nil, 0, err
 		}
 		tasks = append(tasks, task)
@@ -99,27 +275,422 @@ func (r *TaskRepository) GetAll(ctx context.Context, userID uuid.UUID, page, lim
 	return tasks, total, nil
 }
 
-// Update modifies an existing task
-func (r *TaskRepository) Update(ctx context.Context, task *models.Task) error {
-	query := `
+// taskFilterClause builds the WHERE clause and its arguments for the live
+// tasks matching filter that access, a condition on userID as $1, lets the
+// user see. ignoreStatus drops the status condition so per-status tallies
+// cover every status within the other filters.
+func taskFilterClause(access string, userID interface{}, filter models.TaskFilter, ignoreStatus bool) (string, []interface{}) {
+	conds := []string{access, "deleted_at IS NULL"}
+	args := []interface{}{userID}
+
+	if filter.Status != "" && !ignoreStatus {
+		args = append(args, filter.Status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if filter.Search != "" {
+		args = append(args, "%"+escapeLike(filter.Search)+"%")
+		conds = append(conds, taskSearchCondition(len(args)))
+	}
+
+	if len(filter.Tags) > 0 {
+		op := "@>"
+		if filter.TagsMatchAny {
+			op = "&&"
+		}
+		args = append(args, filter.Tags)
+		conds = append(conds, fmt.Sprintf("tags %s $%d::text[]", op, len(args)))
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// scanTextArray scans a text[] column such as tags, which database/sql can't do alone
+func scanTextArray(dest *[]string) sql.Scanner {
+	return pgtype.NewMap().SQLScanner(dest)
+}
+
+// ownTaskCondition matches tasks owned by the user in placeholder $1
+const ownTaskCondition = "user_id = $1"
+
+// anyOwnerCondition matches tasks owned by the nullable user in $1, or every
+// task when it is NULL
+const anyOwnerCondition = "($1::uuid IS NULL OR user_id = $1)"
+
+// visibleTaskCondition matches tasks owned by or shared with the user in
+// placeholder $n
+func visibleTaskCondition(n int) string {
+	return fmt.Sprintf("(user_id = $%[1]d OR EXISTS ("+
+		"SELECT 1 FROM task_collaborators c WHERE c.task_id = tasks.id AND c.user_id = $%[1]d))", n)
+}
+
+// taskRoleColumn selects the role of the user in placeholder $n on a task
+// matched by visibleTaskCondition
+func taskRoleColumn(n int) string {
+	return fmt.Sprintf("CASE WHEN user_id = $%d THEN '%s' ELSE '%s' END",
+		n, models.TaskRoleOwner, models.TaskRoleCollaborator)
+}
+
+// taskSearchCondition matches the search pattern in placeholder $n against
+// title and description. It is a substring match for now; moving to a
+// tsvector index only needs this and the argument above to change.
+func taskSearchCondition(n int) string {
+	return fmt.Sprintf("(title ILIKE $%d OR description ILIKE $%d)", n, n)
+}
+
+// likeEscaper escapes LIKE wildcards so search terms match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes s for use inside a LIKE pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// taskSortColumns maps sortable fields to columns. Sort fields come from
+// clients, so only names in this allow-list ever reach the SQL.
+var taskSortColumns = map[models.TaskSortField]string{
+	models.TaskSortCreatedAt: "created_at",
+	models.TaskSortUpdatedAt: "updated_at",
+	models.TaskSortDueDate:   "due_date",
+	models.TaskSortTitle:     "title",
+}
+
+// taskOrderClause builds the ORDER BY clause for sort. id is always the last
+// key so pages don't overlap or skip rows when other keys tie.
+func taskOrderClause(sort models.TaskSort) (string, error) {
+	field := sort.Field
+	if field == "" {
+		field = models.TaskSortCreatedAt
+	}
+	column, ok := taskSortColumns[field]
+	if !ok {
+		return "", fmt.Errorf("invalid sort field %q", field)
+	}
+
+	direction := "DESC"
+	if sort.Asc {
+		direction = "ASC"
+	}
+
+	var keys []string
+	if sort.GroupByStatus {
+		keys = append(keys, fmt.Sprintf(
+			"CASE status WHEN '%s' THEN 1 ELSE 0 END",
+			models.TaskStatusCompleted))
+	}
+	keys = append(keys,
+		column+" "+direction+" NULLS LAST",
+		"id "+direction)
+	return strings.Join(keys, ", "), nil
+}
+
+// TaskFields holds the task columns to change in Update. Nil fields are not written.
+type TaskFields struct {
+	Title       *string
+	Description *string
+	Status      *models.TaskStatus
+	DueDate     *time.Time
+	Tags        *[]string
+}
+
+// Update writes only the provided columns of a live task owned by ownerID if
+// it is still at version, increments the version and returns the updated
+// task. It returns ErrTaskVersionConflict if the task was changed since that
+// version was read, and ErrTaskNotFound if it doesn't exist or was deleted.
+func (r *TaskRepository) Update(ctx context.Context, id, ownerID uuid.UUID, version int, fields TaskFields) (*models.Task, error) {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if fields.Title != nil {
+		set("title", *fields.Title)
+	}
+	if fields.Description != nil {
+		set("description", *fields.Description)
+	}
+	now := r.clock.Now()
+	if fields.Status != nil {
+		set("status", *fields.Status)
+		// Kept while the task stays completed; SET sees the old status
+		args = append(args, now)
+		sets = append(sets, fmt.Sprintf(
+			"completed_at = CASE WHEN $%d <> '%s' THEN NULL WHEN status = '%[2]s' THEN completed_at ELSE $%d::timestamptz END",
+			len(args)-1, models.TaskStatusCompleted, len(args)))
+	}
+	if fields.DueDate != nil {
+		set("due_date", *fields.DueDate)
+		// A new due date gets a new reminder; SET sees the old due_date
+		sets = append(sets, fmt.Sprintf("reminder_sent_at = CASE WHEN due_date = $%d THEN reminder_sent_at END", len(args)))
+	}
+	if fields.Tags != nil {
+		tags := *fields.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		set("tags", tags)
+	}
+
+	set("updated_at", now)
+	sets = append(sets, "version = version + 1")
+
+	args = append(args, id, ownerID, version)
+	query := fmt.Sprintf(`
 		UPDATE tasks
-		SET title = $1, description = $2, status = $3, due_date = $4, updated_at = NOW()
-		WHERE id = $5 AND user_id = $6 AND deleted_at IS NULL
-		RETURNING updated_at`
+		SET %s
+		WHERE id = $%d AND user_id = $%d AND deleted_at IS NULL AND version = $%d
+		RETURNING id, title, description, status, due_date, user_id, created_at, updated_at, deleted_at, completed_at, tags, version`,
+		strings.Join(sets, ", "), len(args)-2, len(args)-1, len(args))
 
-	return r.db.QueryRowContext(ctx, query,
-		task.Title, task.Description, task.Status, task.DueDate, task.ID, task.UserID,
-	).Scan(&task.UpdatedAt)
+	var task models.Task
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&task.ID, &task.Title, &task.Description, &task.Status, &task.DueDate,
+		&task.UserID, &task.CreatedAt, &task.UpdatedAt, &task.DeletedAt, &task.CompletedAt, scanTextArray(&task.Tags), &task.Version,
+	)
+	if err == nil {
+		return &task, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
+		id, ownerID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrTaskVersionConflict
+	}
+	return nil, ErrTaskNotFound
 }
 
 // Delete marks a task as deleted
 func (r *TaskRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
 	query := `
 		UPDATE tasks
-		SET deleted_at = NOW()
+		SET deleted_at = $3
 		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 
-	result, err := r.db.ExecContext(ctx, query, id, userID)
+	result, err := r.db.ExecContext(ctx, query, id, userID, r.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTaskNotFound
+	}
+
+	return nil
+}
+
+// Restore clears deleted_at on a soft-deleted task owned by the user and
+// returns it. Live tasks are not restorable and return ErrTaskNotFound.
+func (r *TaskRepository) Restore(ctx context.Context, id, userID uuid.UUID) (*models.Task, error) {
+	query := `
+		UPDATE tasks
+		SET deleted_at = NULL, updated_at = $3
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING id, title, description, status, due_date, user_id, created_at, updated_at, deleted_at, completed_at, tags, version`
+
+	task := models.Task{Role: models.TaskRoleOwner}
+	err := r.db.QueryRowContext(ctx, query, id, userID, r.clock.Now()).Scan(
+		&task.ID, &task.Title, &task.Description, &task.Status, &task.DueDate,
+		&task.UserID, &task.CreatedAt, &task.UpdatedAt, &task.DeletedAt, &task.CompletedAt, scanTextArray(&task.Tags), &task.Version,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+// TransitionError is returned by UpdateStatus when the task's status may not
+// move to the requested one
+type TransitionError struct {
+	From, To models.TaskStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("task status cannot change from %s to %s", e.From, e.To)
+}
+
+// Unwrap makes a TransitionError match ErrConflict
+func (e *TransitionError) Unwrap() error {
+	return ErrConflict
+}
+
+// UpdateStatus sets only the status of a live task userID owns or
+// collaborates on, if transitions allows it, and returns the task with Role
+// set. changed is false when the task already had status, which writes
+// nothing. It returns ErrTaskNotFound if the task doesn't exist, was deleted
+// or isn't visible to userID, and a *TransitionError if the change isn't
+// allowed.
+func (r *TaskRepository) UpdateStatus(ctx context.Context, id, userID uuid.UUID, status models.TaskStatus, transitions models.TaskTransitions) (task *models.Task, changed bool, err error) {
+	err = inTx(ctx, r.db, func(tx DBTX) error {
+		// Lock the row so the transition checked is the one written
+		var current models.TaskStatus
+		err := tx.QueryRowContext(ctx, `
+			SELECT status
+			FROM tasks
+			WHERE id = $1 AND `+visibleTaskCondition(2)+` AND deleted_at IS NULL
+			FOR UPDATE`, id, userID).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrTaskNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if current == status {
+			task, err = NewTaskRepository(tx, r.clock).GetByID(ctx, id, userID)
+			return err
+		}
+		if !transitions.Allowed(current, status) {
+			return &TransitionError{From: current, To: status}
+		}
+
+		var updated models.Task
+		if err := tx.QueryRowContext(ctx, `
+			UPDATE tasks
+			SET status = $3, updated_at = $5, version = version + 1,
+				completed_at = CASE WHEN $3 = $4 THEN $5::timestamptz END
+			WHERE id = $1
+			RETURNING id, title, description, status, due_date, user_id, created_at, updated_at, deleted_at, completed_at, tags, version, `+taskRoleColumn(2),
+			id, userID, status, models.TaskStatusCompleted, r.clock.Now(),
+		).Scan(
+			&updated.ID, &updated.Title, &updated.Description, &updated.Status, &updated.DueDate,
+			&updated.UserID, &updated.CreatedAt, &updated.UpdatedAt, &updated.DeletedAt, &updated.CompletedAt, scanTextArray(&updated.Tags), &updated.Version, &updated.Role,
+		); err != nil {
+			return err
+		}
+		task, changed = &updated, true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return task, changed, nil
+}
+
+// BulkUpdateStatus sets status on the user's live tasks among ids in a single
+// transaction, except those transitions doesn't allow to move to it. Results
+// follow the order of ids; ids that don't exist, are deleted, or belong to
+// another user are reported as not found.
+func (r *TaskRepository) BulkUpdateStatus(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, status models.TaskStatus, transitions models.TaskTransitions) ([]models.BulkStatusResult, error) {
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = id.String()
+	}
+
+	current := make(map[uuid.UUID]models.TaskStatus, len(ids))
+	err := inTx(ctx, r.db, func(tx DBTX) error {
+		// Lock the owned rows so the outcome reported matches what was written
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, status
+			FROM tasks
+			WHERE user_id = $1 AND id = ANY($2::uuid[]) AND deleted_at IS NULL
+			FOR UPDATE`, userID, idStrings)
+		if err != nil {
+			return err
+		}
+
+		var changed []string
+		for rows.Next() {
+			var id uuid.UUID
+			var s models.TaskStatus
+			if err := rows.Scan(&id, &s); err != nil {
+				rows.Close()
+				return err
+			}
+			current[id] = s
+			if s != status && transitions.Allowed(s, status) {
+				changed = append(changed, id.String())
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(changed) == 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE tasks
+			SET status = $2, updated_at = $4, version = version + 1,
+				completed_at = CASE WHEN $2 = $3 THEN $4::timestamptz END
+			WHERE id = ANY($1::uuid[])`,
+			changed, status, models.TaskStatusCompleted, r.clock.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkStatusResult, len(ids))
+	for i, id := range ids {
+		result := models.BulkResultUpdated
+		if s, ok := current[id]; !ok {
+			result = models.BulkResultNotFound
+		} else if s == status {
+			result = models.BulkResultSkipped
+		} else if !transitions.Allowed(s, status) {
+			result = models.BulkResultRejected
+		}
+		results[i] = models.BulkStatusResult{ID: id, Result: result}
+	}
+	return results, nil
+}
+
+// AddCollaborator shares a live task owned by ownerID with userID. It reports
+// false if the task was already shared with them, and returns ErrTaskNotFound
+// if the owner has no such task.
+func (r *TaskRepository) AddCollaborator(ctx context.Context, taskID, ownerID, userID uuid.UUID) (bool, error) {
+	query := `
+		WITH owned AS (
+			SELECT id FROM tasks
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		), added AS (
+			INSERT INTO task_collaborators (task_id, user_id, created_at)
+			SELECT id, $3, $4 FROM owned
+			ON CONFLICT DO NOTHING
+			RETURNING task_id
+		)
+		SELECT EXISTS (SELECT 1 FROM owned), EXISTS (SELECT 1 FROM added)`
+
+	var found, added bool
+	if err := r.db.QueryRowContext(ctx, query, taskID, ownerID, userID, r.clock.Now()).Scan(&found, &added); err != nil {
+		return false, err
+	}
+	if !found {
+		return false, ErrTaskNotFound
+	}
+	return added, nil
+}
+
+// RemoveCollaborator stops sharing a task owned by ownerID with userID. It
+// returns ErrCollaboratorNotFound if the owner's task isn't shared with them.
+func (r *TaskRepository) RemoveCollaborator(ctx context.Context, taskID, ownerID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM task_collaborators c
+		USING tasks t
+		WHERE c.task_id = $1 AND c.user_id = $3
+		  AND t.id = c.task_id AND t.user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, taskID, ownerID, userID)
 	if err != nil {
 		return err
 	}
@@ -129,15 +700,215 @@ func (r *TaskRepository) Delete(ctx context.Context, id, userID uuid.UUID) error
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("task not found")
+		return ErrCollaboratorNotFound
 	}
 
 	return nil
 }
 
-// HealthCheck verifies the database connection
+// reminderBatchSize caps how many tasks one GetDueSoon call returns; the
+// rest are picked up on the scheduler's next pass
+const reminderBatchSize = 500
+
+// GetDueSoon returns live, uncompleted tasks of every user due within the
+// next within that haven't had a reminder yet, soonest first. Tasks already
+// past their due date are not included.
+func (r *TaskRepository) GetDueSoon(ctx context.Context, within time.Duration) ([]models.Task, error) {
+	query := `
+		SELECT id, title, description, status, due_date, user_id, created_at, updated_at, tags
+		FROM tasks
+		WHERE deleted_at IS NULL
+		  AND reminder_sent_at IS NULL
+		  AND status <> $1
+		  AND due_date > $4
+		  AND due_date <= $4::timestamptz + make_interval(secs => $2)
+		ORDER BY due_date, id
+		LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, models.TaskStatusCompleted, within.Seconds(), reminderBatchSize, r.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		var task models.Task
+		if err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Status,
+			&task.DueDate, &task.UserID, &task.CreatedAt, &task.UpdatedAt, scanTextArray(&task.Tags)); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// MarkRemindersSent records that the tasks' reminders went out, so
+// GetDueSoon skips them until their due date changes
+func (r *TaskRepository) MarkRemindersSent(ctx context.Context, ids []uuid.UUID) error {
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = id.String()
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE tasks SET reminder_sent_at = $2 WHERE id = ANY($1::uuid[])`, idStrings, r.clock.Now())
+	return err
+}
+
+// Iterate calls fn with the user's live tasks in batches of at most
+// batchSize, in id order. Each batch is a separate keyset-paginated query, so
+// memory stays flat however many tasks the user has. An error from fn stops
+// the iteration and is returned.
+func (r *TaskRepository) Iterate(ctx context.Context, userID uuid.UUID, batchSize int, fn func([]models.Task) error) error {
+	query := `
+		SELECT id, title, description, status, due_date, user_id, created_at, updated_at
+		FROM tasks
+		WHERE user_id = $1 AND deleted_at IS NULL AND ($2::uuid IS NULL OR id > $2)
+		ORDER BY id
+		LIMIT $3`
+
+	var after uuid.NullUUID
+	for {
+		batch, err := r.iterateBatch(ctx, query, userID, after, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		after = uuid.NullUUID{UUID: batch[len(batch)-1].ID, Valid: true}
+	}
+}
+
+// iterateBatch fetches one page of Iterate
+func (r *TaskRepository) iterateBatch(ctx context.Context, query string, userID uuid.UUID, after uuid.NullUUID, limit int) ([]models.Task, error) {
+	rows, err := r.db.QueryContext(ctx, query, userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]models.Task, 0, limit)
+	for rows.Next() {
+		var task models.Task
+		if err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Status,
+			&task.DueDate, &task.UserID, &task.CreatedAt, &task.UpdatedAt); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// CountByUser returns the number of live tasks owned by a user
+func (r *TaskRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND deleted_at IS NULL`, userID,
+	).Scan(&n)
+	return n, err
+}
+
+// CountByStatus returns the number of live tasks per status owned by a user,
+// applying every filter except the status filter itself. Tasks shared with
+// the user count towards their owner only.
+func (r *TaskRepository) CountByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error) {
+	return r.countByStatus(ctx, ownTaskCondition, userID, filter)
+}
+
+// CountVisibleByStatus is CountByStatus over the tasks GetAll lists: those
+// the user owns or collaborates on
+func (r *TaskRepository) CountVisibleByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error) {
+	return r.countByStatus(ctx, visibleTaskCondition(1), userID, filter)
+}
+
+// countByStatus tallies the live tasks matching filter that access lets the
+// user see, by status
+func (r *TaskRepository) countByStatus(ctx context.Context, access string, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error) {
+	where, args := taskFilterClause(access, userID, filter, true)
+	query := `
+		SELECT status, COUNT(*)
+		FROM tasks
+		WHERE ` + where + `
+		GROUP BY status`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[models.TaskStatus]int)
+	for rows.Next() {
+		var status models.TaskStatus
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+
+	return counts, rows.Err()
+}
+
+// CountOverdue returns the number of live, uncompleted tasks owned by a user
+// whose due date has passed
+func (r *TaskRepository) CountOverdue(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM tasks
+		WHERE user_id = $1
+		  AND deleted_at IS NULL
+		  AND status <> $2
+		  AND due_date < $3`,
+		userID, models.TaskStatusCompleted, r.clock.Now(),
+	).Scan(&n)
+	return n, err
+}
+
+// TitleExists reports whether the user has another live task whose title matches
+// case-insensitively, ignoring surrounding whitespace. excludeID skips the task being updated.
+func (r *TaskRepository) TitleExists(ctx context.Context, userID uuid.UUID, title string, excludeID uuid.NullUUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM tasks
+			WHERE user_id = $1
+			  AND lower(trim(title)) = lower(trim($2))
+			  AND deleted_at IS NULL
+			  AND ($3::uuid IS NULL OR id <> $3)
+		)`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, userID, title, excludeID).Scan(&exists)
+	return exists, err
+}
+
+// HealthCheck verifies the database connection. A repository bound to a
+// transaction runs a trivial query on it instead of pinging.
 func (r *TaskRepository) HealthCheck(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	return r.db.PingContext(ctx)
+	if db, ok := r.db.(*sql.DB); ok {
+		return db.PingContext(ctx)
+	}
+	_, err := r.db.ExecContext(ctx, "SELECT 1")
+	return err
+}
+
+// PoolStats returns the database connection pool statistics, which are zero
+// for a repository bound to a transaction
+func (r *TaskRepository) PoolStats() sql.DBStats {
+	if db, ok := r.db.(*sql.DB); ok {
+		return db.Stats()
+	}
+	return sql.DBStats{}
 }
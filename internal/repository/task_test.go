@@ -0,0 +1,1186 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/models"
+)
+
+// limitsDB answers CreateWithinLimits for a user with count live tasks, one
+// of them titled taken
+func limitsDB(t *testing.T, count int64, taken string) (*TaskRepository, *fakeDB) {
+	t.Helper()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		switch {
+		case strings.Contains(query, "FROM users WHERE id = $1 FOR UPDATE"):
+			return rowsOf([]driver.Value{int64(1)})
+		case strings.Contains(query, "SELECT COUNT(*) FROM tasks"):
+			return rowsOf([]driver.Value{count})
+		case strings.Contains(query, "lower(trim(title)) = lower(trim($2))"):
+			title, _ := args[1].(string)
+			return rowsOf([]driver.Value{strings.EqualFold(strings.TrimSpace(title), taken)})
+		case strings.Contains(query, "INSERT INTO tasks"):
+			return rowsOf([]driver.Value{now, now, int64(1)})
+		}
+		return noRows()
+	})
+	return NewTaskRepository(db, clock.Real{}), fake
+}
+
+// queriesOf returns the first word or two of each statement sent, enough to
+// tell the lock, checks and insert apart
+func queriesOf(fake *fakeDB) []string {
+	var kinds []string
+	for _, s := range fake.sent() {
+		q := strings.TrimSpace(s.query)
+		switch {
+		case strings.Contains(q, "FOR UPDATE"):
+			kinds = append(kinds, "LOCK")
+		case strings.Contains(q, "COUNT(*)"):
+			kinds = append(kinds, "COUNT")
+		case strings.Contains(q, "EXISTS"):
+			kinds = append(kinds, "TITLE")
+		case strings.HasPrefix(q, "INSERT"):
+			kinds = append(kinds, "INSERT")
+		default:
+			kinds = append(kinds, q)
+		}
+	}
+	return kinds
+}
+
+func TestCreateWithinLimitsLocksOwnerBeforeChecking(t *testing.T) {
+	r, fake := limitsDB(t, 1, "")
+	userID := uuid.New()
+	task := &models.Task{Title: "Write report", Status: models.TaskStatusPending, UserID: userID}
+
+	err := r.CreateWithinLimits(context.Background(), userID, []*models.Task{task}, TaskLimits{MaxPerUser: 2, UniqueTitles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(queriesOf(fake), " "), "BEGIN LOCK COUNT TITLE INSERT COMMIT"; got != want {
+		t.Fatalf("statements %q, want %q", got, want)
+	}
+	if task.ID == uuid.Nil || task.Version != 1 {
+		t.Fatalf("task = %+v, want it inserted", task)
+	}
+}
+
+func TestCreateWithinLimitsQuota(t *testing.T) {
+	r, fake := limitsDB(t, 2, "")
+	userID := uuid.New()
+	tasks := []*models.Task{{Title: "Write report", UserID: userID}}
+
+	err := r.CreateWithinLimits(context.Background(), userID, tasks, TaskLimits{MaxPerUser: 2})
+	if !errors.Is(err, ErrTaskQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrTaskQuotaExceeded", err)
+	}
+	if len(fake.sentMatching("INSERT")) != 0 || len(fake.sentMatching("ROLLBACK")) != 1 {
+		t.Fatalf("statements %q, want a rollback and no insert", queriesOf(fake))
+	}
+
+	// An import counts every new task against the quota
+	r, _ = limitsDB(t, 1, "")
+	tasks = append(tasks, &models.Task{Title: "File expenses", UserID: userID})
+	err = r.CreateWithinLimits(context.Background(), userID, tasks, TaskLimits{MaxPerUser: 2})
+	if !errors.Is(err, ErrTaskQuotaExceeded) {
+		t.Fatalf("import: err = %v, want ErrTaskQuotaExceeded", err)
+	}
+}
+
+func TestCreateWithinLimitsTitleTaken(t *testing.T) {
+	r, fake := limitsDB(t, 0, "buy milk")
+	userID := uuid.New()
+	tasks := []*models.Task{{Title: " Buy Milk ", UserID: userID}}
+
+	err := r.CreateWithinLimits(context.Background(), userID, tasks, TaskLimits{UniqueTitles: true})
+	if !errors.Is(err, ErrTaskTitleTaken) || !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("err = %v, want ErrTaskTitleTaken matching ErrDuplicate", err)
+	}
+	if len(fake.sentMatching("INSERT")) != 0 {
+		t.Fatal("task inserted over a taken title")
+	}
+}
+
+func TestCreateWithinLimitsMissingOwner(t *testing.T) {
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult { return noRows() })
+	r := NewTaskRepository(db, clock.Real{})
+	userID := uuid.New()
+
+	err := r.CreateWithinLimits(context.Background(), userID, []*models.Task{{Title: "Write report", UserID: userID}}, TaskLimits{MaxPerUser: 5})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestCreateWithinLimitsWithoutLimitsSkipsLock(t *testing.T) {
+	r, fake := limitsDB(t, 0, "")
+	userID := uuid.New()
+
+	if err := r.CreateWithinLimits(context.Background(), userID, []*models.Task{{Title: "Write report", UserID: userID}}, TaskLimits{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.sentMatching("FOR UPDATE")) != 0 || len(fake.sentMatching("COUNT(*)")) != 0 {
+		t.Fatalf("statements %q, want only the insert", queriesOf(fake))
+	}
+}
+
+func TestTitleExistsComparesNormalizedTitles(t *testing.T) {
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		return rowsOf([]driver.Value{true})
+	})
+	r := NewTaskRepository(db, clock.Real{})
+	userID, taskID := uuid.New(), uuid.New()
+
+	exists, err := r.TitleExists(context.Background(), userID, "Buy milk ", uuid.NullUUID{UUID: taskID, Valid: true})
+	if err != nil || !exists {
+		t.Fatalf("TitleExists = %v, %v, want true", exists, err)
+	}
+	sent := fake.sentMatching("lower(trim(title)) = lower(trim($2))")
+	if len(sent) != 1 {
+		t.Fatalf("statements %q, want one comparing lower(trim(title))", queriesOf(fake))
+	}
+	if !strings.Contains(sent[0].query, "deleted_at IS NULL") || !strings.Contains(sent[0].query, "id <> $3") {
+		t.Fatalf("query %s, want live tasks other than the excluded one", sent[0].query)
+	}
+	if sent[0].args[2] != taskID.String() {
+		t.Fatalf("excluded id %v, want %s", sent[0].args[2], taskID)
+	}
+}
+
+func TestBulkUpdateStatusMixedResults(t *testing.T) {
+	pending, started, done, foreign := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if strings.Contains(query, "FOR UPDATE") {
+			// Only the user's own live tasks are selected
+			return rowsOf(
+				[]driver.Value{pending.String(), "pending"},
+				[]driver.Value{started.String(), "in_progress"},
+				[]driver.Value{done.String(), "completed"},
+			)
+		}
+		return fakeResult{affected: 1}
+	})
+	r := NewTaskRepository(db, clock.Real{})
+	ids := []uuid.UUID{foreign, pending, started, done}
+
+	results, err := r.BulkUpdateStatus(context.Background(), uuid.New(), ids, models.TaskStatusInProgress, models.TaskTransitions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{models.BulkResultNotFound, models.BulkResultUpdated, models.BulkResultSkipped, models.BulkResultRejected}
+	for i, result := range results {
+		if result.ID != ids[i] || result.Result != want[i] {
+			t.Errorf("result %d = %+v, want %s for %s", i, result, want[i], ids[i])
+		}
+	}
+
+	updates := fake.sentMatching("UPDATE tasks")
+	if len(updates) != 1 {
+		t.Fatalf("%d updates, want 1", len(updates))
+	}
+	if changed, _ := updates[0].args[0].([]string); len(changed) != 1 || changed[0] != pending.String() {
+		t.Fatalf("updated %v, want only the pending task", updates[0].args[0])
+	}
+	if got := strings.Join(queriesOf(fake), " "); !strings.HasPrefix(got, "BEGIN LOCK") || !strings.HasSuffix(got, "COMMIT") {
+		t.Fatalf("statements %q, want one transaction locking the rows first", got)
+	}
+}
+
+// seededTask is a tasks row for a fake database that filters rows itself
+type seededTask struct {
+	title   string
+	status  models.TaskStatus
+	due     time.Time
+	deleted bool
+}
+
+func TestGetOverdueOnlyPastDueUncompleted(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	seeded := []seededTask{
+		{"due tomorrow", models.TaskStatusPending, now.Add(24 * time.Hour), false},
+		{"overdue a day", models.TaskStatusInProgress, now.Add(-24 * time.Hour), false},
+		{"completed late", models.TaskStatusCompleted, now.Add(-48 * time.Hour), false},
+		{"overdue a week", models.TaskStatusPending, now.Add(-7 * 24 * time.Hour), false},
+		{"deleted overdue", models.TaskStatusPending, now.Add(-time.Hour), true},
+	}
+
+	// The fake applies the bounds GetOverdue passes, so wrong arguments or a
+	// missing condition show up as wrong tasks
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		excluded, _ := args[1].(string)
+		cutoff, _ := args[2].(time.Time)
+		var matched []seededTask
+		for _, task := range seeded {
+			if task.deleted && strings.Contains(query, "deleted_at IS NULL") {
+				continue
+			}
+			if string(task.status) != excluded && task.due.Before(cutoff) {
+				matched = append(matched, task)
+			}
+		}
+		if strings.Contains(query, "COUNT(*)") {
+			return rowsOf([]driver.Value{int64(len(matched))})
+		}
+		sort.Slice(matched, func(i, j int) bool { return matched[i].due.Before(matched[j].due) })
+		var rows [][]driver.Value
+		for _, task := range matched {
+			rows = append(rows, []driver.Value{uuid.NewString(), task.title, "", string(task.status), task.due, uuid.NewString(), now, now, nil, "{}", int64(1), "owner"})
+		}
+		return rowsOf(rows...)
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+
+	tasks, total, err := r.GetOverdue(context.Background(), uuid.New(), 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(tasks) != 2 || tasks[0].Title != "overdue a week" || tasks[1].Title != "overdue a day" {
+		t.Fatalf("overdue = %d %+v, want the week then the day overdue task", total, tasks)
+	}
+	if list := fake.sentMatching("ORDER BY"); len(list) != 1 || !strings.Contains(list[0].query, "due_date ASC") {
+		t.Fatalf("list queries %v, want ordered by due date, most overdue first", list)
+	}
+}
+
+var (
+	setStatus      = regexp.MustCompile(`SET status = \$(\d+)|, status = \$(\d+)`)
+	setCompletedAt = regexp.MustCompile(`completed_at = CASE WHEN \$(\d+) <> 'completed' THEN NULL WHEN status = 'completed' THEN completed_at ELSE \$(\d+)::timestamptz END`)
+)
+
+// completionDB holds one task row and applies Update's status and
+// completed_at assignments to it, reading the placeholders from the query
+func completionDB(t *testing.T, clk clock.Clock) *TaskRepository {
+	t.Helper()
+	row := &seededTask{title: "Write report", status: models.TaskStatusPending}
+	var completed *time.Time
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if m := setCompletedAt.FindStringSubmatch(query); m != nil {
+			status := models.TaskStatus(args[atoi(t, m[1])-1].(string))
+			switch {
+			case status != models.TaskStatusCompleted:
+				completed = nil
+			case row.status != models.TaskStatusCompleted:
+				at := args[atoi(t, m[2])-1].(time.Time)
+				completed = &at
+			}
+		}
+		if m := setStatus.FindStringSubmatch(query); m != nil {
+			row.status = models.TaskStatus(args[atoi(t, m[1]+m[2])-1].(string))
+		}
+		var completedValue driver.Value
+		if completed != nil {
+			completedValue = *completed
+		}
+		now := clk.Now()
+		return rowsOf([]driver.Value{uuid.NewString(), row.title, "", string(row.status), now, uuid.NewString(), now, now, nil, completedValue, "{}", int64(1)})
+	})
+	return NewTaskRepository(db, clk)
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestUpdateSetsAndClearsCompletedAt(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	r := completionDB(t, clk)
+	update := func(fields TaskFields) *models.Task {
+		t.Helper()
+		task, err := r.Update(context.Background(), uuid.New(), uuid.New(), 1, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	status := func(s models.TaskStatus) *models.TaskStatus { return &s }
+	title := "Write the report"
+
+	if task := update(TaskFields{Status: status(models.TaskStatusInProgress)}); task.CompletedAt != nil {
+		t.Fatalf("in progress: completed_at %v, want null", task.CompletedAt)
+	}
+
+	task := update(TaskFields{Status: status(models.TaskStatusCompleted)})
+	if task.CompletedAt == nil || !task.CompletedAt.Equal(start) {
+		t.Fatalf("completed: completed_at %v, want %v", task.CompletedAt, start)
+	}
+
+	// Staying completed keeps the original completion time
+	clk.Advance(time.Hour)
+	if task := update(TaskFields{Title: &title}); task.CompletedAt == nil || !task.CompletedAt.Equal(start) {
+		t.Fatalf("retitled: completed_at %v, want %v", task.CompletedAt, start)
+	}
+	if task := update(TaskFields{Status: status(models.TaskStatusCompleted)}); task.CompletedAt == nil || !task.CompletedAt.Equal(start) {
+		t.Fatalf("completed again: completed_at %v, want %v", task.CompletedAt, start)
+	}
+
+	if task := update(TaskFields{Status: status(models.TaskStatusPending)}); task.CompletedAt != nil {
+		t.Fatalf("reopened: completed_at %v, want null", task.CompletedAt)
+	}
+}
+
+func TestCreateSetsCompletedAtOnlyForCompletedTasks(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		return rowsOf([]driver.Value{now, now, int64(1)})
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+
+	for _, status := range []models.TaskStatus{models.TaskStatusPending, models.TaskStatusCompleted} {
+		task := &models.Task{Title: "Write report", Status: status, UserID: uuid.New()}
+		if err := r.Create(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+		inserts := fake.sentMatching("INSERT INTO tasks")
+		sent := inserts[len(inserts)-1].args[9]
+		if status == models.TaskStatusCompleted {
+			if task.CompletedAt == nil || !task.CompletedAt.Equal(now) || sent != now {
+				t.Fatalf("completed: completed_at %v, sent %v, want %v", task.CompletedAt, sent, now)
+			}
+		} else if task.CompletedAt != nil || sent != nil {
+			t.Fatalf("%s: completed_at %v, sent %v, want null", status, task.CompletedAt, sent)
+		}
+	}
+}
+
+var (
+	statusCondition = regexp.MustCompile(`status = \$(\d+)`)
+	searchCondition = regexp.MustCompile(`title ILIKE \$(\d+)`)
+)
+
+func TestCountVisibleByStatusAppliesFiltersButStatus(t *testing.T) {
+	seeded := []seededTask{
+		{title: "Write report", status: models.TaskStatusPending},
+		{title: "Review report", status: models.TaskStatusCompleted},
+		{title: "Report bug", status: models.TaskStatusPending},
+		{title: "Plan sprint", status: models.TaskStatusInProgress},
+		{title: "Old report", status: models.TaskStatusInProgress, deleted: true},
+	}
+
+	// The fake applies the status and search conditions it finds, so the
+	// tally shows which filters CountVisibleByStatus kept
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		counts := make(map[models.TaskStatus]int64)
+		for _, task := range seeded {
+			if task.deleted && strings.Contains(query, "deleted_at IS NULL") {
+				continue
+			}
+			if m := statusCondition.FindStringSubmatch(query); m != nil && string(task.status) != args[atoi(t, m[1])-1] {
+				continue
+			}
+			if m := searchCondition.FindStringSubmatch(query); m != nil {
+				term := strings.Trim(args[atoi(t, m[1])-1].(string), "%")
+				if !strings.Contains(strings.ToLower(task.title), strings.ToLower(term)) {
+					continue
+				}
+			}
+			counts[task.status]++
+		}
+		var rows [][]driver.Value
+		for status, n := range counts {
+			rows = append(rows, []driver.Value{string(status), n})
+		}
+		return rowsOf(rows...)
+	})
+	r := NewTaskRepository(db, clock.NewFake(time.Now()))
+
+	tests := []struct {
+		name   string
+		filter models.TaskFilter
+		want   map[models.TaskStatus]int
+	}{
+		{"unfiltered", models.TaskFilter{}, map[models.TaskStatus]int{
+			models.TaskStatusPending: 2, models.TaskStatusInProgress: 1, models.TaskStatusCompleted: 1,
+		}},
+		{"search", models.TaskFilter{Search: "report"}, map[models.TaskStatus]int{
+			models.TaskStatusPending: 2, models.TaskStatusCompleted: 1,
+		}},
+		{"status ignored", models.TaskFilter{Status: models.TaskStatusCompleted, Search: "report"}, map[models.TaskStatus]int{
+			models.TaskStatusPending: 2, models.TaskStatusCompleted: 1,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts, err := r.CountVisibleByStatus(context.Background(), uuid.New(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(counts) != len(tt.want) {
+				t.Fatalf("counts %v, want %v", counts, tt.want)
+			}
+			for status, n := range tt.want {
+				if counts[status] != n {
+					t.Fatalf("counts %v, want %v", counts, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// sortableTask is a tasks row for orderingDB
+type sortableTask struct {
+	id               string
+	title            string
+	status           models.TaskStatus
+	due              time.Time
+	created, updated time.Time
+}
+
+var (
+	orderByClause = regexp.MustCompile(`(?s)ORDER BY (.*?)\s+LIMIT \$(\d+) OFFSET \$(\d+)`)
+	orderKey      = regexp.MustCompile(`^(\w+) (ASC|DESC)( NULLS LAST)?$`)
+)
+
+// orderingDB answers list queries over seeded by applying the ORDER BY keys,
+// LIMIT and OFFSET it is sent, so the ordering a query asks for is the one
+// the test sees. It knows the status grouping CASE and the plain sort columns.
+func orderingDB(t *testing.T, seeded []sortableTask) *TaskRepository {
+	t.Helper()
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if strings.Contains(query, "COUNT(*)") {
+			return rowsOf([]driver.Value{int64(len(seeded))})
+		}
+		m := orderByClause.FindStringSubmatch(query)
+		if m == nil {
+			t.Fatalf("list query without ORDER BY and LIMIT: %s", query)
+		}
+
+		var less []func(a, b sortableTask) int
+		for _, key := range strings.Split(m[1], ", ") {
+			if key == "CASE status WHEN 'completed' THEN 1 ELSE 0 END" {
+				rank := func(s sortableTask) int {
+					if s.status == models.TaskStatusCompleted {
+						return 1
+					}
+					return 0
+				}
+				less = append(less, func(a, b sortableTask) int { return rank(a) - rank(b) })
+				continue
+			}
+			k := orderKey.FindStringSubmatch(key)
+			if k == nil {
+				t.Fatalf("unknown ORDER BY key %q", key)
+			}
+			var cmp func(a, b sortableTask) int
+			switch k[1] {
+			case "created_at":
+				cmp = func(a, b sortableTask) int { return a.created.Compare(b.created) }
+			case "updated_at":
+				cmp = func(a, b sortableTask) int { return a.updated.Compare(b.updated) }
+			case "due_date":
+				cmp = func(a, b sortableTask) int { return a.due.Compare(b.due) }
+			case "title":
+				cmp = func(a, b sortableTask) int { return strings.Compare(a.title, b.title) }
+			case "id":
+				cmp = func(a, b sortableTask) int { return strings.Compare(a.id, b.id) }
+			default:
+				t.Fatalf("ORDER BY unknown column %q", k[1])
+			}
+			if k[2] == "DESC" {
+				asc := cmp
+				cmp = func(a, b sortableTask) int { return asc(b, a) }
+			}
+			less = append(less, cmp)
+		}
+
+		ordered := append([]sortableTask(nil), seeded...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			for _, cmp := range less {
+				if c := cmp(ordered[i], ordered[j]); c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+
+		limit, _ := args[atoi(t, m[2])-1].(int64)
+		offset, _ := args[atoi(t, m[3])-1].(int64)
+		var rows [][]driver.Value
+		for i := int(offset); i < len(ordered) && i < int(offset+limit); i++ {
+			task := ordered[i]
+			rows = append(rows, []driver.Value{task.id, task.title, "", string(task.status), task.due, uuid.NewString(), task.created, task.updated, nil, "{}", int64(1), "owner"})
+		}
+		return rowsOf(rows...)
+	})
+	return NewTaskRepository(db, clock.NewFake(time.Now()))
+}
+
+// sortSeed returns tasks whose columns order them differently, with created_at
+// tied between two of them so only id breaks the tie
+func sortSeed() []sortableTask {
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	return []sortableTask{
+		{"00000000-0000-0000-0000-000000000005", "Book flights", models.TaskStatusCompleted, base.Add(5 * day), base, base.Add(4 * day)},
+		{"00000000-0000-0000-0000-000000000002", "Write report", models.TaskStatusPending, base.Add(2 * day), base.Add(day), base.Add(day)},
+		{"00000000-0000-0000-0000-000000000004", "Call plumber", models.TaskStatusInProgress, base.Add(day), base.Add(day), base.Add(5 * day)},
+		{"00000000-0000-0000-0000-000000000001", "Renew passport", models.TaskStatusCompleted, base.Add(3 * day), base.Add(3 * day), base.Add(2 * day)},
+		{"00000000-0000-0000-0000-000000000003", "Archive email", models.TaskStatusPending, base.Add(4 * day), base.Add(2 * day), base.Add(3 * day)},
+	}
+}
+
+// titlesOf lists the titles of tasks in order
+func titlesOf(tasks []models.Task) []string {
+	titles := make([]string, len(tasks))
+	for i, task := range tasks {
+		titles[i] = task.Title
+	}
+	return titles
+}
+
+func TestGetAllSortsByEachColumnAndDirection(t *testing.T) {
+	r := orderingDB(t, sortSeed())
+	tests := []struct {
+		field models.TaskSortField
+		asc   bool
+		want  []string
+	}{
+		{models.TaskSortCreatedAt, false, []string{"Renew passport", "Archive email", "Call plumber", "Write report", "Book flights"}},
+		{models.TaskSortCreatedAt, true, []string{"Book flights", "Write report", "Call plumber", "Archive email", "Renew passport"}},
+		{models.TaskSortUpdatedAt, false, []string{"Call plumber", "Book flights", "Archive email", "Renew passport", "Write report"}},
+		{models.TaskSortUpdatedAt, true, []string{"Write report", "Renew passport", "Archive email", "Book flights", "Call plumber"}},
+		{models.TaskSortDueDate, false, []string{"Book flights", "Archive email", "Renew passport", "Write report", "Call plumber"}},
+		{models.TaskSortDueDate, true, []string{"Call plumber", "Write report", "Renew passport", "Archive email", "Book flights"}},
+		{models.TaskSortTitle, false, []string{"Write report", "Renew passport", "Call plumber", "Book flights", "Archive email"}},
+		{models.TaskSortTitle, true, []string{"Archive email", "Book flights", "Call plumber", "Renew passport", "Write report"}},
+	}
+	for _, tt := range tests {
+		tasks, _, err := r.GetAll(context.Background(), uuid.New(), models.TaskFilter{}, models.TaskSort{Field: tt.field, Asc: tt.asc}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titlesOf(tasks); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s asc=%v: %v, want %v", tt.field, tt.asc, got, tt.want)
+		}
+	}
+}
+
+func TestGetAllRejectsUnknownSortField(t *testing.T) {
+	db, fake := newFakeDB(t, nil)
+	r := NewTaskRepository(db, clock.NewFake(time.Now()))
+
+	_, _, err := r.GetAll(context.Background(), uuid.New(), models.TaskFilter{}, models.TaskSort{Field: "password_hash; DROP TABLE tasks"}, 1, 10)
+	if err == nil || !strings.Contains(err.Error(), "invalid sort field") {
+		t.Fatalf("error %v, want the sort field rejected", err)
+	}
+	if sent := fake.sent(); len(sent) != 0 {
+		t.Fatalf("sent %v, want nothing to reach the database", sent)
+	}
+}
+
+func TestGetAllGroupByStatusListsCompletedLastAcrossPages(t *testing.T) {
+	r := orderingDB(t, sortSeed())
+	sort := models.TaskSort{GroupByStatus: true, Field: models.TaskSortTitle, Asc: true}
+
+	var all []models.Task
+	for page := 1; page <= 3; page++ {
+		tasks, total, err := r.GetAll(context.Background(), uuid.New(), models.TaskFilter{}, sort, page, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 5 {
+			t.Fatalf("page %d: total %d, want 5", page, total)
+		}
+		all = append(all, tasks...)
+	}
+
+	// Incomplete tasks by title, then completed ones by title, each task once
+	want := []string{"Archive email", "Call plumber", "Write report", "Book flights", "Renew passport"}
+	if got := titlesOf(all); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("pages = %v, want %v", got, want)
+	}
+}
+
+// likePattern compiles a LIKE pattern with backslash escapes into an
+// anchored, case-insensitive regexp, as ILIKE matches
+func likePattern(t *testing.T, pattern string) *regexp.Regexp {
+	t.Helper()
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '\\':
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+func TestGetAllSearchesTitleAndDescription(t *testing.T) {
+	seeded := [][2]string{
+		{"Quarterly report", "Numbers for the board"},
+		{"Pay supplier", "Settle the March INVOICE"},
+		{"Discount codes", "Give 50% off to returning customers"},
+		{"Groceries", "Milk and eggs"},
+	}
+
+	// The fake applies the search pattern it is passed, so the term must reach
+	// the database as an argument and match either column
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		var matched [][2]string
+		for _, task := range seeded {
+			if m := searchCondition.FindStringSubmatch(query); m != nil {
+				like := likePattern(t, args[atoi(t, m[1])-1].(string))
+				if !like.MatchString(task[0]) && !like.MatchString(task[1]) {
+					continue
+				}
+			}
+			matched = append(matched, task)
+		}
+		if strings.Contains(query, "COUNT(*)") {
+			return rowsOf([]driver.Value{int64(len(matched))})
+		}
+		now := time.Now()
+		var rows [][]driver.Value
+		for _, task := range matched {
+			rows = append(rows, []driver.Value{uuid.NewString(), task[0], task[1], "pending", now, uuid.NewString(), now, now, nil, "{}", int64(1), "owner"})
+		}
+		return rowsOf(rows...)
+	})
+	r := NewTaskRepository(db, clock.NewFake(time.Now()))
+
+	tests := []struct {
+		name string
+		term string
+		want []string
+	}{
+		{"title only", "REPORT", []string{"Quarterly report"}},
+		{"description only", "invoice", []string{"Pay supplier"}},
+		{"wildcards match literally", "50%", []string{"Discount codes"}},
+		{"no matches", "dentist", nil},
+		{"empty term", "", []string{"Quarterly report", "Pay supplier", "Discount codes", "Groceries"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, total, err := r.GetAll(context.Background(), uuid.New(), models.TaskFilter{Search: tt.term}, models.TaskSort{}, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titlesOf(tasks); total != len(tt.want) || strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("search %q = %d %v, want %d %v", tt.term, total, got, len(tt.want), tt.want)
+			}
+		})
+	}
+
+	for _, s := range fake.sent() {
+		if strings.Contains(s.query, "dentist") || strings.Contains(s.query, "invoice") {
+			t.Fatalf("query %s embeds the search term, want it passed as an argument", s.query)
+		}
+	}
+	if sent := fake.sentMatching("ILIKE"); len(sent) != 8 {
+		t.Fatalf("%d queries searched, want the count and page of each non-empty term", len(sent))
+	}
+}
+
+func TestRestoreOnlyActsOnDeletedTasks(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deleted := map[string]bool{}
+
+	// The fake restores only rows the query allows it to: deleted ones
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		id := args[0].(string)
+		if !strings.Contains(query, "deleted_at IS NOT NULL") || !deleted[id] {
+			return noRows()
+		}
+		deleted[id] = false
+		return rowsOf([]driver.Value{id, "Write report", "", "pending", now, args[1], now, args[2], nil, nil, "{}", int64(1)})
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+
+	id := uuid.New()
+	deleted[id.String()] = true
+	task, err := r.Restore(context.Background(), id, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ID != id || task.DeletedAt != nil || !task.UpdatedAt.Equal(now) || task.Role != models.TaskRoleOwner {
+		t.Fatalf("restored %+v, want the live task touched now", task)
+	}
+
+	if _, err := r.Restore(context.Background(), id, uuid.New()); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("restoring a live task: error %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestIterateReadsKeysetBatches(t *testing.T) {
+	var ids []string
+	for i := 0; i < 7; i++ {
+		ids = append(ids, uuid.NewString())
+	}
+	sort.Strings(ids)
+
+	// The fake serves ids after the keyset cursor, up to the limit
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		after, _ := args[1].(string)
+		limit := int(args[2].(int64))
+		now := time.Now()
+		var rows [][]driver.Value
+		for _, id := range ids {
+			if id > after && len(rows) < limit {
+				rows = append(rows, []driver.Value{id, "Task", "", "pending", now, uuid.NewString(), now, now})
+			}
+		}
+		return rowsOf(rows...)
+	})
+	r := NewTaskRepository(db, clock.NewFake(time.Now()))
+
+	var sizes []int
+	var seen []string
+	err := r.Iterate(context.Background(), uuid.New(), 3, func(batch []models.Task) error {
+		sizes = append(sizes, len(batch))
+		for _, task := range batch {
+			seen = append(seen, task.ID.String())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seen, ",") != strings.Join(ids, ",") || fmt.Sprint(sizes) != "[3 3 1]" {
+		t.Fatalf("batches %v covering %d tasks, want [3 3 1] covering all 7 once", sizes, len(seen))
+	}
+	// A short batch ends the iteration without another query
+	if sent := fake.sent(); len(sent) != 3 {
+		t.Fatalf("%d queries, want one per batch", len(sent))
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = r.Iterate(context.Background(), uuid.New(), 3, func(batch []models.Task) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("error %v after %d calls, want fn's error after the first", err, calls)
+	}
+}
+
+func TestSharedTasksVisibleToCollaborators(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	owner, collaborator, stranger := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	taskID := uuid.New()
+	shared := map[string]bool{}
+
+	// The fake shares the one task only through the collaborators table, and
+	// answers each lookup with the role the query computes for the caller
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		switch {
+		case strings.Contains(query, "INSERT INTO task_collaborators"):
+			found := args[0] == taskID.String() && args[1] == owner
+			added := found && !shared[args[2].(string)]
+			if added {
+				shared[args[2].(string)] = true
+			}
+			return rowsOf([]driver.Value{found, added})
+		case strings.Contains(query, "FROM tasks"):
+			user := args[0].(string)
+			if strings.Contains(query, "WHERE id = $1") {
+				user = args[1].(string)
+			}
+			if !strings.Contains(query, "task_collaborators c WHERE c.task_id = tasks.id") || (user != owner && !shared[user]) {
+				if strings.Contains(query, "COUNT(*)") {
+					return rowsOf([]driver.Value{int64(0)})
+				}
+				return noRows()
+			}
+			if strings.Contains(query, "COUNT(*)") {
+				return rowsOf([]driver.Value{int64(1)})
+			}
+			role := "collaborator"
+			if strings.Contains(query, "CASE WHEN user_id = $") && user == owner {
+				role = "owner"
+			}
+			if strings.Contains(query, "WHERE id = $1") {
+				return rowsOf([]driver.Value{taskID.String(), "Plan trip", "", "pending", now, owner, now, now, nil, nil, "{}", int64(1), role})
+			}
+			return rowsOf([]driver.Value{taskID.String(), "Plan trip", "", "pending", now, owner, now, now, nil, "{}", int64(1), role})
+		}
+		return noRows()
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+	ctx := context.Background()
+	collaboratorID := uuid.MustParse(collaborator)
+
+	if task, err := r.GetByID(ctx, taskID, collaboratorID); err != nil || task != nil {
+		t.Fatalf("before sharing: %+v, %v, want no task", task, err)
+	}
+	if _, err := r.AddCollaborator(ctx, taskID, uuid.MustParse(stranger), collaboratorID); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("sharing another's task: error %v, want ErrTaskNotFound", err)
+	}
+	if added, err := r.AddCollaborator(ctx, taskID, uuid.MustParse(owner), collaboratorID); err != nil || !added {
+		t.Fatalf("share: added %v, %v, want added", added, err)
+	}
+	if added, err := r.AddCollaborator(ctx, taskID, uuid.MustParse(owner), collaboratorID); err != nil || added {
+		t.Fatalf("share again: added %v, %v, want a no-op", added, err)
+	}
+
+	task, err := r.GetByID(ctx, taskID, collaboratorID)
+	if err != nil || task == nil || task.Role != models.TaskRoleCollaborator {
+		t.Fatalf("collaborator's task %+v, %v, want it as collaborator", task, err)
+	}
+	if task, _ := r.GetByID(ctx, taskID, uuid.MustParse(owner)); task == nil || task.Role != models.TaskRoleOwner {
+		t.Fatalf("owner's task %+v, want it as owner", task)
+	}
+	tasks, total, err := r.GetAll(ctx, collaboratorID, models.TaskFilter{}, models.TaskSort{}, 1, 10)
+	if err != nil || total != 1 || len(tasks) != 1 || tasks[0].ID != taskID || tasks[0].Role != models.TaskRoleCollaborator {
+		t.Fatalf("collaborator's list %d %+v, %v, want the shared task", total, tasks, err)
+	}
+	if tasks, _, _ := r.GetAll(ctx, uuid.MustParse(stranger), models.TaskFilter{}, models.TaskSort{}, 1, 10); len(tasks) != 0 {
+		t.Fatalf("stranger's list %+v, want none", tasks)
+	}
+}
+
+// tagCondition matches the tag filter taskFilterClause adds
+var tagCondition = regexp.MustCompile(`tags (@>|&&) \$(\d+)::text\[\]`)
+
+func TestGetAllFiltersByTags(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	seeded := map[string][]string{
+		"Pay rent":      {"home", "bills"},
+		"Fix sink":      {"home"},
+		"File expenses": {"work", "bills"},
+		"Untagged":      {},
+	}
+
+	// The fake applies the tag condition with the array GetAll binds, as
+	// Postgres would with @> (contains every tag) or && (shares any)
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		var titles []string
+		for title, tags := range seeded {
+			if m := tagCondition.FindStringSubmatch(query); m != nil {
+				want, ok := args[atoi(t, m[2])-1].([]string)
+				if !ok {
+					t.Fatalf("tags bound as %T, want []string", args[atoi(t, m[2])-1])
+				}
+				shared := 0
+				for _, tag := range want {
+					for _, have := range tags {
+						if tag == have {
+							shared++
+						}
+					}
+				}
+				if (m[1] == "@>" && shared < len(want)) || (m[1] == "&&" && shared == 0) {
+					continue
+				}
+			}
+			titles = append(titles, title)
+		}
+		if strings.Contains(query, "COUNT(*)") {
+			return rowsOf([]driver.Value{int64(len(titles))})
+		}
+		sort.Strings(titles)
+		var rows [][]driver.Value
+		for _, title := range titles {
+			tags := "{" + strings.Join(seeded[title], ",") + "}"
+			rows = append(rows, []driver.Value{uuid.NewString(), title, "", "pending", now, uuid.NewString(), now, now, nil, tags, int64(1), "owner"})
+		}
+		return rowsOf(rows...)
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+
+	tests := []struct {
+		filter models.TaskFilter
+		want   []string
+	}{
+		{models.TaskFilter{}, []string{"File expenses", "Fix sink", "Pay rent", "Untagged"}},
+		{models.TaskFilter{Tags: []string{"home"}}, []string{"Fix sink", "Pay rent"}},
+		{models.TaskFilter{Tags: []string{"home", "bills"}}, []string{"Pay rent"}},
+		{models.TaskFilter{Tags: []string{"home", "bills"}, TagsMatchAny: true}, []string{"File expenses", "Fix sink", "Pay rent"}},
+		{models.TaskFilter{Tags: []string{"travel"}, TagsMatchAny: true}, []string{}},
+	}
+	for _, tt := range tests {
+		tasks, total, err := r.GetAll(context.Background(), uuid.New(), tt.filter, models.TaskSort{}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titlesOf(tasks); total != len(tt.want) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tags %v (any %v): %d %v, want %v", tt.filter.Tags, tt.filter.TagsMatchAny, total, got, tt.want)
+		}
+	}
+
+	tasks, _, _ := r.GetAll(context.Background(), uuid.New(), models.TaskFilter{Tags: []string{"work"}}, models.TaskSort{}, 1, 10)
+	if len(tasks) != 1 || !reflect.DeepEqual(tasks[0].Tags, []string{"work", "bills"}) {
+		t.Fatalf("tagged task %+v, want its tags scanned", tasks)
+	}
+}
+
+func TestGetDueSoonOnlyUpcomingUncompletedLiveTasks(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	seeded := []seededTask{
+		{"due in an hour", models.TaskStatusPending, now.Add(time.Hour), false},
+		{"due in a week", models.TaskStatusPending, now.Add(7 * 24 * time.Hour), false},
+		{"completed early", models.TaskStatusCompleted, now.Add(2 * time.Hour), false},
+		{"deleted due soon", models.TaskStatusInProgress, now.Add(3 * time.Hour), true},
+		{"already overdue", models.TaskStatusPending, now.Add(-time.Hour), false},
+		{"due in half an hour", models.TaskStatusInProgress, now.Add(30 * time.Minute), false},
+		{"due at the window's end", models.TaskStatusPending, now.Add(24 * time.Hour), false},
+	}
+	ids := make(map[string]string, len(seeded))
+	for _, task := range seeded {
+		ids[task.title] = uuid.NewString()
+	}
+	reminded := map[string]bool{}
+
+	// The fake applies the bounds GetDueSoon passes, and the skip for tasks
+	// MarkRemindersSent stamped, so a missing condition shows up as a task
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if strings.HasPrefix(strings.TrimSpace(query), "UPDATE tasks SET reminder_sent_at") {
+			for _, id := range args[0].([]string) {
+				reminded[id] = true
+			}
+			return fakeResult{affected: int64(len(args[0].([]string)))}
+		}
+		excluded, _ := args[0].(string)
+		within, _ := args[1].(float64)
+		from, _ := args[3].(time.Time)
+		var matched []seededTask
+		for _, task := range seeded {
+			if task.deleted && strings.Contains(query, "deleted_at IS NULL") {
+				continue
+			}
+			if reminded[ids[task.title]] && strings.Contains(query, "reminder_sent_at IS NULL") {
+				continue
+			}
+			if string(task.status) != excluded && task.due.After(from) && !task.due.After(from.Add(time.Duration(within*float64(time.Second)))) {
+				matched = append(matched, task)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool { return matched[i].due.Before(matched[j].due) })
+		var rows [][]driver.Value
+		for _, task := range matched {
+			rows = append(rows, []driver.Value{ids[task.title], task.title, "", string(task.status), task.due, uuid.NewString(), now, now, "{}"})
+		}
+		return rowsOf(rows...)
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+
+	tasks, err := r.GetDueSoon(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"due in half an hour", "due in an hour", "due at the window's end"}
+	if got := titlesOf(tasks); !reflect.DeepEqual(got, want) {
+		t.Fatalf("due soon %v, want %v", got, want)
+	}
+	if list := fake.sentMatching("ORDER BY due_date"); len(list) != 1 || list[0].args[2] != int64(reminderBatchSize) {
+		t.Fatalf("queries %v, want one capped at the batch size", list)
+	}
+
+	if err := r.MarkRemindersSent(context.Background(), []uuid.UUID{tasks[0].ID, tasks[1].ID}); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err = r.GetDueSoon(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titlesOf(tasks); !reflect.DeepEqual(got, []string{"due at the window's end"}) {
+		t.Fatalf("after reminding: %v, want only the unreminded task", got)
+	}
+}
+
+// updateTarget is the one task row updateDB holds
+type updateTarget struct {
+	id, owner string
+	version   int64
+	deleted   bool
+}
+
+// updateDB applies Update's WHERE clause to row, bumping its version on a
+// match, and answers the existence check that tells a stale version from a
+// missing task
+func updateDB(t *testing.T, row *updateTarget) *TaskRepository {
+	t.Helper()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	live := func(query string, args []driver.Value, id, owner int) bool {
+		return args[id-1] == row.id && args[owner-1] == row.owner &&
+			(!row.deleted || !strings.Contains(query, "deleted_at IS NULL"))
+	}
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if strings.Contains(query, "SELECT EXISTS") {
+			return rowsOf([]driver.Value{live(query, args, 1, 2)})
+		}
+		m := updateWhere.FindStringSubmatch(query)
+		if m == nil {
+			t.Fatalf("unexpected query %s", query)
+		}
+		if !live(query, args, atoi(t, m[1]), atoi(t, m[2])) || args[atoi(t, m[3])-1] != row.version {
+			return noRows()
+		}
+		row.version++
+		return rowsOf([]driver.Value{row.id, "Write report", "", "pending", now, row.owner, now, now, nil, nil, "{}", row.version})
+	})
+	return NewTaskRepository(db, clock.NewFake(now))
+}
+
+// updateWhere matches the placeholders of Update's id, owner and version
+var updateWhere = regexp.MustCompile(`WHERE id = \$(\d+) AND user_id = \$(\d+) AND deleted_at IS NULL AND version = \$(\d+)`)
+
+func TestUpdateDeletedOrMissingTaskIsNotFound(t *testing.T) {
+	id, owner := uuid.New(), uuid.New()
+	row := &updateTarget{id: id.String(), owner: owner.String(), version: 1, deleted: true}
+	r := updateDB(t, row)
+	title := "Write the report"
+
+	if _, err := r.Update(context.Background(), id, owner, 1, TaskFields{Title: &title}); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("updating a deleted task: error %v, want ErrTaskNotFound", err)
+	}
+	if _, err := r.Update(context.Background(), uuid.New(), owner, 1, TaskFields{Title: &title}); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("updating a missing task: error %v, want ErrTaskNotFound", err)
+	}
+	if _, err := r.Update(context.Background(), id, uuid.New(), 1, TaskFields{Title: &title}); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("updating another owner's task: error %v, want ErrTaskNotFound", err)
+	}
+	if row.version != 1 {
+		t.Fatalf("version %d, want the deleted task untouched", row.version)
+	}
+}
+
+func TestUpdateChecksVersion(t *testing.T) {
+	id, owner := uuid.New(), uuid.New()
+	row := &updateTarget{id: id.String(), owner: owner.String(), version: 3}
+	r := updateDB(t, row)
+	title := "Write the report"
+
+	task, err := r.Update(context.Background(), id, owner, 3, TaskFields{Title: &title})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Version != 4 || row.version != 4 {
+		t.Fatalf("version %d (stored %d), want it incremented to 4", task.Version, row.version)
+	}
+
+	// The version just replaced is now stale
+	if _, err := r.Update(context.Background(), id, owner, 3, TaskFields{Title: &title}); !errors.Is(err, ErrTaskVersionConflict) {
+		t.Fatalf("stale version: error %v, want ErrTaskVersionConflict", err)
+	}
+	if row.version != 4 {
+		t.Fatalf("stored version %d after a stale update, want 4", row.version)
+	}
+}
+
+func TestUpdateSetsOnlyProvidedColumns(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		return rowsOf([]driver.Value{uuid.NewString(), "Write report", "", "pending", now, uuid.NewString(), now, now, nil, nil, "{}", int64(2)})
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+	empty, title := "", "Write report"
+
+	if _, err := r.Update(context.Background(), uuid.New(), uuid.New(), 1, TaskFields{Description: &empty}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Update(context.Background(), uuid.New(), uuid.New(), 1, TaskFields{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := fake.sentMatching("UPDATE tasks")
+	if len(updates) != 2 {
+		t.Fatalf("sent %d updates, want 2", len(updates))
+	}
+	cleared := updates[0]
+	if !strings.Contains(cleared.query, "description = $1") || cleared.args[0] != "" || strings.Contains(cleared.query, "title =") {
+		t.Fatalf("clearing update %q %v, want only description set, to empty", cleared.query, cleared.args)
+	}
+	if retitled := updates[1].query; !strings.Contains(retitled, "title = $1") || strings.Contains(retitled, "description =") || strings.Contains(retitled, "status =") {
+		t.Fatalf("retitling update %q, want the description and status left alone", retitled)
+	}
+}
+
+func TestCountByStatusAndOverdue(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	owner, other := uuid.New(), uuid.New()
+	type countedTask struct {
+		seededTask
+		owner uuid.UUID
+	}
+	seeded := []countedTask{
+		{seededTask{"pending, overdue", models.TaskStatusPending, now.Add(-time.Hour), false}, owner},
+		{seededTask{"pending", models.TaskStatusPending, now.Add(time.Hour), false}, owner},
+		{seededTask{"in progress, overdue", models.TaskStatusInProgress, now.Add(-24 * time.Hour), false}, owner},
+		{seededTask{"completed late", models.TaskStatusCompleted, now.Add(-time.Hour), false}, owner},
+		{seededTask{"completed", models.TaskStatusCompleted, now.Add(time.Hour), false}, owner},
+		{seededTask{"deleted, overdue", models.TaskStatusPending, now.Add(-time.Hour), true}, owner},
+		{seededTask{"someone else's", models.TaskStatusPending, now.Add(-time.Hour), false}, other},
+	}
+
+	// The fake applies the owner, deleted and overdue conditions each query
+	// carries, grouping by status for the GROUP BY query
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		var matched []countedTask
+		for _, task := range seeded {
+			if args[0] != task.owner.String() || (task.deleted && strings.Contains(query, "deleted_at IS NULL")) {
+				continue
+			}
+			if strings.Contains(query, "due_date < $3") {
+				excluded, _ := args[1].(string)
+				cutoff, _ := args[2].(time.Time)
+				if string(task.status) == excluded || !task.due.Before(cutoff) {
+					continue
+				}
+			}
+			matched = append(matched, task)
+		}
+		if !strings.Contains(query, "GROUP BY status") {
+			return rowsOf([]driver.Value{int64(len(matched))})
+		}
+		counts := map[models.TaskStatus]int64{}
+		for _, task := range matched {
+			counts[task.status]++
+		}
+		var rows [][]driver.Value
+		for status, n := range counts {
+			rows = append(rows, []driver.Value{string(status), n})
+		}
+		return rowsOf(rows...)
+	})
+	r := NewTaskRepository(db, clock.NewFake(now))
+
+	counts, err := r.CountByStatus(context.Background(), owner, models.TaskFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[models.TaskStatus]int{models.TaskStatusPending: 2, models.TaskStatusInProgress: 1, models.TaskStatusCompleted: 2}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("counts %v, want %v", counts, want)
+	}
+	if grouped := fake.sentMatching("GROUP BY status"); len(grouped) != 1 {
+		t.Fatalf("sent %d grouped queries, want one for every status", len(grouped))
+	}
+
+	overdue, err := r.CountOverdue(context.Background(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overdue != 2 {
+		t.Fatalf("overdue %d, want the pending and in-progress tasks past due", overdue)
+	}
+}
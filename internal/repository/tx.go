@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX is the subset of *sql.DB and *sql.Tx the repositories query through
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// inTx runs fn in a transaction on db, committing if fn returns nil and
+// rolling back otherwise. When db is already a transaction fn joins it, and
+// whoever began it decides the outcome.
+func inTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// WithTx runs fn with repositories bound to a single transaction, committing
+// if fn returns nil and rolling back otherwise. On a repository that is
+// already bound to a transaction, fn joins it. A Repository assembled from
+// its fields without a database, as handler tests do, runs fn on itself.
+func (r *Repository) WithTx(ctx context.Context, fn func(txRepo *Repository) error) error {
+	if r.db == nil {
+		return fn(r)
+	}
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		return fn(newRepository(tx, r.opts))
+	})
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/models"
+)
+
+// insertDB answers task inserts, failing those for a task titled fail
+func insertDB(t *testing.T) (*Repository, *fakeDB) {
+	t.Helper()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if !strings.HasPrefix(strings.TrimSpace(query), "INSERT") {
+			return fakeResult{}
+		}
+		if title, _ := args[1].(string); title == "fail" {
+			return fakeResult{err: errors.New("insert failed")}
+		}
+		return rowsOf([]driver.Value{now, now, int64(1)})
+	})
+	return NewRepository(db, Options{}), fake
+}
+
+func TestWithTxCommitsBothInserts(t *testing.T) {
+	repo, fake := insertDB(t)
+	userID := uuid.New()
+
+	err := repo.WithTx(context.Background(), func(tx *Repository) error {
+		if err := tx.Task.Create(context.Background(), &models.Task{Title: "first", UserID: userID}); err != nil {
+			return err
+		}
+		return tx.Task.Create(context.Background(), &models.Task{Title: "second", UserID: userID})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(queriesOf(fake), " "); got != "BEGIN INSERT INSERT COMMIT" {
+		t.Fatalf("statements %q, want both inserts in one committed transaction", got)
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	repo, fake := insertDB(t)
+	userID := uuid.New()
+
+	err := repo.WithTx(context.Background(), func(tx *Repository) error {
+		if err := tx.Task.Create(context.Background(), &models.Task{Title: "first", UserID: userID}); err != nil {
+			return err
+		}
+		return tx.Task.Create(context.Background(), &models.Task{Title: "fail", UserID: userID})
+	})
+	if err == nil {
+		t.Fatal("WithTx succeeded, want the failed insert's error")
+	}
+	if got := strings.Join(queriesOf(fake), " "); got != "BEGIN INSERT INSERT ROLLBACK" {
+		t.Fatalf("statements %q, want the first insert rolled back", got)
+	}
+}
+
+func TestWithTxJoinsEnclosingTransaction(t *testing.T) {
+	repo, fake := insertDB(t)
+
+	err := repo.WithTx(context.Background(), func(tx *Repository) error {
+		return tx.WithTx(context.Background(), func(inner *Repository) error {
+			return inner.Task.Create(context.Background(), &models.Task{Title: "nested", UserID: uuid.New()})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(queriesOf(fake), " "); got != "BEGIN INSERT COMMIT" {
+		t.Fatalf("statements %q, want a single transaction", got)
+	}
+}
+
+func TestWithTxWithoutDatabaseRunsOnItself(t *testing.T) {
+	repo := &Repository{Task: &TaskRepository{}}
+	err := repo.WithTx(context.Background(), func(tx *Repository) error {
+		if tx != repo {
+			t.Fatal("fn got new repositories, losing the ones the caller assembled")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkCreateIsAllOrNothing(t *testing.T) {
+	repo, fake := insertDB(t)
+	userID := uuid.New()
+
+	tasks := []*models.Task{{Title: "first", UserID: userID}, {Title: "second", UserID: userID, Status: models.TaskStatusCompleted}}
+	if err := repo.Task.BulkCreate(context.Background(), tasks); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(queriesOf(fake), " "); got != "BEGIN INSERT INSERT COMMIT" {
+		t.Fatalf("statements %q, want every insert in one committed transaction", got)
+	}
+	for _, task := range tasks {
+		if task.ID == uuid.Nil || task.Version != 1 || task.Tags == nil {
+			t.Fatalf("created %+v, want its id, version and tags set", task)
+		}
+	}
+	if tasks[0].CompletedAt != nil || tasks[1].CompletedAt == nil {
+		t.Fatalf("completed_at %v and %v, want it set only on the completed task", tasks[0].CompletedAt, tasks[1].CompletedAt)
+	}
+
+	repo, fake = insertDB(t)
+	err := repo.Task.BulkCreate(context.Background(), []*models.Task{{Title: "first", UserID: userID}, {Title: "fail", UserID: userID}, {Title: "third", UserID: userID}})
+	if err == nil {
+		t.Fatal("BulkCreate succeeded, want the failed insert's error")
+	}
+	if got := strings.Join(queriesOf(fake), " "); got != "BEGIN INSERT INSERT ROLLBACK" {
+		t.Fatalf("statements %q, want the batch rolled back at the failed row", got)
+	}
+}
@@ -2,48 +2,108 @@ package repository
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"secure-task-api/internal/clock"
 	"secure-task-api/internal/models"
 )
 
 // UserRepository handles all user-related database operations
 type UserRepository struct {
-	db *sql.DB
+	db           DBTX
+	emailHashKey []byte
+	clock        clock.Clock
 }
 
-// NewUserRepository creates a new UserRepository
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+// NewUserRepository creates a new UserRepository reading the time from clk.
+// When emailHashKey is set, a keyed hash of each email is stored and used for
+// lookups.
+func NewUserRepository(db DBTX, emailHashKey []byte, clk clock.Clock) *UserRepository {
+	return &UserRepository{db: db, emailHashKey: emailHashKey, clock: clk}
 }
 
-// Create inserts a new user into the database
+// normalizeEmail is the form of an email that is stored, hashed and looked
+// up, so the plaintext column, its unique index and the hash always agree.
+// Surrounding whitespace is dropped; case is kept.
+func normalizeEmail(email string) string {
+	return strings.TrimSpace(email)
+}
+
+// emailHash returns the keyed hash of an email, or NULL when hashing is off.
+// Case is kept, like the plaintext email column and its unique index, so
+// turning hashing on doesn't change which accounts match or collide.
+func (r *UserRepository) emailHash(email string) sql.NullString {
+	if len(r.emailHashKey) == 0 {
+		return sql.NullString{}
+	}
+	mac := hmac.New(sha256.New, r.emailHashKey)
+	mac.Write([]byte(normalizeEmail(email)))
+	return sql.NullString{String: hex.EncodeToString(mac.Sum(nil)), Valid: true}
+}
+
+// Create inserts a new user into the database. It returns ErrDuplicate if
+// a live account already has the email, which a prior lookup can miss when
+// two registrations race.
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (id, email, password_hash, name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING created_at, updated_at`
+		INSERT INTO users (id, email, email_hash, password_hash, name, email_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, FALSE, $6, $7)
+		RETURNING role, created_at, updated_at`
 
 	user.ID = uuid.New()
-	now := time.Now()
+	user.Email = normalizeEmail(user.Email)
+	user.EmailVerified = false
+	now := r.clock.Now()
 
-	return r.db.QueryRowContext(ctx, query,
-		user.ID, user.Email, user.PasswordHash, user.Name, now, now,
-	).Scan(&user.CreatedAt, &user.UpdatedAt)
+	err := r.db.QueryRowContext(ctx, query,
+		user.ID, user.Email, r.emailHash(user.Email), user.PasswordHash, user.Name, now, now,
+	).Scan(&user.Role, &user.CreatedAt, &user.UpdatedAt)
+	return dbError(err, "create user")
 }
 
-// GetByEmail fetches a user by email
+// GetByEmail fetches a user by email, via the email hash when hashing is enabled
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	email = normalizeEmail(email)
+	hash := r.emailHash(email)
+	if !hash.Valid {
+		return r.getByColumn(ctx, "email", email)
+	}
+
+	user, err := r.getByColumn(ctx, "email_hash", hash.String)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	// Rows created before hashing was enabled have no hash yet; find them by
+	// plaintext once and backfill so later lookups use the hash.
+	user, err = r.getByColumn(ctx, "email", email)
+	if err != nil || user == nil {
+		return user, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE users SET email_hash = $1 WHERE id = $2 AND email_hash IS NULL`, hash, user.ID)
+	return user, err
+}
+
+// getByColumn fetches a user by a unique column; column must be a trusted identifier
+func (r *UserRepository) getByColumn(ctx context.Context, column, value string) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, created_at, updated_at
+		SELECT id, email, password_hash, name, email_verified, role, created_at, updated_at,
+		       failed_login_attempts, locked_until, totp_enabled, COALESCE(totp_secret, ''), token_version
 		FROM users
-		WHERE email = $1`
+		WHERE ` + column + ` = $1 AND deleted_at IS NULL`
 
 	var user models.User
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	err := r.db.QueryRowContext(ctx, query, value).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.EmailVerified, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.FailedLoginAttempts, &user.LockedUntil, &user.TwoFactorEnabled, &user.TOTPSecret, &user.TokenVersion,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -58,13 +118,15 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 // GetByID fetches a user by their ID
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, created_at, updated_at
+		SELECT id, email, password_hash, name, email_verified, role, created_at, updated_at,
+		       failed_login_attempts, locked_until, totp_enabled, COALESCE(totp_secret, ''), token_version
 		FROM users
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	var user models.User
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.EmailVerified, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.FailedLoginAttempts, &user.LockedUntil, &user.TwoFactorEnabled, &user.TOTPSecret, &user.TokenVersion,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -75,3 +137,297 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 
 	return &user, nil
 }
+
+// List returns a page of live users, oldest first, and the total number of live users
+func (r *UserRepository) List(ctx context.Context, page, limit int) ([]models.User, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM users WHERE deleted_at IS NULL`,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, email, password_hash, name, email_verified, role, created_at, updated_at,
+		       failed_login_attempts, locked_until, totp_enabled, COALESCE(totp_secret, ''), token_version
+		FROM users
+		WHERE deleted_at IS NULL
+		ORDER BY created_at, id
+		LIMIT $1 OFFSET $2`, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(
+			&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.EmailVerified, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+			&user.FailedLoginAttempts, &user.LockedUntil, &user.TwoFactorEnabled, &user.TOTPSecret, &user.TokenVersion,
+		); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UserFields holds the user columns to change in UpdateFields. Nil fields are not written.
+type UserFields struct {
+	Name  *string
+	Email *string
+}
+
+// Update sets the user's name and email and returns the updated user, or nil
+// if the user does not exist. A changed email must be verified again.
+func (r *UserRepository) Update(ctx context.Context, id uuid.UUID, name, email string) (*models.User, error) {
+	return r.UpdateFields(ctx, id, UserFields{Name: &name, Email: &email})
+}
+
+// UpdateFields writes only the provided columns and returns the updated user,
+// or nil if the user does not exist. A new email already in use by another
+// account is ErrDuplicate.
+func (r *UserRepository) UpdateFields(ctx context.Context, id uuid.UUID, fields UserFields) (*models.User, error) {
+	var sets []string
+	var args []interface{}
+
+	if fields.Name != nil {
+		args = append(args, *fields.Name)
+		sets = append(sets, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if fields.Email != nil {
+		email := normalizeEmail(*fields.Email)
+		args = append(args, email)
+		sets = append(sets, fmt.Sprintf("email = $%d", len(args)))
+		args = append(args, r.emailHash(email))
+		sets = append(sets, fmt.Sprintf("email_hash = $%d", len(args)))
+		// A new address has to be verified again; SET sees the old email
+		sets = append(sets, fmt.Sprintf("email_verified = (email_verified AND email = $%d)", len(args)-1))
+	}
+	if len(sets) == 0 {
+		return r.GetByID(ctx, id)
+	}
+
+	args = append(args, r.clock.Now())
+	sets = append(sets, fmt.Sprintf("updated_at = $%d", len(args)))
+
+	args = append(args, id)
+	query := fmt.Sprintf(`
+		UPDATE users
+		SET %s
+		WHERE id = $%d AND deleted_at IS NULL
+		RETURNING id, email, password_hash, name, email_verified, role, created_at, updated_at,
+		          failed_login_attempts, locked_until, totp_enabled, COALESCE(totp_secret, ''), token_version`,
+		strings.Join(sets, ", "), len(args))
+
+	var user models.User
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.EmailVerified, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.FailedLoginAttempts, &user.LockedUntil, &user.TwoFactorEnabled, &user.TOTPSecret, &user.TokenVersion,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dbError(err, "update user")
+	}
+
+	return &user, nil
+}
+
+// UpdatePassword replaces a user's password hash
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = $3
+		WHERE id = $2 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, passwordHash, id, r.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// MarkEmailVerified records that the user has confirmed their email address
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET email_verified = TRUE, updated_at = $2
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, id, r.clock.Now())
+	return err
+}
+
+// SoftDelete marks the user deleted along with their live tasks, so they can
+// no longer be found or log in. It reports false if there was no such user.
+func (r *UserRepository) SoftDelete(ctx context.Context, id uuid.UUID) (bool, error) {
+	var deleted bool
+	now := r.clock.Now()
+	err := inTx(ctx, r.db, func(tx DBTX) error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE users
+			SET deleted_at = $2, updated_at = $2
+			WHERE id = $1 AND deleted_at IS NULL`, id, now)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE tasks
+			SET deleted_at = $2
+			WHERE user_id = $1 AND deleted_at IS NULL`, id, now); err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// GetTokenVersion returns a live user's current token version, and false if
+// there is no such user
+func (r *UserRepository) GetTokenVersion(ctx context.Context, id uuid.UUID) (int, bool, error) {
+	var version int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT token_version FROM users
+		WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return version, true, nil
+}
+
+// BumpTokenVersion increments the user's token version, so access tokens
+// issued before it are rejected, and returns the new version
+func (r *UserRepository) BumpTokenVersion(ctx context.Context, id uuid.UUID) (int, error) {
+	var version int
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE users
+		SET token_version = token_version + 1, updated_at = $2
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING token_version`, id, r.clock.Now()).Scan(&version)
+	return version, err
+}
+
+// SetTOTPSecret stores a new, not yet enabled TOTP secret for a live user,
+// replacing any pending one. It reports false if the user doesn't exist or
+// already has two-factor authentication enabled.
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, id uuid.UUID, encryptedSecret string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET totp_secret = $2, totp_last_counter = NULL
+		WHERE id = $1 AND deleted_at IS NULL AND NOT totp_enabled`, id, encryptedSecret)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// EnableTOTP turns on two-factor authentication with the pending secret,
+// recording counter as the time step of the code that confirmed it
+func (r *UserRepository) EnableTOTP(ctx context.Context, id uuid.UUID, counter int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET totp_enabled = TRUE, totp_last_counter = $2
+		WHERE id = $1 AND deleted_at IS NULL AND totp_secret IS NOT NULL`, id, counter)
+	return err
+}
+
+// DisableTOTP turns off two-factor authentication and forgets the secret
+func (r *UserRepository) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET totp_enabled = FALSE, totp_secret = NULL, totp_last_counter = NULL
+		WHERE id = $1 AND deleted_at IS NULL`, id)
+	return err
+}
+
+// UseTOTPCounter records counter as the time step of an accepted code. It
+// reports false if a code from that step or a later one was already used.
+func (r *UserRepository) UseTOTPCounter(ctx context.Context, id uuid.UUID, counter int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET totp_last_counter = $2
+		WHERE id = $1 AND (totp_last_counter IS NULL OR totp_last_counter < $2)`, id, counter)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// RecordFailedLogin counts a failed password check. Failures more than window
+// apart start a new count; reaching threshold locks the account for lockout
+// and starts the count over. It returns the lock expiry, nil if not locked.
+func (r *UserRepository) RecordFailedLogin(ctx context.Context, id uuid.UUID, threshold int, window, lockout time.Duration) (*time.Time, error) {
+	// SET sees the old row, so the new count is spelled out where it's needed
+	query := `
+		UPDATE users
+		SET failed_login_attempts = CASE
+		        WHEN (CASE WHEN last_failed_login_at >= $2 THEN failed_login_attempts + 1 ELSE 1 END) >= $4 THEN 0
+		        ELSE (CASE WHEN last_failed_login_at >= $2 THEN failed_login_attempts + 1 ELSE 1 END)
+		    END,
+		    locked_until = CASE
+		        WHEN (CASE WHEN last_failed_login_at >= $2 THEN failed_login_attempts + 1 ELSE 1 END) >= $4 THEN $5
+		        ELSE locked_until
+		    END,
+		    last_failed_login_at = $3
+		WHERE id = $1
+		RETURNING locked_until`
+
+	now := r.clock.Now()
+	var lockedUntil sql.NullTime
+	err := r.db.QueryRowContext(ctx, query,
+		id, now.Add(-window), now, threshold, now.Add(lockout),
+	).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !lockedUntil.Valid || !now.Before(lockedUntil.Time) {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+// ResetFailedLogins clears the failed login count and any lock
+func (r *UserRepository) ResetFailedLogins(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL
+		WHERE id = $1 AND (failed_login_attempts <> 0 OR locked_until IS NOT NULL)`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
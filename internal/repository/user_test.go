@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/models"
+)
+
+func TestEmailHashKeepsCase(t *testing.T) {
+	r := NewUserRepository(nil, []byte("key"), nil)
+
+	if r.emailHash("Foo@example.com") == r.emailHash("foo@example.com") {
+		t.Fatal("emails differing only in case hashed the same, unlike the plaintext index")
+	}
+	if r.emailHash(" foo@example.com ") != r.emailHash("foo@example.com") {
+		t.Fatal("surrounding whitespace changed the hash")
+	}
+	if NewUserRepository(nil, nil, nil).emailHash("foo@example.com").Valid {
+		t.Fatal("hash set with hashing disabled")
+	}
+}
+
+// userRow is a users row as getByColumn selects it
+func userRow(id uuid.UUID, email string) []driver.Value {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []driver.Value{id.String(), email, "hash", "User", true, "user", now, now, int64(0), nil, false, "", int64(0)}
+}
+
+func TestGetByEmailUsesHash(t *testing.T) {
+	id := uuid.New()
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if strings.Contains(query, "WHERE email_hash = $1") {
+			return rowsOf(userRow(id, "user@example.com"))
+		}
+		return noRows()
+	})
+	r := NewUserRepository(db, []byte("key"), clock.Real{})
+
+	user, err := r.GetByEmail(context.Background(), " user@example.com ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.ID != id {
+		t.Fatalf("user = %+v, want %s", user, id)
+	}
+
+	sent := fake.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d statements, want a single hash lookup: %+v", len(sent), sent)
+	}
+	if got, want := sent[0].args[0], r.emailHash("user@example.com").String; got != want {
+		t.Fatalf("looked up hash %v, want %s", got, want)
+	}
+}
+
+func TestGetByEmailBackfillsHash(t *testing.T) {
+	id := uuid.New()
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if strings.Contains(query, "WHERE email = $1") {
+			return rowsOf(userRow(id, "user@example.com"))
+		}
+		if strings.HasPrefix(strings.TrimSpace(query), "UPDATE users SET email_hash") {
+			return fakeResult{affected: 1}
+		}
+		return noRows()
+	})
+	r := NewUserRepository(db, []byte("key"), clock.Real{})
+
+	// Created before hashing was on: no hash yet, so found by plaintext
+	user, err := r.GetByEmail(context.Background(), "user@example.com ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.ID != id {
+		t.Fatalf("user = %+v, want %s", user, id)
+	}
+
+	byEmail := fake.sentMatching("WHERE email = $1")
+	if len(byEmail) != 1 || byEmail[0].args[0] != "user@example.com" {
+		t.Fatalf("plaintext lookups %+v, want one for the trimmed email", byEmail)
+	}
+	backfill := fake.sentMatching("UPDATE users SET email_hash")
+	if len(backfill) != 1 {
+		t.Fatalf("backfills %+v, want one", backfill)
+	}
+	if got, want := backfill[0].args[0], r.emailHash("user@example.com").String; got != want {
+		t.Fatalf("backfilled hash %v, want %s", got, want)
+	}
+	if backfill[0].args[1] != id.String() {
+		t.Fatalf("backfilled user %v, want %s", backfill[0].args[1], id)
+	}
+}
+
+func TestGetByEmailWithoutHashing(t *testing.T) {
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult { return noRows() })
+	r := NewUserRepository(db, nil, clock.Real{})
+
+	user, err := r.GetByEmail(context.Background(), "nobody@example.com")
+	if err != nil || user != nil {
+		t.Fatalf("GetByEmail = %+v, %v, want nil, nil", user, err)
+	}
+	if sent := fake.sent(); len(sent) != 1 || !strings.Contains(sent[0].query, "WHERE email = $1") {
+		t.Fatalf("sent %+v, want one plaintext lookup", sent)
+	}
+}
+
+func TestCreateStoresNormalizedEmail(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		return rowsOf([]driver.Value{"user", now, now})
+	})
+	r := NewUserRepository(db, []byte("key"), clock.Real{})
+
+	user := &models.User{Email: "  User@example.com\n", PasswordHash: "hash", Name: "User"}
+	if err := r.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "User@example.com" {
+		t.Fatalf("email %q, want it trimmed", user.Email)
+	}
+
+	insert := fake.sentMatching("INSERT INTO users")
+	if len(insert) != 1 {
+		t.Fatalf("inserts %+v, want one", insert)
+	}
+	if insert[0].args[1] != "User@example.com" {
+		t.Fatalf("stored email %q, want it trimmed like the hash", insert[0].args[1])
+	}
+	if got, want := insert[0].args[2], r.emailHash("User@example.com").String; got != want {
+		t.Fatalf("stored hash %v, want the trimmed email's %s", got, want)
+	}
+}
+
+func TestRecordFailedLoginLocksAtThreshold(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	var attempts int64
+	var lastFailed, lockedUntil *time.Time
+
+	// The fake evaluates the UPDATE's CASE expressions on one users row, from
+	// the window start, time, threshold and lock end it is passed
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		if !strings.Contains(query, "SET failed_login_attempts = CASE") {
+			return fakeResult{}
+		}
+		windowStart, now := args[1].(time.Time), args[2].(time.Time)
+		threshold, lockEnd := args[3].(int64), args[4].(time.Time)
+		count := int64(1)
+		if lastFailed != nil && !lastFailed.Before(windowStart) {
+			count = attempts + 1
+		}
+		attempts = count
+		if count >= threshold {
+			attempts, lockedUntil = 0, &lockEnd
+		}
+		lastFailed = &now
+		if lockedUntil == nil {
+			return rowsOf([]driver.Value{nil})
+		}
+		return rowsOf([]driver.Value{*lockedUntil})
+	})
+	r := NewUserRepository(db, nil, clk)
+	record := func() *time.Time {
+		t.Helper()
+		until, err := r.RecordFailedLogin(context.Background(), uuid.New(), 3, 15*time.Minute, 30*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return until
+	}
+
+	if record() != nil || record() != nil {
+		t.Fatal("locked before the threshold")
+	}
+	until := record()
+	if want := clk.Now().Add(30 * time.Minute); until == nil || !until.Equal(want) {
+		t.Fatalf("locked until %v, want %v", until, want)
+	}
+
+	// Once the lock has passed, a lone failure reports no lock
+	clk.Advance(31 * time.Minute)
+	if until := record(); until != nil {
+		t.Fatalf("after the lock: locked until %v, want no lock", until)
+	}
+}
+
+func TestUpdateSetsProfileAndUpdatedAt(t *testing.T) {
+	id := uuid.New()
+	now := time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		row := userRow(id, "new@example.com")
+		row[7] = now
+		return rowsOf(row)
+	})
+	r := NewUserRepository(db, []byte("key"), clock.NewFake(now))
+
+	user, err := r.Update(context.Background(), id, "New Name", " new@example.com ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.ID != id || user.Email != "new@example.com" || !user.UpdatedAt.Equal(now) {
+		t.Fatalf("user = %+v, want the updated row", user)
+	}
+
+	updates := fake.sentMatching("UPDATE users")
+	if len(updates) != 1 {
+		t.Fatalf("updates %+v, want one", updates)
+	}
+	q, args := updates[0].query, updates[0].args
+	for _, want := range []string{"name = $1", "email = $2", "email_hash = $3", "updated_at = $4", "WHERE id = $5 AND deleted_at IS NULL", "RETURNING"} {
+		if !strings.Contains(q, want) {
+			t.Errorf("query %s, want it to contain %s", q, want)
+		}
+	}
+	if args[0] != "New Name" || args[1] != "new@example.com" || args[2] != r.emailHash("new@example.com").String || args[4] != id.String() {
+		t.Fatalf("args %v, want the name, trimmed email, its hash and the id", args)
+	}
+	if at, ok := args[3].(time.Time); !ok || !at.Equal(now) {
+		t.Fatalf("updated_at %v, want the clock's now", args[3])
+	}
+}
+
+func TestUpdateMissingUserOrTakenEmail(t *testing.T) {
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		return noRows()
+	})
+	user, err := NewUserRepository(db, nil, clock.Real{}).Update(context.Background(), uuid.New(), "Name", "user@example.com")
+	if err != nil || user != nil {
+		t.Fatalf("missing user: user %+v, err %v, want nil and no error", user, err)
+	}
+
+	db, _ = newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		return fakeResult{err: &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}}
+	})
+	_, err = NewUserRepository(db, nil, clock.Real{}).Update(context.Background(), uuid.New(), "Name", "taken@example.com")
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("taken email: err = %v, want %v", err, ErrDuplicate)
+	}
+}
+
+func TestSoftDeleteHidesUserAndTasks(t *testing.T) {
+	id := uuid.New()
+	now := time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)
+	deleted := false
+	db, fake := newFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		switch {
+		case strings.Contains(query, "UPDATE users"):
+			if deleted {
+				return fakeResult{}
+			}
+			deleted = true
+			return fakeResult{affected: 1}
+		case strings.Contains(query, "UPDATE tasks"):
+			return fakeResult{affected: 3}
+		}
+		return noRows()
+	})
+	r := NewUserRepository(db, nil, clock.NewFake(now))
+
+	ok, err := r.SoftDelete(context.Background(), id)
+	if err != nil || !ok {
+		t.Fatalf("SoftDelete = %v, %v, want the user deleted", ok, err)
+	}
+	var kinds []string
+	for _, s := range fake.sent() {
+		kind, _, _ := strings.Cut(strings.TrimSpace(s.query), "\n")
+		kinds = append(kinds, strings.TrimSpace(kind))
+		if strings.HasPrefix(kind, "UPDATE") {
+			if s.args[0] != id.String() || !s.args[1].(time.Time).Equal(now) || !strings.Contains(s.query, "deleted_at IS NULL") {
+				t.Errorf("%s args %v, want the user and the clock's now, live rows only", kind, s.args)
+			}
+		}
+	}
+	if got := strings.Join(kinds, ", "); got != "BEGIN, UPDATE users, UPDATE tasks, COMMIT" {
+		t.Fatalf("statements %s, want the user and their tasks marked in one transaction", got)
+	}
+
+	// Already deleted: nothing is found, and the tasks are left alone
+	before := len(fake.sent())
+	if ok, err := r.SoftDelete(context.Background(), id); err != nil || ok {
+		t.Fatalf("second SoftDelete = %v, %v, want false", ok, err)
+	}
+	if len(fake.sentMatching("UPDATE tasks")) != 1 || len(fake.sent()) == before {
+		t.Fatal("second SoftDelete touched the tasks")
+	}
+
+	// Lookups only see live users
+	r.GetByID(context.Background(), id)
+	r.GetByEmail(context.Background(), "user@example.com")
+	lookups := fake.sentMatching("FROM users")
+	if len(lookups) < 2 {
+		t.Fatalf("lookups %+v, want GetByID and GetByEmail to query users", lookups)
+	}
+	for _, s := range lookups {
+		if !strings.Contains(s.query, "deleted_at IS NULL") {
+			t.Errorf("lookup %s, want deleted users excluded", s.query)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/models"
+)
+
+// UserTokenRepository handles database operations for single-use user tokens
+type UserTokenRepository struct {
+	db    DBTX
+	clock clock.Clock
+}
+
+// NewUserTokenRepository creates a new UserTokenRepository that stamps and
+// expires tokens by clk
+func NewUserTokenRepository(db DBTX, clk clock.Clock) *UserTokenRepository {
+	return &UserTokenRepository{db: db, clock: clk}
+}
+
+// Create records an issued token by its hash
+func (r *UserTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+
+	return r.db.QueryRowContext(ctx, query,
+		token.TokenHash, token.UserID, token.Purpose, token.ExpiresAt, r.clock.Now(),
+	).Scan(&token.CreatedAt)
+}
+
+// Consume atomically marks an unused, unexpired token as used and returns it.
+// It returns nil if the token is unknown, already used, or expired.
+func (r *UserTokenRepository) Consume(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error) {
+	query := `
+		UPDATE user_tokens
+		SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING token_hash, user_id, purpose, expires_at, used_at, created_at`
+
+	var token models.UserToken
+	err := r.db.QueryRowContext(ctx, query, tokenHash, purpose, r.clock.Now()).Scan(
+		&token.TokenHash, &token.UserID, &token.Purpose, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// GetActive returns an unused, unexpired token without consuming it, for
+// long-lived tokens that are checked on every use. It returns nil otherwise.
+func (r *UserTokenRepository) GetActive(ctx context.Context, tokenHash, purpose string) (*models.UserToken, error) {
+	query := `
+		SELECT token_hash, user_id, purpose, expires_at, used_at, created_at
+		FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3`
+
+	var token models.UserToken
+	err := r.db.QueryRowContext(ctx, query, tokenHash, purpose, r.clock.Now()).Scan(
+		&token.TokenHash, &token.UserID, &token.Purpose, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// InvalidateForUser marks every outstanding token of purpose for a user as used
+func (r *UserTokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string) error {
+	query := `
+		UPDATE user_tokens
+		SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, userID, purpose, r.clock.Now())
+	return err
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"secure-task-api/internal/clock"
+	"secure-task-api/internal/models"
+)
+
+// WebhookDeliveryRepository handles database operations for webhook
+// deliveries that failed after every retry
+type WebhookDeliveryRepository struct {
+	db    DBTX
+	clock clock.Clock
+}
+
+// NewWebhookDeliveryRepository creates a new WebhookDeliveryRepository that
+// stamps deliveries with times from clk
+func NewWebhookDeliveryRepository(db DBTX, clk clock.Clock) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{db: db, clock: clk}
+}
+
+const webhookDeliveryColumns = `id, user_id, task_id, event, target, payload, status, error, attempts, last_attempt_at, replayed_at, created_at`
+
+// Create records a failed delivery as failed after one attempt. A delivery
+// already recorded under the same ID is left as is.
+func (r *WebhookDeliveryRepository) Create(ctx context.Context, d *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, user_id, task_id, event, target, payload, status, error, attempts, last_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1, $9, $9)
+		ON CONFLICT (id) DO NOTHING`
+
+	now := r.clock.Now()
+	d.Status = models.WebhookDeliveryFailed
+	d.Attempts = 1
+	d.LastAttemptAt = now
+	d.CreatedAt = now
+
+	_, err := r.db.ExecContext(ctx, query,
+		d.ID, d.UserID, d.TaskID, d.Event, d.Target, []byte(d.Payload), d.Status, d.Error, now,
+	)
+	return err
+}
+
+// ListByUser returns a page of the user's deliveries, newest first, and the
+// total matching. An empty status matches every status.
+func (r *WebhookDeliveryRepository) ListByUser(ctx context.Context, userID uuid.UUID, status string, page, limit int) ([]models.WebhookDelivery, int, error) {
+	where := `user_id = $1 AND ($2 = '' OR status = $2)`
+
+	var total int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM webhook_deliveries WHERE `+where, userID, status,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE `+where+`
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4`,
+		userID, status, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, total, rows.Err()
+}
+
+// GetByID retrieves one of the user's deliveries, or nil if they have no such delivery
+func (r *WebhookDeliveryRepository) GetByID(ctx context.Context, id, userID uuid.UUID) (*models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE id = $1 AND user_id = $2`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// MarkReplayed records a successful replay of one of the user's failed
+// deliveries and returns it. It returns nil if the delivery is not failed,
+// such as when a concurrent replay got there first.
+func (r *WebhookDeliveryRepository) MarkReplayed(ctx context.Context, id, userID uuid.UUID) (*models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $3, error = '', attempts = attempts + 1, last_attempt_at = $4, replayed_at = $4
+		WHERE id = $1 AND user_id = $2 AND status = $5
+		RETURNING `+webhookDeliveryColumns,
+		id, userID, models.WebhookDeliveryReplayed, r.clock.Now(), models.WebhookDeliveryFailed))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// RecordFailedReplay counts a replay of one of the user's failed deliveries
+// that failed again with reason, and returns the delivery. It returns nil if
+// the delivery is not failed.
+func (r *WebhookDeliveryRepository) RecordFailedReplay(ctx context.Context, id, userID uuid.UUID, reason string) (*models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries
+		SET error = $3, attempts = attempts + 1, last_attempt_at = $4
+		WHERE id = $1 AND user_id = $2 AND status = $5
+		RETURNING `+webhookDeliveryColumns,
+		id, userID, reason, r.clock.Now(), models.WebhookDeliveryFailed))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// DeleteBefore removes deliveries first recorded before cutoff, whatever
+// their status, and returns how many were removed
+func (r *WebhookDeliveryRepository) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanWebhookDelivery scans webhookDeliveryColumns from row
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	if err := row.Scan(&d.ID, &d.UserID, &d.TaskID, &d.Event, &d.Target, &payload, &d.Status, &d.Error,
+		&d.Attempts, &d.LastAttemptAt, &d.ReplayedAt, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return &d, nil
+}
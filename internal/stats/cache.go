@@ -0,0 +1,182 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+)
+
+// Counter computes per-status and overdue task counts for a user
+type Counter interface {
+	CountByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error)
+	CountOverdue(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// Cache serves per-user task stats, recomputing them in the background for
+// recently active users. When disabled every read goes to the database.
+type Cache struct {
+	counter  Counter
+	enabled  bool
+	interval time.Duration
+	log      *logger.Logger
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*entry
+}
+
+type entry struct {
+	stats      models.TaskStats
+	stale      bool
+	lastAccess time.Time
+	generation uint64 // Bumped by Invalidate, so counts read before it are dropped
+}
+
+// activeWindow is how many refresh intervals a user stays active without reading stats
+const activeWindow = 10
+
+// NewCache creates a stats cache backed by counter
+func NewCache(counter Counter, enabled bool, interval time.Duration, log *logger.Logger) *Cache {
+	return &Cache{
+		counter:  counter,
+		enabled:  enabled,
+		interval: interval,
+		log:      log,
+		entries:  make(map[uuid.UUID]*entry),
+	}
+}
+
+// Get returns the user's stats, from cache unless fresh is set or the
+// cached value was invalidated by a mutation
+func (c *Cache) Get(ctx context.Context, userID uuid.UUID, fresh bool) (models.TaskStats, error) {
+	if c.enabled && !fresh {
+		c.mu.Lock()
+		e, ok := c.entries[userID]
+		if ok {
+			e.lastAccess = time.Now()
+			if !e.stale {
+				stats := e.stats
+				c.mu.Unlock()
+				return stats, nil
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	return c.compute(ctx, userID)
+}
+
+// Invalidate marks the user's cached stats stale so the next read recomputes them
+func (c *Cache) Invalidate(userID uuid.UUID) {
+	if !c.enabled {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[userID]; ok {
+		e.stale = true
+		e.generation++
+	}
+}
+
+// Run periodically recomputes stats for active users until ctx is cancelled.
+// It is a no-op when the cache is disabled.
+func (c *Cache) Run(ctx context.Context) {
+	if !c.enabled || c.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.refreshActive(ctx)
+		}
+	}
+}
+
+// refreshActive recomputes every active user's stats and evicts idle users
+func (c *Cache) refreshActive(ctx context.Context) {
+	cutoff := time.Now().Add(-activeWindow * c.interval)
+
+	c.mu.Lock()
+	var active []uuid.UUID
+	for userID, e := range c.entries {
+		if e.lastAccess.Before(cutoff) {
+			delete(c.entries, userID)
+			continue
+		}
+		active = append(active, userID)
+	}
+	c.mu.Unlock()
+
+	for _, userID := range active {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := c.compute(ctx, userID); err != nil {
+			c.log.WithError(err).Warn("failed to refresh task stats",
+				zap.String("user_id", userID.String()))
+		}
+	}
+}
+
+// compute queries the counts and stores them when caching is enabled. Counts
+// are only stored if the user's stats weren't invalidated while they were
+// being read, since they may predate the mutation.
+func (c *Cache) compute(ctx context.Context, userID uuid.UUID) (models.TaskStats, error) {
+	var e *entry
+	var generation uint64
+	if c.enabled {
+		c.mu.Lock()
+		e = c.entries[userID]
+		if e == nil {
+			// Created up front so an invalidation during the first read is seen
+			e = &entry{stale: true, lastAccess: time.Now()}
+			c.entries[userID] = e
+		}
+		generation = e.generation
+		c.mu.Unlock()
+	}
+
+	counts, err := c.counter.CountByStatus(ctx, userID, models.TaskFilter{})
+	if err != nil {
+		return models.TaskStats{}, err
+	}
+	overdue, err := c.counter.CountOverdue(ctx, userID)
+	if err != nil {
+		return models.TaskStats{}, err
+	}
+
+	stats := models.TaskStats{
+		Pending:    counts[models.TaskStatusPending],
+		InProgress: counts[models.TaskStatusInProgress],
+		Completed:  counts[models.TaskStatusCompleted],
+		Overdue:    overdue,
+		ComputedAt: time.Now(),
+	}
+	for _, n := range counts {
+		stats.Total += n
+	}
+
+	if c.enabled {
+		c.mu.Lock()
+		if c.entries[userID] == e && e.generation == generation {
+			e.stats = stats
+			e.stale = false
+		}
+		c.mu.Unlock()
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/models"
+)
+
+// fakeCounter reports pending tasks, optionally blocking each count until
+// release is closed
+type fakeCounter struct {
+	mu      sync.Mutex
+	pending int
+	calls   int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *fakeCounter) CountByStatus(ctx context.Context, userID uuid.UUID, filter models.TaskFilter) (map[models.TaskStatus]int, error) {
+	f.mu.Lock()
+	f.calls++
+	pending := f.pending
+	started, release := f.started, f.release
+	f.mu.Unlock()
+
+	if started != nil {
+		close(started)
+		<-release
+	}
+	return map[models.TaskStatus]int{models.TaskStatusPending: pending}, nil
+}
+
+func (f *fakeCounter) CountOverdue(ctx context.Context, userID uuid.UUID) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeCounter) set(pending int, started, release chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending, f.started, f.release = pending, started, release
+}
+
+func TestGetServesCachedStats(t *testing.T) {
+	counter := &fakeCounter{pending: 1}
+	cache := NewCache(counter, true, 0, nil)
+	userID := uuid.New()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(context.Background(), userID, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counter.calls != 1 {
+		t.Fatalf("counted %d times, want 1", counter.calls)
+	}
+
+	cache.Invalidate(userID)
+	counter.set(2, nil, nil)
+	stats, err := cache.Get(context.Background(), userID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pending != 2 {
+		t.Fatalf("pending = %d after invalidation, want 2", stats.Pending)
+	}
+}
+
+func TestInvalidateDuringComputeDropsOldCounts(t *testing.T) {
+	for _, name := range []string{"first read", "refresh"} {
+		t.Run(name, func(t *testing.T) {
+			counter := &fakeCounter{pending: 1}
+			cache := NewCache(counter, true, 0, nil)
+			userID := uuid.New()
+			if name == "refresh" {
+				if _, err := cache.Get(context.Background(), userID, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// A read that started before the mutation returns its counts
+			// late, after the mutation committed and invalidated the user
+			started, release := make(chan struct{}), make(chan struct{})
+			counter.set(1, started, release)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				cache.compute(context.Background(), userID)
+			}()
+			<-started
+			cache.Invalidate(userID)
+			counter.set(2, nil, nil)
+			close(release)
+			<-done
+
+			stats, err := cache.Get(context.Background(), userID, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Pending != 2 {
+				t.Fatalf("pending = %d, want 2: counts read before the invalidation were cached", stats.Pending)
+			}
+		})
+	}
+}
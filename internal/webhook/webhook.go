@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"secure-task-api/internal/config"
+	"secure-task-api/internal/httpclient"
+	"secure-task-api/internal/logger"
+	"secure-task-api/internal/models"
+)
+
+// Task lifecycle events sent to the webhook
+const (
+	EventTaskCreated   = "task.created"
+	EventTaskUpdated   = "task.updated"
+	EventTaskCompleted = "task.completed" // An update that moved the task to completed
+	EventTaskDeleted   = "task.deleted"
+)
+
+// Headers sent with each delivery
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload is the JSON body POSTed to the webhook for each event
+type Payload struct {
+	ID        uuid.UUID   `json:"id"`
+	Event     string      `json:"event"`
+	Task      models.Task `json:"task"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp, the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret. Covering the
+// timestamp lets receivers reject replayed deliveries.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrDisabled is returned when replaying a delivery with webhooks turned off
+var ErrDisabled = errors.New("task webhooks are disabled")
+
+// deadLetterSweepInterval is how often expired dead letters are removed
+const deadLetterSweepInterval = time.Hour
+
+// DeadLetterStore keeps deliveries that still failed after every retry, so
+// their owners can replay them until the retention period ends
+type DeadLetterStore interface {
+	Create(ctx context.Context, d *models.WebhookDelivery) error
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Dispatcher delivers task events to the webhook from a background worker, so
+// handlers never wait on the receiver. Events are queued up to cfg.QueueSize
+// and dropped with a warning beyond that. Events still failing after their
+// retries go to the dead-letter store when one is set. A nil Dispatcher, or
+// one without a URL, ignores every event.
+type Dispatcher struct {
+	cfg         config.WebhookConfig
+	client      *httpclient.Client
+	deadLetters DeadLetterStore
+	log         *logger.Logger
+	queue       chan Payload
+}
+
+// NewDispatcher creates a webhook dispatcher. Deliveries are retried with
+// backoff by client, and those that still fail are kept in deadLetters,
+// which may be nil to drop them.
+func NewDispatcher(cfg config.WebhookConfig, client *httpclient.Client, deadLetters DeadLetterStore, log *logger.Logger) *Dispatcher {
+	d := &Dispatcher{cfg: cfg, client: client, deadLetters: deadLetters, log: log}
+	if d.Enabled() {
+		d.queue = make(chan Payload, cfg.QueueSize)
+	}
+	return d
+}
+
+// Enabled reports whether events are delivered anywhere
+func (d *Dispatcher) Enabled() bool {
+	return d != nil && d.cfg.URL != ""
+}
+
+// Publish queues event for task without blocking
+func (d *Dispatcher) Publish(event string, task *models.Task) {
+	if !d.Enabled() || task == nil {
+		return
+	}
+
+	payload := Payload{ID: uuid.New(), Event: event, Task: *task, Timestamp: time.Now().UTC()}
+	select {
+	case d.queue <- payload:
+	default:
+		d.log.Warn("webhook queue full, dropping event",
+			zap.String("event", event),
+			zap.String("task_id", task.ID.String()),
+		)
+	}
+}
+
+// Run delivers queued events one at a time until ctx is cancelled, and
+// removes dead letters older than cfg.DeadLetterRetention at start and every
+// hour after. With webhooks disabled it only sweeps dead letters.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d == nil || (!d.Enabled() && d.deadLetters == nil) {
+		return
+	}
+
+	var sweep <-chan time.Time
+	if d.deadLetters != nil {
+		d.sweepDeadLetters(ctx)
+		ticker := time.NewTicker(deadLetterSweepInterval)
+		defer ticker.Stop()
+		sweep = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sweep:
+			d.sweepDeadLetters(ctx)
+		case payload := <-d.queue:
+			if err := d.deliver(ctx, payload); err != nil && ctx.Err() == nil {
+				d.log.WithError(d.log.Redactor().Error(err)).Warn("failed to deliver webhook",
+					zap.String("event", payload.Event),
+					zap.String("task_id", payload.Task.ID.String()),
+				)
+				d.recordDeadLetter(ctx, payload, err)
+			}
+		}
+	}
+}
+
+// Replay sends a dead-lettered delivery again to the target it failed
+// against, with its original body and X-Webhook-ID and a fresh timestamp and
+// signature. It returns ErrDisabled when webhooks are turned off.
+func (d *Dispatcher) Replay(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if !d.Enabled() {
+		return ErrDisabled
+	}
+	return d.send(ctx, delivery.Target, delivery.ID, time.Now(), delivery.Payload)
+}
+
+// deliver POSTs payload to the webhook, signed with the configured secret
+func (d *Dispatcher) deliver(ctx context.Context, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return d.send(ctx, d.cfg.URL, payload.ID, payload.Timestamp, body)
+}
+
+// send POSTs body to target as delivery id, signed for sentAt
+func (d *Dispatcher) send(ctx context.Context, target string, id uuid.UUID, sentAt time.Time, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, id.String())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(d.cfg.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("task webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// recordDeadLetter keeps payload, which failed against the configured URL
+// with err, in the dead-letter store
+func (d *Dispatcher) recordDeadLetter(ctx context.Context, payload Payload, err error) {
+	if d.deadLetters == nil {
+		return
+	}
+	body, marshalErr := json.Marshal(payload)
+	if marshalErr != nil {
+		return
+	}
+
+	delivery := &models.WebhookDelivery{
+		ID:      payload.ID,
+		UserID:  payload.Task.UserID,
+		TaskID:  payload.Task.ID,
+		Event:   payload.Event,
+		Target:  d.cfg.URL,
+		Payload: body,
+		Error:   FailureReason(d.cfg.URL, err),
+	}
+	if err := d.deadLetters.Create(ctx, delivery); err != nil {
+		d.log.WithError(err).Error("failed to record failed webhook delivery",
+			zap.String("event", payload.Event),
+			zap.String("task_id", payload.Task.ID.String()),
+		)
+	}
+}
+
+// sweepDeadLetters removes dead letters older than the retention period
+func (d *Dispatcher) sweepDeadLetters(ctx context.Context) {
+	removed, err := d.deadLetters.DeleteBefore(ctx, time.Now().Add(-d.cfg.DeadLetterRetention))
+	if err != nil {
+		if ctx.Err() == nil {
+			d.log.WithError(err).Error("failed to remove expired webhook deliveries")
+		}
+		return
+	}
+	if removed > 0 {
+		d.log.Info("removed expired webhook deliveries", zap.Int64("count", removed))
+	}
+}
+
+// RedactTarget returns target without credentials or query, so it can be
+// shown to task owners who don't run the receiver
+func RedactTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+}
+
+// FailureReason describes why a delivery to target failed with err, with
+// the target redacted
+func FailureReason(target string, err error) string {
+	reason := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		reason = strings.ReplaceAll(reason, urlErr.URL, RedactTarget(target))
+	}
+	return strings.ReplaceAll(reason, target, RedactTarget(target))
+}
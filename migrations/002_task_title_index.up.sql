@@ -0,0 +1,4 @@
+-- Functional index backing the optional case-insensitive title uniqueness check
+CREATE INDEX IF NOT EXISTS idx_tasks_user_title_normalized
+    ON tasks (user_id, lower(trim(title)))
+    WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Keyed email hash used for lookups when PRIVACY_HASH_EMAILS is enabled
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_hash
+    ON users(email_hash)
+    WHERE email_hash IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS chk_tasks_description_length;
@@ -0,0 +1,6 @@
+-- Hard ceiling on description size. The API enforces a lower configurable
+-- limit (TASK_MAX_DESCRIPTION_LENGTH); the column stays TEXT so that limit can
+-- change without a migration. NOT VALID skips checking existing rows.
+ALTER TABLE tasks
+    ADD CONSTRAINT chk_tasks_description_length
+    CHECK (char_length(description) <= 65535) NOT VALID;
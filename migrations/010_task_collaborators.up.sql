@@ -0,0 +1,9 @@
+-- Users a task's owner has shared it with
+CREATE TABLE IF NOT EXISTS task_collaborators (
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (task_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_collaborators_user_id ON task_collaborators(user_id);
@@ -0,0 +1,4 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Backs the @> and && tag filters
+CREATE INDEX IF NOT EXISTS idx_tasks_tags ON tasks USING GIN (tags);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tasks_reminder_due;
+ALTER TABLE tasks DROP COLUMN IF EXISTS reminder_sent_at;
@@ -0,0 +1,5 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+-- Backs the reminder scheduler's scan for tasks coming due
+CREATE INDEX IF NOT EXISTS idx_tasks_reminder_due ON tasks(due_date)
+    WHERE reminder_sent_at IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,2 @@
+-- Incremented on every edit, for optimistic concurrency control
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
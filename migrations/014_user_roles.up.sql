@@ -0,0 +1,3 @@
+-- Admins can see every user's tasks; grant with UPDATE users SET role = 'admin'
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CONSTRAINT chk_users_role CHECK (role IN ('user', 'admin'));
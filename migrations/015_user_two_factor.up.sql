@@ -0,0 +1,5 @@
+-- TOTP secret, encrypted by the application; set by setup and only in force once enabled
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+-- Time step of the last accepted code, so a code can't be replayed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_counter BIGINT;
@@ -0,0 +1,2 @@
+-- Stamped into access tokens; bumping it invalidates every token issued before
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
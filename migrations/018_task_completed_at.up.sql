@@ -0,0 +1,5 @@
+-- Set when a task moves to completed and cleared if it is reopened
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+-- Tasks completed before this column existed: their last edit is the best estimate
+UPDATE tasks SET completed_at = updated_at WHERE status = 'completed' AND completed_at IS NULL;
@@ -0,0 +1,2 @@
+-- Cleared hashes are backfilled with the lowercased address again on lookup
+UPDATE users SET email_hash = NULL WHERE email_hash IS NOT NULL AND email <> LOWER(email);
@@ -0,0 +1,4 @@
+-- Email hashes used to be taken of the lowercased address. They are now taken
+-- of the address as stored, which only differs for mixed-case emails; clear
+-- those so they are backfilled on the next lookup instead of colliding.
+UPDATE users SET email_hash = NULL WHERE email_hash IS NOT NULL AND email <> LOWER(email);
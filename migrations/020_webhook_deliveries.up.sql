@@ -0,0 +1,20 @@
+-- Task webhook deliveries that still failed after every retry, kept for
+-- their owner to inspect and replay until TASK_WEBHOOK_DEAD_LETTER_RETENTION ends.
+-- id is the event's X-Webhook-ID, which a replay sends again.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task_id UUID NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    target TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'failed' CHECK (status IN ('failed', 'replayed')),
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    replayed_at TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_user_status ON webhook_deliveries(user_id, status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
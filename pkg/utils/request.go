@@ -2,19 +2,84 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
 	"strconv"
 )
 
-// ParseJSON parses JSON from request body
+// DefaultMaxBodyBytes is the default cap on JSON request bodies
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// ErrBodyTooLarge is returned by ParseJSON when the body exceeds the limit
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// ErrTrailingData is returned by ParseJSON when more follows the JSON value
+var ErrTrailingData = errors.New("request body must contain a single JSON value")
+
+// ErrUnsupportedMediaType is returned by ParseJSON when a body isn't declared
+// as application/json
+var ErrUnsupportedMediaType = errors.New("request body must be application/json")
+
+// JSONOptions controls how ParseJSON decodes request bodies
+type JSONOptions struct {
+	MaxBodyBytes          int64 // Larger bodies fail with ErrBodyTooLarge; 0 uses DefaultMaxBodyBytes
+	DisallowUnknownFields bool  // Reject fields the target struct doesn't have
+	RequireContentType    bool  // Reject bodies whose Content-Type isn't application/json
+}
+
+var jsonOptions = JSONOptions{MaxBodyBytes: DefaultMaxBodyBytes}
+
+// SetJSONOptions sets the options ParseJSON uses. Call it once at startup.
+func SetJSONOptions(opts JSONOptions) {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	jsonOptions = opts
+}
+
+// ParseJSON parses a single JSON value from the request body, reading at
+// most the configured number of bytes. With RequireContentType, a body sent
+// as anything but application/json (parameters such as charset allowed) is
+// ErrUnsupportedMediaType; an empty body is left to fail decoding as before.
 func ParseJSON(r *http.Request, v interface{}) error {
 	defer r.Body.Close()
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return err
+	opts := jsonOptions
+
+	if opts.RequireContentType && r.ContentLength != 0 && !isJSONContentType(r.Header.Get("Content-Type")) {
+		return ErrUnsupportedMediaType
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, opts.MaxBodyBytes))
+	if opts.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return bodyError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if err != nil && errors.Is(bodyError(err), ErrBodyTooLarge) {
+			return ErrBodyTooLarge
+		}
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// isJSONContentType reports whether a Content-Type header names application/json
+func isJSONContentType(header string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	return err == nil && mediaType == "application/json"
+}
+
+// bodyError maps a body size overrun to ErrBodyTooLarge
+func bodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return ErrBodyTooLarge
 	}
-	return json.Unmarshal(body, v)
+	return err
 }
 
 // GetQueryParam gets a query parameter with default value
@@ -39,20 +104,67 @@ func GetQueryParamInt(r *http.Request, key string, defaultValue int) int {
 	return intValue
 }
 
-// GetPaginationParams gets pagination parameters from request
-func GetPaginationParams(r *http.Request) (page, limit int) {
+// Default pagination bounds
+const (
+	DefaultPageLimit = 10
+	DefaultMaxLimit  = 100
+	DefaultMaxPage   = 10000
+)
+
+// ErrLimitTooLarge is returned by GetPaginationParams in strict mode for a
+// limit above the maximum
+var ErrLimitTooLarge = errors.New("limit exceeds the maximum page size")
+
+// PaginationOptions bounds the pagination parameters clients may ask for
+type PaginationOptions struct {
+	MaxLimit int  // Larger limits are capped; 0 uses DefaultMaxLimit
+	Strict   bool // Fail larger limits with ErrLimitTooLarge instead
+	MaxPage  int  // Larger pages are capped; 0 uses DefaultMaxPage
+}
+
+var paginationOptions = PaginationOptions{MaxLimit: DefaultMaxLimit, MaxPage: DefaultMaxPage}
+
+// SetPaginationOptions sets the bounds GetPaginationParams applies. Call it
+// once at startup.
+func SetPaginationOptions(opts PaginationOptions) {
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = DefaultMaxLimit
+	}
+	if opts.MaxPage <= 0 {
+		opts.MaxPage = DefaultMaxPage
+	}
+	paginationOptions = opts
+}
+
+// MaxPageLimit returns the largest limit GetPaginationParams allows
+func MaxPageLimit() int {
+	return paginationOptions.MaxLimit
+}
+
+// GetPaginationParams gets pagination parameters from request. A limit above
+// the maximum is capped, reporting capped, or fails with ErrLimitTooLarge in
+// strict mode. Pages past the maximum are capped, so offsets stay sane.
+func GetPaginationParams(r *http.Request) (page, limit int, capped bool, err error) {
+	opts := paginationOptions
+	defaultLimit := min(DefaultPageLimit, opts.MaxLimit)
 	page = GetQueryParamInt(r, "page", 1)
-	limit = GetQueryParamInt(r, "limit", 10)
+	limit = GetQueryParamInt(r, "limit", defaultLimit)
 
 	if page < 1 {
 		page = 1
 	}
+	if page > opts.MaxPage {
+		page = opts.MaxPage
+	}
 	if limit < 1 {
-		limit = 10
+		limit = defaultLimit
 	}
-	if limit > 100 {
-		limit = 100
+	if limit > opts.MaxLimit {
+		if opts.Strict {
+			return 0, 0, false, ErrLimitTooLarge
+		}
+		limit, capped = opts.MaxLimit, true
 	}
 
-	return page, limit
+	return page, limit, capped, nil
 }
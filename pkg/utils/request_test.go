@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONContentType(t *testing.T) {
+	SetJSONOptions(JSONOptions{RequireContentType: true})
+	t.Cleanup(func() { SetJSONOptions(JSONOptions{}) })
+
+	tests := []struct {
+		contentType string
+		body        string
+		want        error
+	}{
+		{"application/json", `{"title":"a"}`, nil},
+		{"application/json; charset=utf-8", `{"title":"a"}`, nil},
+		{"Application/JSON", `{"title":"a"}`, nil},
+		{"text/plain", `{"title":"a"}`, ErrUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", "title=a", ErrUnsupportedMediaType},
+		{"", `{"title":"a"}`, ErrUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/tasks", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		var v struct {
+			Title string `json:"title"`
+		}
+
+		err := ParseJSON(r, &v)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Content-Type %q: err = %v, want %v", tt.contentType, err, tt.want)
+		}
+		if tt.want == nil && v.Title != "a" {
+			t.Errorf("Content-Type %q: title = %q, want a", tt.contentType, v.Title)
+		}
+	}
+}
+
+func TestParseJSONEmptyBodySkipsContentType(t *testing.T) {
+	SetJSONOptions(JSONOptions{RequireContentType: true})
+	t.Cleanup(func() { SetJSONOptions(JSONOptions{}) })
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/auth/logout", http.NoBody)
+	r.Header.Set("Content-Type", "text/plain")
+	var v struct{}
+	if err := ParseJSON(r, &v); errors.Is(err, ErrUnsupportedMediaType) {
+		t.Fatalf("bodyless request rejected as %v", err)
+	}
+}
+
+// parseTitle decodes body into a struct with a title, as a handler would
+func parseTitle(body string) (string, error) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/tasks", strings.NewReader(body))
+	var v struct {
+		Title string `json:"title"`
+	}
+	err := ParseJSON(r, &v)
+	return v.Title, err
+}
+
+func TestParseJSONBodyLimit(t *testing.T) {
+	SetJSONOptions(JSONOptions{MaxBodyBytes: 64})
+	t.Cleanup(func() { SetJSONOptions(JSONOptions{}) })
+
+	fits := `{"title":"` + strings.Repeat("a", 64-len(`{"title":""}`)) + `"}`
+	if title, err := parseTitle(fits); err != nil || len(title) != 52 {
+		t.Fatalf("%d-byte body: title %q, err %v, want it decoded", len(fits), title, err)
+	}
+	for _, body := range []string{
+		`{"title":"` + strings.Repeat("a", 64) + `"}`,
+		fits + strings.Repeat(" ", 10) + `{}`,
+		strings.Repeat(" ", 1<<20) + `{}`,
+	} {
+		if _, err := parseTitle(body); !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("%d-byte body: err = %v, want ErrBodyTooLarge", len(body), err)
+		}
+	}
+}
+
+func TestParseJSONDefaultBodyLimit(t *testing.T) {
+	SetJSONOptions(JSONOptions{})
+	body := `{"title":"` + strings.Repeat("a", int(DefaultMaxBodyBytes)) + `"}`
+	if _, err := parseTitle(body); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("body over the default limit: err = %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestParseJSONRejectsTrailingData(t *testing.T) {
+	for body, want := range map[string]error{
+		`{"title":"a"}`:              nil,
+		"{\"title\":\"a\"}\n \t":     nil,
+		`{"title":"a"} junk`:         ErrTrailingData,
+		`{"title":"a"}{"title":"b"}`: ErrTrailingData,
+		`{"title":"a"}]`:             ErrTrailingData,
+		`{"title":"a"} 1`:            ErrTrailingData,
+	} {
+		if _, err := parseTitle(body); !errors.Is(err, want) {
+			t.Errorf("body %q: err = %v, want %v", body, err, want)
+		}
+	}
+}
+
+func TestParseJSONDisallowUnknownFields(t *testing.T) {
+	body := `{"title":"a","owner":"someone"}`
+	if title, err := parseTitle(body); err != nil || title != "a" {
+		t.Fatalf("unknown field allowed by default: title %q, err %v", title, err)
+	}
+
+	SetJSONOptions(JSONOptions{DisallowUnknownFields: true})
+	t.Cleanup(func() { SetJSONOptions(JSONOptions{}) })
+	if _, err := parseTitle(body); err == nil || !strings.Contains(err.Error(), "owner") {
+		t.Fatalf("unknown field: err = %v, want it rejected by name", err)
+	}
+}
+
+func TestUnsupportedMediaTypeStatus(t *testing.T) {
+	rec := httptest.NewRecorder()
+	UnsupportedMediaType(rec, httptest.NewRequest(http.MethodPost, "/v1/tasks", nil), "Content-Type must be application/json")
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status %d, want 415", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("Content-Type %q, want a JSON error body", ct)
+	}
+}
+
+func TestGetPaginationParamsBounds(t *testing.T) {
+	t.Cleanup(func() { SetPaginationOptions(PaginationOptions{}) })
+
+	tests := []struct {
+		strict   bool
+		query    string
+		page     int
+		limit    int
+		capped   bool
+		tooLarge bool
+	}{
+		{false, "", 1, DefaultPageLimit, false, false},
+		{false, "page=3&limit=50", 3, 50, false, false},
+		{false, "limit=50", 1, 50, false, false},
+		{false, "limit=51", 1, 50, true, false},
+		{false, "limit=1000", 1, 50, true, false},
+		{true, "limit=50", 1, 50, false, false},
+		{true, "limit=51", 0, 0, false, true},
+		{false, "page=0&limit=-5", 1, DefaultPageLimit, false, false},
+		{false, "page=999999999", 200, DefaultPageLimit, false, false},
+		{true, "page=999999999", 200, DefaultPageLimit, false, false},
+	}
+	for _, tt := range tests {
+		SetPaginationOptions(PaginationOptions{MaxLimit: 50, MaxPage: 200, Strict: tt.strict})
+		page, limit, capped, err := GetPaginationParams(httptest.NewRequest(http.MethodGet, "/tasks?"+tt.query, nil))
+		if tt.tooLarge != errors.Is(err, ErrLimitTooLarge) {
+			t.Errorf("strict=%v %q: err = %v, want too large %v", tt.strict, tt.query, err, tt.tooLarge)
+			continue
+		}
+		if page != tt.page || limit != tt.limit || capped != tt.capped {
+			t.Errorf("strict=%v %q: page %d limit %d capped %v, want %d %d %v", tt.strict, tt.query, page, limit, capped, tt.page, tt.limit, tt.capped)
+		}
+	}
+}
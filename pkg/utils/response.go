@@ -3,11 +3,17 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
 	"time"
 
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
 	"secure-task-api/internal/models"
 )
 
+// RequestIDHeader carries the request ID on responses
+const RequestIDHeader = "X-Request-ID"
+
 // JSONResponse sends a JSON response
 func JSONResponse(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -15,51 +21,144 @@ func JSONResponse(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// JSONError sends an error response
-func JSONError(w http.ResponseWriter, status int, message string) {
+// JSONError sends an error response, identifying r by its path and the
+// request ID chi's RequestID middleware assigned
+func JSONError(w http.ResponseWriter, r *http.Request, status int, message string) {
 	JSONResponse(w, status, models.ErrorResponse{
 		Error:      http.StatusText(status),
 		Message:    message,
 		Timestamp:  time.Now(),
 		StatusCode: status,
+		RequestID:  chimiddleware.GetReqID(r.Context()),
+		Path:       r.URL.Path,
 	})
 }
 
-// JSONSuccess sends a success response
-func JSONSuccess(w http.ResponseWriter, status int, data interface{}) {
-	JSONResponse(w, status, map[string]interface{}{
-		"success": true,
-		"data":    data,
-	})
+// JSONSuccess sends a success response with data in the standard envelope
+func JSONSuccess[T any](w http.ResponseWriter, status int, data T) {
+	JSONResponse(w, status, models.APIResponse[T]{Success: true, Data: data})
+}
+
+// Validation error response shapes
+const (
+	ValidationErrorsMap   = "map"   // {"field": "message"}, first failure per field
+	ValidationErrorsArray = "array" // [{"field", "message"}], every failure in order
+)
+
+var validationErrorFormat = ValidationErrorsMap
+
+// SetValidationErrorFormat sets the shape of the "errors" member of validation
+// error responses. Call it once at startup; unknown formats keep the map.
+func SetValidationErrorFormat(format string) {
+	if format != ValidationErrorsArray {
+		format = ValidationErrorsMap
+	}
+	validationErrorFormat = format
 }
 
 // ValidationError sends a validation error response
 func ValidationError(w http.ResponseWriter, errors map[string]string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error":   "Validation Error",
-		"message": "Invalid input data",
-		"errors":  errors,
+	fields := make([]string, 0, len(errors))
+	for field := range errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	list := make([]FieldError, len(fields))
+	for i, field := range fields {
+		list[i] = FieldError{Field: field, Message: errors[field]}
+	}
+	ValidationErrors(w, list)
+}
+
+// ValidationErrors sends a validation error response from an ordered list,
+// which may hold several errors per field
+func ValidationErrors(w http.ResponseWriter, errors []FieldError) {
+	if validationErrorFormat == ValidationErrorsArray {
+		validationErrorResponse(w, errors)
+		return
+	}
+
+	byField := make(map[string]string, len(errors))
+	for _, e := range errors {
+		if _, ok := byField[e.Field]; !ok {
+			byField[e.Field] = e.Message
+		}
+	}
+	validationErrorResponse(w, byField)
+}
+
+// validationErrorResponse sends a 400 with errors in the validation envelope
+func validationErrorResponse[E any](w http.ResponseWriter, errors E) {
+	JSONResponse(w, http.StatusBadRequest, models.ValidationErrorResponse[E]{
+		Error:     "Validation Error",
+		Message:   "Invalid input data",
+		Errors:    errors,
+		RequestID: w.Header().Get(RequestIDHeader),
 	})
 }
 
 // Unauthorized sends an unauthorized response
-func Unauthorized(w http.ResponseWriter, message string) {
-	JSONError(w, http.StatusUnauthorized, message)
+func Unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusUnauthorized, message)
+}
+
+// Forbidden sends a forbidden response
+func Forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusForbidden, message)
 }
 
 // NotFound sends a not found response
-func NotFound(w http.ResponseWriter, message string) {
-	JSONError(w, http.StatusNotFound, message)
+func NotFound(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusNotFound, message)
 }
 
 // InternalServerError sends an internal server error response
-func InternalServerError(w http.ResponseWriter, message string) {
-	JSONError(w, http.StatusInternalServerError, message)
+func InternalServerError(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusInternalServerError, message)
+}
+
+// Conflict sends a conflict response
+func Conflict(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusConflict, message)
+}
+
+// LengthRequired sends a length required response
+func LengthRequired(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusLengthRequired, message)
 }
 
 // BadRequest sends a bad request response
-func BadRequest(w http.ResponseWriter, message string) {
-	JSONError(w, http.StatusBadRequest, message)
+func BadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusBadRequest, message)
+}
+
+// PayloadTooLarge sends a payload too large response
+func PayloadTooLarge(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
+// UnsupportedMediaType sends an unsupported media type response
+func UnsupportedMediaType(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+// Locked sends a locked response
+func Locked(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusLocked, message)
+}
+
+// ServiceUnavailable sends a service unavailable response
+func ServiceUnavailable(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusServiceUnavailable, message)
+}
+
+// BadGateway sends a bad gateway response
+func BadGateway(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusBadGateway, message)
+}
+
+// TooManyRequests sends a too many requests response
+func TooManyRequests(w http.ResponseWriter, r *http.Request, message string) {
+	JSONError(w, r, http.StatusTooManyRequests, message)
 }
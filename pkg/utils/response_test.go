@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"secure-task-api/internal/models"
+)
+
+// decoded unmarshals data into generic JSON values, for comparing shapes
+// regardless of key order
+func decoded(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return v
+}
+
+func TestJSONSuccessKeepsWireFormat(t *testing.T) {
+	task := &models.Task{ID: uuid.New(), UserID: uuid.New(), Title: "Write report", Status: models.TaskStatusPending, Tags: []string{}, CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+	for name, tc := range map[string]struct {
+		data   interface{}
+		legacy interface{}
+	}{
+		"task":    {models.TaskResponse{Task: task}, map[string]interface{}{"task": task}},
+		"message": {models.MessageResponse{Message: "done"}, map[string]interface{}{"message": "done"}},
+	} {
+		rec := httptest.NewRecorder()
+		JSONSuccess(rec, http.StatusCreated, tc.data)
+
+		if rec.Code != http.StatusCreated || rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("%s: status %d content type %q, want 201 JSON", name, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		// The body handlers built as maps before the typed envelope
+		want, err := json.Marshal(map[string]interface{}{"success": true, "data": tc.legacy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decoded(t, rec.Body.Bytes()); !reflect.DeepEqual(got, decoded(t, want)) {
+			t.Errorf("%s: body %s, want %s", name, rec.Body, want)
+		}
+	}
+}
+
+func TestJSONSuccessEnvelopeFields(t *testing.T) {
+	rec := httptest.NewRecorder()
+	JSONSuccess(rec, http.StatusOK, models.MessageResponse{Message: "done"})
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope) != 2 || string(envelope["success"]) != "true" || string(envelope["data"]) != `{"message":"done"}` {
+		t.Fatalf("envelope %s, want exactly success and data", rec.Body)
+	}
+}
+
+func TestValidationErrorShapes(t *testing.T) {
+	list := []FieldError{
+		{Field: "password", Message: "password must be at least 8 characters"},
+		{Field: "password", Message: "password must contain a digit"},
+		{Field: "email", Message: "email must be a valid email address"},
+	}
+	t.Cleanup(func() { SetValidationErrorFormat(ValidationErrorsMap) })
+
+	for _, tc := range []struct {
+		format string
+		send   func(w http.ResponseWriter)
+		want   interface{}
+	}{
+		// The map keeps each field's first failure
+		{ValidationErrorsMap, func(w http.ResponseWriter) { ValidationErrors(w, list) }, map[string]interface{}{
+			"password": "password must be at least 8 characters",
+			"email":    "email must be a valid email address",
+		}},
+		// The array keeps every failure in the order found
+		{ValidationErrorsArray, func(w http.ResponseWriter) { ValidationErrors(w, list) }, []interface{}{
+			map[string]interface{}{"field": "password", "message": "password must be at least 8 characters"},
+			map[string]interface{}{"field": "password", "message": "password must contain a digit"},
+			map[string]interface{}{"field": "email", "message": "email must be a valid email address"},
+		}},
+		// A map sent as an array is ordered by field
+		{ValidationErrorsArray, func(w http.ResponseWriter) {
+			ValidationError(w, map[string]string{"title": "title is required", "due_date": "due_date is invalid"})
+		}, []interface{}{
+			map[string]interface{}{"field": "due_date", "message": "due_date is invalid"},
+			map[string]interface{}{"field": "title", "message": "title is required"},
+		}},
+		// Unknown formats fall back to the map
+		{"yaml", func(w http.ResponseWriter) { ValidationErrors(w, list[:1]) }, map[string]interface{}{
+			"password": "password must be at least 8 characters",
+		}},
+	} {
+		SetValidationErrorFormat(tc.format)
+		rec := httptest.NewRecorder()
+		tc.send(rec)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, want 400", tc.format, rec.Code)
+		}
+		body := decoded(t, rec.Body.Bytes()).(map[string]interface{})
+		if body["error"] != "Validation Error" || !reflect.DeepEqual(body["errors"], tc.want) {
+			t.Errorf("%s: body %s, want errors %v", tc.format, rec.Body, tc.want)
+		}
+	}
+}
+
+func TestJSONErrorIdentifiesRequest(t *testing.T) {
+	send := func(r *http.Request, write func(w http.ResponseWriter, r *http.Request, message string)) models.ErrorResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		write(rec, r, "went wrong")
+		var resp models.ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != rec.Code || resp.Message != "went wrong" {
+			t.Fatalf("response %+v, want status %d and the message", resp, rec.Code)
+		}
+		return resp
+	}
+	writers := []func(w http.ResponseWriter, r *http.Request, message string){
+		BadRequest, Unauthorized, Forbidden, NotFound, Conflict, TooManyRequests, InternalServerError, ServiceUnavailable,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks/42?verbose=true", nil)
+	req = req.WithContext(context.WithValue(req.Context(), chimiddleware.RequestIDKey, "req-42"))
+	for _, write := range writers {
+		if resp := send(req, write); resp.RequestID != "req-42" || resp.Path != "/v1/tasks/42" {
+			t.Errorf("status %d: request_id %q, path %q, want req-42 and the path without its query", resp.StatusCode, resp.RequestID, resp.Path)
+		}
+	}
+
+	// Without a request ID the field is left out
+	rec := httptest.NewRecorder()
+	NotFound(rec, httptest.NewRequest(http.MethodGet, "/v1/tasks/42", nil), "Task not found")
+	if body := decoded(t, rec.Body.Bytes()).(map[string]interface{}); body["request_id"] != nil || body["path"] != "/v1/tasks/42" {
+		t.Fatalf("body %v, want a path but no request_id", body)
+	}
+}
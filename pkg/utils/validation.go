@@ -3,12 +3,22 @@ package utils
 import (
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-// Validator provides basic validation functionality
+// FieldError is one failed check on a field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validator provides basic validation functionality. Errors holds the first
+// failure per field; List holds every failure in the order it was found.
 type Validator struct {
 	Errors map[string]string
+	List   []FieldError
 }
 
 // NewValidator creates a new validator
@@ -18,24 +28,32 @@ func NewValidator() *Validator {
 	}
 }
 
+// AddError records a failure for field
+func (v *Validator) AddError(field, message string) {
+	if _, ok := v.Errors[field]; !ok {
+		v.Errors[field] = message
+	}
+	v.List = append(v.List, FieldError{Field: field, Message: message})
+}
+
 // Required checks if a field is required
 func (v *Validator) Required(field, value string) {
 	if strings.TrimSpace(value) == "" {
-		v.Errors[field] = field + " is required"
+		v.AddError(field, field+" is required")
 	}
 }
 
-// MinLength checks if a string has minimum length
+// MinLength checks if a string has minimum length, counted in runes
 func (v *Validator) MinLength(field, value string, min int) {
-	if len(value) < min {
-		v.Errors[field] = field + " must be at least " + string(rune(min)) + " characters"
+	if utf8.RuneCountInString(value) < min {
+		v.AddError(field, field+" must be at least "+strconv.Itoa(min)+" characters")
 	}
 }
 
-// MaxLength checks if a string has maximum length
+// MaxLength checks if a string has maximum length, counted in runes
 func (v *Validator) MaxLength(field, value string, max int) {
-	if len(value) > max {
-		v.Errors[field] = field + " must be at most " + string(rune(max)) + " characters"
+	if utf8.RuneCountInString(value) > max {
+		v.AddError(field, field+" must be at most "+strconv.Itoa(max)+" characters")
 	}
 }
 
@@ -43,7 +61,7 @@ func (v *Validator) MaxLength(field, value string, max int) {
 func (v *Validator) Email(field, value string) {
 	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	if !emailRegex.MatchString(value) {
-		v.Errors[field] = field + " must be a valid email address"
+		v.AddError(field, field+" must be a valid email address")
 	}
 }
 
@@ -52,22 +70,126 @@ func (v *Validator) IsValid() bool {
 	return len(v.Errors) == 0
 }
 
-// ValidateStruct performs basic validation on struct fields
+// OneOf checks if a string is one of the allowed values
+func (v *Validator) OneOf(field, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.AddError(field, field+" must be one of "+strings.Join(allowed, ", "))
+}
+
+// ValidateStruct validates struct fields against their validate tags and
+// returns errors keyed by JSON field name. Rules are comma-separated:
+// required, omitempty, email, min=N, max=N (lengths in runes) and oneof=a b c.
+// Only the first failing rule is reported per field; unknown rules are ignored.
+// On pointer fields omitempty skips only nil, so a value sent empty is still checked.
 func ValidateStruct(s interface{}) map[string]string {
+	return validateStruct(s, false).Errors
+}
+
+// ValidateStructAll is ValidateStruct reporting every failing rule, in field
+// then rule order, except that a missing required field skips its other rules
+func ValidateStructAll(s interface{}) []FieldError {
+	return validateStruct(s, true).List
+}
+
+// validateStruct checks s's validate tags, stopping at each field's first
+// failure unless all is set
+func validateStruct(s interface{}, all bool) *Validator {
 	v := NewValidator()
-	val := reflect.ValueOf(s)
+	val := reflect.Indirect(reflect.ValueOf(s))
+	if val.Kind() != reflect.Struct {
+		return v
+	}
 	typeOfS := val.Type()
 
 	for i := 0; i < val.NumField(); i++ {
+		sf := typeOfS.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || !sf.IsExported() {
+			continue
+		}
+
+		name := jsonFieldName(sf)
 		field := val.Field(i)
-		fieldName := typeOfS.Field(i).Name
-		tag := typeOfS.Field(i).Tag
+		isPtr := field.Kind() == reflect.Ptr
+		if isPtr {
+			if field.IsNil() {
+				field = reflect.Value{}
+			} else {
+				field = field.Elem()
+			}
+		}
+		empty := !field.IsValid() || field.IsZero() || (hasLen(field) && field.Len() == 0)
+		omitted := empty
+		if isPtr {
+			omitted = !field.IsValid()
+		}
+		value := ""
+		if field.IsValid() && field.Kind() == reflect.String {
+			value = field.String()
+		}
 
-		// Check for required tag
-		if tag.Get("validate") == "required" {
-			v.Required(fieldName, field.String())
+	rules:
+		for _, rule := range strings.Split(tag, ",") {
+			rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			switch rule {
+			case "omitempty":
+				if omitted {
+					break rules
+				}
+			case "required":
+				if empty {
+					v.AddError(name, name+" is required")
+					break rules
+				}
+				if field.Kind() == reflect.String {
+					v.Required(name, value)
+				}
+			case "email":
+				if field.Kind() == reflect.String {
+					v.Email(name, value)
+				}
+			case "min", "max":
+				n, err := strconv.Atoi(arg)
+				if err != nil || field.Kind() != reflect.String {
+					continue
+				}
+				if rule == "min" {
+					v.MinLength(name, value, n)
+				} else {
+					v.MaxLength(name, value, n)
+				}
+			case "oneof":
+				if field.Kind() == reflect.String {
+					v.OneOf(name, value, strings.Fields(arg))
+				}
+			}
+			if _, failed := v.Errors[name]; failed && !all {
+				break
+			}
 		}
 	}
 
-	return v.Errors
+	return v
+}
+
+// jsonFieldName returns the name a struct field has in JSON
+func jsonFieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// hasLen reports whether v's kind supports Len
+func hasLen(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return true
+	}
+	return false
 }
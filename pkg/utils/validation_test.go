@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"secure-task-api/internal/models"
+)
+
+func TestLengthMessages(t *testing.T) {
+	tests := []struct {
+		check func(v *Validator)
+		want  string
+	}{
+		{func(v *Validator) { v.MinLength("password", "abc", 6) }, "password must be at least 6 characters"},
+		{func(v *Validator) { v.MinLength("password", "", 12) }, "password must be at least 12 characters"},
+		{func(v *Validator) { v.MinLength("code", "", 1) }, "code must be at least 1 characters"},
+		{func(v *Validator) { v.MaxLength("title", "abcdef", 5) }, "title must be at most 5 characters"},
+		{func(v *Validator) { v.MaxLength("description", string(make([]byte, 1001)), 1000) }, "description must be at most 1000 characters"},
+	}
+
+	for _, tt := range tests {
+		v := NewValidator()
+		tt.check(v)
+		if len(v.List) != 1 || v.List[0].Message != tt.want {
+			t.Errorf("errors %v, want exactly %q", v.List, tt.want)
+		}
+	}
+}
+
+func TestLengthCountsRunes(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max int
+		valid    bool
+	}{
+		// 6 runes in 12 bytes
+		{"éééééé", 6, 6, true},
+		{"ééééé", 6, 10, false},
+		// 3 runes in 9 and 12 bytes
+		{"日本語", 3, 3, true},
+		{"🙂🙂🙂", 1, 3, true},
+		{"🙂🙂🙂🙂", 1, 3, false},
+		{"plain", 5, 5, true},
+	}
+
+	for _, tt := range tests {
+		v := NewValidator()
+		v.MinLength("field", tt.value, tt.min)
+		v.MaxLength("field", tt.value, tt.max)
+		if v.IsValid() != tt.valid {
+			t.Errorf("%q (%d bytes) within [%d, %d]: valid = %v, want %v", tt.value, len(tt.value), tt.min, tt.max, v.IsValid(), tt.valid)
+		}
+	}
+}
+
+func TestValidateStructRegisterRequest(t *testing.T) {
+	got := ValidateStruct(models.RegisterRequest{Email: "not-an-email", Password: "abc"})
+	want := map[string]string{
+		"email": "email must be a valid email address",
+		"name":  "name is required",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("errors %v, want %v", got, want)
+	}
+
+	if got := ValidateStruct(&models.RegisterRequest{Email: "user@example.com", Password: "abc", Name: "User"}); len(got) != 0 {
+		t.Fatalf("valid request: errors %v, want none", got)
+	}
+}
+
+func TestValidateStructRules(t *testing.T) {
+	type request struct {
+		Email    string  `json:"email" validate:"required, email"`
+		Password string  `json:"password,omitempty" validate:"required,min=6,max=8"`
+		Role     string  `json:"role" validate:"omitempty,oneof=user admin"`
+		Nickname *string `json:"nickname" validate:"omitempty,min=2"`
+		Untagged string  `validate:"required"`
+		Ignored  string  `json:"ignored" validate:"min=x,unknown"`
+	}
+	empty, short := "", "a"
+
+	tests := []struct {
+		req  request
+		want map[string]string
+	}{
+		{
+			request{Email: "user@example.com", Password: "secret", Untagged: "x"},
+			map[string]string{},
+		},
+		{
+			request{Email: "bad", Password: "abc", Role: "root", Untagged: "x"},
+			map[string]string{
+				"email":    "email must be a valid email address",
+				"password": "password must be at least 6 characters",
+				"role":     "role must be one of user, admin",
+			},
+		},
+		{
+			request{Password: "much-too-long", Nickname: &short},
+			map[string]string{
+				"email":    "email is required",
+				"password": "password must be at most 8 characters",
+				"nickname": "nickname must be at least 2 characters",
+				"Untagged": "Untagged is required",
+			},
+		},
+		// A pointer sent empty is still checked; only nil is omitted
+		{
+			request{Email: "user@example.com", Password: "secret", Untagged: "x", Nickname: &empty},
+			map[string]string{"nickname": "nickname must be at least 2 characters"},
+		},
+	}
+
+	for i, tt := range tests {
+		if got := ValidateStruct(tt.req); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("case %d: errors %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestValidateStructAllReportsEveryRule(t *testing.T) {
+	type request struct {
+		Code  string `json:"code" validate:"min=4,oneof=abcd efgh"`
+		Email string `json:"email" validate:"required,email,min=5"`
+	}
+	got := ValidateStructAll(request{Code: "xy"})
+	want := []FieldError{
+		{Field: "code", Message: "code must be at least 4 characters"},
+		{Field: "code", Message: "code must be one of abcd, efgh"},
+		{Field: "email", Message: "email is required"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("errors %v, want %v", got, want)
+	}
+}